@@ -0,0 +1,288 @@
+package clone
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bebop/poly/io/genbank"
+)
+
+/******************************************************************************
+
+Annotated cloning functions begin here.
+
+These work like CutWithEnzyme, CircularLigate and GoldenGate, but on genbank
+sequences, carrying the features of the inputs over to the fragments and the
+constructs they make up. Features lying entirely within a fragment follow it
+into every construct it ends up in, while features cut through by an enzyme
+are lost, along with source features.
+
+******************************************************************************/
+
+// AnnotatedFragment is a fragment cut from an annotated sequence.
+type AnnotatedFragment struct {
+	Fragment
+	// Source is the locus name of the sequence the fragment was cut from.
+	Source string
+	// Features are the features of the sequence lying entirely within the
+	// fragment, overhangs included, located from the start of its forward
+	// overhang.
+	Features []genbank.Feature
+}
+
+// CutWithEnzymeAnnotated cuts an annotated sequence like CutWithEnzyme,
+// carrying its features over to the fragments they lie in. Features spanning
+// the origin of a circular sequence are kept by the fragment spanning it.
+func CutWithEnzymeAnnotated(sequence genbank.Genbank, directional bool, enzymes ...Enzyme) []AnnotatedFragment {
+	part := Part{sequence.Sequence, sequence.Meta.Locus.Circular}
+	var fragments []AnnotatedFragment
+	for _, cutFragment := range cutWithEnzyme(part, nil, directional, enzymes) {
+		fragment := AnnotatedFragment{Fragment: cutFragment.Fragment, Source: sequence.Meta.Locus.Name}
+		for _, feature := range sequence.Features {
+			if feature.Type == "source" {
+				continue
+			}
+			location, ok := sliceLocation(feature.Location, cutFragment.start, cutFragment.end, len(part.Sequence), part.Circular)
+			if !ok {
+				continue
+			}
+			fragment.Features = append(fragment.Features, copyFeature(feature, simplifyLocation(location)))
+		}
+		fragments = append(fragments, fragment)
+	}
+	return fragments
+}
+
+// CircularLigateAnnotated simulates ligation of all possible fragment
+// combinations into circular plasmids like CircularLigate, returning the
+// plasmids as genbank sequences with the features of their fragments.
+// Plasmids are named after the sources of their fragments.
+func CircularLigateAnnotated(fragments []AnnotatedFragment) ([]genbank.Genbank, []string) {
+	plainFragments := make([]Fragment, len(fragments))
+	for index, fragment := range fragments {
+		plainFragments[index] = fragment.Fragment
+	}
+	ligations, infiniteLoops := circularLigate(plainFragments)
+
+	var constructs []genbank.Genbank
+	for _, ligation := range ligations {
+		length := len(ligation.construct)
+		var sources []string
+		var features []genbank.Feature
+		seenFeatures := make(map[string]bool)
+		// each fragment starts at the start of its forward overhang, which
+		// is the reverse overhang of the fragment before it
+		position := 0
+		for _, ligated := range ligation.fragments {
+			fragment := fragments[ligated.index]
+			if len(sources) == 0 || sources[len(sources)-1] != fragment.Source {
+				sources = append(sources, fragment.Source)
+			}
+			fragmentLength := len(fragment.ForwardOverhang) + len(fragment.Sequence) + len(fragment.ReverseOverhang)
+			for _, feature := range fragment.Features {
+				location := feature.Location
+				if ligated.flipped {
+					location = flipLocation(location, fragmentLength)
+				}
+				location = simplifyLocation(wrapLocation(shiftLocation(location, position), length))
+				feature = copyFeature(feature, location)
+				key := fmt.Sprint(feature.Type, genbank.BuildLocationString(location), feature.Attributes)
+				if seenFeatures[key] {
+					continue
+				}
+				seenFeatures[key] = true
+				features = append(features, feature)
+			}
+			if ligated.flipped {
+				position += len(fragment.ReverseOverhang) + len(fragment.Sequence)
+			} else {
+				position += len(fragment.ForwardOverhang) + len(fragment.Sequence)
+			}
+		}
+
+		name := strings.Join(sources, "_")
+		construct := genbank.Genbank{
+			Meta: genbank.Meta{
+				Name: name,
+				Locus: genbank.Locus{
+					Name:           name,
+					SequenceLength: strconv.Itoa(length),
+					MoleculeType:   "DNA",
+					Circular:       true,
+				},
+			},
+			Sequence: strings.ToLower(ligation.construct),
+		}
+		for index := range features {
+			_ = construct.AddFeature(&features[index])
+		}
+		constructs = append(constructs, construct)
+	}
+	return constructs, infiniteLoops
+}
+
+// GoldenGateAnnotated simulates a GoldenGate cloning reaction like GoldenGate,
+// returning the constructs as genbank sequences with the features of the
+// sequences they were assembled from.
+func GoldenGateAnnotated(sequences []genbank.Genbank, cuttingEnzyme Enzyme) (openConstructs []genbank.Genbank, infiniteLoops []string) {
+	var fragments []AnnotatedFragment
+	for _, sequence := range sequences {
+		fragments = append(fragments, CutWithEnzymeAnnotated(sequence, true, cuttingEnzyme)...)
+	}
+	return CircularLigateAnnotated(fragments)
+}
+
+// copyFeature returns a copy of a feature at a new location, without a parent
+// sequence.
+func copyFeature(feature genbank.Feature, location genbank.Location) genbank.Feature {
+	attributes := make(map[string]string, len(feature.Attributes))
+	for key, value := range feature.Attributes {
+		attributes[key] = value
+	}
+	feature.Attributes = attributes
+	feature.Location = location
+	feature.ParentSequence = nil
+	return feature
+}
+
+// sliceLocation returns a location relative to the start of the slice
+// [start, end) of a sequence, and whether the location lies entirely within
+// the slice. Slices of circular sequences may end past their length.
+func sliceLocation(location genbank.Location, start, end, length int, circular bool) (genbank.Location, bool) {
+	location.GbkLocationString = ""
+	if len(location.SubLocations) > 0 {
+		subLocations := make([]genbank.Location, len(location.SubLocations))
+		for index, subLocation := range location.SubLocations {
+			slicedLocation, ok := sliceLocation(subLocation, start, end, length, circular)
+			if !ok {
+				return genbank.Location{}, false
+			}
+			subLocations[index] = slicedLocation
+		}
+		location.SubLocations = subLocations
+		return location, true
+	}
+	// features spanning the origin of a circular sequence may end before
+	// they start, like 2315..217
+	shifts := []int{0}
+	if circular {
+		if location.End < location.Start {
+			location.End += length
+		} else {
+			shifts = append(shifts, length)
+		}
+	}
+	for _, shift := range shifts {
+		if location.Start+shift >= start && location.End+shift <= end {
+			location.Start += shift - start
+			location.End += shift - start
+			return location, true
+		}
+	}
+	return genbank.Location{}, false
+}
+
+// shiftLocation returns a location shifted by an offset.
+func shiftLocation(location genbank.Location, offset int) genbank.Location {
+	if len(location.SubLocations) > 0 {
+		subLocations := make([]genbank.Location, len(location.SubLocations))
+		for index, subLocation := range location.SubLocations {
+			subLocations[index] = shiftLocation(subLocation, offset)
+		}
+		location.SubLocations = subLocations
+		return location
+	}
+	location.Start += offset
+	location.End += offset
+	return location
+}
+
+// flipLocation returns the location on the reverse complement of a sequence
+// of a given length. Each part of the location moves to the other strand, in
+// the same order, so the location still reads the same bases.
+func flipLocation(location genbank.Location, length int) genbank.Location {
+	if len(location.SubLocations) > 0 {
+		subLocations := make([]genbank.Location, len(location.SubLocations))
+		for index, subLocation := range location.SubLocations {
+			subLocations[index] = flipLocation(subLocation, length)
+		}
+		location.SubLocations = subLocations
+		return location
+	}
+	location.Start, location.End = length-location.End, length-location.Start
+	location.Complement = !location.Complement
+	location.FivePrimePartial, location.ThreePrimePartial = location.ThreePrimePartial, location.FivePrimePartial
+	return location
+}
+
+// wrapLocation returns a location on a circular sequence of a given length,
+// with positions past its length wrapped around the origin. Parts spanning
+// the origin are split in two and joined.
+func wrapLocation(location genbank.Location, length int) genbank.Location {
+	if len(location.SubLocations) > 0 {
+		var subLocations []genbank.Location
+		for _, subLocation := range location.SubLocations {
+			wrapped := wrapLocation(subLocation, length)
+			// the halves of a forward part split by the origin read the same
+			// as part of the join they are in
+			if location.Join && !location.Complement && wrapped.Join && !wrapped.Complement && len(subLocation.SubLocations) == 0 {
+				subLocations = append(subLocations, wrapped.SubLocations...)
+				continue
+			}
+			subLocations = append(subLocations, wrapped)
+		}
+		location.SubLocations = subLocations
+		return location
+	}
+	if location.Start >= length {
+		location.Start -= length
+		location.End -= length
+		return location
+	}
+	if location.End <= length {
+		return location
+	}
+	return genbank.Location{
+		Complement: location.Complement,
+		Join:       true,
+		SubLocations: []genbank.Location{
+			{Start: location.Start, End: length, FivePrimePartial: location.FivePrimePartial},
+			{Start: 0, End: location.End - length, ThreePrimePartial: location.ThreePrimePartial},
+		},
+	}
+}
+
+// simplifyLocation merges the parts of joins that follow each other on the
+// same strand, like the halves of a feature that spanned the origin of a
+// circular sequence, and replaces joins of a single part by that part.
+func simplifyLocation(location genbank.Location) genbank.Location {
+	if len(location.SubLocations) == 0 {
+		return location
+	}
+	var subLocations []genbank.Location
+	for _, subLocation := range location.SubLocations {
+		subLocation = simplifyLocation(subLocation)
+		if last := len(subLocations) - 1; location.Join && last >= 0 && len(subLocation.SubLocations) == 0 && len(subLocations[last].SubLocations) == 0 && subLocation.Complement == subLocations[last].Complement {
+			previous := &subLocations[last]
+			switch {
+			case !subLocation.Complement && previous.End == subLocation.Start:
+				previous.End = subLocation.End
+				previous.ThreePrimePartial = subLocation.ThreePrimePartial
+				continue
+			case subLocation.Complement && subLocation.End == previous.Start:
+				previous.Start = subLocation.Start
+				previous.FivePrimePartial = subLocation.FivePrimePartial
+				continue
+			}
+		}
+		subLocations = append(subLocations, subLocation)
+	}
+	if len(subLocations) == 1 {
+		single := subLocations[0]
+		single.Complement = single.Complement != location.Complement
+		return single
+	}
+	location.SubLocations = subLocations
+	return location
+}
@@ -0,0 +1,145 @@
+package clone
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/transform"
+)
+
+// featureSequence returns the sequence of a feature of an annotated sequence.
+func featureSequence(t *testing.T, sequence genbank.Genbank, feature genbank.Feature) string {
+	t.Helper()
+	feature.ParentSequence = &sequence
+	featureSequence, err := feature.GetSequence()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.ToUpper(featureSequence)
+}
+
+func TestCutWithEnzymeAnnotated(t *testing.T) {
+	puc19, err := genbank.Read("../data/puc19.gbk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	aatII := rebaseEnzymes(t, "AatII", "GACGT^C")[0]
+	fragments := CutWithEnzymeAnnotated(puc19, false, aatII)
+	if len(fragments) != 1 {
+		t.Fatalf("expected a single fragment, got %d", len(fragments))
+	}
+	fragment := fragments[0]
+	if fragment.Source != puc19.Meta.Locus.Name {
+		t.Errorf("expected source %q, got %q", puc19.Meta.Locus.Name, fragment.Source)
+	}
+
+	// AatII doesn't cut any feature, so every feature but the source
+	// follows the fragment, including the origin of replication spanning
+	// the origin of pUC19
+	if len(fragment.Features) != len(puc19.Features)-1 {
+		t.Fatalf("expected %d features, got %d", len(puc19.Features)-1, len(fragment.Features))
+	}
+	linear := genbank.Genbank{Sequence: fragment.ForwardOverhang + fragment.Sequence + fragment.ReverseOverhang}
+	for _, feature := range fragment.Features {
+		if feature.Attributes["label"] != "ori" {
+			continue
+		}
+		expected := strings.ToUpper(puc19.Sequence[2314:] + puc19.Sequence[:217])
+		if got := featureSequence(t, linear, feature); got != expected {
+			t.Errorf("unexpected ori sequence %s", got)
+		}
+	}
+
+	// PvuII cuts lacZ-alpha, which is lost
+	pvuII := rebaseEnzymes(t, "PvuII", "CAG^CTG")[0]
+	for _, fragment := range CutWithEnzymeAnnotated(puc19, false, pvuII) {
+		for _, feature := range fragment.Features {
+			if feature.Attributes["label"] == "lacZ-alpha" {
+				t.Errorf("lacZ-alpha should have been cut by PvuII")
+			}
+		}
+	}
+}
+
+func TestGoldenGateAnnotated(t *testing.T) {
+	bsai := GetBaseRestrictionEnzymes()[0]
+	x, y := "CCGATTAGCGGCTAACGTGCCA", "GTTCAGCAGCTAGGATCGATCGTTACCG"
+	backbone := genbank.Genbank{
+		Meta:     genbank.Meta{Locus: genbank.Locus{Name: "backbone", Circular: true}},
+		Sequence: x + "TACTAGAGACCAAAAAAAAAAAAAAAAGGTCTCAAATG" + y,
+	}
+	length := len(backbone.Sequence)
+	_ = backbone.AddFeature(&genbank.Feature{
+		Type:       "misc_feature",
+		Attributes: map[string]string{"label": "marker"},
+		Location:   genbank.Location{Join: true, SubLocations: []genbank.Location{{Start: length - 10, End: length}, {Start: 0, End: 10}}},
+	})
+
+	gene := "ATGAGCAAAGGAGAAGAACTTTTCACTGGAGTTGTCCCAATTCTTTAA"
+	insertSequence := "CCGGTCTCATACT" + gene + "AATGTGAGACCCC"
+	// the insert is given reverse complemented, so it gets ligated flipped
+	insert := genbank.Genbank{
+		Meta:     genbank.Meta{Locus: genbank.Locus{Name: "insert"}},
+		Sequence: transform.ReverseComplement(insertSequence),
+	}
+	geneStart := len(insertSequence) - 13 - len(gene)
+	_ = insert.AddFeature(&genbank.Feature{
+		Type:       "CDS",
+		Attributes: map[string]string{"label": "gene"},
+		Location:   genbank.Location{Start: geneStart, End: geneStart + len(gene), Complement: true},
+	})
+	_ = insert.AddFeature(&genbank.Feature{
+		Type:       "primer_bind",
+		Attributes: map[string]string{"label": "primer"},
+		Location:   genbank.Location{Start: geneStart + 5, End: geneStart + 25},
+	})
+
+	constructs, infiniteLoops := GoldenGateAnnotated([]genbank.Genbank{backbone, insert}, bsai)
+	if len(constructs) != 1 || len(infiniteLoops) != 0 {
+		t.Fatalf("expected a single construct, got %d and %d infinite loops", len(constructs), len(infiniteLoops))
+	}
+	construct := constructs[0]
+	if construct.Meta.Locus.Name != "backbone_insert" || !construct.Meta.Locus.Circular {
+		t.Errorf("unexpected locus %+v", construct.Meta.Locus)
+	}
+	if len(construct.Features) != 3 {
+		t.Fatalf("expected 3 features, got %d", len(construct.Features))
+	}
+	originals := map[string]genbank.Genbank{"marker": backbone, "gene": insert, "primer": insert}
+	for _, feature := range construct.Features {
+		label := feature.Attributes["label"]
+		original := originals[label]
+		var originalFeature genbank.Feature
+		for _, candidate := range original.Features {
+			if candidate.Attributes["label"] == label {
+				originalFeature = candidate
+			}
+		}
+		if got, expected := featureSequence(t, construct, feature), featureSequence(t, original, originalFeature); got != expected {
+			t.Errorf("feature %s reads %s, expected %s", label, got, expected)
+		}
+		switch label {
+		case "marker":
+			// the marker spanned the origin of the backbone, and no longer
+			// does
+			if len(feature.Location.SubLocations) != 0 {
+				t.Errorf("expected the marker to be merged, got %+v", feature.Location)
+			}
+		case "gene":
+			if feature.Location.Complement {
+				t.Errorf("expected the flipped gene on the top strand")
+			}
+		}
+	}
+	if _, err := genbank.Build(construct); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWrapLocation(t *testing.T) {
+	location := simplifyLocation(wrapLocation(genbank.Location{Start: 95, End: 110, Complement: true}, 100))
+	if got := genbank.BuildLocationString(location); got != "complement(join(96..100,1..10))" {
+		t.Errorf("unexpected location string %s", got)
+	}
+}
@@ -4,7 +4,7 @@ Package clone provides functions for cloning DNA sequences.
 Since 1973, the most common way to make recombinant DNA has been restriction
 enzyme cloning (though lately, homologous recombination based methods like
 Gibson assembly have attracted a lot of use). The cloning functions here allow
-for simulation of restriction enzyme cloning.
+for simulation of restriction enzyme cloning and of homology based assembly.
 
 For a historical review leading up to the discovery:
 https://doi.org/10.1073/pnas.1313397110
@@ -36,8 +36,9 @@ Let's build some DNA!
 
 # Keoni
 
-PS: We do NOT (yet) handle restriction enzymes which recognize one site but cut
-in multiple places (Type IIG enzymes) such as BcgI.
+PS: Enzymes which recognize one site but cut on both sides of it (Type IIG
+enzymes) such as BcgI or BaeI are handled, but each cut is assumed to leave the
+same kind of overhang.
 */
 package clone
 
@@ -62,6 +63,9 @@ type Part struct {
 }
 
 // Overhang is a struct that represents the ends of a linearized sequence where Enzymes had cut.
+//
+// Deprecated: CutWithEnzyme no longer uses Overhang, see the enzyme and
+// overhang fields of Fragment.
 type Overhang struct {
 	Length                        int
 	Position                      int
@@ -70,13 +74,27 @@ type Overhang struct {
 }
 
 // Fragment is a struct that represents linear DNA sequences with sticky ends.
+// Overhangs are always written as the sequence of the top strand they span,
+// whichever strand actually sticks out.
 type Fragment struct {
 	Sequence        string
 	ForwardOverhang string
 	ReverseOverhang string
+	// ForwardEnzyme and ReverseEnzyme are the names of the enzymes that cut
+	// each end of the fragment. They are empty for the ends of a linear part.
+	ForwardEnzyme string
+	ReverseEnzyme string
+	// ForwardThreePrime and ReverseThreePrime are true if the overhang at
+	// that end is a 3' overhang rather than a 5' overhang.
+	ForwardThreePrime bool
+	ReverseThreePrime bool
 }
 
 // Enzyme is a struct that represents restriction enzymes.
+//
+// Skip is the distance from the end of the recognition site to the nearest
+// cut, negative when the enzyme cuts within its site, and OverheadLength is
+// the distance between the cuts of each strand, 0 for blunt cutters.
 type Enzyme struct {
 	Name            string
 	RegexpFor       *regexp.Regexp
@@ -84,6 +102,50 @@ type Enzyme struct {
 	Skip            int
 	OverheadLength  int
 	RecognitionSite string
+	// ThreePrimeOverhang is true if the enzyme leaves 3' overhangs, like
+	// KpnI or PstI, rather than 5' overhangs.
+	ThreePrimeOverhang bool
+	// CutsBothSides is true if the enzyme also cuts before its recognition
+	// site, like BaeI. UpstreamSkip is then the distance from the start of the
+	// site to the nearest upstream cut, and UpstreamOverheadLength the
+	// distance between the upstream cuts of each strand.
+	CutsBothSides          bool
+	UpstreamSkip           int
+	UpstreamOverheadLength int
+	// BlockedBy are the names of the methylations, like "dam" or "dcm", that
+	// block the enzyme when they overlap its recognition site. See
+	// CutMethylatedWithEnzyme.
+	BlockedBy []string
+}
+
+// cut is where an enzyme cut a sequence. The overhang left between the cuts
+// of each strand spans [start, end), and the recognition site of the enzyme
+// spans [siteStart, siteEnd).
+type cut struct {
+	start      int
+	end        int
+	siteStart  int
+	siteEnd    int
+	threePrime bool
+	enzyme     string
+}
+
+// topStrandCut returns the position of the cut of the top strand, at the
+// start of 5' overhangs and at the end of 3' overhangs.
+func (c cut) topStrandCut() int {
+	if c.threePrime {
+		return c.end
+	}
+	return c.start
+}
+
+// shift returns the cut moved by offset bases.
+func (c cut) shift(offset int) cut {
+	c.start += offset
+	c.end += offset
+	c.siteStart += offset
+	c.siteEnd += offset
+	return c
 }
 
 // EnzymeManager manager for Enzymes. Allows for management of enzymes throughout the lifecyle of your
@@ -111,18 +173,22 @@ Base cloning functions begin here.
 
 ******************************************************************************/
 
-// CutWithEnzymeByName cuts a given sequence with an enzyme represented by the
-// enzyme's name. It is a convenience wrapper around CutWithEnzyme that
-// allows us to specify the enzyme by name.
-func (enzymeManager EnzymeManager) CutWithEnzymeByName(part Part, directional bool, name string) ([]Fragment, error) {
-	// Get the enzyme from the enzyme map
-	enzyme, err := enzymeManager.GetEnzymeByName(name)
-	if err != nil {
-		// Return an error if there was an error
-		return []Fragment{}, err
+// CutWithEnzymeByName cuts a given sequence with enzymes represented by the
+// enzymes' names. It is a convenience wrapper around CutWithEnzyme that
+// allows us to specify the enzymes by name.
+func (enzymeManager EnzymeManager) CutWithEnzymeByName(part Part, directional bool, names ...string) ([]Fragment, error) {
+	// Get the enzymes from the enzyme map
+	var enzymes []Enzyme
+	for _, name := range names {
+		enzyme, err := enzymeManager.GetEnzymeByName(name)
+		if err != nil {
+			// Return an error if there was an error
+			return []Fragment{}, err
+		}
+		enzymes = append(enzymes, enzyme)
 	}
-	// Cut the sequence with the enzyme
-	return CutWithEnzyme(part, directional, enzyme), nil
+	// Cut the sequence with the enzymes
+	return CutWithEnzyme(part, directional, enzymes...), nil
 }
 
 // GetEnzymeByName gets the enzyme by it's name. If the enzyme manager does not
@@ -134,166 +200,267 @@ func (enzymeManager EnzymeManager) GetEnzymeByName(name string) (Enzyme, error)
 	return Enzyme{}, errors.New("Enzyme " + name + " not found")
 }
 
-// CutWithEnzyme cuts a given sequence with an enzyme represented by an Enzyme struct.
-func CutWithEnzyme(part Part, directional bool, enzyme Enzyme) []Fragment {
-	var fragmentSequences []string
-	var sequence string
+// CutWithEnzyme cuts a given sequence with one or more enzymes, as in a
+// digest with all of them at once.
+//
+// Fragments are returned in the order of their position in the sequence,
+// along with the enzymes that cut each of their ends and the kind of overhang
+// they left. A linear part with a single cut keeps its historical order, with
+// the fragment downstream of the recognition site first. Fragments of 8 base
+// pairs or less, overhangs included, are left out, since they are too short
+// to be recovered for assembly (https://doi.org/10.1186/1756-0500-3-291).
+//
+// If directional is true, the fragments that would be cut again by the
+// enzymes, because they still carry the recognition site of one of their
+// ends, are left out, as are the ends of linear parts. This is the basis of
+// GoldenGate assembly with Type IIS enzymes.
+func CutWithEnzyme(part Part, directional bool, enzymes ...Enzyme) []Fragment {
+	return fragmentsOf(cutWithEnzyme(part, nil, directional, enzymes))
+}
+
+// cutFragment is a fragment along with where it was cut from a sequence. Its
+// forward overhang starts at start and its reverse overhang ends at end, past
+// the length of circular sequences for fragments spanning their origin.
+type cutFragment struct {
+	Fragment
+	start int
+	end   int
+}
+
+// fragmentsOf returns the fragments of cut fragments.
+func fragmentsOf(cutFragments []cutFragment) []Fragment {
+	if cutFragments == nil {
+		return nil
+	}
+	fragments := make([]Fragment, len(cutFragments))
+	for index, cutFragment := range cutFragments {
+		fragments[index] = cutFragment.Fragment
+	}
+	return fragments
+}
+
+// cutWithEnzyme cuts a sequence grown in a host with the given methylations.
+func cutWithEnzyme(part Part, host []Methylation, directional bool, enzymes []Enzyme) []cutFragment {
+	sequence := strings.ToUpper(part.Sequence)
+	length := len(sequence)
+	cuts := digestCuts(sequence, part.Circular, host, enzymes)
+	if len(cuts) == 0 {
+		return nil
+	}
+
+	// Each fragment spans from a cut to the next one. The last fragment of a
+	// circular sequence wraps around its origin to the first cut, while the
+	// ends of a linear sequence are cuts of no enzyme.
+	var boundaries []cut
 	if part.Circular {
-		sequence = strings.ToUpper(part.Sequence + part.Sequence)
+		sequence = sequence + sequence + sequence
+		boundaries = append(cuts, cuts[0].shift(length))
 	} else {
-		sequence = strings.ToUpper(part.Sequence)
+		boundaries = append(append([]cut{{}}, cuts...), cut{start: length, end: length})
+	}
+	var fragments []cutFragment
+	for index := 0; index < len(boundaries)-1; index++ {
+		current, next := boundaries[index], boundaries[index+1]
+		if next.start < current.end || next.end-current.start <= 8 {
+			continue
+		}
+		if directional && (current.enzyme == "" || next.enzyme == "" || current.containsSite(current, next) || next.containsSite(current, next)) {
+			continue
+		}
+		fragments = append(fragments, cutFragment{
+			Fragment: Fragment{
+				Sequence:          sequence[current.end:next.start],
+				ForwardOverhang:   sequence[current.start:current.end],
+				ReverseOverhang:   sequence[next.start:next.end],
+				ForwardEnzyme:     current.enzyme,
+				ReverseEnzyme:     next.enzyme,
+				ForwardThreePrime: current.threePrime,
+				ReverseThreePrime: next.threePrime,
+			},
+			start: current.start,
+			end:   next.end,
+		})
 	}
 
-	// Check for palindromes
-	palindromic := checks.IsPalindromic(enzyme.RecognitionSite)
+	if !part.Circular && len(cuts) == 1 && len(fragments) == 2 && cuts[0].siteStart < cuts[0].start {
+		fragments[0], fragments[1] = fragments[1], fragments[0]
+	}
+	return fragments
+}
 
-	// Find and define overhangs
-	var overhangs []Overhang
-	var forwardOverhangs []Overhang
-	var reverseOverhangs []Overhang
-	forwardCuts := enzyme.RegexpFor.FindAllStringIndex(sequence, -1)
-	for _, forwardCut := range forwardCuts {
-		forwardOverhangs = append(forwardOverhangs, Overhang{Length: enzyme.OverheadLength, Position: forwardCut[1] + enzyme.Skip, Forward: true, RecognitionSitePlusSkipLength: len(enzyme.RecognitionSite) + enzyme.Skip})
+// digestCuts returns the cuts enzymes make in a sequence grown in a host with
+// the given methylations, sorted by position. The same cut made by several
+// enzymes, like isoschizomers, is only returned once. Cuts of circular
+// sequences start within the sequence, but may end past its origin.
+func digestCuts(sequence string, circular bool, host []Methylation, enzymes []Enzyme) []cut {
+	length := len(sequence)
+	if length == 0 {
+		return nil
 	}
-	// Palindromic enzymes won't need reverseCuts
-	if !palindromic {
-		reverseCuts := enzyme.RegexpRev.FindAllStringIndex(sequence, -1)
-		for _, reverseCut := range reverseCuts {
-			reverseOverhangs = append(reverseOverhangs, Overhang{Length: enzyme.OverheadLength, Position: reverseCut[0] - enzyme.Skip, Forward: false, RecognitionSitePlusSkipLength: len(enzyme.RecognitionSite) + enzyme.Skip})
-		}
+	var methylated map[string][]bool
+	if len(host) > 0 {
+		methylated = methylatedBases(sequence, circular, host)
 	}
 
-	// If, on a linear sequence, the last overhang's position + EnzymeSkip + EnzymeOverhangLength is over the length of the sequence, remove that overhang.
-	for _, overhangSet := range [][]Overhang{forwardOverhangs, reverseOverhangs} {
-		if len(overhangSet) > 0 {
-			if !part.Circular && (overhangSet[len(overhangSet)-1].Position+enzyme.Skip+enzyme.OverheadLength > len(sequence)) {
-				overhangSet = overhangSet[:len(overhangSet)-1]
+	// Circular sequences are searched twice over to find the sites spanning
+	// their origin, and every cut is moved so that it starts in the first
+	// copy of the sequence.
+	searchSequence := sequence
+	if circular {
+		searchSequence = sequence + sequence
+	}
+	var cuts []cut
+	for _, enzyme := range enzymes {
+		for _, enzymeCut := range findCuts(searchSequence, enzyme) {
+			if methylated != nil && blocked(enzymeCut, enzyme.BlockedBy, methylated) {
+				continue
+			}
+			if circular {
+				if enzymeCut.siteStart >= length {
+					continue
+				}
+				offset := enzymeCut.start % length
+				if offset < 0 {
+					offset += length
+				}
+				cuts = append(cuts, enzymeCut.shift(offset-enzymeCut.start))
+				continue
+			}
+			// Enzymes cutting away from their recognition site can cut off
+			// either end of a linear sequence, which doesn't happen.
+			if enzymeCut.start >= 0 && enzymeCut.end <= length {
+				cuts = append(cuts, enzymeCut)
 			}
 		}
-		overhangs = append(overhangs, overhangSet...)
+	}
+	if len(cuts) == 0 {
+		return nil
 	}
 
-	// Sort overhangs
-	sort.SliceStable(overhangs, func(i, j int) bool {
-		return overhangs[i].Position < overhangs[j].Position
+	sort.SliceStable(cuts, func(i, j int) bool {
+		if cuts[i].start != cuts[j].start {
+			return cuts[i].start < cuts[j].start
+		}
+		return cuts[i].end < cuts[j].end
 	})
-
-	// Convert Overhangs into Fragments
-	var fragments []Fragment
-	var currentOverhang Overhang
-	var nextOverhang Overhang
-	// Linear fragments with 1 cut that are no directional will always give a
-	// 2 fragments
-	if len(overhangs) == 1 && !directional && !part.Circular { // Check the case of a single cut
-		// In the case of a single cut in a linear sequence, we get two fragments with only 1 stick end
-
-		var fragmentSequence1 string
-		var fragmentSequence2 string
-		var overhangSequence string
-
-		if len(forwardOverhangs) > 0 {
-			fragmentSequence1 = sequence[overhangs[0].Position+overhangs[0].Length:]
-			fragmentSequence2 = sequence[:overhangs[0].Position]
-			overhangSequence = sequence[overhangs[0].Position : overhangs[0].Position+overhangs[0].Length]
-			fragments = append(fragments, Fragment{fragmentSequence1, overhangSequence, ""})
-			fragments = append(fragments, Fragment{fragmentSequence2, "", overhangSequence})
-		} else {
-			fragmentSequence1 = sequence[overhangs[0].Position:]
-			fragmentSequence2 = sequence[:overhangs[0].Position-overhangs[0].Length]
-			overhangSequence = sequence[overhangs[0].Position-overhangs[0].Length : overhangs[0].Position]
-			fragments = append(fragments, Fragment{fragmentSequence2, "", overhangSequence})
-			fragments = append(fragments, Fragment{fragmentSequence1, overhangSequence, ""})
+	uniqueCuts := cuts[:1]
+	for _, enzymeCut := range cuts[1:] {
+		previous := uniqueCuts[len(uniqueCuts)-1]
+		if enzymeCut.start != previous.start || enzymeCut.end != previous.end || enzymeCut.threePrime != previous.threePrime {
+			uniqueCuts = append(uniqueCuts, enzymeCut)
 		}
-
-		return fragments
 	}
+	return uniqueCuts
+}
 
-	// Circular fragments with 1 cut will always have 2 overhangs (because of the
-	// concat earlier). If we don't require directionality, this will always get
-	// cut into a single fragment
-	if len(overhangs) == 2 && !directional && part.Circular {
-		// In the case of a single cut in a circular sequence, we get one fragment out with sticky overhangs
-		fragmentSequence1 := sequence[overhangs[0].Position+overhangs[0].Length : len(part.Sequence)]
-		fragmentSequence2 := sequence[:overhangs[0].Position]
-		fragmentSequence := fragmentSequence1 + fragmentSequence2
-		overhangSequence := sequence[overhangs[0].Position : overhangs[0].Position+overhangs[0].Length]
-		fragments = append(fragments, Fragment{fragmentSequence, overhangSequence, overhangSequence})
-		return fragments
+// findCuts returns every cut an enzyme makes in a sequence, on either strand.
+func findCuts(sequence string, enzyme Enzyme) []cut {
+	var cuts []cut
+	for _, match := range enzyme.RegexpFor.FindAllStringIndex(sequence, -1) {
+		site := cut{siteStart: match[0], siteEnd: match[1], threePrime: enzyme.ThreePrimeOverhang, enzyme: enzyme.Name}
+		downstream := site
+		downstream.start = match[1] + enzyme.Skip
+		downstream.end = downstream.start + enzyme.OverheadLength
+		cuts = append(cuts, downstream)
+		if enzyme.CutsBothSides {
+			upstream := site
+			upstream.end = match[0] - enzyme.UpstreamSkip
+			upstream.start = upstream.end - enzyme.UpstreamOverheadLength
+			cuts = append(cuts, upstream)
+		}
 	}
 
-	if len(overhangs) > 1 {
-		// The following will iterate over the overhangs list to turn them into fragments
-		// There are two important variables: if the sequence is circular, and if the enzyme cutting is directional. All Type IIS enzymes
-		// are directional, and in normal GoldenGate reactions these fragments would be constantly cut with enzyme as the reaction runs,
-		// so are removed from the output sequences. If the enzyme is not directional, all fragments are valid.
-		// If the sequence is circular, there is a chance that the nextOverhang's position will be greater than the length of the original sequence.
-		// This is ok, and represents a valid cut/fragmentation of a rotation of the sequence. However, everything after will be a repeat fragment
-		// of current fragments, so the iteration is terminated.
-		for overhangIndex := 0; overhangIndex < len(overhangs)-1; overhangIndex++ {
-			currentOverhang = overhangs[overhangIndex]
-			nextOverhang = overhangs[overhangIndex+1]
-			// If we want directional cutting and the enzyme is not palindromic, we
-			// can remove fragments that are continuously cut by the enzyme. This is
-			// the basis of GoldenGate assembly.
-			if directional && !palindromic {
-				if currentOverhang.Forward && !nextOverhang.Forward {
-					fragmentSequences = append(fragmentSequences, sequence[currentOverhang.Position:nextOverhang.Position])
-				}
-				// We have to subtract RecognitionSitePlusSkipLength in case we have a recognition site on
-				// one side of the origin of a circular sequence and the cut site on the other side of the origin
-				if nextOverhang.Position-nextOverhang.RecognitionSitePlusSkipLength > len(part.Sequence) {
-					break
-				}
-			} else {
-				fragmentSequences = append(fragmentSequences, sequence[currentOverhang.Position:nextOverhang.Position])
-				if nextOverhang.Position-nextOverhang.RecognitionSitePlusSkipLength > len(part.Sequence) {
-					break
-				}
-			}
-		}
-		// Convert fragment sequences into fragments
-		for _, fragmentsequence := range fragmentSequences {
-			// Minimum lengths (given oligos) for assembly is 8 base pairs
-			// https://doi.org/10.1186/1756-0500-3-291
-			if len(fragmentsequence) > 8 {
-				fragmentSequence := fragmentsequence[enzyme.OverheadLength : len(fragmentsequence)-enzyme.OverheadLength]
-				forwardOverhang := fragmentsequence[:enzyme.OverheadLength]
-				reverseOverhang := fragmentsequence[len(fragmentsequence)-enzyme.OverheadLength:]
-				fragments = append(fragments, Fragment{Sequence: fragmentSequence, ForwardOverhang: forwardOverhang, ReverseOverhang: reverseOverhang})
-			}
+	// Palindromic enzymes won't need reverse cuts
+	if checks.IsPalindromic(enzyme.RecognitionSite) {
+		return cuts
+	}
+	for _, match := range enzyme.RegexpRev.FindAllStringIndex(sequence, -1) {
+		site := cut{siteStart: match[0], siteEnd: match[1], threePrime: enzyme.ThreePrimeOverhang, enzyme: enzyme.Name}
+		downstream := site
+		downstream.end = match[0] - enzyme.Skip
+		downstream.start = downstream.end - enzyme.OverheadLength
+		cuts = append(cuts, downstream)
+		if enzyme.CutsBothSides {
+			upstream := site
+			upstream.start = match[1] + enzyme.UpstreamSkip
+			upstream.end = upstream.start + enzyme.UpstreamOverheadLength
+			cuts = append(cuts, upstream)
 		}
 	}
+	return cuts
+}
 
-	return fragments
+// containsSite returns whether the fragment from one cut to the next still
+// carries the recognition site of c, so would be cut again.
+func (c cut) containsSite(from, to cut) bool {
+	return c.enzyme != "" && c.siteStart >= from.start && c.siteEnd <= to.end
+}
+
+// ligates returns whether an end with a given overhang ligates to another
+// end. Blunt ends ligate to any blunt end, and sticky ends to ends with the
+// same overhang that stick out on the same strand.
+func ligates(overhang string, threePrime bool, otherOverhang string, otherThreePrime bool) bool {
+	return overhang == otherOverhang && (overhang == "" || threePrime == otherThreePrime)
+}
+
+// ligatedFragment is a fragment of a ligation, by its index in the fragments
+// ligated, and whether it was ligated reverse complemented.
+type ligatedFragment struct {
+	index   int
+	flipped bool
+}
+
+// ligation is a circular construct and the fragments ligated into it, in
+// order from its origin.
+type ligation struct {
+	construct string
+	fragments []ligatedFragment
 }
 
-func recurseLigate(seedFragment Fragment, fragmentList []Fragment, usedFragments []Fragment, existingSeqhashes map[string]struct{}) (openConstructs []string, infiniteConstructs []string) {
+func recurseLigate(seedFragment Fragment, path []ligatedFragment, fragmentList []Fragment, usedFragments []Fragment, existingSeqhashes map[string]struct{}) (openConstructs []ligation, infiniteConstructs []string) {
 	// Recurse ligate simulates all possible ligations of a series of fragments. Each possible combination begins with a "seed" that fragments from the pool can be added to.
 	// If the seed ligates to itself, we can call it done with a successful circularization!
-	if seedFragment.ForwardOverhang == seedFragment.ReverseOverhang {
+	if ligates(seedFragment.ReverseOverhang, seedFragment.ReverseThreePrime, seedFragment.ForwardOverhang, seedFragment.ForwardThreePrime) {
 		construct := seedFragment.ForwardOverhang + seedFragment.Sequence
 		seqhash, _ := seqhash.Hash(construct, "DNA", true, true)
 		if _, ok := existingSeqhashes[seqhash]; ok {
 			return nil, nil
 		}
 		existingSeqhashes[seqhash] = struct{}{}
-		return []string{construct}, nil
+		return []ligation{{construct, path}}, nil
 	}
 
 	// If the seed ligates to another fragment, we can recurse and add that fragment to the seed
-	for _, newFragment := range fragmentList {
+	for index, newFragment := range fragmentList {
 		// If the seedFragment's reverse overhang is ligates to a fragment's forward overhang, we can ligate those together and seed another ligation reaction
 		var newSeed Fragment
-		var fragmentAttached bool
-		if seedFragment.ReverseOverhang == newFragment.ForwardOverhang {
+		var fragmentAttached, flipped bool
+		if ligates(seedFragment.ReverseOverhang, seedFragment.ReverseThreePrime, newFragment.ForwardOverhang, newFragment.ForwardThreePrime) {
 			fragmentAttached = true
-			newSeed = Fragment{seedFragment.Sequence + seedFragment.ReverseOverhang + newFragment.Sequence, seedFragment.ForwardOverhang, newFragment.ReverseOverhang}
+			newSeed = Fragment{
+				Sequence:          seedFragment.Sequence + seedFragment.ReverseOverhang + newFragment.Sequence,
+				ForwardOverhang:   seedFragment.ForwardOverhang,
+				ReverseOverhang:   newFragment.ReverseOverhang,
+				ForwardEnzyme:     seedFragment.ForwardEnzyme,
+				ReverseEnzyme:     newFragment.ReverseEnzyme,
+				ForwardThreePrime: seedFragment.ForwardThreePrime,
+				ReverseThreePrime: newFragment.ReverseThreePrime,
+			}
 		}
 		// This checks if we can ligate the next fragment in its reverse direction. We have to be careful though - if our seed has a palindrome, it will ligate to itself
 		// like [-> <- -> <- -> ...] infinitely. We check for that case here as well.
-		if (seedFragment.ReverseOverhang == transform.ReverseComplement(newFragment.ReverseOverhang)) && (seedFragment.ReverseOverhang != transform.ReverseComplement(seedFragment.ReverseOverhang)) { // If the second statement isn't there, program will crash on palindromes
+		if ligates(seedFragment.ReverseOverhang, seedFragment.ReverseThreePrime, transform.ReverseComplement(newFragment.ReverseOverhang), newFragment.ReverseThreePrime) && (seedFragment.ReverseOverhang != transform.ReverseComplement(seedFragment.ReverseOverhang)) { // If the second statement isn't there, program will crash on palindromes
 			fragmentAttached = true
-			newSeed = Fragment{seedFragment.Sequence + seedFragment.ReverseOverhang + transform.ReverseComplement(newFragment.Sequence), seedFragment.ForwardOverhang, transform.ReverseComplement(newFragment.ForwardOverhang)}
+			flipped = true
+			newSeed = Fragment{
+				Sequence:          seedFragment.Sequence + seedFragment.ReverseOverhang + transform.ReverseComplement(newFragment.Sequence),
+				ForwardOverhang:   seedFragment.ForwardOverhang,
+				ReverseOverhang:   transform.ReverseComplement(newFragment.ForwardOverhang),
+				ForwardEnzyme:     seedFragment.ForwardEnzyme,
+				ReverseEnzyme:     newFragment.ForwardEnzyme,
+				ForwardThreePrime: seedFragment.ForwardThreePrime,
+				ReverseThreePrime: newFragment.ForwardThreePrime,
+			}
 		}
 
 		// If fragment is actually attached, move to some checks
@@ -312,7 +479,8 @@ func recurseLigate(seedFragment Fragment, fragmentList []Fragment, usedFragments
 			}
 			// If everything is clear, append fragment to usedFragments and recurse.
 			usedFragments = append(usedFragments, newFragment)
-			openconstructs, infiniteconstructs := recurseLigate(newSeed, fragmentList, usedFragments, existingSeqhashes)
+			newPath := append(path[:len(path):len(path)], ligatedFragment{index, flipped})
+			openconstructs, infiniteconstructs := recurseLigate(newSeed, newPath, fragmentList, usedFragments, existingSeqhashes)
 
 			openConstructs = append(openConstructs, openconstructs...)
 			infiniteConstructs = append(infiniteConstructs, infiniteconstructs...)
@@ -324,11 +492,22 @@ func recurseLigate(seedFragment Fragment, fragmentList []Fragment, usedFragments
 
 // CircularLigate simulates ligation of all possible fragment combinations into circular plasmids.
 func CircularLigate(fragments []Fragment) ([]string, []string) {
+	ligations, outputInfiniteLoopingConstructs := circularLigate(fragments)
 	var outputConstructs []string
+	for _, ligation := range ligations {
+		outputConstructs = append(outputConstructs, ligation.construct)
+	}
+	return outputConstructs, outputInfiniteLoopingConstructs
+}
+
+// circularLigate simulates ligation of all possible fragment combinations into
+// circular plasmids, keeping track of the fragments making up each plasmid.
+func circularLigate(fragments []Fragment) ([]ligation, []string) {
+	var outputConstructs []ligation
 	var outputInfiniteLoopingConstructs []string
 	existingSeqhashes := make(map[string]struct{})
-	for _, fragment := range fragments {
-		openConstructs, infiniteConstructs := recurseLigate(fragment, fragments, []Fragment{}, existingSeqhashes)
+	for index, fragment := range fragments {
+		openConstructs, infiniteConstructs := recurseLigate(fragment, []ligatedFragment{{index: index}}, fragments, []Fragment{}, existingSeqhashes)
 
 		outputConstructs = append(outputConstructs, openConstructs...)
 		outputInfiniteLoopingConstructs = append(outputInfiniteLoopingConstructs, infiniteConstructs...)
@@ -357,8 +536,8 @@ func GoldenGate(sequences []Part, cuttingEnzyme Enzyme) (openConstructs []string
 // GetBaseRestrictionEnzymes return a basic slice of common enzymes used in Golden Gate Assembly. Eventually, we want to get the data for this map from ftp://ftp.neb.com/pub/rebase
 func GetBaseRestrictionEnzymes() []Enzyme {
 	return []Enzyme{
-		{"BsaI", regexp.MustCompile("GGTCTC"), regexp.MustCompile("GAGACC"), 1, 4, "GGTCTC"},
-		{"BbsI", regexp.MustCompile("GAAGAC"), regexp.MustCompile("GTCTTC"), 2, 4, "GAAGAC"},
-		{"BtgZI", regexp.MustCompile("GCGATG"), regexp.MustCompile("CATCGC"), 10, 4, "GCGATG"},
+		{Name: "BsaI", RegexpFor: regexp.MustCompile("GGTCTC"), RegexpRev: regexp.MustCompile("GAGACC"), Skip: 1, OverheadLength: 4, RecognitionSite: "GGTCTC"},
+		{Name: "BbsI", RegexpFor: regexp.MustCompile("GAAGAC"), RegexpRev: regexp.MustCompile("GTCTTC"), Skip: 2, OverheadLength: 4, RecognitionSite: "GAAGAC"},
+		{Name: "BtgZI", RegexpFor: regexp.MustCompile("GCGATG"), RegexpRev: regexp.MustCompile("CATCGC"), Skip: 10, OverheadLength: 4, RecognitionSite: "GCGATG"},
 	}
 }
@@ -1,7 +1,10 @@
 package clone
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/bebop/poly/io/rebase"
 )
 
 // pOpen plasmid series (https://stanford.freegenes.org/collections/open-genes/products/open-plasmids#description). I use it for essentially all my cloning. -Keoni
@@ -139,11 +142,91 @@ func TestCutWithEnzymeRegression(t *testing.T) {
 	}
 }
 
+func TestCutWithEnzymeMultipleEnzymes(t *testing.T) {
+	// EcoRV cuts blunt ends and KpnI leaves 3' overhangs
+	ecorv, _ := EnzymeFromRebase(rebase.Enzyme{Name: "EcoRV", RecognitionSequence: "GAT^ATC"})
+	kpni, _ := EnzymeFromRebase(rebase.Enzyme{Name: "KpnI", RecognitionSequence: "GGTAC^C"})
+	part := Part{"AAAAAAAAAA" + "GATATC" + "CCCCCCCCCC" + "GGTACC" + "TTTTTTTTTT", false}
+	fragments := CutWithEnzyme(part, false, ecorv, kpni)
+	expected := []Fragment{
+		{Sequence: "AAAAAAAAAAGAT", ReverseEnzyme: "EcoRV"},
+		{Sequence: "ATCCCCCCCCCCCG", ReverseOverhang: "GTAC", ForwardEnzyme: "EcoRV", ReverseEnzyme: "KpnI", ReverseThreePrime: true},
+		{Sequence: "CTTTTTTTTTT", ForwardOverhang: "GTAC", ForwardEnzyme: "KpnI", ForwardThreePrime: true},
+	}
+	if len(fragments) != len(expected) {
+		t.Fatalf("Expected %d fragments, got %d: %+v", len(expected), len(fragments), fragments)
+	}
+	for index := range expected {
+		if fragments[index] != expected[index] {
+			t.Errorf("Expected fragment %+v, got %+v", expected[index], fragments[index])
+		}
+	}
+
+	// enzymes can be given by name too
+	enzymeManager := NewEnzymeManager([]Enzyme{ecorv, kpni})
+	byName, err := enzymeManager.CutWithEnzymeByName(part, false, "EcoRV", "KpnI")
+	if err != nil || len(byName) != len(expected) {
+		t.Errorf("CutWithEnzymeByName should cut with both enzymes")
+	}
+	if _, err := enzymeManager.CutWithEnzymeByName(part, false, "EcoRV", "EcoFake"); err == nil {
+		t.Errorf("CutWithEnzymeByName should fail on EcoFake")
+	}
+}
+
+func TestCutWithEnzymeBothSides(t *testing.T) {
+	baei, _ := EnzymeFromRebase(rebase.Enzyme{Name: "BaeI", RecognitionSequence: "(10/15)ACNNNNGTAYC(12/7)"})
+	flank := strings.Repeat("C", 20)
+	part := Part{flank + "ACAAAAGTACC" + flank, false}
+	fragments := CutWithEnzyme(part, false, baei)
+	if len(fragments) != 3 {
+		t.Fatalf("BaeI should cut out its site, got %+v", fragments)
+	}
+	excised := fragments[1]
+	if excised.Sequence != strings.Repeat("C", 10)+"ACAAAAGTACC"+strings.Repeat("C", 7) || excised.ForwardOverhang != "CCCCC" || excised.ReverseOverhang != "CCCCC" || !excised.ForwardThreePrime || !excised.ReverseThreePrime {
+		t.Errorf("Unexpected excised fragment %+v", excised)
+	}
+
+	// the excised fragment keeps the site, so a directional digest drops it
+	if fragments := CutWithEnzyme(part, true, baei); len(fragments) != 0 {
+		t.Errorf("Expected no fragments from a directional digest, got %+v", fragments)
+	}
+}
+
+func TestCircularLigateOverhangPolarity(t *testing.T) {
+	// KpnI and Acc65I both leave GTAC overhangs, but on opposite strands, so
+	// an insert cut by KpnI only ligates into a vector cut by KpnI
+	kpni, _ := EnzymeFromRebase(rebase.Enzyme{Name: "KpnI", RecognitionSequence: "GGTAC^C"})
+	acc65i, _ := EnzymeFromRebase(rebase.Enzyme{Name: "Acc65I", RecognitionSequence: "G^GTACC"})
+	psti, _ := EnzymeFromRebase(rebase.Enzyme{Name: "PstI", RecognitionSequence: "CTGCA^G"})
+	vector := Part{strings.Repeat("A", 30) + "CTGCAG" + "GGTACC" + strings.Repeat("T", 30), true}
+	insertBody := strings.Repeat("G", 20)
+	insert := Part{"CCCCC" + "CTGCAG" + insertBody + "GGTACC" + "CCCCC", false}
+	insertFragments := CutWithEnzyme(insert, true, psti, kpni)
+	if len(insertFragments) != 1 {
+		t.Fatalf("Expected 1 insert fragment, got %d", len(insertFragments))
+	}
+
+	for _, test := range []struct {
+		vectorEnzyme Enzyme
+		ligates      bool
+	}{{kpni, true}, {acc65i, false}} {
+		fragments := append(CutWithEnzyme(vector, false, psti, test.vectorEnzyme), insertFragments...)
+		constructs, _ := CircularLigate(fragments)
+		withInsert := false
+		for _, construct := range constructs {
+			withInsert = withInsert || strings.Contains(construct, insertBody)
+		}
+		if withInsert != test.ligates {
+			t.Errorf("Insert cut by KpnI ligating into vector cut by %s: expected %t, got %t", test.vectorEnzyme.Name, test.ligates, withInsert)
+		}
+	}
+}
+
 func TestCircularLigate(t *testing.T) {
 	// The following tests for complementing overhangs. Specific, this line:
 	// newSeed := Fragment{seedFragment.Sequence + seedFragment.ReverseOverhang + ReverseComplement(newFragment.Sequence), seedFragment.ForwardOverhang, ReverseComplement(newFragment.ForwardOverhang)}
-	fragment1 := Fragment{"AAAAAA", "GTTG", "CTAT"}
-	fragment2 := Fragment{"AAAAAA", "CAAC", "ATAG"}
+	fragment1 := Fragment{Sequence: "AAAAAA", ForwardOverhang: "GTTG", ReverseOverhang: "CTAT"}
+	fragment2 := Fragment{Sequence: "AAAAAA", ForwardOverhang: "CAAC", ReverseOverhang: "ATAG"}
 	outputConstructs, infiniteLoops := CircularLigate([]Fragment{fragment1, fragment2})
 	if len(outputConstructs) != 1 {
 		t.Errorf("Circular ligation with complementing overhangs should only output 1 valid rotated sequence.")
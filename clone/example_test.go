@@ -5,6 +5,9 @@ import (
 	"log"
 
 	"github.com/bebop/poly/clone"
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/io/rebase"
+	"github.com/bebop/poly/random"
 	"github.com/bebop/poly/seqhash"
 )
 
@@ -29,3 +32,177 @@ func ExampleGoldenGate() {
 	fmt.Println(seqhash.RotateSequence(Clones[0]))
 	// Output: AAAAAAAGGATCTCAAGAAGGCCTACTATTAGCAACAACGATCCTTTGATCTTTTCTACGGGGTCTGACGCTCAGTGGAACGAAAACTCACGTTAAGGGATTTTGGTCATGAGATTATCAAAAAGGATCTTCACCTAGATCCTTTTAAATTAAAAATGAAGTTTTAAATCAATCTAAAGTATATATGAGTAAACTTGGTCTGACAGTTACCAATGCTTAATCAGTGAGGCACCTATCTCAGCGATCTGTCTATTTCGTTCATCCATAGTTGCCTGACTCCCCGTCGTGTAGATAACTACGATACGGGAGGGCTTACCATCTGGCCCCAGTGCTGCAATGATACCGCGAGAACCACGCTCACCGGCTCCAGATTTATCAGCAATAAACCAGCCAGCCGGAAGGGCCGAGCGCAGAAGTGGTCCTGCAACTTTATCCGCCTCCATCCAGTCTATTAATTGTTGCCGGGAAGCTAGAGTAAGTAGTTCGCCAGTTAATAGTTTGCGCAACGTTGTTGCCATTGCTACAGGCATCGTGGTGTCACGCTCGTCGTTTGGTATGGCTTCATTCAGCTCCGGTTCCCAACGATCAAGGCGAGTTACATGATCCCCCATGTTGTGCAAAAAAGCGGTTAGCTCCTTCGGTCCTCCGATCGTTGTCAGAAGTAAGTTGGCCGCAGTGTTATCACTCATGGTTATGGCAGCACTGCATAATTCTCTTACTGTCATGCCATCCGTAAGATGCTTTTCTGTGACTGGTGAGTACTCAACCAAGTCATTCTGAGAATAGTGTATGCGGCGACCGAGTTGCTCTTGCCCGGCGTCAATACGGGATAATACCGCGCCACATAGCAGAACTTTAAAAGTGCTCATCATTGGAAAACGTTCTTCGGGGCGAAAACTCTCAAGGATCTTACCGCTGTTGAGATCCAGTTCGATGTAACCCACTCGTGCACCCAACTGATCTTCAGCATCTTTTACTTTCACCAGCGTTTCTGGGTGAGCAAAAACAGGAAGGCAAAATGCCGCAAAAAAGGGAATAAGGGCGACACGGAAATGTTGAATACTCATACTCTTCCTTTTTCAATATTATTGAAGCATTTATCAGGGTTATTGTCTCATGAGCGGATACATATTTGAATGTATTTAGAAAAATAAACAAATAGGGGTTCCGCGCACCTGCACCAGTCAGTAAAACGACGGCCAGTAGTCAAAAGCCTCCGACCGGAGGCTTTTGACTTGGTTCAGGTGGAGTGGGAGAAACACGTGGCAAACATTCCGGTCTCAAATGGAAAAGAGCAACGAAACCAACGGCTACCTTGACAGCGCTCAAGCCGGCCCTGCAGCTGGCCCGGGCGCTCCGGGTACCGCCGCGGGTCGTGCACGTCGTTGCGCGGGCTTCCTGCGGCGCCAAGCGCTGGTGCTGCTCACGGTGTCTGGTGTTCTGGCAGGCGCCGGTTTGGGCGCGGCACTGCGTGGGCTCAGCCTGAGCCGCACCCAGGTCACCTACCTGGCCTTCCCCGGCGAGATGCTGCTCCGCATGCTGCGCATGATCATCCTGCCGCTGGTGGTCTGCAGCCTGGTGTCGGGCGCCGCCTCCCTCGATGCCAGCTGCCTCGGGCGTCTGGGCGGTATCGCTGTCGCCTACTTTGGCCTCACCACACTGAGTGCCTCGGCGCTCGCCGTGGCCTTGGCGTTCATCATCAAGCCAGGATCCGGTGCGCAGACCCTTCAGTCCAGCGACCTGGGGCTGGAGGACTCGGGGCCTCCTCCTGTCCCCAAAGAAACGGTGGACTCTTTCCTCGACCTGGCCAGAAACCTGTTTCCCTCCAATCTTGTGGTTGCAGCTTTCCGTACGTATGCAACCGATTATAAAGTCGTGACCCAGAACAGCAGCTCTGGAAATGTAACCCATGAAAAGATCCCCATAGGCACTGAGATAGAAGGGATGAACATTTTAGGATTGGTCCTGTTTGCTCTGGTGTTAGGAGTGGCCTTAAAGAAACTAGGCTCCGAAGGAGAGGACCTCATCCGTTTCTTCAATTCCCTCAACGAGGCGACGATGGTGCTGGTGTCCTGGATTATGTGGTACGTACCTGTGGGCATCATGTTCCTTGTTGGAAGCAAGATCGTGGAAATGAAAGACATCATCGTGCTGGTGACCAGCCTGGGGAAATACATCTTCGCATCTATATTGGGCCACGTCATTCATGGTGGTATCGTCCTGCCGCTGATTTATTTTGTTTTCACACGAAAAAACCCATTCAGATTCCTCCTGGGCCTCCTCGCCCCATTTGCGACAGCATTTGCTACGTGCTCCAGCTCAGCGACCCTTCCCTCTATGATGAAGTGCATTGAAGAGAACAATGGTGTGGACAAGAGGATCTCCAGGTTTATTCTCCCCATCGGGGCCACCGTGAACATGGACGGAGCAGCCATCTTCCAGTGTGTGGCCGCGGTGTTCATTGCGCAACTCAACAACGTAGAGCTCAACGCAGGACAGATTTTCACCATTCTAGTGACTGCCACAGCGTCCAGTGTTGGAGCAGCAGGCGTGCCAGCTGGAGGGGTCCTCACCATTGCCATTATCCTGGAGGCCATTGGGCTGCCTACTCATGATCTGCCTCTGATCCTGGCTGTGGACTGGATTGTGGACCGGACCACCACGGTGGTGAATGTGGAAGGGGATGCCCTGGGTGCAGGCATTCTCCACCACCTGAATCAGAAGGCAACAAAGAAAGGCGAGCAGGAACTTGCTGAGGTGAAAGTGGAAGCCATCCCCAACTGCAAGTCTGAGGAGGAAACCTCGCCCCTGGTGACACACCAGAACCCCGCTGGCCCCGTGGCCAGTGCCCCAGAACTGGAATCCAAGGAGTCGGTTCTGTGAAGAGCTTAGAGACCGACGACTGCCTAAGGACATTCGCTGAGGTGTCAATCGTCGGAGCCGCTGAGCAATAACTAGCATAACCCCTTGGGGCCTCTAAACGGGTCTTGAGGGGTTTTTTGCATGGTCATAGCTGTTTCCTGAGAGCTTGGCAGGTGATGACACACATTAACAAATTTCGTGAGGAGTCTCCAGAAGAATGCCATTAATTTCCATAGGCTCCGCCCCCCTGACGAGCATCACAAAAATCGACGCTCAAGTCAGAGGTGGCGAAACCCGACAGGACTATAAAGATACCAGGCGTTTCCCCCTGGAAGCTCCCTCGTGCGCTCTCCTGTTCCGACCCTGCCGCTTACCGGATACCTGTCCGCCTTTCTCCCTTCGGGAAGCGTGGCGCTTTCTCATAGCTCACGCTGTAGGTATCTCAGTTCGGTGTAGGTCGTTCGCTCCAAGCTGGGCTGTGTGCACGAACCCCCCGTTCAGCCCGACCGCTGCGCCTTATCCGGTAACTATCGTCTTGAGTCCAACCCGGTAAGACACGACTTATCGCCACTGGCAGCAGCCACTGGTAACAGGATTAGCAGAGCGAGGTATGTAGGCGGTGCTACAGAGTTCTTGAAGTGGTGGCCTAACTACGGCTACACTAGAAGAACAGTATTTGGTATCTGCGCTCTGCTGAAGCCAGTTACCTTCGGAAAAAGAGTTGGTAGCTCTTGATCCGGCAAACAAACCACCGCTGGTAGCGGTGGTTTTTTTGTTTGCAAGCAGCAGATTACGCGCAG
 }
+
+func ExampleGibsonAssembly() {
+	// two parts share about 20 bases of homology at each end, so they assemble
+	// into a circular plasmid
+	insert := clone.Part{"GGCTCTAGAGTCGACCTGCAATGGCTAGCAAAGGAGAAGAACTTTTCACTGGAGTTGTCCCAATTCTTGTTGAATTAGATGGTGATGTTAATGGGCACAAATTTTCTGTCAGTGGAGAGGGTGAAGGTGATGCGAATTCGAGCTCGGTACCC", false}
+	vector := clone.Part{"GCGAATTCGAGCTCGGTACCCGGGGATCCAAGCTTGGCACTGGCCGTCGTTTTACAACGTCGTGACTGGGAAAACCCTGGCGTTACCCAACTTAATCGCCTTGCAGCACATCCCCCTTTCGCCAGCTGGCGTAATAGCGAAGAGGCCCGCACCGATCGCCCTTCCCAACAGTTGCGCAGCCTGAATGGCGAATGGCTCTAGAGTCGACCTGCA", false}
+
+	assemblies, _ := clone.GibsonAssembly([]clone.Part{insert, vector})
+	for _, assembly := range assemblies {
+		fmt.Println(len(assembly.Sequence), assembly.Circular, assembly.Misassembly)
+	}
+	// Output: 324 true false
+}
+
+func ExampleDesignGibsonAssembly() {
+	promoter, _ := random.DNASequence(150, 1)
+	gene, _ := random.DNASequence(700, 2)
+	backbone, _ := random.DNASequence(2000, 3)
+	parts := []clone.NamedPart{{Name: "promoter", Sequence: promoter}, {Name: "gene", Sequence: gene}}
+
+	design, _ := clone.DesignGibsonAssembly("pExample", parts, clone.NamedPart{Name: "backbone", Sequence: backbone}, clone.DefaultGibsonOverlapConstraints, 55.0)
+	for _, fragment := range design.Fragments {
+		fmt.Println(fragment.Name, len(fragment.Product))
+	}
+	for _, feature := range design.Construct.Features {
+		fmt.Println(feature.Attributes["label"], feature.Location.Start, feature.Location.End)
+	}
+	// Output:
+	// promoter 161
+	// gene 729
+	// backbone 2020
+	// promoter 0 150
+	// gene 150 850
+	// backbone 850 2850
+}
+
+func ExampleCutWithEnzyme() {
+	enzymeMap, _ := rebase.Read("../io/rebase/data/rebase_test.txt")
+	enzymeManager := clone.NewEnzymeManager(clone.EnzymesFromRebase(enzymeMap, true))
+
+	// a double digest with a blunt cutter and an enzyme leaving 5' overhangs
+	fragments, _ := enzymeManager.CutWithEnzymeByName(clone.Part{Sequence: "AAAAAAAAAATTATAACCCCCCCCCCGGTACCTTTTTTTTTT", Circular: false}, false, "AanI", "Acc65I")
+	for _, fragment := range fragments {
+		fmt.Printf("%q %q %q %q\n", fragment.ForwardEnzyme, fragment.ForwardOverhang, fragment.Sequence, fragment.ReverseOverhang)
+	}
+	// Output:
+	// "" "" "AAAAAAAAAATTA" ""
+	// "AanI" "" "TAACCCCCCCCCCG" "GTAC"
+	// "Acc65I" "GTAC" "CTTTTTTTTTT" ""
+}
+
+func ExampleMapRestrictionSites() {
+	puc19, _ := genbank.Read("../data/puc19.gbk")
+	enzymeMap, _ := rebase.Read("../io/rebase/data/rebase_test.txt")
+	enzymeManager := clone.NewEnzymeManager(clone.EnzymesFromRebase(enzymeMap, true))
+	acc65I, _ := enzymeManager.GetEnzymeByName("Acc65I")
+	aatII, _ := enzymeManager.GetEnzymeByName("AatII")
+
+	restrictionMap := clone.MapRestrictionSites(puc19, []clone.Enzyme{acc65I, aatII})
+	for _, enzymeCuts := range restrictionMap.Enzymes {
+		for _, cutSite := range enzymeCuts.CutSites {
+			fmt.Println(enzymeCuts.Enzyme, cutSite.Position, cutSite.Features)
+		}
+	}
+	fmt.Println(restrictionMap.UniqueCutters())
+	// Output:
+	// Acc65I 671 [lacZ-alpha MCS]
+	// AatII 1152 []
+	// [Acc65I AatII]
+}
+
+func ExampleCutMethylatedWithEnzyme() {
+	enzymeMap, _ := rebase.Read("../io/rebase/data/rebase_test.txt")
+	enzymeManager := clone.NewEnzymeManager(clone.EnzymesFromRebase(enzymeMap, true))
+	acc65I, _ := enzymeManager.GetEnzymeByName("Acc65I")
+
+	// the second Acc65I site overlaps a dcm site, CCWGG
+	plasmid := clone.Part{Sequence: "TTTTTTTTTTGGTACCTTTTTTTTTTGGTACCAGGTTTTTTTTTT", Circular: true}
+	unmethylated := clone.CutWithEnzyme(plasmid, false, acc65I)
+	methylated := clone.CutMethylatedWithEnzyme(plasmid, clone.EcoliK12Methylation, false, acc65I)
+	fmt.Println(len(unmethylated), len(methylated))
+	// Output: 2 1
+}
+
+func ExampleEnzymesFromRebase() {
+	enzymeMap, _ := rebase.Read("../io/rebase/data/rebase_test.txt")
+	enzymeManager := clone.NewEnzymeManager(clone.EnzymesFromRebase(enzymeMap, true))
+
+	acc65I, _ := enzymeManager.GetEnzymeByName("Acc65I")
+	fragments := clone.CutWithEnzyme(clone.Part{Sequence: "TTTTTTTTTTGGTACCAAAAAAAAAAGGTACCTTTTTTTTTT", Circular: false}, false, acc65I)
+	for _, fragment := range fragments {
+		fmt.Println(fragment.ForwardOverhang, fragment.Sequence, fragment.ReverseOverhang)
+	}
+	// Output:
+	// TTTTTTTTTTG GTAC
+	// GTAC CAAAAAAAAAAG GTAC
+	// GTAC CTTTTTTTTTT
+}
+
+func ExampleRecombinase_Recombine() {
+	// Cre excises a stop cassette between two loxP sites
+	floxed := clone.Part{Sequence: "ATGAAA" + clone.LoxP.Sequence + "TAATAGTGA" + clone.LoxP.Sequence + "GGCTTA", Circular: false}
+	products, _ := clone.Cre.Recombine(floxed)
+	for _, product := range products {
+		fmt.Println(len(product.Sequence), product.Circular)
+	}
+	// Output:
+	// 46 false
+	// 43 true
+}
+
+func ExampleCutWithEnzymeAnnotated() {
+	puc19, _ := genbank.Read("../data/puc19.gbk")
+	enzymeMap, _ := rebase.Read("../io/rebase/data/rebase_test.txt")
+	enzymeManager := clone.NewEnzymeManager(clone.EnzymesFromRebase(enzymeMap, true))
+	aatII, _ := enzymeManager.GetEnzymeByName("AatII")
+
+	// the linearized plasmid keeps the origin of replication, which spans the
+	// origin of pUC19
+	fragment := clone.CutWithEnzymeAnnotated(puc19, false, aatII)[0]
+	for _, feature := range fragment.Features {
+		if feature.Attributes["label"] == "ori" {
+			fmt.Println(genbank.BuildLocationString(feature.Location))
+		}
+	}
+	// Output: 1167..1755
+}
+
+func ExampleGoldenGateWithFidelity() {
+	bsai, _ := clone.NewEnzymeManager(clone.GetBaseRestrictionEnzymes()).GetEnzymeByName("BsaI")
+	// the inserts join through TACT and AATC, which is a single base away
+	// from AATG, the overhang closing the backbone
+	backbone := clone.Part{Sequence: "CCGATTAGCGGCTAACGTGCCATACTAGAGACCAAAAAAAAAAAAAAAAGGTCTCAAATGGTTCAGCAGCTAGGATCGATCGTTACCG", Circular: true}
+	firstInsert := clone.Part{Sequence: "CCGGTCTCATACTATGAGCAAAGGAGAAGAACTTTTCACTGGAGTTGTCAATCAGAGACCCC", Circular: false}
+	secondInsert := clone.Part{Sequence: "CCGGTCTCAAATCCCAATTCTTGTTGAATTAGATGGTGATGTTAATGGGCACAATGAGAGACCCC", Circular: false}
+
+	assembly := clone.GoldenGateWithFidelity([]clone.Part{backbone, firstInsert, secondInsert}, bsai)
+	fmt.Printf("fidelity %.3f\n", assembly.Fidelity)
+	for _, product := range assembly.Products[1:3] {
+		fmt.Printf("%d bp, yield %.4f, misligated %v\n", len(product.Sequence), product.Yield, product.Misligations)
+	}
+	// Output:
+	// fidelity 0.991
+	// 43 bp, yield 0.0048, misligated [[AATG AATC]]
+	// 94 bp, yield 0.0041, misligated [[AATC AATG]]
+}
+
+func ExampleLibraryIterator() {
+	bsai, _ := clone.NewEnzymeManager(clone.GetBaseRestrictionEnzymes()).GetEnzymeByName("BsaI")
+	backbone := clone.Part{Sequence: "CCGATTAGCGGCTAACGTGCCATACTAGAGACCAAAAAAAAAAAAAAAAGGTCTCAAATGGTTCAGCAGCTAGGATCGATCGTTACCG", Circular: true}
+	promoters := []clone.Part{
+		{Sequence: "CCGGTCTCATACTTTGACAGCTAGCTCAGTCCTAGGTATAATGCTAGCGCTTAGAGACCCC", Circular: false},
+		{Sequence: "CCGGTCTCATACTTTTACGGCTAGCTCAGTCCTAGGTACAATGCTAGCGCTTAGAGACCCC", Circular: false},
+	}
+	genes := []clone.Part{
+		{Sequence: "CCGGTCTCAGCTTATGAGCAAAGGAGAAGAACTTTTCACTGGAGTTGTCAATGAGAGACCCC", Circular: false},
+		// this gene has an internal BsaI site
+		{Sequence: "CCGGTCTCAGCTTATGAGCAAAGGTCTCGAACTTTTCACTGGAGTTGTCAATGAGAGACCCC", Circular: false},
+	}
+
+	library := clone.NewLibraryIterator([][]clone.Part{{backbone}, promoters, genes}, clone.GoldenGateMethod(bsai))
+	for {
+		member, err := library.Next()
+		if err != nil {
+			break
+		}
+		fmt.Println(member.Variants, len(member.Products), member.OK())
+	}
+	// Output:
+	// [0 0 0] 1 true
+	// [0 0 1] 0 false
+	// [0 1 0] 1 true
+	// [0 1 1] 0 false
+}
@@ -0,0 +1,190 @@
+package clone
+
+import (
+	"sort"
+
+	"github.com/bebop/poly/seqhash"
+	"github.com/bebop/poly/synthesis/fragment"
+	"github.com/bebop/poly/transform"
+)
+
+// GoldenGateProduct is a construct of a GoldenGate assembly.
+type GoldenGateProduct struct {
+	Sequence string
+	// Yield is the expected fraction of assemblies making the construct, the
+	// product of the chance of each of its junctions, summed over the
+	// ligations making it.
+	Yield float64
+	// Misligations are the overhangs of the junctions of the construct that
+	// joined mismatched overhangs, as written on the top strand of the
+	// fragment on each side of the junction. The bases of a misligated
+	// junction are taken from the fragment after it.
+	Misligations [][2]string
+}
+
+// GoldenGateAssembly is the expected outcome of a GoldenGate assembly.
+type GoldenGateAssembly struct {
+	// Products are the constructs with a yield of at least
+	// GoldenGateMinYield, from the highest to the lowest yield.
+	Products []GoldenGateProduct
+	// Fidelity is the expected fraction of correct assemblies, the total yield
+	// of the products without misligations.
+	Fidelity float64
+}
+
+// fidelityEnd is an end a fragment can ligate to, the forward end of a
+// fragment or, for flipped fragments, their reverse end.
+type fidelityEnd struct {
+	fragment int
+	flipped  bool
+	overhang string
+}
+
+// GoldenGateMinYield is the lowest yield of the products of a GoldenGate
+// assembly. Since the chances of an end to ligate to each other end add up to
+// one, the yields of the paths of ligated fragments of each length starting
+// from a fragment add up to at most one too, so at most 1/GoldenGateMinYield
+// of them are followed.
+const GoldenGateMinYield = 1e-4
+
+// GoldenGateWithFidelity simulates a GoldenGate cloning reaction like
+// GoldenGate, returning the expected yield of each construct and the fidelity
+// of the assembly according to the ligation fidelity data of
+// synthesis/fragment, including constructs from misligation of mismatched
+// overhangs. It is slower than GoldenGate, so only use it when yields matter.
+//
+// Each end ligates to the ends of the other fragments, or to its own
+// fragment, in proportion to how often their overhangs ligated in the data.
+// Each fragment is used at most once in a construct, and constructs with a
+// yield under GoldenGateMinYield are left out. Overhangs missing from the
+// data, like overhangs that aren't 4 bases long, only ligate to matching
+// overhangs.
+func GoldenGateWithFidelity(sequences []Part, cuttingEnzyme Enzyme) GoldenGateAssembly {
+	var fragments []Fragment
+	for _, sequence := range sequences {
+		fragments = append(fragments, CutWithEnzyme(sequence, true, cuttingEnzyme)...)
+	}
+
+	// the ends fragments can ligate to, fragments being oriented so that
+	// their reverse end ligates to the forward end of the next one
+	var ends []fidelityEnd
+	for index, fragment := range fragments {
+		ends = append(ends, fidelityEnd{index, false, fragment.ForwardOverhang}, fidelityEnd{index, true, transform.ReverseComplement(fragment.ReverseOverhang)})
+	}
+	// the chances of the last end of each fragment, flipped or not, to
+	// ligate to each of the ends
+	chances := make([][]float64, len(ends))
+	for index, end := range ends {
+		overhang := fragments[end.fragment].ReverseOverhang
+		if end.flipped {
+			overhang = transform.ReverseComplement(fragments[end.fragment].ForwardOverhang)
+		}
+		chances[index] = ligationChances(overhang, ends)
+	}
+
+	products := make(map[string]*GoldenGateProduct)
+	var seqhashes []string
+	for index := range fragments {
+		recurseFidelity([]int{2 * index}, 1, fragments, ends, chances, products, &seqhashes)
+	}
+
+	assembly := GoldenGateAssembly{}
+	for _, hash := range seqhashes {
+		if products[hash].Yield >= GoldenGateMinYield {
+			assembly.Products = append(assembly.Products, *products[hash])
+		}
+	}
+	sort.SliceStable(assembly.Products, func(i, j int) bool {
+		return assembly.Products[i].Yield > assembly.Products[j].Yield
+	})
+	for _, product := range assembly.Products {
+		if len(product.Misligations) == 0 {
+			assembly.Fidelity += product.Yield
+		}
+	}
+	return assembly
+}
+
+// recurseFidelity extends a path of ligated fragments, by the indexes of
+// their ends, with every end the last fragment can ligate to, closing the
+// path into a construct when it ligates back to the first fragment.
+//
+// A circular ligation of n fragments is found n times, once from each of its
+// fragments, so each path adds a nth of its yield to the yield of its
+// construct. Different ligations making the same construct add up.
+func recurseFidelity(path []int, yield float64, fragments []Fragment, ends []fidelityEnd, chances [][]float64, products map[string]*GoldenGateProduct, seqhashes *[]string) {
+	for endIndex, end := range ends {
+		chance := chances[path[len(path)-1]][endIndex]
+		nextYield := yield * chance
+		if chance == 0 || nextYield < GoldenGateMinYield {
+			continue
+		}
+		if endIndex == path[0] {
+			product := closeFidelityPath(path, fragments, ends)
+			hash, _ := seqhash.Hash(product.Sequence, "DNA", true, true)
+			if _, ok := products[hash]; !ok {
+				products[hash] = &product
+				*seqhashes = append(*seqhashes, hash)
+			}
+			products[hash].Yield += nextYield / float64(len(path))
+			continue
+		}
+		used := false
+		for _, pathEnd := range path {
+			used = used || ends[pathEnd].fragment == end.fragment
+		}
+		if used {
+			continue
+		}
+		recurseFidelity(append(path[:len(path):len(path)], endIndex), nextYield, fragments, ends, chances, products, seqhashes)
+	}
+}
+
+// ligationChances returns the chance of an end with an overhang to ligate to
+// each of the ends.
+func ligationChances(overhang string, ends []fidelityEnd) []float64 {
+	chances := make([]float64, len(ends))
+	total := 0.0
+	for index, end := range ends {
+		chances[index] = float64(fragment.LigationCount(overhang, end.overhang))
+		total += chances[index]
+	}
+	// overhangs missing from the data only ligate to matching overhangs
+	if total == 0 {
+		for index, end := range ends {
+			if end.overhang == overhang {
+				chances[index] = 1
+				total++
+			}
+		}
+	}
+	if total == 0 {
+		return chances
+	}
+	for index := range chances {
+		chances[index] /= total
+	}
+	return chances
+}
+
+// closeFidelityPath returns the construct of a path of ligated fragments,
+// without its yield.
+func closeFidelityPath(path []int, fragments []Fragment, ends []fidelityEnd) GoldenGateProduct {
+	var product GoldenGateProduct
+	var construct string
+	for index, endIndex := range path {
+		end := ends[endIndex]
+		fragment := fragments[end.fragment]
+		forwardOverhang, sequence, reverseOverhang := fragment.ForwardOverhang, fragment.Sequence, fragment.ReverseOverhang
+		if end.flipped {
+			forwardOverhang, sequence, reverseOverhang = transform.ReverseComplement(reverseOverhang), transform.ReverseComplement(sequence), transform.ReverseComplement(forwardOverhang)
+		}
+		construct += forwardOverhang + sequence
+		next := ends[path[(index+1)%len(path)]]
+		if next.overhang != reverseOverhang {
+			product.Misligations = append(product.Misligations, [2]string{reverseOverhang, next.overhang})
+		}
+	}
+	product.Sequence = construct
+	return product
+}
@@ -0,0 +1,97 @@
+package clone
+
+import (
+	"math"
+	"testing"
+
+	"github.com/bebop/poly/seqhash"
+	"github.com/bebop/poly/synthesis/fragment"
+)
+
+// fidelityParts returns a backbone and two inserts assembled by BsaI through
+// the given overhangs.
+func fidelityParts(backboneOverhang, firstOverhang, secondOverhang string) []Part {
+	return []Part{
+		{"CCGATTAGCGGCTAACGTGCCA" + firstOverhang + "AGAGACCAAAAAAAAAAAAAAAAGGTCTCA" + backboneOverhang + "GTTCAGCAGCTAGGATCGATCGTTACCG", true},
+		{"CCGGTCTCA" + firstOverhang + "ATGAGCAAAGGAGAAGAACTTTTCACTGGAGTTGTC" + secondOverhang + "AGAGACCCC", false},
+		{"CCGGTCTCA" + secondOverhang + "CCAATTCTTGTTGAATTAGATGGTGATGTTAATGGGCAC" + backboneOverhang + "AGAGACCCC", false},
+	}
+}
+
+func TestGoldenGateWithFidelity(t *testing.T) {
+	bsai := GetBaseRestrictionEnzymes()[0]
+	parts := fidelityParts("AATG", "TACT", "GCTT")
+	assembly := GoldenGateWithFidelity(parts, bsai)
+	if len(assembly.Products) == 0 {
+		t.Fatal("expected products")
+	}
+
+	// the most likely product is the construct GoldenGate makes
+	constructs, _ := GoldenGate(parts, bsai)
+	if len(constructs) != 1 {
+		t.Fatalf("expected a single GoldenGate construct, got %d", len(constructs))
+	}
+	expected, _ := seqhash.Hash(constructs[0], "DNA", true, true)
+	best := assembly.Products[0]
+	if hash, _ := seqhash.Hash(best.Sequence, "DNA", true, true); hash != expected || len(best.Misligations) != 0 {
+		t.Errorf("expected the GoldenGate construct to be the most likely product, got %+v", best)
+	}
+
+	// its yield is the fidelity of the overhang set
+	efficiency := fragment.SetEfficiency([]string{"AATG", "TACT", "GCTT"})
+	if math.Abs(best.Yield-efficiency) > 1e-9 || math.Abs(assembly.Fidelity-efficiency) > 1e-9 {
+		t.Errorf("expected a yield and fidelity of %f, got %f and %f", efficiency, best.Yield, assembly.Fidelity)
+	}
+	for index, product := range assembly.Products[1:] {
+		if product.Yield > assembly.Products[index].Yield {
+			t.Errorf("products aren't sorted by yield")
+		}
+		if len(product.Misligations) == 0 {
+			t.Errorf("unexpected second product without misligations %+v", product)
+		}
+	}
+}
+
+func TestGoldenGateWithFidelityMisligation(t *testing.T) {
+	bsai := GetBaseRestrictionEnzymes()[0]
+	// AATG and AATC differ by a single base, so the backbone misligates to
+	// the end of the first insert, skipping the second one
+	assembly := GoldenGateWithFidelity(fidelityParts("AATG", "TACT", "AATC"), bsai)
+	var misligated *GoldenGateProduct
+	for index, product := range assembly.Products {
+		if len(product.Misligations) == 1 && product.Misligations[0] == [2]string{"AATC", "AATG"} {
+			misligated = &assembly.Products[index]
+		}
+	}
+	if misligated == nil {
+		t.Fatalf("expected a product misligating AATC to AATG, got %+v", assembly.Products)
+	}
+	if misligated.Yield <= 0 || misligated.Yield >= assembly.Fidelity {
+		t.Errorf("unexpected misligation yield %f for a fidelity of %f", misligated.Yield, assembly.Fidelity)
+	}
+	if distinct := GoldenGateWithFidelity(fidelityParts("AATG", "TACT", "GCTT"), bsai); assembly.Fidelity >= distinct.Fidelity {
+		t.Errorf("expected similar overhangs to lower the fidelity")
+	}
+}
+
+func TestGoldenGateWithFidelitySum(t *testing.T) {
+	bsai := GetBaseRestrictionEnzymes()[0]
+	parts := fidelityParts("AATG", "TACT", "GCTT")
+	assembly := GoldenGateWithFidelity(parts, bsai)
+
+	// with two copies of the first insert, either ligates into the construct,
+	// so the yields of both ligations add up to the yield of the construct
+	duplicated := GoldenGateWithFidelity(append(parts, parts[1]), bsai)
+	if math.Abs(duplicated.Products[0].Yield-assembly.Products[0].Yield) > 0.01 {
+		t.Errorf("expected a yield of %f with a duplicated insert, got %f", assembly.Products[0].Yield, duplicated.Products[0].Yield)
+	}
+
+	// the yields of all the constructs can't add up to more than one
+	total := 0.0
+	for _, product := range duplicated.Products {
+		total += product.Yield
+	}
+	if total > 1 {
+		t.Errorf("expected yields adding up to at most 1, got %f", total)
+	}
+}
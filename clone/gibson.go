@@ -0,0 +1,257 @@
+package clone
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bebop/poly/checks"
+	"github.com/bebop/poly/fold"
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/primers"
+	"github.com/bebop/poly/primers/pcr"
+	"github.com/bebop/poly/seqhash"
+	"github.com/bebop/poly/transform"
+)
+
+// gibsonTemperature is the temperature of a Gibson assembly reaction, at
+// which overlaps must not fold on themselves, in Celsius.
+const gibsonTemperature = 50.0
+
+// OverlapConstraints are the constraints on the overlaps designed to join the
+// parts of a homology based assembly. Every bound must be set, the zero value
+// rejecting every overlap, so start from DefaultGibsonOverlapConstraints.
+type OverlapConstraints struct {
+	MinLength int
+	MaxLength int
+	MinTm     float64
+	MaxTm     float64
+	MinGC     float64 // fraction of G and C bases, from 0 to 1
+	MaxGC     float64
+	// MinHairpinDeltaG is the lowest free energy, in kcal/mol, allowed for an
+	// overlap folded on itself at the temperature of a Gibson assembly.
+	MinHairpinDeltaG float64
+}
+
+// DefaultGibsonOverlapConstraints are overlap constraints suited to Gibson
+// assembly, following the NEBuilder recommendations.
+var DefaultGibsonOverlapConstraints = OverlapConstraints{
+	MinLength:        20,
+	MaxLength:        40,
+	MinTm:            GibsonMinOverlapTm,
+	MaxTm:            70,
+	MinGC:            0.4,
+	MaxGC:            0.6,
+	MinHairpinDeltaG: -3,
+}
+
+// NamedPart is a named linear DNA part.
+type NamedPart struct {
+	Name     string
+	Sequence string
+}
+
+// GibsonFragment is a part of a designed Gibson assembly, amplified by PCR
+// with primers adding homology arms to its ends.
+type GibsonFragment struct {
+	Name          string
+	Template      string
+	ForwardPrimer string
+	ReversePrimer string
+	// Product is the amplified part with its homology arms.
+	Product string
+}
+
+// GibsonDesign is a designed Gibson assembly.
+type GibsonDesign struct {
+	Fragments []GibsonFragment
+	// Junctions[i] joins Fragments[i] to the next fragment, the last one
+	// joining the backbone back to the first part.
+	Junctions []Junction
+	// Construct is the assembled plasmid, with a feature for each part.
+	Construct genbank.Genbank
+	// Misassemblies are the other products a simulated assembly of the
+	// fragments gives, from off target overlaps.
+	Misassemblies []Assembly
+}
+
+// DesignGibsonAssembly designs a Gibson assembly inserting parts, in order,
+// into a backbone. The parts go between the end and the start of the backbone
+// sequence, so a circular backbone must be rotated so that its sequence starts
+// right after the insertion site.
+//
+// Each junction gets the shortest overlap meeting the constraints, made of
+// the end of the part before it and the start of the part after it, as evenly
+// as possible. The overlap must not be found anywhere else in the construct.
+// Each part, including the backbone, is amplified with primers melting at
+// primerTm, designed by pcr.DesignPrimersWithOverhangs, that add the overlaps
+// as homology arms.
+func DesignGibsonAssembly(name string, parts []NamedPart, backbone NamedPart, constraints OverlapConstraints, primerTm float64) (GibsonDesign, error) {
+	if len(parts) == 0 {
+		return GibsonDesign{}, fmt.Errorf("gibson design: no parts to assemble")
+	}
+	if err := constraints.validate(); err != nil {
+		return GibsonDesign{}, fmt.Errorf("gibson design: %w", err)
+	}
+
+	fragments := append(append([]NamedPart{}, parts...), backbone)
+	var construct strings.Builder
+	for index := range fragments {
+		fragments[index].Sequence = strings.ToUpper(fragments[index].Sequence)
+		sequence := fragments[index].Sequence
+		if !canDesignPrimers(sequence, primerTm) {
+			return GibsonDesign{}, fmt.Errorf("gibson design: can't design primers melting at %.1fC for part %q", primerTm, fragments[index].Name)
+		}
+		construct.WriteString(sequence)
+	}
+	constructSequence := construct.String()
+
+	// overlaps[i] is made of leftLengths[i] bases from the end of fragment i
+	// and the rest from the start of the next fragment
+	design := GibsonDesign{}
+	leftLengths := make([]int, len(fragments))
+	for index, left := range fragments {
+		right := fragments[(index+1)%len(fragments)]
+		junction, leftLength, err := designOverlap(left.Sequence, right.Sequence, constructSequence, constraints)
+		if err != nil {
+			return GibsonDesign{}, fmt.Errorf("gibson design: junction between %q and %q: %w", left.Name, right.Name, err)
+		}
+		design.Junctions = append(design.Junctions, junction)
+		leftLengths[index] = leftLength
+	}
+
+	var products []Part
+	for index, fragment := range fragments {
+		previous := (index + len(fragments) - 1) % len(fragments)
+		// the forward primer adds the bases of the previous fragment in the
+		// overlap before it, and the reverse primer the bases of the next
+		// fragment in the overlap after it
+		forwardOverhang := fragments[previous].Sequence[len(fragments[previous].Sequence)-leftLengths[previous]:]
+		reverseOverhang := design.Junctions[index].Overlap[leftLengths[index]:]
+		forwardPrimer, reversePrimer := pcr.DesignPrimersWithOverhangs(fragment.Sequence, forwardOverhang, reverseOverhang, primerTm)
+		product := forwardOverhang + fragment.Sequence + reverseOverhang
+		design.Fragments = append(design.Fragments, GibsonFragment{
+			Name:          fragment.Name,
+			Template:      fragment.Sequence,
+			ForwardPrimer: forwardPrimer,
+			ReversePrimer: reversePrimer,
+			Product:       product,
+		})
+		products = append(products, Part{product, false})
+	}
+
+	design.Construct = genbank.Genbank{
+		Meta: genbank.Meta{
+			Name: name,
+			Locus: genbank.Locus{
+				Name:           name,
+				SequenceLength: strconv.Itoa(len(constructSequence)),
+				MoleculeType:   "DNA",
+				Circular:       true,
+			},
+		},
+		Sequence: strings.ToLower(constructSequence),
+	}
+	start := 0
+	for _, fragment := range fragments {
+		feature := genbank.Feature{
+			Type:       "misc_feature",
+			Attributes: map[string]string{"label": fragment.Name},
+			Location:   genbank.Location{Start: start, End: start + len(fragment.Sequence)},
+		}
+		_ = design.Construct.AddFeature(&feature)
+		start += len(fragment.Sequence)
+	}
+
+	// simulate the assembly to find what else the overlaps could make
+	assemblies, err := HomologyAssembly(products, constraints.MinLength, constraints.MinTm)
+	if err != nil {
+		return GibsonDesign{}, fmt.Errorf("gibson design: %w", err)
+	}
+	expected, _ := seqhash.Hash(constructSequence, "DNA", true, true)
+	for _, assembly := range assemblies {
+		hash, _ := seqhash.Hash(assembly.Sequence, "DNA", assembly.Circular, true)
+		if hash != expected {
+			design.Misassemblies = append(design.Misassemblies, assembly)
+		}
+	}
+	return design, nil
+}
+
+// validate returns an error if no overlap can meet the constraints, like the
+// unset bounds of the zero value.
+func (constraints OverlapConstraints) validate() error {
+	switch {
+	case constraints.MinLength < 1 || constraints.MaxLength < constraints.MinLength:
+		return fmt.Errorf("invalid overlap lengths from %d to %d", constraints.MinLength, constraints.MaxLength)
+	case constraints.MaxTm <= 0 || constraints.MaxTm < constraints.MinTm:
+		return fmt.Errorf("invalid overlap melting temperatures from %.1fC to %.1fC", constraints.MinTm, constraints.MaxTm)
+	case constraints.MaxGC <= 0 || constraints.MaxGC < constraints.MinGC:
+		return fmt.Errorf("invalid overlap GC contents from %.2f to %.2f", constraints.MinGC, constraints.MaxGC)
+	}
+	return nil
+}
+
+// designOverlap returns the shortest overlap joining left to right that meets
+// the constraints and is found only once in the construct, along with the
+// number of its bases taken from left.
+func designOverlap(left, right, construct string, constraints OverlapConstraints) (Junction, int, error) {
+	for length := constraints.MinLength; length <= constraints.MaxLength; length++ {
+		// try the most even splits between left and right first, shifting
+		// the split alternately towards left and right
+		for shift := 0; shift <= length; shift++ {
+			leftLength := length/2 + shift/2
+			if shift%2 == 1 {
+				leftLength = length/2 - (shift+1)/2
+			}
+			if leftLength < 0 || leftLength > length || leftLength > len(left) || length-leftLength > len(right) {
+				continue
+			}
+			overlap := left[len(left)-leftLength:] + right[:length-leftLength]
+			meltingTemp := primers.MeltingTemp(overlap)
+			if meltingTemp < constraints.MinTm || meltingTemp > constraints.MaxTm {
+				continue
+			}
+			if gc := checks.GcContent(overlap); gc < constraints.MinGC || gc > constraints.MaxGC {
+				continue
+			}
+			if strings.Count(construct+construct[:length-1], overlap)+strings.Count(construct+construct[:length-1], transform.ReverseComplement(overlap)) != 1 {
+				continue
+			}
+			if hairpinDeltaG(overlap) < constraints.MinHairpinDeltaG {
+				continue
+			}
+			return Junction{Overlap: overlap, MeltingTemp: meltingTemp}, leftLength, nil
+		}
+	}
+	return Junction{}, 0, fmt.Errorf("no overlap from %d to %d bases meets the constraints", constraints.MinLength, constraints.MaxLength)
+}
+
+// hairpinDeltaG returns the minimum free energy of a sequence folded on
+// itself at the temperature of a Gibson assembly, or 0 if it doesn't fold.
+func hairpinDeltaG(sequence string) float64 {
+	result, err := fold.Zuker(sequence, gibsonTemperature)
+	if err != nil {
+		return 0
+	}
+	deltaG := result.MinimumFreeEnergy()
+	if math.IsInf(deltaG, 0) || deltaG > 0 {
+		return 0
+	}
+	return deltaG
+}
+
+// canDesignPrimers returns whether pcr.DesignPrimersWithOverhangs can design
+// primers melting at targetTm on both ends of a sequence.
+func canDesignPrimers(sequence string, targetTm float64) bool {
+	forward, reverse := false, false
+	for length := pcr.MinimalPrimerLength; length <= len(sequence); length++ {
+		forward = forward || primers.MeltingTemp(sequence[:length]) >= targetTm
+		reverse = reverse || primers.MeltingTemp(transform.ReverseComplement(sequence[len(sequence)-length:])) >= targetTm
+		if forward && reverse {
+			return true
+		}
+	}
+	return false
+}
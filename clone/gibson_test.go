@@ -0,0 +1,104 @@
+package clone
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/random"
+	"github.com/bebop/poly/seqhash"
+	"github.com/bebop/poly/transform"
+)
+
+func TestDesignGibsonAssembly(t *testing.T) {
+	promoter, _ := random.DNASequence(120, 10)
+	gene, _ := random.DNASequence(600, 11)
+	terminator, _ := random.DNASequence(90, 12)
+	backbone, _ := random.DNASequence(1500, 13)
+	parts := []NamedPart{{"promoter", promoter}, {"gene", gene}, {"terminator", terminator}}
+
+	design, err := DesignGibsonAssembly("pTest", parts, NamedPart{"backbone", backbone}, DefaultGibsonOverlapConstraints, 55.0)
+	if err != nil {
+		t.Fatalf("DesignGibsonAssembly failed: %s", err)
+	}
+	if len(design.Fragments) != 4 || len(design.Junctions) != 4 {
+		t.Fatalf("Expected 4 fragments and 4 junctions, got %d and %d", len(design.Fragments), len(design.Junctions))
+	}
+	for _, junction := range design.Junctions {
+		constraints := DefaultGibsonOverlapConstraints
+		if len(junction.Overlap) < constraints.MinLength || len(junction.Overlap) > constraints.MaxLength || junction.MeltingTemp < constraints.MinTm || junction.MeltingTemp > constraints.MaxTm {
+			t.Errorf("Overlap doesn't meet the constraints: %+v", junction)
+		}
+	}
+
+	// the primers amplify the fragments with their homology arms
+	for _, fragment := range design.Fragments {
+		if !strings.HasPrefix(fragment.Product, fragment.ForwardPrimer) || !strings.HasSuffix(fragment.Product, transform.ReverseComplement(fragment.ReversePrimer)) || !strings.Contains(fragment.Product, fragment.Template) {
+			t.Errorf("Primers of %s don't amplify its product", fragment.Name)
+		}
+	}
+
+	// and the products assemble into the construct
+	var products []Part
+	for _, fragment := range design.Fragments {
+		products = append(products, Part{fragment.Product, false})
+	}
+	assemblies, err := GibsonAssembly(products)
+	if err != nil {
+		t.Fatalf("GibsonAssembly failed: %s", err)
+	}
+	expected, _ := seqhash.Hash(promoter+gene+terminator+backbone, "DNA", true, true)
+	found := false
+	for _, assembly := range assemblies {
+		if hash, _ := seqhash.Hash(assembly.Sequence, "DNA", true, true); assembly.Circular && hash == expected {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Fragments don't assemble into the expected construct")
+	}
+	if len(design.Misassemblies) != 0 {
+		t.Errorf("Expected no mis-assemblies, got %d", len(design.Misassemblies))
+	}
+
+	// every part is annotated on the construct
+	construct := design.Construct
+	if !construct.Meta.Locus.Circular || !strings.EqualFold(construct.Sequence, promoter+gene+terminator+backbone) {
+		t.Errorf("Construct should be the circular concatenation of the parts")
+	}
+	if len(construct.Features) != 4 {
+		t.Fatalf("Expected 4 features, got %d", len(construct.Features))
+	}
+	for index, part := range append(parts, NamedPart{"backbone", backbone}) {
+		feature := construct.Features[index]
+		sequence, _ := feature.GetSequence()
+		if feature.Attributes["label"] != part.Name || !strings.EqualFold(sequence, part.Sequence) {
+			t.Errorf("Feature %d should be %s", index, part.Name)
+		}
+	}
+	if _, err := genbank.Build(construct); err != nil {
+		t.Errorf("Construct should build into a genbank file: %s", err)
+	}
+}
+
+func TestDesignGibsonAssemblyErrors(t *testing.T) {
+	backbone, _ := random.DNASequence(1000, 14)
+	gene, _ := random.DNASequence(500, 15)
+	if _, err := DesignGibsonAssembly("pTest", nil, NamedPart{"backbone", backbone}, DefaultGibsonOverlapConstraints, 55.0); err == nil {
+		t.Errorf("DesignGibsonAssembly should fail without parts")
+	}
+	if _, err := DesignGibsonAssembly("pTest", []NamedPart{{"short", "ATGC"}}, NamedPart{"backbone", backbone}, DefaultGibsonOverlapConstraints, 55.0); err == nil {
+		t.Errorf("DesignGibsonAssembly should fail on parts too short for primers")
+	}
+
+	// no overlap can be 100% GC
+	constraints := DefaultGibsonOverlapConstraints
+	constraints.MinGC, constraints.MaxGC = 1, 1
+	if _, err := DesignGibsonAssembly("pTest", []NamedPart{{"gene", gene}}, NamedPart{"backbone", backbone}, constraints, 55.0); err == nil {
+		t.Errorf("DesignGibsonAssembly should fail when no overlap meets the constraints")
+	}
+	// the zero value leaves the bounds unset
+	if _, err := DesignGibsonAssembly("pTest", []NamedPart{{"gene", gene}}, NamedPart{"backbone", backbone}, OverlapConstraints{MinLength: 20, MaxLength: 40}, 55.0); err == nil || !strings.Contains(err.Error(), "melting temperatures") {
+		t.Errorf("DesignGibsonAssembly should reject unset melting temperatures, got %v", err)
+	}
+}
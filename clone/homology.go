@@ -0,0 +1,310 @@
+package clone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bebop/poly/primers"
+	"github.com/bebop/poly/seqhash"
+	"github.com/bebop/poly/transform"
+)
+
+/******************************************************************************
+
+Homology based cloning functions begin here.
+
+Gibson assembly and In-Fusion join linear DNA parts whose ends share homology.
+An exonuclease chews back one strand of each end, the single stranded ends
+anneal to each other where they are homologous, and the gaps are repaired. If
+the homology at the end of one part is also found inside another part, the
+ends can anneal there instead and produce a mis-assembly.
+
+Gibson assembly:
+https://doi.org/10.1038/nmeth.1318
+
+In-Fusion:
+https://www.takarabio.com/learning-centers/cloning/in-fusion-cloning-general-information
+
+******************************************************************************/
+
+// Default overlap constraints of homology based assembly reactions. NEBuilder
+// recommends overlaps of at least 15 bases melting at 48C or higher for
+// Gibson assembly, while In-Fusion only requires 15 bases of homology.
+const (
+	GibsonMinOverlapLength   = 15
+	GibsonMinOverlapTm       = 48.0
+	InFusionMinOverlapLength = 15
+)
+
+// AssembledPart is an input part of a homology based assembly, as it appears
+// in an assembled product.
+type AssembledPart struct {
+	Index             int  // index of the part in the assembly inputs
+	ReverseComplement bool // whether the part was assembled in reverse
+}
+
+// Junction is the homologous overlap where two parts were joined.
+type Junction struct {
+	Overlap     string
+	MeltingTemp float64
+	// OffTarget is true if the overlap is not at the very end of both parts,
+	// so that the bases of either part beyond it are lost.
+	OffTarget bool
+}
+
+// Assembly is a product of a homology based assembly reaction. Junctions[i]
+// joins Parts[i] to the next part. In circular products the last junction
+// joins the last part back to the first.
+type Assembly struct {
+	Sequence  string
+	Circular  bool
+	Parts     []AssembledPart
+	Junctions []Junction
+	// Misassembly is true if any junction is off target.
+	Misassembly bool
+}
+
+// homologyJunction joins the bases of one oriented part before leftEnd to
+// the bases of another from rightStart on.
+type homologyJunction struct {
+	to         int
+	leftEnd    int
+	rightStart int
+	junction   Junction
+}
+
+// GibsonAssembly simulates a Gibson assembly of linear parts using the
+// default Gibson overlap constraints. See HomologyAssembly for details.
+func GibsonAssembly(parts []Part) ([]Assembly, error) {
+	return HomologyAssembly(parts, GibsonMinOverlapLength, GibsonMinOverlapTm)
+}
+
+// InFusionAssembly simulates an In-Fusion assembly of linear parts using the
+// default In-Fusion overlap constraints. See HomologyAssembly for details.
+func InFusionAssembly(parts []Part) ([]Assembly, error) {
+	return HomologyAssembly(parts, InFusionMinOverlapLength, 0)
+}
+
+// HomologyAssembly simulates all products of a homology based assembly of
+// linear parts, in either orientation, joined by overlaps of at least
+// minOverlapLength bases that melt at minOverlapTm or higher.
+//
+// Circular products are every way of closing parts into a loop, each part
+// used at most once. Linear products are the longest chains starting at a part
+// whose end has no homology to any other part. Products are deduplicated by
+// their seqhash. Junctions found away from the ends of the parts, from off
+// target homology, are flagged along with the products that use them.
+//
+// The distance exonucleases chew back is not modeled, so off target homology
+// anywhere within a part is considered.
+func HomologyAssembly(parts []Part, minOverlapLength int, minOverlapTm float64) ([]Assembly, error) {
+	if minOverlapLength < 1 {
+		return nil, fmt.Errorf("homology assembly: minimum overlap length must be positive, got %d", minOverlapLength)
+	}
+
+	// every part can be assembled forward or in reverse. Oriented part
+	// 2*index is forward and 2*index+1 is the reverse complement.
+	orientedSequences := make([]string, 2*len(parts))
+	for index, part := range parts {
+		if part.Circular {
+			return nil, fmt.Errorf("homology assembly: part %d is circular, only linear parts can be assembled", index)
+		}
+		if len(part.Sequence) == 0 {
+			return nil, fmt.Errorf("homology assembly: part %d is empty", index)
+		}
+		sequence := strings.ToUpper(part.Sequence)
+		orientedSequences[2*index] = sequence
+		orientedSequences[2*index+1] = transform.ReverseComplement(sequence)
+	}
+
+	junctions := make([][]homologyJunction, len(orientedSequences))
+	hasIncoming := make([]bool, len(orientedSequences))
+	for from := range orientedSequences {
+		for to := range orientedSequences {
+			// a part can't join its own reverse complement
+			if from != to && from/2 == to/2 {
+				continue
+			}
+			for _, junction := range findJunctions(orientedSequences[from], orientedSequences[to], minOverlapLength, minOverlapTm) {
+				junction.to = to
+				junctions[from] = append(junctions[from], junction)
+				hasIncoming[to] = true
+			}
+		}
+	}
+
+	var assemblies []Assembly
+	existingSeqhashes := make(map[string]struct{})
+	for seed, sequence := range orientedSequences {
+		path := []assemblyStep{{part: seed, start: 0, end: len(sequence)}}
+		assemblies = append(assemblies, recurseAssemble(path, junctions, orientedSequences, !hasIncoming[seed], existingSeqhashes)...)
+	}
+	return assemblies, nil
+}
+
+// assemblyStep is an oriented part in a chain of joined parts. The bases of
+// the part from start to end are in the chain, and junction joins it to the
+// previous part.
+type assemblyStep struct {
+	part     int
+	start    int
+	end      int
+	junction Junction
+}
+
+// recurseAssemble extends a chain of joined parts by every junction leaving
+// its last part, and returns the circular products closing it and, if linear
+// is true, the linear product of every chain that can't be extended.
+func recurseAssemble(path []assemblyStep, junctions [][]homologyJunction, orientedSequences []string, linear bool, existingSeqhashes map[string]struct{}) []Assembly {
+	var assemblies []Assembly
+	last := len(path) - 1
+	extended := false
+	for _, next := range junctions[path[last].part] {
+		if next.leftEnd <= path[last].start {
+			continue
+		}
+		if next.to == path[0].part {
+			// the chain closes back on its first part, which now starts
+			// where it is joined
+			closed := append([]assemblyStep{}, path...)
+			closed[last].end = next.leftEnd
+			closed[0].start = next.rightStart
+			closed[0].junction = next.junction
+			if closed[0].start >= closed[0].end {
+				continue
+			}
+			if assembly, ok := buildAssembly(closed, true, orientedSequences, existingSeqhashes); ok {
+				assemblies = append(assemblies, assembly)
+			}
+			continue
+		}
+		used := false
+		for _, step := range path {
+			if step.part/2 == next.to/2 {
+				used = true
+				break
+			}
+		}
+		if used {
+			continue
+		}
+		extended = true
+		extendedPath := append(append([]assemblyStep{}, path...), assemblyStep{part: next.to, start: next.rightStart, end: len(orientedSequences[next.to]), junction: next.junction})
+		extendedPath[last].end = next.leftEnd
+		assemblies = append(assemblies, recurseAssemble(extendedPath, junctions, orientedSequences, linear, existingSeqhashes)...)
+	}
+	if linear && !extended && len(path) > 1 {
+		if assembly, ok := buildAssembly(path, false, orientedSequences, existingSeqhashes); ok {
+			assemblies = append(assemblies, assembly)
+		}
+	}
+	return assemblies
+}
+
+// buildAssembly joins a chain of parts into an assembly, unless an equal
+// assembly was already built.
+func buildAssembly(path []assemblyStep, circular bool, orientedSequences []string, existingSeqhashes map[string]struct{}) (Assembly, bool) {
+	var sequence strings.Builder
+	assembly := Assembly{Circular: circular}
+	for index, step := range path {
+		// the overlap of each junction is kept in the part before it, which ends
+		// at leftEnd, the part after it starting at rightStart, past the overlap
+		sequence.WriteString(orientedSequences[step.part][step.start:step.end])
+		assembly.Parts = append(assembly.Parts, AssembledPart{Index: step.part / 2, ReverseComplement: step.part%2 == 1})
+		if index > 0 {
+			assembly.Junctions = append(assembly.Junctions, step.junction)
+		}
+	}
+	if circular {
+		assembly.Junctions = append(assembly.Junctions, path[0].junction)
+	}
+	for _, junction := range assembly.Junctions {
+		if junction.OffTarget {
+			assembly.Misassembly = true
+		}
+	}
+
+	assembly.Sequence = sequence.String()
+	hash, _ := seqhash.Hash(assembly.Sequence, "DNA", circular, true)
+	if _, ok := existingSeqhashes[hash]; ok {
+		return Assembly{}, false
+	}
+	existingSeqhashes[hash] = struct{}{}
+	return assembly, true
+}
+
+// findJunctions returns all the ways the end of left can anneal to right.
+//
+// The intended junction is the longest overlap between the end of left and
+// the start of right. Off target junctions are where the last
+// minOverlapLength bases of left are found inside right, or the first
+// minOverlapLength bases of right are found inside left.
+func findJunctions(left, right string, minOverlapLength int, minOverlapTm float64) []homologyJunction {
+	var junctions []homologyJunction
+	addJunction := func(leftEnd, rightStart, overlapLength int, offTarget bool) {
+		overlap := right[rightStart-overlapLength : rightStart]
+		meltingTemp := primers.MeltingTemp(overlap)
+		if meltingTemp < minOverlapTm {
+			return
+		}
+		junctions = append(junctions, homologyJunction{leftEnd: leftEnd, rightStart: rightStart, junction: Junction{Overlap: overlap, MeltingTemp: meltingTemp, OffTarget: offTarget}})
+	}
+
+	// parts must have bases left beyond the overlap
+	for length := min(len(left), len(right)) - 1; length >= minOverlapLength; length-- {
+		if left[len(left)-length:] == right[:length] {
+			addJunction(len(left), length, length, false)
+			break
+		}
+	}
+
+	if len(left) < minOverlapLength || len(right) < minOverlapLength {
+		return junctions
+	}
+
+	// the end of left inside right. The match is extended towards the start
+	// of right as far as the homology goes.
+	leftTail := left[len(left)-minOverlapLength:]
+	for offset := 0; ; offset++ {
+		position := strings.Index(right[offset:], leftTail)
+		if position < 0 {
+			break
+		}
+		position += offset
+		offset = position
+		rightStart := position + minOverlapLength
+		length := minOverlapLength
+		for length < len(left) && length < rightStart && left[len(left)-length-1] == right[rightStart-length-1] {
+			length++
+		}
+		if length == rightStart {
+			// homology reaching the start of right is part of the intended
+			// junction
+			continue
+		}
+		addJunction(len(left), rightStart, length, true)
+	}
+
+	// the start of right inside left. The match is extended towards the end
+	// of left as far as the homology goes.
+	rightHead := right[:minOverlapLength]
+	for offset := 0; ; offset++ {
+		position := strings.Index(left[offset:], rightHead)
+		if position < 0 {
+			break
+		}
+		position += offset
+		offset = position
+		length := minOverlapLength
+		for position+length < len(left) && length < len(right) && left[position+length] == right[length] {
+			length++
+		}
+		if position+length == len(left) {
+			// homology reaching the end of left is part of the intended
+			// junction, or an off target one found above
+			continue
+		}
+		addJunction(position+length, length, length, true)
+	}
+	return junctions
+}
@@ -0,0 +1,161 @@
+package clone
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/random"
+	"github.com/bebop/poly/seqhash"
+	"github.com/bebop/poly/transform"
+)
+
+// gibsonParts splits plasmid into parts whose neighbors share overlapLength
+// bases, with the last part overlapping the first.
+func gibsonParts(plasmid string, cuts []int, overlapLength int) []Part {
+	var parts []Part
+	for index, cut := range cuts {
+		end := len(plasmid)
+		if index+1 < len(cuts) {
+			end = cuts[index+1]
+		}
+		sequence := plasmid[cut:end]
+		if index+1 < len(cuts) {
+			sequence += plasmid[end : end+overlapLength]
+		} else {
+			sequence += plasmid[:overlapLength]
+		}
+		parts = append(parts, Part{sequence, false})
+	}
+	return parts
+}
+
+func TestGibsonAssembly(t *testing.T) {
+	plasmid, _ := random.DNASequence(900, 1)
+	parts := gibsonParts(plasmid, []int{0, 300, 600}, 25)
+	// the middle part is ordered in reverse, which shouldn't matter
+	parts[1].Sequence = transform.ReverseComplement(parts[1].Sequence)
+
+	assemblies, err := GibsonAssembly(parts)
+	if err != nil {
+		t.Fatalf("GibsonAssembly failed: %s", err)
+	}
+	if len(assemblies) != 1 {
+		t.Fatalf("Expected 1 assembly, got %d", len(assemblies))
+	}
+	assembly := assemblies[0]
+	if !assembly.Circular || assembly.Misassembly {
+		t.Errorf("Expected a circular assembly without mis-assemblies, got circular %t and misassembly %t", assembly.Circular, assembly.Misassembly)
+	}
+	expected, _ := seqhash.Hash(plasmid, "DNA", true, true)
+	got, _ := seqhash.Hash(assembly.Sequence, "DNA", true, true)
+	if got != expected {
+		t.Errorf("Assembled plasmid of length %d doesn't match the original of length %d", len(assembly.Sequence), len(plasmid))
+	}
+	if len(assembly.Parts) != 3 || len(assembly.Junctions) != 3 {
+		t.Fatalf("Expected 3 parts and 3 junctions, got %d and %d", len(assembly.Parts), len(assembly.Junctions))
+	}
+	for _, part := range assembly.Parts {
+		if part.ReverseComplement != (part.Index == 1) {
+			t.Errorf("Only part 1 should be assembled in reverse, got %+v", assembly.Parts)
+		}
+	}
+	for _, junction := range assembly.Junctions {
+		if len(junction.Overlap) != 25 || junction.MeltingTemp < GibsonMinOverlapTm {
+			t.Errorf("Expected 25 base overlaps melting above %f, got %+v", GibsonMinOverlapTm, junction)
+		}
+	}
+}
+
+func TestGibsonAssemblyLinear(t *testing.T) {
+	plasmid, _ := random.DNASequence(900, 2)
+	parts := gibsonParts(plasmid, []int{0, 300, 600}, 25)
+	// without the overlap back to the first part, the product is linear
+	parts[2].Sequence = parts[2].Sequence[:len(parts[2].Sequence)-25]
+
+	assemblies, err := GibsonAssembly(parts)
+	if err != nil {
+		t.Fatalf("GibsonAssembly failed: %s", err)
+	}
+	if len(assemblies) != 1 {
+		t.Fatalf("Expected 1 assembly, got %d", len(assemblies))
+	}
+	if assemblies[0].Circular {
+		t.Errorf("Expected a linear assembly")
+	}
+	if assemblies[0].Sequence != plasmid && assemblies[0].Sequence != transform.ReverseComplement(plasmid) {
+		t.Errorf("Linear assembly doesn't match the original sequence")
+	}
+}
+
+func TestGibsonAssemblyMisassembly(t *testing.T) {
+	plasmid, _ := random.DNASequence(900, 3)
+	parts := gibsonParts(plasmid, []int{0, 300, 600}, 25)
+	// the overlap between the first two parts is repeated in the middle of
+	// the last one, where both of them can anneal too
+	overlap := parts[0].Sequence[len(parts[0].Sequence)-25:]
+	parts[2].Sequence = parts[2].Sequence[:100] + overlap + parts[2].Sequence[100:]
+
+	assemblies, err := GibsonAssembly(parts)
+	if err != nil {
+		t.Fatalf("GibsonAssembly failed: %s", err)
+	}
+	var intended, misassembled int
+	for _, assembly := range assemblies {
+		if assembly.Misassembly {
+			misassembled++
+			found := false
+			for _, junction := range assembly.Junctions {
+				if junction.OffTarget && strings.Contains(junction.Overlap, overlap) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("Mis-assembly should have an off target junction with the repeated overlap, got %+v", assembly.Junctions)
+			}
+		} else if assembly.Circular {
+			intended++
+		}
+	}
+	if intended != 1 || misassembled == 0 {
+		t.Errorf("Expected the intended assembly and some mis-assemblies, got %d and %d", intended, misassembled)
+	}
+}
+
+func TestInFusionAssembly(t *testing.T) {
+	// In-Fusion only needs 15 bases of homology, regardless of melting
+	// temperature
+	plasmid, _ := random.DNASequence(600, 4)
+	parts := gibsonParts(plasmid, []int{0, 300}, 15)
+	parts[0].Sequence = parts[0].Sequence[:len(parts[0].Sequence)-15] + "ATATATATATATATA"
+	parts[1].Sequence = "ATATATATATATATA" + parts[1].Sequence[15:]
+
+	assemblies, err := InFusionAssembly(parts)
+	if err != nil {
+		t.Fatalf("InFusionAssembly failed: %s", err)
+	}
+	if len(assemblies) != 1 || !assemblies[0].Circular {
+		t.Errorf("Expected 1 circular assembly, got %+v", assemblies)
+	}
+
+	// the AT rich overlap melts too low for Gibson assembly, leaving only a
+	// linear product
+	assemblies, err = GibsonAssembly(parts)
+	if err != nil {
+		t.Fatalf("GibsonAssembly failed: %s", err)
+	}
+	if len(assemblies) != 1 || assemblies[0].Circular {
+		t.Errorf("Expected 1 linear assembly, got %+v", assemblies)
+	}
+}
+
+func TestHomologyAssemblyErrors(t *testing.T) {
+	if _, err := HomologyAssembly([]Part{{"ATGC", true}}, 15, 0); err == nil {
+		t.Errorf("HomologyAssembly should fail on circular parts")
+	}
+	if _, err := HomologyAssembly([]Part{{"", false}}, 15, 0); err == nil {
+		t.Errorf("HomologyAssembly should fail on empty parts")
+	}
+	if _, err := HomologyAssembly([]Part{{"ATGC", false}}, 0, 0); err == nil {
+		t.Errorf("HomologyAssembly should fail without a minimum overlap length")
+	}
+}
@@ -0,0 +1,175 @@
+package clone
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bebop/poly/seqhash"
+)
+
+/******************************************************************************
+
+Combinatorial library functions begin here.
+
+Combinatorial libraries assemble one variant part of each slot, like a
+promoter, an RBS, a CDS and a terminator, in every possible combination. They
+grow quickly, so the combinations are assembled one at a time as they are
+asked for.
+
+******************************************************************************/
+
+// AssemblyMethod is a way of assembling parts into constructs.
+type AssemblyMethod struct {
+	Name string
+	// Assemble returns the constructs made by assembling parts, and the
+	// infinitely looping constructs, like GoldenGate.
+	Assemble func(parts []Part) (constructs []string, infiniteLoops []string)
+	// Enzymes are enzymes whose sites constructs shouldn't contain, like the
+	// enzyme of a GoldenGate assembly or the enzymes of later assemblies.
+	Enzymes []Enzyme
+}
+
+// GoldenGateMethod returns the assembly method of GoldenGate with an enzyme,
+// checking constructs for sites of that enzyme and of any other enzymes.
+func GoldenGateMethod(cuttingEnzyme Enzyme, otherEnzymes ...Enzyme) AssemblyMethod {
+	return AssemblyMethod{
+		Name: "GoldenGate " + cuttingEnzyme.Name,
+		Assemble: func(parts []Part) ([]string, []string) {
+			return GoldenGate(parts, cuttingEnzyme)
+		},
+		Enzymes: append([]Enzyme{cuttingEnzyme}, otherEnzymes...),
+	}
+}
+
+// LibraryProduct is a construct of a member of a combinatorial library.
+type LibraryProduct struct {
+	Sequence string
+	Seqhash  string
+	// InternalSites are the names of the enzymes of the assembly method with
+	// sites in the construct.
+	InternalSites []string
+	// CollidesWith are the variants of the first member that made the same
+	// construct, if it isn't this member.
+	CollidesWith []int
+}
+
+// LibraryMember is a combination of variant parts of a combinatorial library
+// and the constructs they assemble into.
+type LibraryMember struct {
+	// Variants are the indexes of the variant part of each slot.
+	Variants      []int
+	Products      []LibraryProduct
+	InfiniteLoops []string
+}
+
+// OK returns whether a member assembles into a single construct, without
+// sites of the enzymes of the assembly method, that no other member made.
+func (member LibraryMember) OK() bool {
+	if len(member.Products) != 1 || len(member.InfiniteLoops) != 0 {
+		return false
+	}
+	product := member.Products[0]
+	return len(product.InternalSites) == 0 && product.CollidesWith == nil
+}
+
+// LibraryIterator enumerates the members of a combinatorial library.
+type LibraryIterator struct {
+	slots     [][]Part
+	method    AssemblyMethod
+	variants  []int
+	done      bool
+	seqhashes map[string][]int
+}
+
+// NewLibraryIterator returns an iterator over the members of a combinatorial
+// library assembling a variant part of each slot with an assembly method,
+// every variant of the last slot being enumerated before moving to the next
+// variant of the slot before it. Slots of a single part, like a backbone, are
+// in every member.
+func NewLibraryIterator(slots [][]Part, method AssemblyMethod) *LibraryIterator {
+	return &LibraryIterator{
+		slots:     slots,
+		method:    method,
+		variants:  make([]int, len(slots)),
+		seqhashes: make(map[string][]int),
+	}
+}
+
+// Size returns the number of members of the library.
+func (iterator *LibraryIterator) Size() int {
+	if len(iterator.slots) == 0 {
+		return 0
+	}
+	size := 1
+	for _, slot := range iterator.slots {
+		size *= len(slot)
+	}
+	return size
+}
+
+// Next assembles the next member of the library. It returns io.EOF once all
+// members were enumerated, and an error for libraries without any member.
+func (iterator *LibraryIterator) Next() (LibraryMember, error) {
+	if iterator.done {
+		return LibraryMember{}, io.EOF
+	}
+	if iterator.Size() == 0 {
+		iterator.done = true
+		return LibraryMember{}, errors.New("library has no members: it needs at least one slot and one part in each slot")
+	}
+
+	member := LibraryMember{Variants: make([]int, len(iterator.variants))}
+	copy(member.Variants, iterator.variants)
+	parts := make([]Part, len(iterator.slots))
+	for slot, variant := range member.Variants {
+		parts[slot] = iterator.slots[slot][variant]
+	}
+	iterator.advance()
+
+	constructs, infiniteLoops := iterator.method.Assemble(parts)
+	member.InfiniteLoops = infiniteLoops
+	for _, construct := range constructs {
+		hash, err := seqhash.Hash(construct, "DNA", true, true)
+		if err != nil {
+			return LibraryMember{}, fmt.Errorf("variants %v: %w", member.Variants, err)
+		}
+		product := LibraryProduct{Sequence: construct, Seqhash: hash}
+		for _, enzyme := range iterator.method.Enzymes {
+			if hasCircularSite(construct, enzyme) {
+				product.InternalSites = append(product.InternalSites, enzyme.Name)
+			}
+		}
+		if variants, ok := iterator.seqhashes[hash]; ok {
+			product.CollidesWith = variants
+		} else {
+			iterator.seqhashes[hash] = member.Variants
+		}
+		member.Products = append(member.Products, product)
+	}
+	return member, nil
+}
+
+// advance moves the iterator to the next combination of variants.
+func (iterator *LibraryIterator) advance() {
+	for slot := len(iterator.variants) - 1; slot >= 0; slot-- {
+		iterator.variants[slot]++
+		if iterator.variants[slot] < len(iterator.slots[slot]) {
+			return
+		}
+		iterator.variants[slot] = 0
+	}
+	iterator.done = true
+}
+
+// hasCircularSite returns whether a circular sequence contains a site of an
+// enzyme on either strand, including across its origin.
+func hasCircularSite(sequence string, enzyme Enzyme) bool {
+	sequence = strings.ToUpper(sequence)
+	wrap := min(len(enzyme.RecognitionSite)-1, len(sequence))
+	if wrap > 0 {
+		sequence += sequence[:wrap]
+	}
+	return enzyme.RegexpFor.MatchString(sequence) || enzyme.RegexpRev.MatchString(sequence)
+}
@@ -0,0 +1,69 @@
+package clone
+
+import (
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestLibraryIterator(t *testing.T) {
+	enzymes := GetBaseRestrictionEnzymes()
+	bsai, bbsi := enzymes[0], enzymes[1]
+	parts := fidelityParts("AATG", "TACT", "GCTT")
+	withBbsI := Part{"CCGGTCTCATACTATGAGCAAAGAAGACCTTTTCACTGGAGTTGTCGCTTAGAGACCCC", false}
+	wrongOverhang := Part{"CCGGTCTCATACTATGAGCAAAGGAGAAGAACTTTTCACTGGAGTTGTCGGGGAGAGACCCC", false}
+	slots := [][]Part{{parts[0]}, {parts[1], withBbsI, wrongOverhang}, {parts[2], parts[2]}}
+
+	iterator := NewLibraryIterator(slots, GoldenGateMethod(bsai, bbsi))
+	if iterator.Size() != 6 {
+		t.Errorf("expected 6 members, got %d", iterator.Size())
+	}
+	var members []LibraryMember
+	for {
+		member, err := iterator.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		members = append(members, member)
+	}
+	if len(members) != 6 {
+		t.Fatalf("expected 6 members, got %d", len(members))
+	}
+
+	expectedVariants := [][]int{{0, 0, 0}, {0, 0, 1}, {0, 1, 0}, {0, 1, 1}, {0, 2, 0}, {0, 2, 1}}
+	for index, member := range members {
+		if !reflect.DeepEqual(member.Variants, expectedVariants[index]) {
+			t.Errorf("member %d: expected variants %v, got %v", index, expectedVariants[index], member.Variants)
+		}
+	}
+
+	if !members[0].OK() || len(members[0].Products) != 1 {
+		t.Fatalf("expected the first member to make a single construct, got %+v", members[0])
+	}
+	constructs, _ := GoldenGate([]Part{parts[0], parts[1], parts[2]}, bsai)
+	if members[0].Products[0].Sequence != constructs[0] || members[0].Products[0].Seqhash == "" {
+		t.Errorf("unexpected product %+v", members[0].Products[0])
+	}
+
+	// identical variants make identical constructs
+	if members[1].OK() || !reflect.DeepEqual(members[1].Products[0].CollidesWith, []int{0, 0, 0}) || members[1].Products[0].Seqhash != members[0].Products[0].Seqhash {
+		t.Errorf("expected a collision with the first member, got %+v", members[1].Products)
+	}
+
+	// the BbsI site of a variant ends up in its constructs
+	if members[2].OK() || !reflect.DeepEqual(members[2].Products[0].InternalSites, []string{"BbsI"}) {
+		t.Errorf("expected a BbsI site, got %+v", members[2].Products)
+	}
+
+	// a variant with the wrong overhang doesn't assemble
+	if members[4].OK() || len(members[4].Products) != 0 {
+		t.Errorf("expected no products, got %+v", members[4].Products)
+	}
+
+	if _, err := NewLibraryIterator([][]Part{{parts[0]}, {}}, GoldenGateMethod(bsai)).Next(); err == nil || err == io.EOF {
+		t.Errorf("expected an error for a library with an empty slot, got %v", err)
+	}
+}
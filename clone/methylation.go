@@ -0,0 +1,131 @@
+package clone
+
+import (
+	"github.com/bebop/poly/transform"
+)
+
+// Methylation is a base methylation made by a DNA methyltransferase of the
+// host a sequence was grown in.
+type Methylation struct {
+	Name string
+	// Site is the IUPAC recognition sequence of the methyltransferase.
+	// Position is the index in Site of the base methylated on the top strand,
+	// and ComplementPosition the index of the base paired with the base
+	// methylated on the bottom strand.
+	Site               string
+	Position           int
+	ComplementPosition int
+}
+
+// Common methylations of cloning hosts.
+var (
+	// Dam methylates the adenine of GATC.
+	Dam = Methylation{Name: "dam", Site: "GATC", Position: 1, ComplementPosition: 2}
+	// Dcm methylates the inner cytosine of CCWGG.
+	Dcm = Methylation{Name: "dcm", Site: "CCWGG", Position: 1, ComplementPosition: 3}
+	// CpG methylates the cytosine of CG in mammalian cells, or by M.SssI.
+	CpG = Methylation{Name: "CpG", Site: "CG", Position: 0, ComplementPosition: 1}
+	// EcoKI methylates the adenines of AACNNNNNNGTGC in E. coli K-12.
+	EcoKI = Methylation{Name: "EcoKI", Site: "AACNNNNNNGTGC", Position: 1, ComplementPosition: 10}
+)
+
+// EcoliK12Methylation are the methylations of common E. coli K-12 cloning
+// strains, like DH5alpha or TOP10.
+var EcoliK12Methylation = []Methylation{Dam, Dcm, EcoKI}
+
+// methylationSensitivity are the methylations blocking common enzymes when
+// they overlap their recognition site, from NEB. REBASE doesn't record these.
+var methylationSensitivity = map[string][]string{
+	"AatII":    {"CpG"},
+	"Acc65I":   {"dcm", "CpG"},
+	"ApaI":     {"dcm", "CpG"},
+	"AscI":     {"CpG"},
+	"AvaII":    {"dcm", "CpG"},
+	"BclI":     {"dam"},
+	"BsaBI":    {"dam", "CpG"},
+	"BspDI":    {"dam", "CpG"},
+	"BstUI":    {"CpG"},
+	"ClaI":     {"dam", "CpG"},
+	"DpnII":    {"dam"},
+	"EagI":     {"CpG"},
+	"EcoO109I": {"dcm"},
+	"HhaI":     {"CpG"},
+	"HpaII":    {"CpG"},
+	"MboI":     {"dam"},
+	"MluI":     {"CpG"},
+	"NarI":     {"CpG"},
+	"NotI":     {"CpG"},
+	"NruI":     {"dam", "CpG"},
+	"PflMI":    {"dcm"},
+	"PspGI":    {"dcm"},
+	"PvuI":     {"CpG"},
+	"SacII":    {"CpG"},
+	"SalI":     {"CpG"},
+	"SexAI":    {"dcm"},
+	"SmaI":     {"CpG"},
+	"StuI":     {"dcm"},
+	"XbaI":     {"dam"},
+	"XhoI":     {"CpG"},
+}
+
+// CutMethylatedWithEnzyme cuts a sequence grown in a host with the given
+// methylations, like CutWithEnzyme. Enzymes don't cut sites overlapping a
+// base methylated by one of the methylations in their BlockedBy list.
+func CutMethylatedWithEnzyme(part Part, host []Methylation, directional bool, enzymes ...Enzyme) []Fragment {
+	return fragmentsOf(cutWithEnzyme(part, host, directional, enzymes))
+}
+
+// methylatedBases returns which bases of a sequence are methylated by each
+// methylation, by name. Sequences are upper case, and circular sequences are
+// searched across their origin.
+func methylatedBases(sequence string, circular bool, host []Methylation) map[string][]bool {
+	methylated := make(map[string][]bool)
+	length := len(sequence)
+	searchSequence := sequence
+	if circular {
+		searchSequence = sequence + sequence
+	}
+	for _, methylation := range host {
+		bases := methylated[methylation.Name]
+		if bases == nil {
+			bases = make([]bool, length)
+			methylated[methylation.Name] = bases
+		}
+		siteLength := len(methylation.Site)
+		forward, err := iupacToRegexp(methylation.Site)
+		if err != nil {
+			continue
+		}
+		reverse, _ := iupacToRegexp(transform.ReverseComplement(methylation.Site))
+
+		// sites can overlap each other, like CGCG, so every start is tried
+		for start := 0; start < length && start+siteLength <= len(searchSequence); start++ {
+			window := searchSequence[start : start+siteLength]
+			if forward.MatchString(window) {
+				bases[(start+methylation.Position)%length] = true
+				bases[(start+methylation.ComplementPosition)%length] = true
+			}
+			if reverse.MatchString(window) {
+				bases[(start+siteLength-1-methylation.Position)%length] = true
+				bases[(start+siteLength-1-methylation.ComplementPosition)%length] = true
+			}
+		}
+	}
+	return methylated
+}
+
+// blocked returns whether a recognition site is blocked by methylation.
+func blocked(enzymeCut cut, blockedBy []string, methylated map[string][]bool) bool {
+	for _, name := range blockedBy {
+		bases := methylated[name]
+		if bases == nil {
+			continue
+		}
+		for position := enzymeCut.siteStart; position < enzymeCut.siteEnd; position++ {
+			if bases[((position%len(bases))+len(bases))%len(bases)] {
+				return true
+			}
+		}
+	}
+	return false
+}
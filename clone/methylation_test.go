@@ -0,0 +1,64 @@
+package clone
+
+import (
+	"testing"
+)
+
+func TestCutMethylatedWithEnzyme(t *testing.T) {
+	enzymes := rebaseEnzymes(t, "XbaI", "T^CTAGA", "Acc65I", "G^GTACC", "KpnI", "GGTAC^C", "SmaI", "CCC^GGG")
+	xbai, acc65i, kpni, smai := enzymes[0], enzymes[1], enzymes[2], enzymes[3]
+	flank := "AAAAAAAAAAAAAAAAAAAA"
+
+	tests := []struct {
+		name     string
+		sequence string
+		host     []Methylation
+		enzyme   Enzyme
+		cuts     int
+	}{
+		{"XbaI without methylation", flank + "TCTAGATC" + flank, nil, xbai, 1},
+		{"XbaI overlapping dam", flank + "TCTAGATC" + flank, EcoliK12Methylation, xbai, 0},
+		{"XbaI next to dam", flank + "TCTAGAAGATC" + flank, EcoliK12Methylation, xbai, 1},
+		{"Acc65I overlapping dcm", flank + "GGTACCAGG" + flank, EcoliK12Methylation, acc65i, 0},
+		{"KpnI isn't dcm sensitive", flank + "GGTACCAGG" + flank, EcoliK12Methylation, kpni, 1},
+		{"SmaI in E. coli", flank + "CCCGGG" + flank, EcoliK12Methylation, smai, 1},
+		{"SmaI with CpG methylation", flank + "CCCGGG" + flank, []Methylation{CpG}, smai, 0},
+	}
+	for _, test := range tests {
+		fragments := CutMethylatedWithEnzyme(Part{test.sequence, false}, test.host, false, test.enzyme)
+		if cuts := len(fragments) - 1; cuts != test.cuts && !(cuts == -1 && test.cuts == 0) {
+			t.Errorf("%s: expected %d cuts, got %d fragments", test.name, test.cuts, len(fragments))
+		}
+	}
+
+	// dam methylation across the origin of a plasmid blocks XbaI too
+	plasmid := Part{"TC" + flank + "TCTAGAG" + flank + "TCTAGA", true}
+	if fragments := CutMethylatedWithEnzyme(plasmid, []Methylation{Dam}, false, xbai); len(fragments) != 1 {
+		t.Errorf("Expected a single cut in the plasmid, got %d fragments", len(fragments))
+	}
+	if fragments := CutWithEnzyme(plasmid, false, xbai); len(fragments) != 2 {
+		t.Errorf("Expected two cuts without methylation, got %d fragments", len(fragments))
+	}
+}
+
+func TestMethylatedBases(t *testing.T) {
+	// EcoKI methylates an adenine on each strand of its site, whichever
+	// strand the site is on
+	sequence := "AACGGGGGGGTGCTTGCACCCCCCCGTTT"
+	bases := methylatedBases(sequence, false, []Methylation{EcoKI})["EcoKI"]
+	var methylated []int
+	for position, isMethylated := range bases {
+		if isMethylated {
+			methylated = append(methylated, position)
+		}
+	}
+	expected := []int{1, 10, 17, 26}
+	if len(methylated) != len(expected) {
+		t.Fatalf("Expected methylated bases %v, got %v", expected, methylated)
+	}
+	for index := range expected {
+		if methylated[index] != expected[index] || (sequence[expected[index]] != 'A' && sequence[expected[index]] != 'T') {
+			t.Errorf("Expected methylated bases %v, got %v", expected, methylated)
+		}
+	}
+}
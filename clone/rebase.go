@@ -0,0 +1,154 @@
+package clone
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bebop/poly/io/rebase"
+	"github.com/bebop/poly/transform"
+)
+
+// iupacRegexp are the regular expressions matching each IUPAC base code.
+var iupacRegexp = map[rune]string{
+	'A': "A",
+	'C': "C",
+	'G': "G",
+	'T': "T",
+	'R': "[AG]",
+	'Y': "[CT]",
+	'M': "[AC]",
+	'K': "[GT]",
+	'S': "[CG]",
+	'W': "[AT]",
+	'B': "[CGT]",
+	'D': "[AGT]",
+	'H': "[ACT]",
+	'V': "[ACG]",
+	'N': "[ACGT]",
+}
+
+// rebaseCutRegex matches REBASE recognition sequences with cut positions in
+// parentheses, like GGTCTC(1/5).
+var rebaseCutRegex = regexp.MustCompile(`^([A-Z]+)\((-?\d+)/(-?\d+)\)$`)
+
+// rebaseBothSidesCutRegex matches REBASE recognition sequences of enzymes
+// cutting on both sides of their site, like (10/15)ACNNNNGTAYC(12/7).
+var rebaseBothSidesCutRegex = regexp.MustCompile(`^\((-?\d+)/(-?\d+)\)([A-Z]+)\((-?\d+)/(-?\d+)\)$`)
+
+// EnzymeFromRebase converts a REBASE enzyme into an Enzyme that can cut
+// sequences.
+//
+// REBASE recognition sequences mark where enzymes cut either with a caret in
+// the site, like G^AATTC, in which case the other strand is cut
+// symmetrically, or with the cut positions on each strand after the site in
+// parentheses, like GGTCTC(1/5). Enzymes cutting on both sides of their site
+// also have the cut positions on each strand before the site, like
+// (10/15)ACNNNNGTAYC(12/7). IUPAC degenerate bases in the site are matched by
+// any of the bases they stand for.
+//
+// REBASE doesn't record which host methylations block enzymes, so BlockedBy is
+// filled in for common enzymes sensitive to dam, dcm or CpG methylation.
+//
+// Enzymes whose cut positions are unknown can't be converted.
+func EnzymeFromRebase(enzyme rebase.Enzyme) (Enzyme, error) {
+	recognitionSequence := strings.ToUpper(strings.TrimSpace(enzyme.RecognitionSequence))
+
+	// topCut and bottomCut are the positions of the cuts on each strand,
+	// relative to the end of the site on the top strand
+	var site string
+	var topCut, bottomCut int
+	// upstreamTopCut and upstreamBottomCut are the positions of the cuts
+	// before the site, relative to its start, of enzymes cutting both sides
+	var upstreamTopCut, upstreamBottomCut int
+	cutsBothSides := false
+	if match := rebaseBothSidesCutRegex.FindStringSubmatch(recognitionSequence); match != nil {
+		cutsBothSides = true
+		upstreamTopCut, _ = strconv.Atoi(match[1])
+		upstreamBottomCut, _ = strconv.Atoi(match[2])
+		site = match[3]
+		topCut, _ = strconv.Atoi(match[4])
+		bottomCut, _ = strconv.Atoi(match[5])
+	} else if match := rebaseCutRegex.FindStringSubmatch(recognitionSequence); match != nil {
+		site = match[1]
+		topCut, _ = strconv.Atoi(match[2])
+		bottomCut, _ = strconv.Atoi(match[3])
+	} else if caret := strings.Index(recognitionSequence, "^"); caret >= 0 && strings.Count(recognitionSequence, "^") == 1 {
+		site = strings.Replace(recognitionSequence, "^", "", 1)
+		topCut = caret - len(site)
+		bottomCut = -caret
+	} else {
+		return Enzyme{}, fmt.Errorf("enzyme %s has no known cut position in its recognition sequence %q", enzyme.Name, recognitionSequence)
+	}
+
+	regexpFor, err := iupacToRegexp(site)
+	if err != nil {
+		return Enzyme{}, fmt.Errorf("enzyme %s: %w", enzyme.Name, err)
+	}
+	regexpRev, _ := iupacToRegexp(transform.ReverseComplement(site))
+
+	// the overhang lies between the two cuts, and is a 3' overhang if the top
+	// strand is cut after the bottom strand
+	return Enzyme{
+		Name:                   enzyme.Name,
+		RegexpFor:              regexpFor,
+		RegexpRev:              regexpRev,
+		Skip:                   min(topCut, bottomCut),
+		OverheadLength:         abs(topCut - bottomCut),
+		RecognitionSite:        site,
+		ThreePrimeOverhang:     topCut > bottomCut,
+		CutsBothSides:          cutsBothSides,
+		UpstreamSkip:           min(upstreamTopCut, upstreamBottomCut),
+		UpstreamOverheadLength: abs(upstreamTopCut - upstreamBottomCut),
+		BlockedBy:              methylationSensitivity[enzyme.Name],
+	}, nil
+}
+
+// abs returns the absolute value of an integer.
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// EnzymesFromRebase converts REBASE enzymes into Enzymes, sorted by name,
+// for use in an EnzymeManager. If commercialOnly is true, only enzymes that
+// are commercially available are converted. Enzymes that can't be converted,
+// see EnzymeFromRebase, are left out.
+func EnzymesFromRebase(enzymeMap map[string]rebase.Enzyme, commercialOnly bool) []Enzyme {
+	var enzymes []Enzyme
+	for _, rebaseEnzyme := range enzymeMap {
+		if commercialOnly && len(rebaseEnzyme.CommercialAvailability) == 0 {
+			continue
+		}
+		enzyme, err := EnzymeFromRebase(rebaseEnzyme)
+		if err != nil {
+			continue
+		}
+		enzymes = append(enzymes, enzyme)
+	}
+	sort.Slice(enzymes, func(i, j int) bool {
+		return enzymes[i].Name < enzymes[j].Name
+	})
+	return enzymes
+}
+
+// iupacToRegexp compiles a recognition site with IUPAC degenerate bases into
+// a regular expression matching it.
+func iupacToRegexp(site string) (*regexp.Regexp, error) {
+	if len(site) == 0 {
+		return nil, fmt.Errorf("empty recognition site")
+	}
+	var expression strings.Builder
+	for _, base := range site {
+		baseExpression, ok := iupacRegexp[base]
+		if !ok {
+			return nil, fmt.Errorf("invalid base %q in recognition site %s", base, site)
+		}
+		expression.WriteString(baseExpression)
+	}
+	return regexp.Compile(expression.String())
+}
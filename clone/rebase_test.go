@@ -0,0 +1,132 @@
+package clone
+
+import (
+	"testing"
+
+	"github.com/bebop/poly/io/rebase"
+)
+
+func TestEnzymeFromRebase(t *testing.T) {
+	tests := []struct {
+		recognitionSequence string
+		site                string
+		skip                int
+		overhangLength      int
+		threePrime          bool
+	}{
+		{"GGTCTC(1/5)", "GGTCTC", 1, 4, false}, // BsaI, 5' overhang after the site
+		{"G^AATTC", "GAATTC", -5, 4, false},    // EcoRI, 5' overhang within the site
+		{"GGTAC^C", "GGTACC", -5, 4, true},     // KpnI, 3' overhang within the site
+		{"CAC^GTG", "CACGTG", -3, 0, false},    // PmlI, blunt
+		{"CCGC(-3/-1)", "CCGC", -3, 2, false},  // AciI, cut within the site
+		{"GACNNNN^NNGTC", "GACNNNNNNGTC", -7, 2, true},
+	}
+	for _, test := range tests {
+		enzyme, err := EnzymeFromRebase(rebase.Enzyme{Name: "test", RecognitionSequence: test.recognitionSequence})
+		if err != nil {
+			t.Errorf("EnzymeFromRebase(%s) failed: %s", test.recognitionSequence, err)
+			continue
+		}
+		if enzyme.RecognitionSite != test.site || enzyme.Skip != test.skip || enzyme.OverheadLength != test.overhangLength || enzyme.ThreePrimeOverhang != test.threePrime {
+			t.Errorf("EnzymeFromRebase(%s) got site %s, skip %d, overhang length %d and 3' overhang %t, expected %s, %d, %d and %t", test.recognitionSequence, enzyme.RecognitionSite, enzyme.Skip, enzyme.OverheadLength, enzyme.ThreePrimeOverhang, test.site, test.skip, test.overhangLength, test.threePrime)
+		}
+	}
+
+	// BaeI cuts on both sides of its site, leaving 3' overhangs
+	baei, err := EnzymeFromRebase(rebase.Enzyme{Name: "BaeI", RecognitionSequence: "(10/15)ACNNNNGTAYC(12/7)"})
+	if err != nil {
+		t.Fatalf("EnzymeFromRebase failed on BaeI: %s", err)
+	}
+	if !baei.CutsBothSides || baei.UpstreamSkip != 10 || baei.UpstreamOverheadLength != 5 || baei.Skip != 7 || baei.OverheadLength != 5 || !baei.ThreePrimeOverhang {
+		t.Errorf("Unexpected BaeI %+v", baei)
+	}
+
+	for _, recognitionSequence := range []string{"?", "ACCGAG", "(8/13)GACNNNNNNTGG", "GGXTC(1/5)"} {
+		if _, err := EnzymeFromRebase(rebase.Enzyme{Name: "test", RecognitionSequence: recognitionSequence}); err == nil {
+			t.Errorf("EnzymeFromRebase(%s) should have failed", recognitionSequence)
+		}
+	}
+}
+
+func TestEnzymeFromRebaseDegenerate(t *testing.T) {
+	enzyme, err := EnzymeFromRebase(rebase.Enzyme{Name: "BstNI", RecognitionSequence: "CC^WGG"})
+	if err != nil {
+		t.Fatalf("EnzymeFromRebase failed: %s", err)
+	}
+	for _, site := range []string{"CCAGG", "CCTGG"} {
+		if !enzyme.RegexpFor.MatchString(site) {
+			t.Errorf("BstNI should match %s", site)
+		}
+	}
+	if enzyme.RegexpFor.MatchString("CCGGG") {
+		t.Errorf("BstNI shouldn't match CCGGG")
+	}
+
+	// non palindromic sites are matched on the reverse strand too
+	enzyme, _ = EnzymeFromRebase(rebase.Enzyme{Name: "BsmAI", RecognitionSequence: "GTCTCN(1/5)"})
+	if !enzyme.RegexpRev.MatchString("AGAGAC") || enzyme.RegexpRev.MatchString("GAGACA") {
+		t.Errorf("BsmAI reverse regexp should match NGAGAC, got %s", enzyme.RegexpRev)
+	}
+}
+
+func TestEnzymeFromRebaseCut(t *testing.T) {
+	// a BsaI converted from REBASE cuts like the built in BsaI
+	bsai, err := EnzymeFromRebase(rebase.Enzyme{Name: "BsaI", RecognitionSequence: "GGTCTC(1/5)"})
+	if err != nil {
+		t.Fatalf("EnzymeFromRebase failed: %s", err)
+	}
+	enzymeManager := NewEnzymeManager(GetBaseRestrictionEnzymes())
+	builtInBsai, _ := enzymeManager.GetEnzymeByName("BsaI")
+	expected := CutWithEnzyme(popen, true, builtInBsai)
+	got := CutWithEnzyme(popen, true, bsai)
+	if len(got) != len(expected) {
+		t.Fatalf("Expected %d fragments, got %d", len(expected), len(got))
+	}
+	for index := range got {
+		if got[index] != expected[index] {
+			t.Errorf("Fragment %d differs from the built in BsaI", index)
+		}
+	}
+
+	// EcoRI leaves AATT overhangs
+	ecori, _ := EnzymeFromRebase(rebase.Enzyme{Name: "EcoRI", RecognitionSequence: "G^AATTC"})
+	fragments := CutWithEnzyme(Part{"ATATATATATATATGAATTCGCGCGCGCGCGCGCGAATTCTATATATATATATA", false}, false, ecori)
+	expectedFragment := Fragment{Sequence: "CGCGCGCGCGCGCGCG", ForwardOverhang: "AATT", ReverseOverhang: "AATT", ForwardEnzyme: "EcoRI", ReverseEnzyme: "EcoRI"}
+	found := false
+	for _, fragment := range fragments {
+		found = found || fragment == expectedFragment
+	}
+	if !found {
+		t.Errorf("Expected %+v, got %+v", expectedFragment, fragments)
+	}
+
+	// cuts before the site off the start of the sequence are ignored
+	acii, _ := EnzymeFromRebase(rebase.Enzyme{Name: "AciI", RecognitionSequence: "CCGC(-3/-1)"})
+	_ = CutWithEnzyme(Part{"CGCATATATATATATATATAT", false}, false, acii)
+}
+
+func TestEnzymesFromRebase(t *testing.T) {
+	enzymeMap, err := rebase.Read("../io/rebase/data/rebase_test.txt")
+	if err != nil {
+		t.Fatalf("Failed to read rebase: %s", err)
+	}
+	all := EnzymesFromRebase(enzymeMap, false)
+	commercial := EnzymesFromRebase(enzymeMap, true)
+	if len(commercial) == 0 || len(commercial) >= len(all) {
+		t.Errorf("Expected fewer commercial enzymes than enzymes, got %d and %d", len(commercial), len(all))
+	}
+	for index := 1; index < len(all); index++ {
+		if all[index-1].Name >= all[index].Name {
+			t.Errorf("Enzymes should be sorted by name")
+		}
+	}
+
+	enzymeManager := NewEnzymeManager(commercial)
+	aari, err := enzymeManager.GetEnzymeByName("AarI")
+	if err != nil {
+		t.Fatalf("AarI should be commercially available: %s", err)
+	}
+	if aari.RecognitionSite != "CACCTGC" || aari.Skip != 4 || aari.OverheadLength != 4 {
+		t.Errorf("AarI should cut CACCTGC(4/8), got %+v", aari)
+	}
+}
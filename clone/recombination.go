@@ -0,0 +1,357 @@
+package clone
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bebop/poly/synthesis/codon"
+	"github.com/bebop/poly/transform"
+)
+
+/******************************************************************************
+
+Site-specific recombination functions begin here.
+
+Site-specific recombinases, like Cre, Flp or the lambda integrase used by
+Gateway cloning, exchange the strands of two DNA molecules within a short
+overlap (or spacer) that two recognition sites share. Sites with different
+overlaps, like loxP and lox2272, don't recombine with each other, which lets
+two pairs of sites swap the DNA between them in a single reaction.
+
+Recombining two sites on the same molecule excises the DNA between them if
+they point the same way, or inverts it if they point opposite ways, while
+recombining sites on two molecules integrates them into one. Two pairs of
+sites on two molecules exchange the DNA between the sites, which is how
+Gateway BP and LR reactions and recombinase mediated cassette exchange work.
+
+******************************************************************************/
+
+// RecombinationSite is a site recognized by a site-specific recombinase. The
+// strands of two sites are exchanged within Sequence[OverlapStart:OverlapEnd],
+// which must be the same in both sites for them to recombine.
+type RecombinationSite struct {
+	Name         string
+	Sequence     string
+	OverlapStart int
+	OverlapEnd   int
+}
+
+// Recombinase is a site-specific recombinase and the sites it recombines.
+type Recombinase struct {
+	Name  string
+	Sites []RecombinationSite
+}
+
+// Sites recombined by Cre. The variants only recombine with themselves.
+var (
+	LoxP    = RecombinationSite{Name: "loxP", Sequence: "ATAACTTCGTATAGCATACATTATACGAAGTTAT", OverlapStart: 13, OverlapEnd: 21}
+	Lox2272 = RecombinationSite{Name: "lox2272", Sequence: "ATAACTTCGTATAAAGTATCCTATACGAAGTTAT", OverlapStart: 13, OverlapEnd: 21}
+	LoxN    = RecombinationSite{Name: "loxN", Sequence: "ATAACTTCGTATAGTATACCTTATACGAAGTTAT", OverlapStart: 13, OverlapEnd: 21}
+	Lox511  = RecombinationSite{Name: "lox511", Sequence: "ATAACTTCGTATAATGTATACTATACGAAGTTAT", OverlapStart: 13, OverlapEnd: 21}
+)
+
+// Sites recombined by Flp. F3 only recombines with itself.
+var (
+	FRT = RecombinationSite{Name: "FRT", Sequence: "GAAGTTCCTATTCTCTAGAAAGTATAGGAACTTC", OverlapStart: 13, OverlapEnd: 21}
+	F3  = RecombinationSite{Name: "F3", Sequence: "GAAGTTCCTATTCTTCAAATAGTATAGGAACTTC", OverlapStart: 13, OverlapEnd: 21}
+)
+
+// Cre is the recombinase of bacteriophage P1.
+var Cre = Recombinase{Name: "Cre", Sites: []RecombinationSite{LoxP, Lox2272, LoxN, Lox511}}
+
+// Flp is the recombinase of the yeast 2 micron plasmid.
+var Flp = Recombinase{Name: "Flp", Sites: []RecombinationSite{FRT, F3}}
+
+// AttB1 and AttB2 are the Gateway attB sites added by PCR primers to the
+// start and, reverse complemented, to the end of a sequence cloned by a BP
+// reaction.
+const (
+	AttB1 = "ACAAGTTTGTACAAAAAAGCAGGCT"
+	AttB2 = "ACCACTTTGTACAAGAAAGCTGGGT"
+)
+
+// attSite is the core of the Gateway att sites of one specificity, and the
+// arms next to it that attB sites have. The other arms come from attP.
+type attSite struct {
+	core      RecombinationSite
+	bArm      string
+	bPrimeArm string
+}
+
+// attSites are the Gateway att1 and att2 sites.
+var attSites = []attSite{
+	{RecombinationSite{Name: "att1", Sequence: "TTTGTACAAAAAAG", OverlapStart: 3, OverlapEnd: 10}, AttB1[:5], AttB1[19:]},
+	{RecombinationSite{Name: "att2", Sequence: "TTTGTACAAGAAAG", OverlapStart: 3, OverlapEnd: 10}, AttB2[:5], AttB2[19:]},
+}
+
+// ccdB is the CcdB toxin, which Gateway vectors carry between their att sites
+// to kill cells taking up unreacted or byproduct plasmids.
+const ccdB = "MQFKVYTYKRESRYRLFVDVQSDIIDTPGRRMVIPLASARLLSDKVSRELYPVVHIGDESWRMMTTDMASVPVSVIGEEVADLSHRENDIKNAINLMFWGI"
+
+// RecombinationProduct is a product of a site-specific recombination.
+type RecombinationProduct struct {
+	Part
+	// CcdB is true if the product carries the ccdB gene, which kills common
+	// cloning strains, so is selected against.
+	CcdB bool
+}
+
+// siteMatch is a recombination site found on a molecule.
+type siteMatch struct {
+	site     RecombinationSite
+	molecule int
+	// start is the position of the site on the top strand, and forward is
+	// false if the site is on the bottom strand.
+	start   int
+	forward bool
+}
+
+// overlapStart returns the position on the top strand of the first base of
+// the overlap of a site.
+func (match siteMatch) overlapStart() int {
+	if match.forward {
+		return match.start + match.site.OverlapStart
+	}
+	return match.start + len(match.site.Sequence) - match.site.OverlapEnd
+}
+
+// overlapEnd returns the position on the top strand after the last base of
+// the overlap of a site.
+func (match siteMatch) overlapEnd() int {
+	return match.overlapStart() + match.site.OverlapEnd - match.site.OverlapStart
+}
+
+// Recombine simulates the recombination of parts by a recombinase.
+//
+// Each kind of site of the recombinase found exactly twice among the parts
+// recombines once, in the order of the recombinase's sites: sites on the same
+// part excise or invert the DNA between them, and sites on different parts
+// integrate them, or exchange their ends if both are linear. Integrated parts
+// carrying a second pair of sites then resolve by excision, exchanging the DNA
+// between the sites of each part.
+func (recombinase Recombinase) Recombine(parts ...Part) ([]RecombinationProduct, error) {
+	return recombine(parts, recombinase.Sites, nil)
+}
+
+// GatewayBP simulates a Gateway BP reaction between a sequence flanked by
+// attB1 and attB2 sites and a donor vector with attP1 and attP2 sites. The
+// products are an entry clone with the sequence between attL1 and attL2
+// sites, and a byproduct with the ccdB cassette of the donor between attR1
+// and attR2 sites.
+func GatewayBP(parts ...Part) ([]RecombinationProduct, error) {
+	return gatewayReaction(parts, "B", "P")
+}
+
+// GatewayLR simulates a Gateway LR reaction between an entry clone with attL1
+// and attL2 sites and a destination vector with attR1 and attR2 sites. The
+// products are an expression clone with the sequence of the entry clone
+// between attB1 and attB2 sites, and a byproduct with the ccdB cassette of
+// the destination vector between attP1 and attP2 sites.
+func GatewayLR(parts ...Part) ([]RecombinationProduct, error) {
+	return gatewayReaction(parts, "L", "R")
+}
+
+// gatewayReaction recombines att sites of two kinds, like attB and attP.
+func gatewayReaction(parts []Part, kind, partnerKind string) ([]RecombinationProduct, error) {
+	var sites []RecombinationSite
+	arms := make(map[string]attSite)
+	for _, att := range attSites {
+		sites = append(sites, att.core)
+		arms[att.core.Name] = att
+	}
+	allowed := func(molecules []Part, first, second siteMatch) bool {
+		firstKind := attKind(molecules[first.molecule], first, arms[first.site.Name])
+		secondKind := attKind(molecules[second.molecule], second, arms[second.site.Name])
+		return (firstKind == kind && secondKind == partnerKind) || (firstKind == partnerKind && secondKind == kind)
+	}
+	products, err := recombine(parts, sites, allowed)
+	if err != nil {
+		return nil, fmt.Errorf("gateway att%s x att%s: %w", kind, partnerKind, err)
+	}
+	return products, nil
+}
+
+// attKind returns the kind of an att site, B, P, L or R, from the arms next to
+// its core: attB sites have both arms of attB, attP sites have neither, attL
+// sites have the arm of attB after the core and attR sites the arm before it.
+func attKind(part Part, match siteMatch, att attSite) string {
+	sequence := strings.ToUpper(part.Sequence)
+	if part.Circular {
+		sequence = sequence + sequence + sequence
+		match.start += len(part.Sequence)
+	}
+	coreLength := len(att.core.Sequence)
+	var before, after string
+	if match.forward {
+		before = sequence[max(0, match.start-len(att.bArm)):match.start]
+		after = sequence[match.start+coreLength : min(len(sequence), match.start+coreLength+len(att.bPrimeArm))]
+	} else {
+		before = transform.ReverseComplement(sequence[match.start+coreLength : min(len(sequence), match.start+coreLength+len(att.bArm))])
+		after = transform.ReverseComplement(sequence[max(0, match.start-len(att.bPrimeArm)):match.start])
+	}
+	hasBArm, hasBPrimeArm := before == att.bArm, after == att.bPrimeArm
+	switch {
+	case hasBArm && hasBPrimeArm:
+		return "B"
+	case hasBPrimeArm:
+		return "L"
+	case hasBArm:
+		return "R"
+	default:
+		return "P"
+	}
+}
+
+// recombine recombines each kind of site found exactly twice among parts, in
+// the order of sites. If allowed isn't nil, sites only recombine if it returns
+// true.
+func recombine(parts []Part, sites []RecombinationSite, allowed func(molecules []Part, first, second siteMatch) bool) ([]RecombinationProduct, error) {
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("recombination: no parts")
+	}
+	molecules := make([]Part, len(parts))
+	for index, part := range parts {
+		if len(part.Sequence) == 0 {
+			return nil, fmt.Errorf("recombination: part %d is empty", index)
+		}
+		molecules[index] = Part{strings.ToUpper(part.Sequence), part.Circular}
+	}
+
+	recombined := false
+	for _, site := range sites {
+		var matches []siteMatch
+		for index, molecule := range molecules {
+			matches = append(matches, findSites(molecule, index, site)...)
+		}
+		if len(matches) != 2 || (allowed != nil && !allowed(molecules, matches[0], matches[1])) {
+			continue
+		}
+		recombined = true
+		first, second := matches[0], matches[1]
+		var products []Part
+		if first.molecule == second.molecule {
+			products = recombineIntramolecular(molecules[first.molecule], first, second)
+		} else {
+			products = recombineIntermolecular(molecules[first.molecule], molecules[second.molecule], first, second)
+		}
+
+		// replace the recombined molecules by the products
+		var remaining []Part
+		for index, molecule := range molecules {
+			if index != first.molecule && index != second.molecule {
+				remaining = append(remaining, molecule)
+			}
+		}
+		molecules = append(remaining, products...)
+	}
+	if !recombined {
+		return nil, fmt.Errorf("recombination: no pair of sites can recombine")
+	}
+
+	products := make([]RecombinationProduct, len(molecules))
+	for index, molecule := range molecules {
+		products[index] = RecombinationProduct{Part: molecule, CcdB: encodes(molecule, ccdB)}
+	}
+	return products, nil
+}
+
+// findSites returns the sites found on both strands of a molecule, including
+// sites spanning the origin of circular molecules.
+func findSites(molecule Part, index int, site RecombinationSite) []siteMatch {
+	sequence := molecule.Sequence
+	if molecule.Circular {
+		sequence += molecule.Sequence[:min(len(molecule.Sequence), len(site.Sequence)-1)]
+	}
+	var matches []siteMatch
+	reverseSite := transform.ReverseComplement(site.Sequence)
+	for start := 0; start+len(site.Sequence) <= len(sequence) && start < len(molecule.Sequence); start++ {
+		window := sequence[start : start+len(site.Sequence)]
+		if window == site.Sequence {
+			matches = append(matches, siteMatch{site: site, molecule: index, start: start, forward: true})
+		} else if window == reverseSite {
+			matches = append(matches, siteMatch{site: site, molecule: index, start: start, forward: false})
+		}
+	}
+	return matches
+}
+
+// recombineIntramolecular recombines two sites on the same molecule, excising
+// the DNA between them if they point the same way and inverting it otherwise.
+func recombineIntramolecular(molecule Part, first, second siteMatch) []Part {
+	// rotate circular molecules so that neither site spans the origin
+	sequence := molecule.Sequence
+	if molecule.Circular {
+		rotation := first.start
+		sequence = sequence[rotation:] + sequence[:rotation]
+		first.start, second.start = 0, (second.start-rotation+len(sequence))%len(sequence)
+		if second.start < first.start {
+			first, second = second, first
+		}
+	}
+
+	if first.forward != second.forward {
+		inverted := sequence[:first.overlapStart()] + transform.ReverseComplement(sequence[first.overlapStart():second.overlapEnd()]) + sequence[second.overlapEnd():]
+		return []Part{{inverted, molecule.Circular}}
+	}
+	// the crossover is at the start of the overlaps in the orientation of
+	// the sites
+	firstCrossover, secondCrossover := first.overlapStart(), second.overlapStart()
+	if !first.forward {
+		firstCrossover, secondCrossover = first.overlapEnd(), second.overlapEnd()
+	}
+	excised := Part{sequence[firstCrossover:secondCrossover], true}
+	remaining := Part{sequence[:firstCrossover] + sequence[secondCrossover:], molecule.Circular}
+	return []Part{remaining, excised}
+}
+
+// recombineIntermolecular recombines sites on two molecules, integrating them
+// into one if either is circular and exchanging their ends otherwise.
+func recombineIntermolecular(firstMolecule, secondMolecule Part, first, second siteMatch) []Part {
+	// turn the second molecule so that its site points the same way as the
+	// first one
+	secondSequence := secondMolecule.Sequence
+	if first.forward != second.forward {
+		secondSequence = transform.ReverseComplement(secondSequence)
+		second.start = len(secondSequence) - second.start - len(second.site.Sequence)
+		second.forward = first.forward
+	}
+	firstCrossover, secondCrossover := first.overlapStart(), second.overlapStart()
+	if !first.forward {
+		firstCrossover, secondCrossover = first.overlapEnd(), second.overlapEnd()
+	}
+	firstCrossover %= len(firstMolecule.Sequence)
+	secondCrossover %= len(secondSequence)
+
+	firstLeft, firstRight := firstMolecule.Sequence[:firstCrossover], firstMolecule.Sequence[firstCrossover:]
+	secondLeft, secondRight := secondSequence[:secondCrossover], secondSequence[secondCrossover:]
+	switch {
+	case firstMolecule.Circular && secondMolecule.Circular:
+		return []Part{{firstRight + firstLeft + secondRight + secondLeft, true}}
+	case secondMolecule.Circular:
+		return []Part{{firstLeft + secondRight + secondLeft + firstRight, false}}
+	case firstMolecule.Circular:
+		return []Part{{secondLeft + firstRight + firstLeft + secondRight, false}}
+	default:
+		// two linear molecules exchange their ends, making two reciprocal
+		// products
+		return []Part{{firstLeft + secondRight, false}, {secondLeft + firstRight, false}}
+	}
+}
+
+// encodes returns whether a molecule encodes a protein in any frame.
+func encodes(molecule Part, protein string) bool {
+	sequence := molecule.Sequence
+	if molecule.Circular {
+		sequence += molecule.Sequence[:min(len(molecule.Sequence), 3*len(protein))]
+	}
+	table := codon.NewTranslationTable(11)
+	for _, strand := range []string{sequence, transform.ReverseComplement(sequence)} {
+		for frame := 0; frame < 3 && frame < len(strand); frame++ {
+			translation, err := table.Translate(strand[frame : frame+(len(strand)-frame)/3*3])
+			if err == nil && strings.Contains(translation, protein) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,196 @@
+package clone
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/transform"
+)
+
+// ccdBGene is the ccdB gene of pOpen.
+const ccdBGene = "ATGCAGTTTAAGGTTTACACCTATAAAAGAGAGAGCCGTTATCGTCTGTTTGTGGATGTACAGAGTGATATTATTGACACGCCCGGGCGACGGATGGTGATCCCCCTGGCCAGTGCACGTCTGCTGTCAGATAAAGTCTCCCGTGAACTTTACCCGGTGGTGCATATCGGGGATGAAAGCTGGCGCATGATGACCACCGATATGGCCAGTGTGCCGGTCTCCGTTATCGGGGAAGAAGTGGCTGATCTCAGCCACCGCGAAAATGACATCAAAAACGCCATTAACCTGATGTTCTGGGGAATATAA"
+
+// Arms of made up attP sites, around the cores of att1 and att2.
+const (
+	attP1Arm      = "GTCGACTGATAGTGACCTGTTCG"
+	attP1PrimeArm = "CTGAACGAGAAACGTAAAATGAT"
+	attP2Arm      = "GTCGTCAGATAGCGATCTTTCGA"
+	attP2PrimeArm = "CTTTCTGAGCGTTAAAATGCTAA"
+)
+
+// containsCircular returns whether a part contains a sequence, across its
+// origin if it is circular.
+func containsCircular(part Part, sequence string) bool {
+	if part.Circular && len(sequence) <= len(part.Sequence) {
+		return strings.Contains(part.Sequence+part.Sequence, sequence)
+	}
+	return strings.Contains(part.Sequence, sequence)
+}
+
+func TestRecombineExcision(t *testing.T) {
+	left, inner, tail := "GGGCCCAAATTTGGG", "ATGCCGTAGCTAGCTAGGCTAACG", "TTTTTCCCCC"
+	products, err := Cre.Recombine(Part{left + LoxP.Sequence + inner + LoxP.Sequence + tail, false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 {
+		t.Fatalf("expected 2 products, got %d", len(products))
+	}
+	if products[0].Sequence != left+LoxP.Sequence+tail || products[0].Circular {
+		t.Errorf("unexpected remaining part %v", products[0].Part)
+	}
+	if !products[1].Circular || len(products[1].Sequence) != len(inner)+len(LoxP.Sequence) || !containsCircular(products[1].Part, LoxP.Sequence+inner) {
+		t.Errorf("unexpected excised part %v", products[1].Part)
+	}
+
+	// excision works the same with sites on the bottom strand, or across the
+	// origin of a circular part
+	products, err = Cre.Recombine(Part{transform.ReverseComplement(left + LoxP.Sequence + inner + LoxP.Sequence + tail), false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if products[0].Sequence != transform.ReverseComplement(left+LoxP.Sequence+tail) {
+		t.Errorf("unexpected remaining part %v", products[0].Part)
+	}
+	products, err = Flp.Recombine(Part{FRT.Sequence[20:] + inner + FRT.Sequence + tail + FRT.Sequence[:20], true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 || containsCircular(products[0].Part, FRT.Sequence+inner) == containsCircular(products[1].Part, FRT.Sequence+inner) {
+		t.Errorf("unexpected products of a circular excision %v", products)
+	}
+}
+
+func TestRecombineInversion(t *testing.T) {
+	left, inner, tail := "GGGCCCAAATTTGGG", "ATGCCGTAGCTAGCTAGGCTAACG", "TTTTTCCCCC"
+	reverseLoxP := transform.ReverseComplement(LoxP.Sequence)
+	products, err := Cre.Recombine(Part{left + LoxP.Sequence + inner + reverseLoxP + tail, false})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := left + LoxP.Sequence + transform.ReverseComplement(inner) + reverseLoxP + tail
+	if len(products) != 1 || products[0].Sequence != expected {
+		t.Errorf("expected inversion %s, got %v", expected, products)
+	}
+}
+
+func TestRecombineIntegration(t *testing.T) {
+	first := Part{"AAAAAAAAAA" + FRT.Sequence + "CCCCCCCCCC", true}
+	second := Part{"GAGAGAGAGA" + transform.ReverseComplement(FRT.Sequence) + "TGTGTGTGTG", true}
+	products, err := Flp.Recombine(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || !products[0].Circular || len(products[0].Sequence) != len(first.Sequence)+len(second.Sequence) {
+		t.Fatalf("expected a single cointegrate, got %v", products)
+	}
+	if !containsCircular(products[0].Part, "AAAAAAAAAA"+FRT.Sequence+"TCTCTCTCTC") || !containsCircular(products[0].Part, "CACACACACA"+FRT.Sequence+"CCCCCCCCCC") {
+		t.Errorf("unexpected cointegrate %v", products[0].Part)
+	}
+}
+
+func TestRecombineLinearExchange(t *testing.T) {
+	first := Part{"AAAAAAAAAA" + LoxP.Sequence + "CCCCCCCCCC", false}
+	second := Part{"GAGAGAGAGA" + LoxP.Sequence + "TGTGTGTGTG", false}
+	products, err := Cre.Recombine(first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// both reciprocal products of the exchange are kept
+	if len(products) != 2 {
+		t.Fatalf("expected two products, got %v", products)
+	}
+	crossover := 10 + LoxP.OverlapStart
+	expected := []string{first.Sequence[:crossover] + second.Sequence[crossover:], second.Sequence[:crossover] + first.Sequence[crossover:]}
+	for index, product := range products {
+		if product.Circular || product.Sequence != expected[index] {
+			t.Errorf("expected linear product %s, got %v", expected[index], product.Part)
+		}
+	}
+}
+
+func TestRecombineCassetteExchange(t *testing.T) {
+	target := Part{"GCGCGCGCGCATATATAT" + LoxP.Sequence + "AAAAACCCCC" + Lox2272.Sequence, true}
+	donor := Part{LoxP.Sequence + "GGGGGTTTTT" + Lox2272.Sequence + "CTCTCTCTCT", true}
+	products, err := Cre.Recombine(target, donor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 2 {
+		t.Fatalf("expected 2 products, got %v", products)
+	}
+	if !containsCircular(products[0].Part, "GCGCGCGCGCATATATAT"+LoxP.Sequence+"GGGGGTTTTT"+Lox2272.Sequence) && !containsCircular(products[1].Part, "GCGCGCGCGCATATATAT"+LoxP.Sequence+"GGGGGTTTTT"+Lox2272.Sequence) {
+		t.Errorf("the target didn't take the cassette of the donor: %v", products)
+	}
+}
+
+func TestGateway(t *testing.T) {
+	gene := "ATGGCTAGCAAAGGAGAAGAACTTTTCACTGGAGTTGTCCCAATTCTTGTTGAATTAGATGGTGATGTTAATGGGCACAAATTTTCTGTCTAA"
+	attP1 := attP1Arm + attSites[0].core.Sequence + attP1PrimeArm
+	attP2 := attP2Arm + attSites[1].core.Sequence + attP2PrimeArm
+	insert := Part{"GGGG" + AttB1 + gene + transform.ReverseComplement(AttB2) + "CCCC", false}
+	donor := Part{"TTAGCGGCCGCATTGCAATCGGAGGCTTAC" + attP1 + ccdBGene + transform.ReverseComplement(attP2), true}
+
+	products, err := GatewayBP(insert, donor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry Part
+	for _, product := range products {
+		if product.CcdB {
+			continue
+		}
+		if entry.Sequence != "" {
+			t.Fatalf("expected a single product without ccdB, got %v", products)
+		}
+		entry = product.Part
+	}
+	attL1 := attP1Arm + attSites[0].core.Sequence + AttB1[19:]
+	attL2 := attP2Arm + attSites[1].core.Sequence + AttB2[19:]
+	if !entry.Circular || !containsCircular(entry, attL1+gene+transform.ReverseComplement(attL2)) {
+		t.Fatalf("unexpected entry clone %v", entry)
+	}
+
+	// attL sites don't recombine in a BP reaction
+	if _, err := GatewayBP(entry, donor); err == nil {
+		t.Errorf("expected an error for an attL x attP BP reaction")
+	}
+
+	attR1 := AttB1[:5] + attSites[0].core.Sequence + attP1PrimeArm
+	attR2 := AttB2[:5] + attSites[1].core.Sequence + attP2PrimeArm
+	destination := Part{"CATCATCATGGTACCGGATCCTTAAGGCTAG" + attR1 + ccdBGene + transform.ReverseComplement(attR2), true}
+	products, err = GatewayLR(entry, destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expression Part
+	ccdBCount := 0
+	for _, product := range products {
+		if product.CcdB {
+			ccdBCount++
+			continue
+		}
+		expression = product.Part
+	}
+	if len(products) != 2 || ccdBCount != 1 {
+		t.Fatalf("expected an expression clone and a ccdB byproduct, got %v", products)
+	}
+	if !expression.Circular || !containsCircular(expression, "CATCATCATGGTACCGGATCCTTAAGGCTAG"+AttB1+gene+transform.ReverseComplement(AttB2)) {
+		t.Errorf("unexpected expression clone %v", expression)
+	}
+}
+
+func TestRecombineErrors(t *testing.T) {
+	if _, err := Cre.Recombine(); err == nil {
+		t.Errorf("expected an error without parts")
+	}
+	if _, err := Cre.Recombine(Part{"", false}); err == nil {
+		t.Errorf("expected an error for an empty part")
+	}
+	if _, err := Cre.Recombine(Part{"AAAA" + LoxP.Sequence + "CCCC" + Lox2272.Sequence, true}); err == nil {
+		t.Errorf("expected an error for sites that don't recombine")
+	}
+	if _, err := Flp.Recombine(Part{"AAAA" + LoxP.Sequence + "CCCC" + LoxP.Sequence, true}); err == nil {
+		t.Errorf("expected an error for sites of another recombinase")
+	}
+}
@@ -0,0 +1,185 @@
+package clone
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bebop/poly/io/genbank"
+)
+
+// CutSite is where an enzyme cuts the top strand of a sequence.
+type CutSite struct {
+	Position int
+	// Features are the labels of the features the cut falls in, or their
+	// types for features without a label.
+	Features []string
+}
+
+// EnzymeCuts are the cuts an enzyme makes in a sequence.
+type EnzymeCuts struct {
+	Enzyme   string
+	CutSites []CutSite
+	// FragmentSizes are the sizes of the fragments a digest with the enzyme
+	// gives, in order from the start of the sequence, or from the first cut
+	// of circular sequences.
+	FragmentSizes []int
+}
+
+// RestrictionMap lists where each enzyme of a set cuts a sequence.
+type RestrictionMap struct {
+	Name     string
+	Length   int
+	Circular bool
+	// Enzymes are the cuts of each enzyme, in the order the enzymes were
+	// given, including enzymes that don't cut.
+	Enzymes []EnzymeCuts
+}
+
+// MapRestrictionSites maps the cut sites of enzymes in a genbank sequence,
+// along with the features each cut falls in and the fragment sizes of a
+// digest with each enzyme.
+func MapRestrictionSites(sequence genbank.Genbank, enzymes []Enzyme) RestrictionMap {
+	upperSequence := strings.ToUpper(sequence.Sequence)
+	restrictionMap := RestrictionMap{
+		Name:     sequence.Meta.Locus.Name,
+		Length:   len(upperSequence),
+		Circular: sequence.Meta.Locus.Circular,
+	}
+	for _, enzyme := range enzymes {
+		var positions []int
+		for _, enzymeCut := range digestCuts(upperSequence, restrictionMap.Circular, nil, []Enzyme{enzyme}) {
+			position := enzymeCut.topStrandCut() % len(upperSequence)
+			// cuts at the ends of a linear sequence don't cut anything off
+			if !restrictionMap.Circular && (position == 0 || enzymeCut.topStrandCut() == len(upperSequence)) {
+				continue
+			}
+			positions = append(positions, position)
+		}
+		sort.Ints(positions)
+
+		enzymeCuts := EnzymeCuts{Enzyme: enzyme.Name}
+		for index, position := range positions {
+			if index > 0 && position == positions[index-1] {
+				continue
+			}
+			enzymeCuts.CutSites = append(enzymeCuts.CutSites, CutSite{Position: position, Features: featuresAt(sequence.Features, position)})
+		}
+		enzymeCuts.FragmentSizes = fragmentSizes(enzymeCuts.CutSites, restrictionMap.Length, restrictionMap.Circular)
+		restrictionMap.Enzymes = append(restrictionMap.Enzymes, enzymeCuts)
+	}
+	return restrictionMap
+}
+
+// UniqueCutters returns the names of the enzymes cutting the sequence only
+// once.
+func (restrictionMap RestrictionMap) UniqueCutters() []string {
+	var uniqueCutters []string
+	for _, enzymeCuts := range restrictionMap.Enzymes {
+		if len(enzymeCuts.CutSites) == 1 {
+			uniqueCutters = append(uniqueCutters, enzymeCuts.Enzyme)
+		}
+	}
+	return uniqueCutters
+}
+
+// NonCutters returns the names of the enzymes that don't cut the sequence.
+func (restrictionMap RestrictionMap) NonCutters() []string {
+	var nonCutters []string
+	for _, enzymeCuts := range restrictionMap.Enzymes {
+		if len(enzymeCuts.CutSites) == 0 {
+			nonCutters = append(nonCutters, enzymeCuts.Enzyme)
+		}
+	}
+	return nonCutters
+}
+
+// String returns a plain text report of the restriction map, with the cut
+// sites and fragment sizes of each enzyme that cuts, followed by the unique
+// cutters and non cutters. Positions are 1-based, as the position of the last
+// base before each cut.
+func (restrictionMap RestrictionMap) String() string {
+	var report strings.Builder
+	topology := "linear"
+	if restrictionMap.Circular {
+		topology = "circular"
+	}
+	fmt.Fprintf(&report, "Restriction map of %s (%d bp, %s)\n", restrictionMap.Name, restrictionMap.Length, topology)
+	for _, enzymeCuts := range restrictionMap.Enzymes {
+		if len(enzymeCuts.CutSites) == 0 {
+			continue
+		}
+		fmt.Fprintf(&report, "\n%s: %d cut", enzymeCuts.Enzyme, len(enzymeCuts.CutSites))
+		if len(enzymeCuts.CutSites) > 1 {
+			report.WriteString("s")
+		}
+		report.WriteString("\n")
+		for _, cutSite := range enzymeCuts.CutSites {
+			fmt.Fprintf(&report, "  %d", cutSite.Position)
+			if len(cutSite.Features) > 0 {
+				fmt.Fprintf(&report, " in %s", strings.Join(cutSite.Features, ", "))
+			}
+			report.WriteString("\n")
+		}
+		var sizes []string
+		for _, size := range enzymeCuts.FragmentSizes {
+			sizes = append(sizes, fmt.Sprint(size))
+		}
+		fmt.Fprintf(&report, "  fragments: %s bp\n", strings.Join(sizes, ", "))
+	}
+	fmt.Fprintf(&report, "\nUnique cutters: %s\n", strings.Join(restrictionMap.UniqueCutters(), ", "))
+	fmt.Fprintf(&report, "Non cutters: %s\n", strings.Join(restrictionMap.NonCutters(), ", "))
+	return report.String()
+}
+
+// fragmentSizes returns the sizes of the fragments between cut sites.
+func fragmentSizes(cutSites []CutSite, length int, circular bool) []int {
+	if len(cutSites) == 0 {
+		return []int{length}
+	}
+	var sizes []int
+	if !circular {
+		sizes = append(sizes, cutSites[0].Position)
+	}
+	for index := 1; index < len(cutSites); index++ {
+		sizes = append(sizes, cutSites[index].Position-cutSites[index-1].Position)
+	}
+	last := cutSites[len(cutSites)-1].Position
+	if circular {
+		sizes = append(sizes, length-last+cutSites[0].Position)
+	} else {
+		sizes = append(sizes, length-last)
+	}
+	return sizes
+}
+
+// featuresAt returns the labels of the features a cut before position falls
+// in. A cut falls in a feature if the bases on both sides of it are in the
+// feature.
+func featuresAt(features []genbank.Feature, position int) []string {
+	var labels []string
+	for _, feature := range features {
+		if feature.Type == "source" || !locationContains(feature.Location, position-1) || !locationContains(feature.Location, position) {
+			continue
+		}
+		label := feature.Attributes["label"]
+		if label == "" {
+			label = feature.Type
+		}
+		labels = append(labels, label)
+	}
+	return labels
+}
+
+// locationContains returns whether a base is in a location.
+func locationContains(location genbank.Location, position int) bool {
+	if len(location.SubLocations) > 0 {
+		for _, subLocation := range location.SubLocations {
+			if locationContains(subLocation, position) {
+				return true
+			}
+		}
+		return false
+	}
+	return position >= location.Start && position < location.End
+}
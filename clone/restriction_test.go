@@ -0,0 +1,71 @@
+package clone
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/io/rebase"
+)
+
+// rebaseEnzymes builds enzymes from their names and REBASE recognition
+// sequences.
+func rebaseEnzymes(t *testing.T, recognitionSequences ...string) []Enzyme {
+	var enzymes []Enzyme
+	for index := 0; index < len(recognitionSequences); index += 2 {
+		enzyme, err := EnzymeFromRebase(rebase.Enzyme{Name: recognitionSequences[index], RecognitionSequence: recognitionSequences[index+1]})
+		if err != nil {
+			t.Fatalf("EnzymeFromRebase failed: %s", err)
+		}
+		enzymes = append(enzymes, enzyme)
+	}
+	return enzymes
+}
+
+func TestMapRestrictionSites(t *testing.T) {
+	puc19, err := genbank.Read("../data/puc19.gbk")
+	if err != nil {
+		t.Fatalf("Failed to read pUC19: %s", err)
+	}
+	enzymes := rebaseEnzymes(t, "EcoRI", "G^AATTC", "PvuII", "CAG^CTG", "NotI", "GC^GGCCGC", "BsaI", "GGTCTC(1/5)")
+	restrictionMap := MapRestrictionSites(puc19, enzymes)
+	if restrictionMap.Length != 2686 || !restrictionMap.Circular || len(restrictionMap.Enzymes) != len(enzymes) {
+		t.Fatalf("Unexpected restriction map %+v", restrictionMap)
+	}
+
+	ecori := restrictionMap.Enzymes[0]
+	if len(ecori.CutSites) != 1 || strings.Join(ecori.CutSites[0].Features, ",") != "lacZ-alpha,MCS" || ecori.FragmentSizes[0] != 2686 {
+		t.Errorf("EcoRI should cut once in the MCS, got %+v", ecori)
+	}
+	pvuii := restrictionMap.Enzymes[1]
+	if len(pvuii.FragmentSizes) != 2 || pvuii.FragmentSizes[0] != 322 || pvuii.FragmentSizes[1] != 2364 {
+		t.Errorf("PvuII should give 322 and 2364 bp fragments, got %v", pvuii.FragmentSizes)
+	}
+	// BsaI cuts once, in the ampicillin resistance gene
+	bsai := restrictionMap.Enzymes[3]
+	if len(bsai.CutSites) != 1 || strings.Join(bsai.CutSites[0].Features, ",") != "AmpR" {
+		t.Errorf("BsaI should cut once in AmpR, got %+v", bsai)
+	}
+
+	if strings.Join(restrictionMap.UniqueCutters(), ",") != "EcoRI,BsaI" {
+		t.Errorf("Expected unique cutters EcoRI and BsaI, got %v", restrictionMap.UniqueCutters())
+	}
+	if strings.Join(restrictionMap.NonCutters(), ",") != "NotI" {
+		t.Errorf("Expected non cutter NotI, got %v", restrictionMap.NonCutters())
+	}
+	report := restrictionMap.String()
+	for _, line := range []string{"PvuII: 2 cuts", "fragments: 322, 2364 bp", "Non cutters: NotI"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("Report should contain %q, got:\n%s", line, report)
+		}
+	}
+}
+
+func TestMapRestrictionSitesLinear(t *testing.T) {
+	sequence := genbank.Genbank{Meta: genbank.Meta{Locus: genbank.Locus{Name: "linear"}}, Sequence: "aaaaaaaaaagaattcaaaaaaaaaagaattcaaaaa"}
+	restrictionMap := MapRestrictionSites(sequence, rebaseEnzymes(t, "EcoRI", "G^AATTC"))
+	sizes := restrictionMap.Enzymes[0].FragmentSizes
+	if len(sizes) != 3 || sizes[0] != 11 || sizes[1] != 16 || sizes[2] != 10 {
+		t.Errorf("Expected fragments of 11, 16 and 10 bp, got %v", sizes)
+	}
+}
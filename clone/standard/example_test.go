@@ -0,0 +1,29 @@
+package standard_test
+
+import (
+	"fmt"
+
+	"github.com/bebop/poly/clone"
+	"github.com/bebop/poly/clone/standard"
+	"github.com/bebop/poly/synthesis/codon"
+)
+
+func ExampleStandard_Validate() {
+	promoter := clone.Part{Sequence: "GGTCTCAGGAGTTGACAGCTAGCTCAGTCCTAGGTATAATGCTAGCAATGAGAGACC", Circular: false}
+	fmt.Println(standard.MoClo.Validate(promoter, "Promoter"))
+	fmt.Println(standard.MoClo.Validate(promoter, "Terminator"))
+	// Output:
+	// <nil>
+	// MoClo Terminator part: overhangs GGAG and AATG, expected GCTT and CGCT
+}
+
+func ExampleStandard_Domesticate() {
+	codonTable := codon.ReadCodonJSON("../../data/pichiaTable.json")
+	gene := "ATGAAAGGTCTCGCGTAA"
+	part, changes, _ := standard.MoClo.Domesticate(gene, "CDS", codonTable)
+	fmt.Println(part.Sequence)
+	fmt.Println(changes[0].Position, changes[0].From, changes[0].To)
+	// Output:
+	// GGTCTCAAATGAAAGGACTCGCGTAAGCTTAGAGACC
+	// 2 GGT GGA
+}
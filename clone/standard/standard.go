@@ -0,0 +1,243 @@
+/*
+Package standard validates and domesticates parts of modular cloning standards.
+
+Modular cloning standards, like MoClo, GoldenBraid or Loop assembly, build
+constructs out of level 0 parts, each released by a Type IIS enzyme with a pair
+of overhangs that fixes its position in the construct: a promoter always ends
+with the overhang a CDS starts with, which ends with the overhang a terminator
+starts with, and so on. Parts from anyone following the same standard can then
+be assembled together in a single GoldenGate reaction.
+
+For this to work, level 0 parts can't contain the sites of any of the enzymes
+the standard uses, at any level. Removing them is called domestication, and
+for coding sequences it can be done without changing the protein by swapping
+codons for synonymous ones.
+*/
+package standard
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/bebop/poly/clone"
+	"github.com/bebop/poly/io/rebase"
+	"github.com/bebop/poly/synthesis/codon"
+	"github.com/bebop/poly/synthesis/fix"
+	"github.com/bebop/poly/transform"
+)
+
+// Enzymes used by modular cloning standards, from their REBASE recognition
+// sequences.
+var (
+	BsaI  = enzymeFromRebase("BsaI", "GGTCTC(1/5)")
+	BpiI  = enzymeFromRebase("BpiI", "GAAGAC(2/6)")
+	Esp3I = enzymeFromRebase("Esp3I", "CGTCTC(1/5)")
+	SapI  = enzymeFromRebase("SapI", "GCTCTTC(1/4)")
+)
+
+// enzymeFromRebase returns the enzyme of a REBASE recognition sequence,
+// panicking if it can't be converted, as the enzymes of standards are fixed.
+func enzymeFromRebase(name, recognitionSequence string) clone.Enzyme {
+	enzyme, err := clone.EnzymeFromRebase(rebase.Enzyme{Name: name, RecognitionSequence: recognitionSequence})
+	if err != nil {
+		panic(err)
+	}
+	return enzyme
+}
+
+// Position is a position, or slot, of a level 0 part in constructs of a
+// standard.
+type Position struct {
+	Name               string
+	FivePrimeOverhang  string
+	ThreePrimeOverhang string
+	// Coding is true for positions of coding sequences, read in frame from the
+	// ATG ending their 5' overhang through their 3' overhang.
+	Coding bool
+}
+
+// Standard is a modular cloning standard.
+type Standard struct {
+	Name string
+	// PartEnzyme is the enzyme releasing level 0 parts for assembly.
+	PartEnzyme clone.Enzyme
+	// Enzymes are all the enzymes the standard uses, at any level, so level 0
+	// parts can't contain their sites.
+	Enzymes   []clone.Enzyme
+	Positions []Position
+}
+
+// commonSyntax are the main positions of the common syntax of plant
+// synthetic biology (https://doi.org/10.1111/nph.13532), shared by MoClo,
+// GoldenBraid and Loop assembly.
+var commonSyntax = []Position{
+	{Name: "Promoter", FivePrimeOverhang: "GGAG", ThreePrimeOverhang: "AATG"},
+	{Name: "CDS", FivePrimeOverhang: "AATG", ThreePrimeOverhang: "GCTT", Coding: true},
+	{Name: "Terminator", FivePrimeOverhang: "GCTT", ThreePrimeOverhang: "CGCT"},
+}
+
+// Modular cloning standards.
+var (
+	// MoClo assembles level 0 parts with BsaI and level 1 parts with BpiI
+	// (https://doi.org/10.1371/journal.pone.0016765).
+	MoClo = Standard{Name: "MoClo", PartEnzyme: BsaI, Enzymes: []clone.Enzyme{BsaI, BpiI}, Positions: commonSyntax}
+	// CIDARMoClo is the bacterial MoClo standard of the CIDAR lab, with a
+	// position for ribosome binding sites
+	// (https://doi.org/10.1021/acssynbio.5b00124).
+	CIDARMoClo = Standard{Name: "CIDAR MoClo", PartEnzyme: BsaI, Enzymes: []clone.Enzyme{BsaI, BpiI}, Positions: []Position{
+		{Name: "Promoter", FivePrimeOverhang: "GGAG", ThreePrimeOverhang: "TACT"},
+		{Name: "RBS", FivePrimeOverhang: "TACT", ThreePrimeOverhang: "AATG"},
+		{Name: "CDS", FivePrimeOverhang: "AATG", ThreePrimeOverhang: "AGGT", Coding: true},
+		{Name: "Terminator", FivePrimeOverhang: "AGGT", ThreePrimeOverhang: "GCTT"},
+	}}
+	// GoldenBraid alternates BsaI and Esp3I between levels
+	// (https://doi.org/10.1104/pp.112.212183).
+	GoldenBraid = Standard{Name: "GoldenBraid", PartEnzyme: BsaI, Enzymes: []clone.Enzyme{BsaI, Esp3I}, Positions: commonSyntax}
+	// Loop assembles odd levels with BsaI and even levels with SapI
+	// (https://doi.org/10.1111/nph.15625).
+	Loop = Standard{Name: "Loop", PartEnzyme: BsaI, Enzymes: []clone.Enzyme{BsaI, SapI}, Positions: commonSyntax}
+)
+
+// Position returns the position of a standard with a given name.
+func (standard Standard) Position(name string) (Position, error) {
+	for _, position := range standard.Positions {
+		if position.Name == name {
+			return position, nil
+		}
+	}
+	return Position{}, fmt.Errorf("%s has no position %q", standard.Name, name)
+}
+
+// Validate checks that a part is a level 0 part of the standard for a
+// position: the part enzyme releases a single part from it, with the
+// overhangs of the position, without sites of any enzyme of the standard and,
+// for coding positions, keeping the reading frame. All the problems found are
+// returned together.
+func (standard Standard) Validate(part clone.Part, position string) error {
+	slot, err := standard.Position(position)
+	if err != nil {
+		return err
+	}
+	part.Sequence = strings.ToUpper(part.Sequence)
+
+	fragments := clone.CutWithEnzyme(part, true, standard.PartEnzyme)
+	if len(fragments) != 1 {
+		return fmt.Errorf("%s %s part: %s releases %d parts, expected 1", standard.Name, slot.Name, standard.PartEnzyme.Name, len(fragments))
+	}
+	fragment := fragments[0]
+
+	var problems []error
+	if fragment.ForwardOverhang != slot.FivePrimeOverhang || fragment.ReverseOverhang != slot.ThreePrimeOverhang {
+		problems = append(problems, fmt.Errorf("overhangs %s and %s, expected %s and %s", fragment.ForwardOverhang, fragment.ReverseOverhang, slot.FivePrimeOverhang, slot.ThreePrimeOverhang))
+	}
+	released := fragment.ForwardOverhang + fragment.Sequence + fragment.ReverseOverhang
+	for _, enzyme := range standard.Enzymes {
+		for _, site := range internalSites(released, enzyme) {
+			problems = append(problems, fmt.Errorf("internal %s site at %d", enzyme.Name, site))
+		}
+	}
+	if slot.Coding && len(fragment.Sequence)%3 != 0 {
+		problems = append(problems, fmt.Errorf("coding sequence of %d bases shifts the reading frame", len(fragment.Sequence)+3))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s %s part: %w", standard.Name, slot.Name, errors.Join(problems...))
+	}
+	return nil
+}
+
+// Domesticate turns a sequence into a linear level 0 part of the standard
+// for a position, flanked by outward facing sites of the part enzyme leaving
+// the overhangs of the position.
+//
+// Coding sequences start with the ATG ending the 5' overhang of their
+// position, and sites of the enzymes of the standard are removed from them by
+// fix.Cds with synonymous codons from the codon table, which keeps them in
+// frame. The changes made are returned. Sites in sequences of other positions
+// can't be removed this way, so they are returned as an *InternalSitesError
+// for the sequence to be fixed by hand.
+func (standard Standard) Domesticate(sequence string, position string, codonTable codon.Table) (clone.Part, []fix.Change, error) {
+	slot, err := standard.Position(position)
+	if err != nil {
+		return clone.Part{}, nil, err
+	}
+	sequence = strings.ToUpper(sequence)
+
+	var changes []fix.Change
+	if slot.Coding {
+		if !strings.HasPrefix(sequence, "ATG") || len(sequence)%3 != 0 {
+			return clone.Part{}, nil, fmt.Errorf("%s %s part: coding sequence must start with ATG and be made of whole codons", standard.Name, slot.Name)
+		}
+		var sites []string
+		for _, enzyme := range standard.Enzymes {
+			sites = append(sites, enzyme.RecognitionSite)
+		}
+		reason := fmt.Sprintf("%s domestication", standard.Name)
+		sequence, changes, err = fix.Cds(sequence, codonTable, []func(string, chan fix.DnaSuggestion, *sync.WaitGroup){fix.RemoveSequence(sites, reason)})
+		if err != nil {
+			return clone.Part{}, nil, fmt.Errorf("%s %s part: %w", standard.Name, slot.Name, err)
+		}
+		// the ATG is part of the 5' overhang
+		sequence = sequence[3:]
+	} else {
+		var sites []Site
+		for _, enzyme := range standard.Enzymes {
+			for _, position := range internalSites(sequence, enzyme) {
+				sites = append(sites, Site{Enzyme: enzyme.Name, Position: position})
+			}
+		}
+		if len(sites) > 0 {
+			return clone.Part{}, nil, &InternalSitesError{Standard: standard.Name, Position: slot.Name, Sites: sites}
+		}
+	}
+
+	enzyme := standard.PartEnzyme
+	spacer := strings.Repeat("A", enzyme.Skip)
+	part := clone.Part{
+		Sequence: enzyme.RecognitionSite + spacer + slot.FivePrimeOverhang + sequence + slot.ThreePrimeOverhang + spacer + transform.ReverseComplement(enzyme.RecognitionSite),
+		Circular: false,
+	}
+	if err := standard.Validate(part, position); err != nil {
+		return clone.Part{}, nil, fmt.Errorf("domestication failed: %w", err)
+	}
+	return part, changes, nil
+}
+
+// Site is the site of an enzyme in a sequence.
+type Site struct {
+	Enzyme string
+	// Position is the index of the first base of the site, on the top strand.
+	Position int
+}
+
+// InternalSitesError is the error of Domesticate for non-coding sequences
+// with sites of the enzymes of a standard.
+type InternalSitesError struct {
+	Standard, Position string
+	Sites              []Site
+}
+
+// Error returns the sites of an InternalSitesError.
+func (e *InternalSitesError) Error() string {
+	sites := make([]string, len(e.Sites))
+	for index, site := range e.Sites {
+		sites[index] = fmt.Sprintf("internal %s site at %d", site.Enzyme, site.Position)
+	}
+	return fmt.Sprintf("%s %s part: %s can't be removed without changing the sequence", e.Standard, e.Position, strings.Join(sites, ", "))
+}
+
+// internalSites returns the positions of the sites of an enzyme on either
+// strand of a sequence.
+func internalSites(sequence string, enzyme clone.Enzyme) []int {
+	var sites []int
+	for _, match := range enzyme.RegexpFor.FindAllStringIndex(sequence, -1) {
+		sites = append(sites, match[0])
+	}
+	if enzyme.RegexpRev.String() != enzyme.RegexpFor.String() {
+		for _, match := range enzyme.RegexpRev.FindAllStringIndex(sequence, -1) {
+			sites = append(sites, match[0])
+		}
+	}
+	return sites
+}
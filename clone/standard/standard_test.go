@@ -0,0 +1,101 @@
+package standard
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/clone"
+	"github.com/bebop/poly/synthesis/codon"
+	"github.com/bebop/poly/transform"
+)
+
+// bla is the beta-lactamase gene, with an internal BsaI site.
+const bla = "ATGAGTATTCAACATTTCCGTGTCGCCCTTATTCCCTTTTTTGCGGCATTTTGCCTTCCTGTTTTTGCTCACCCAGAAACGCTGGTGAAAGTAAAAGATGCTGAAGATCAGTTGGGTGCACGAGTGGGTTACATCGAACTGGATCTCAACAGCGGTAAGATCCTTGAGAGTTTTCGCCCCGAAGAACGTTTTCCAATGATGAGCACTTTTAAAGTTCTGCTATGTGGCGCGGTATTATCCCGTATTGACGCCGGGCAAGAGCAACTCGGTCGCCGCATACACTATTCTCAGAATGACTTGGTTGAGTACTCACCAGTCACAGAAAAGCATCTTACGGATGGCATGACAGTAAGAGAATTATGCAGTGCTGCCATAACCATGAGTGATAACACTGCGGCCAACTTACTTCTGACAACGATCGGAGGACCGAAGGAGCTAACCGCTTTTTTGCACAACATGGGGGATCATGTAACTCGCCTTGATCGTTGGGAACCGGAGCTGAATGAAGCCATACCAAACGACGAGCGTGACACCACGATGCCTGTAGCAATGGCAACAACGTTGCGCAAACTATTAACTGGCGAACTACTTACTCTAGCTTCCCGGCAACAATTAATAGACTGGATGGAGGCGGATAAAGTTGCAGGACCACTTCTGCGCTCGGCCCTTCCGGCTGGCTGGTTTATTGCTGATAAATCTGGAGCCGGTGAGCGTGGGTCTCGCGGTATCATTGCAGCACTGGGGCCAGATGGTAAGCCCTCCCGTATCGTAGTTATCTACACGACGGGGAGTCAGGCAACTATGGATGAACGAAATAGACAGATCGCTGAGATAGGTGCCTCACTGATTAAGCATTGGTAA"
+
+// levelZero returns a level 0 part releasing a sequence with BsaI.
+func levelZero(fivePrimeOverhang, sequence, threePrimeOverhang string) clone.Part {
+	return clone.Part{Sequence: "GGTCTCA" + fivePrimeOverhang + sequence + threePrimeOverhang + "AGAGACC", Circular: false}
+}
+
+func TestValidate(t *testing.T) {
+	promoter := "TTGACAGCTAGCTCAGTCCTAGGTATAATGCTAGC"
+	if err := MoClo.Validate(levelZero("GGAG", promoter, "AATG"), "Promoter"); err != nil {
+		t.Errorf("unexpected error for a valid promoter: %s", err)
+	}
+	if err := MoClo.Validate(levelZero("GGAG", strings.ToLower(promoter), "AATG"), "Promoter"); err != nil {
+		t.Errorf("unexpected error for a lower case promoter: %s", err)
+	}
+
+	// a part can be a valid part of a standard and not of another
+	withEsp3I := promoter + "CGTCTC" + promoter
+	if err := MoClo.Validate(levelZero("GGAG", withEsp3I, "AATG"), "Promoter"); err != nil {
+		t.Errorf("unexpected error for a MoClo promoter with an Esp3I site: %s", err)
+	}
+	if err := GoldenBraid.Validate(levelZero("GGAG", withEsp3I, "AATG"), "Promoter"); err == nil || !strings.Contains(err.Error(), "Esp3I") {
+		t.Errorf("expected an Esp3I site error for a GoldenBraid promoter, got %v", err)
+	}
+
+	// all the problems of a part are reported
+	err := MoClo.Validate(levelZero("GGAG", "AAAA"+transform.ReverseComplement("GAAGAC")+"A", "GCTT"), "CDS")
+	if err == nil {
+		t.Fatal("expected an error for an invalid CDS")
+	}
+	for _, problem := range []string{"overhangs GGAG and GCTT, expected AATG and GCTT", "internal BpiI site", "reading frame"} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q in %q", problem, err)
+		}
+	}
+
+	if err := MoClo.Validate(clone.Part{Sequence: promoter, Circular: false}, "Promoter"); err == nil {
+		t.Errorf("expected an error for a part without BsaI sites")
+	}
+	if err := MoClo.Validate(levelZero("GGAG", promoter, "AATG"), "RBS"); err == nil {
+		t.Errorf("expected an error for a position MoClo doesn't have")
+	}
+	if err := CIDARMoClo.Validate(levelZero("TACT", "AAAGAGGAGAAA", "AATG"), "RBS"); err != nil {
+		t.Errorf("unexpected error for a CIDAR RBS: %s", err)
+	}
+}
+
+func TestDomesticate(t *testing.T) {
+	codonTable := codon.ReadCodonJSON("../../data/pichiaTable.json")
+	for _, standard := range []Standard{MoClo, CIDARMoClo, GoldenBraid, Loop} {
+		part, changes, err := standard.Domesticate(bla, "CDS", codonTable)
+		if err != nil {
+			t.Fatalf("%s: %s", standard.Name, err)
+		}
+		if len(changes) == 0 {
+			t.Errorf("%s: expected the BsaI site of bla to be changed", standard.Name)
+		}
+		if err := standard.Validate(part, "CDS"); err != nil {
+			t.Errorf("%s: domesticated part isn't valid: %s", standard.Name, err)
+		}
+
+		// the domesticated gene makes the same protein
+		fragment := clone.CutWithEnzyme(part, true, standard.PartEnzyme)[0]
+		gene := fragment.ForwardOverhang[1:] + fragment.Sequence
+		protein, _ := codon.NewTranslationTable(11).Translate(gene)
+		expected, _ := codon.NewTranslationTable(11).Translate(bla)
+		if protein != expected {
+			t.Errorf("%s: domestication changed the protein to %s", standard.Name, protein)
+		}
+	}
+
+	_, _, err := MoClo.Domesticate("ATGAAAGGTCTCAAAGAAGACTAA", "Promoter", codonTable)
+	var sitesError *InternalSitesError
+	if !errors.As(err, &sitesError) || !reflect.DeepEqual(sitesError.Sites, []Site{{"BsaI", 6}, {"BpiI", 15}}) {
+		t.Errorf("expected the BsaI and BpiI sites of a promoter, got %v", err)
+	}
+	if _, _, err := MoClo.Domesticate("GTGAAATAA", "CDS", codonTable); err == nil {
+		t.Errorf("expected an error for a CDS without ATG")
+	}
+	if _, _, err := MoClo.Domesticate("ATGAAATAAA", "CDS", codonTable); err == nil {
+		t.Errorf("expected an error for a CDS of partial codons")
+	}
+	part, changes, err := MoClo.Domesticate("ttgacagctagc", "Terminator", codonTable)
+	if err != nil || len(changes) != 0 || part.Sequence != "GGTCTCAGCTTTTGACAGCTAGCCGCTAGAGACC" {
+		t.Errorf("unexpected terminator %v %v %v", part, changes, err)
+	}
+}
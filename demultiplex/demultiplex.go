@@ -0,0 +1,352 @@
+/*
+Package demultiplex sorts the reads of a multiplexed sequencing run into the
+samples they come from by their barcodes.
+
+The DNA of each sample of a run carries a barcode on both ends, ligated or
+amplified on along with the sequencing adapters, so a read starts with the
+adapter and the barcode and ends with their reverse complements. Reads break
+off or get cut short, losing one end, and barcodes are read with errors, so
+barcodes are searched for in the first and last bases of each read, on both
+strands, and decoded with a primers.BarcodeDecoder correcting them.
+
+Reads are assigned to the sample of the barcodes found at their ends, and left
+unclassified if none is found or the ends disagree. Barcodes and everything
+outside of them, like the sequencing adapters, are trimmed off the reads of
+samples.
+
+Barcodes more than twice the number of errors corrected apart from each other
+and from their reverse complements are never mistaken for each other, which
+is what primers.CreateBarcodeSet designs.
+*/
+package demultiplex
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bebop/poly/io/fastq"
+	"github.com/bebop/poly/primers"
+	"github.com/bebop/poly/transform"
+)
+
+// Unclassified is the name unclassified reads are written under.
+const Unclassified = "unclassified"
+
+// Sample is a sample of a run and the barcode its reads carry.
+type Sample struct {
+	Name    string
+	Barcode string
+}
+
+// SamplesFromBarcodes names samples after the position of their barcodes in a
+// barcode set, like barcode01.
+func SamplesFromBarcodes(barcodes []string) []Sample {
+	samples := make([]Sample, len(barcodes))
+	for index, barcode := range barcodes {
+		samples[index] = Sample{Name: fmt.Sprintf("barcode%02d", index+1), Barcode: barcode}
+	}
+	return samples
+}
+
+// Options are the options of a Demultiplexer.
+type Options struct {
+	// Metric and MaxErrors are the errors corrected in barcodes. The decode
+	// table grows exponentially with MaxErrors, so correcting more than two
+	// errors takes a lot of memory for long barcodes.
+	Metric    primers.DistanceMetric
+	MaxErrors int
+	// SearchLength is the number of bases at each end of reads searched for
+	// barcodes, which should cover the adapter and the barcode.
+	SearchLength int
+	// RequireBothEnds leaves reads with a barcode at one end only
+	// unclassified.
+	RequireBothEnds bool
+	// MinLength is the length trimmed reads need to be classified.
+	MinLength int
+}
+
+// DefaultOptions returns options searching the first and last 150 bases of
+// reads for barcodes with up to one substitution, insertion or deletion.
+func DefaultOptions() Options {
+	return Options{
+		Metric:       primers.Levenshtein,
+		MaxErrors:    1,
+		SearchLength: 150,
+		MinLength:    1,
+	}
+}
+
+// Status is why a read is classified or not.
+type Status int
+
+// Statuses of reads.
+const (
+	// Classified reads are assigned to a sample.
+	Classified Status = iota
+	// NoBarcode reads have no barcode at either end.
+	NoBarcode
+	// ConflictingBarcodes reads have different barcodes at their ends, or
+	// barcodes as close to several samples at one of them.
+	ConflictingBarcodes
+	// OneEnd reads have a barcode at one end only, when both are required.
+	OneEnd
+	// TooShort reads are shorter than the minimum length once trimmed.
+	TooShort
+)
+
+// String returns the name of a status.
+func (status Status) String() string {
+	switch status {
+	case Classified:
+		return "classified"
+	case NoBarcode:
+		return "no barcode"
+	case ConflictingBarcodes:
+		return "conflicting barcodes"
+	case OneEnd:
+		return "one end"
+	case TooShort:
+		return "too short"
+	}
+	return fmt.Sprintf("Status(%d)", int(status))
+}
+
+// Hit is a barcode found in a read.
+type Hit struct {
+	// Sample is the index of the sample of the barcode.
+	Sample int
+	// Start and End are the bases of the read the barcode spans, End being
+	// exclusive.
+	Start, End int
+	// Reverse is true for barcodes found as their reverse complement.
+	Reverse bool
+	// Distance is the number of errors corrected.
+	Distance int
+}
+
+// Assignment is the sample a read is assigned to.
+type Assignment struct {
+	// Sample is the index of the sample of the read, or -1 if it is
+	// unclassified.
+	Sample int
+	Status Status
+	// Hits are the barcodes found at the start and end of the read, in that
+	// order.
+	Hits []Hit
+	// Read is the read with its barcodes and what is outside of them trimmed
+	// off if it is classified, or the read as it is if it isn't.
+	Read fastq.Fastq
+}
+
+// Demultiplexer assigns reads to the samples of a run.
+type Demultiplexer struct {
+	Samples []Sample
+	Options Options
+	decoder *primers.BarcodeDecoder
+}
+
+// NewDemultiplexer returns a demultiplexer for the samples of a run. It
+// returns an error if samples don't have unique names usable as file names,
+// or if barcodes can't be told apart.
+func NewDemultiplexer(samples []Sample, options Options) (*Demultiplexer, error) {
+	if options.SearchLength <= 0 {
+		return nil, fmt.Errorf("search length %d isn't positive", options.SearchLength)
+	}
+	names := make(map[string]bool)
+	barcodes := make([]string, len(samples))
+	for index, sample := range samples {
+		if sample.Name == "" || sample.Name == Unclassified || names[sample.Name] {
+			return nil, fmt.Errorf("sample name %q is empty, reserved or not unique", sample.Name)
+		}
+		// names are written to files of a directory, which they mustn't leave
+		if strings.ContainsAny(sample.Name, `/\`) || sample.Name == "." || sample.Name == ".." {
+			return nil, fmt.Errorf("sample name %q isn't a file name", sample.Name)
+		}
+		names[sample.Name] = true
+		barcodes[index] = sample.Barcode
+	}
+	decoder, err := primers.NewBarcodeDecoder(barcodes, options.Metric, options.MaxErrors)
+	if err != nil {
+		return nil, err
+	}
+	for index, sample := range samples {
+		if match, ok := decoder.Decode(sample.Barcode); !ok || match.Barcode != index {
+			return nil, fmt.Errorf("barcode %s of sample %s can't be told apart from the others", sample.Barcode, sample.Name)
+		}
+	}
+	return &Demultiplexer{Samples: samples, Options: options, decoder: decoder}, nil
+}
+
+// Classify assigns a read to a sample.
+func (demultiplexer *Demultiplexer) Classify(read fastq.Fastq) Assignment {
+	assignment := Assignment{Sample: -1, Read: read}
+	sequence := strings.ToUpper(read.Sequence)
+
+	// the ends searched don't overlap, so each barcode is found at one end
+	searchLength := min(demultiplexer.Options.SearchLength, len(sequence)/2)
+	startHit, startFound, startAmbiguous := demultiplexer.search(sequence[:searchLength], 0)
+	endHit, endFound, endAmbiguous := demultiplexer.search(sequence[len(sequence)-searchLength:], len(sequence)-searchLength)
+	if startFound {
+		assignment.Hits = append(assignment.Hits, startHit)
+	}
+	if endFound {
+		assignment.Hits = append(assignment.Hits, endHit)
+	}
+
+	switch {
+	case startAmbiguous || endAmbiguous || (startFound && endFound && startHit.Sample != endHit.Sample):
+		assignment.Status = ConflictingBarcodes
+		return assignment
+	case !startFound && !endFound:
+		assignment.Status = NoBarcode
+		return assignment
+	case demultiplexer.Options.RequireBothEnds && (!startFound || !endFound):
+		assignment.Status = OneEnd
+		return assignment
+	}
+
+	start, end := 0, len(sequence)
+	if startFound {
+		start = startHit.End
+	}
+	if endFound {
+		end = endHit.Start
+	}
+	if end-start < demultiplexer.Options.MinLength {
+		assignment.Status = TooShort
+		return assignment
+	}
+	assignment.Read.Sequence = read.Sequence[start:end]
+	if len(read.Quality) == len(read.Sequence) {
+		assignment.Read.Quality = read.Quality[start:end]
+	}
+	assignment.Sample = assignment.Hits[0].Sample
+	assignment.Status = Classified
+	return assignment
+}
+
+// search returns the barcode with the fewest errors in a window of a read
+// starting at offset, on either strand, whether there is one, and whether
+// barcodes of several samples have as few.
+func (demultiplexer *Demultiplexer) search(window string, offset int) (Hit, bool, bool) {
+	var best Hit
+	found, ambiguous := false, false
+	for _, reverse := range []bool{false, true} {
+		strand := window
+		if reverse {
+			strand = transform.ReverseComplement(window)
+		}
+		for position := range strand {
+			match, length, ok := demultiplexer.decoder.DecodePrefix(strand[position:])
+			if !ok || (found && match.Distance > best.Distance) {
+				continue
+			}
+			hit := Hit{Sample: match.Barcode, Start: offset + position, End: offset + position + length, Reverse: reverse, Distance: match.Distance}
+			if reverse {
+				hit.Start, hit.End = offset+len(window)-position-length, offset+len(window)-position
+			}
+			switch {
+			case !found || hit.Distance < best.Distance:
+				best, found, ambiguous = hit, true, false
+			case hit.Sample != best.Sample:
+				ambiguous = true
+			}
+		}
+	}
+	return best, found, ambiguous
+}
+
+// Summary counts the reads of a run.
+type Summary struct {
+	Reads int
+	// Samples is the number of reads of each sample, by name.
+	Samples map[string]int
+	// BothEnds is the number of reads classified with barcodes at both ends.
+	BothEnds int
+	// Unclassified is the number of unclassified reads, by status.
+	Unclassified map[Status]int
+}
+
+// Demultiplex classifies all reads of a parser, writing each to the writer of
+// its sample, or of Unclassified. Reads without a writer aren't written. It
+// returns the number of reads of each sample.
+func (demultiplexer *Demultiplexer) Demultiplex(parser *fastq.Parser, writers map[string]io.Writer) (Summary, error) {
+	summary := Summary{Samples: make(map[string]int), Unclassified: make(map[Status]int)}
+	for {
+		read, _, err := parser.ParseNext()
+		if errors.Is(err, io.EOF) {
+			return summary, nil
+		}
+		if err != nil {
+			return summary, err
+		}
+
+		assignment := demultiplexer.Classify(read)
+		summary.Reads++
+		name := Unclassified
+		if assignment.Status == Classified {
+			name = demultiplexer.Samples[assignment.Sample].Name
+			summary.Samples[name]++
+			if len(assignment.Hits) == 2 {
+				summary.BothEnds++
+			}
+		} else {
+			summary.Unclassified[assignment.Status]++
+		}
+		if writer, ok := writers[name]; ok {
+			fastqBytes, _ := fastq.Build([]fastq.Fastq{assignment.Read}) // fastq.Build returns only nil errors.
+			if _, err := writer.Write(fastqBytes); err != nil {
+				return summary, err
+			}
+		}
+	}
+}
+
+// DemultiplexToDirectory classifies all reads of a parser like Demultiplex,
+// writing the reads of each sample to a fastq file named after it in a
+// directory, and unclassified reads to unclassified.fastq.
+func (demultiplexer *Demultiplexer) DemultiplexToDirectory(parser *fastq.Parser, directory string) (Summary, error) {
+	names := []string{Unclassified}
+	for _, sample := range demultiplexer.Samples {
+		names = append(names, sample.Name)
+	}
+	writers := make(map[string]io.Writer)
+	files := make(map[string]*os.File)
+	bufferedWriters := make(map[string]*bufio.Writer)
+	// files are closed once written, or on errors
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, name := range names {
+		file, err := os.Create(filepath.Join(directory, name+".fastq"))
+		if err != nil {
+			return Summary{}, err
+		}
+		files[name] = file
+		bufferedWriters[name] = bufio.NewWriter(file)
+		writers[name] = bufferedWriters[name]
+	}
+
+	summary, err := demultiplexer.Demultiplex(parser, writers)
+	if err != nil {
+		return summary, err
+	}
+	for _, name := range names {
+		if err := bufferedWriters[name].Flush(); err != nil {
+			return summary, err
+		}
+		err := files[name].Close()
+		delete(files, name)
+		if err != nil {
+			return summary, err
+		}
+	}
+	return summary, nil
+}
@@ -0,0 +1,172 @@
+package demultiplex
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/io/fastq"
+	"github.com/bebop/poly/primers"
+	"github.com/bebop/poly/random"
+	"github.com/bebop/poly/transform"
+)
+
+// adapter is the top strand of the nanopore sequencing adapter.
+const adapter = "AATGTACTTCGTTCAGTTACGTATTGCT"
+
+func newTestDemultiplexer(t *testing.T, options Options) (*Demultiplexer, []string) {
+	t.Helper()
+	barcodes, err := primers.CreateBarcodeSet(primers.DefaultBarcodeConstraints(16, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	demultiplexer, err := NewDemultiplexer(SamplesFromBarcodes(barcodes), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return demultiplexer, barcodes
+}
+
+// newRead returns a read of an insert with a start and an end, and a quality
+// telling the bases of the insert apart.
+func newRead(start, insert, end string) fastq.Fastq {
+	quality := strings.Repeat("!", len(start)) + strings.Repeat("I", len(insert)) + strings.Repeat("!", len(end))
+	return fastq.Fastq{Identifier: "read", Sequence: start + insert + end, Quality: quality}
+}
+
+func TestClassify(t *testing.T) {
+	demultiplexer, barcodes := newTestDemultiplexer(t, DefaultOptions())
+	insert, _ := random.DNASequence(300, 0)
+	barcode, other := barcodes[3], barcodes[7]
+	start, end := adapter+barcode, transform.ReverseComplement(adapter+barcode)
+	startWithError := adapter + barcode[:5] + barcode[6:]
+
+	tests := map[string]struct {
+		read     fastq.Fastq
+		status   Status
+		hits     int
+		trimmed  string
+		distance int
+	}{
+		"both ends":          {newRead(start, insert, end), Classified, 2, insert, 0},
+		"start only":         {newRead(start, insert, ""), Classified, 1, insert, 0},
+		"end only":           {newRead("", insert, end), Classified, 1, insert, 0},
+		"deletion":           {newRead(startWithError, insert, end), Classified, 2, insert, 1},
+		"reverse barcodes":   {newRead(adapter+transform.ReverseComplement(barcode), insert, barcode+transform.ReverseComplement(adapter)), Classified, 2, insert, 0},
+		"lowercase":          {newRead(strings.ToLower(start), insert, end), Classified, 2, insert, 0},
+		"no barcode":         {newRead(adapter, insert, transform.ReverseComplement(adapter)), NoBarcode, 0, "", 0},
+		"conflicting ends":   {newRead(start, insert, transform.ReverseComplement(adapter+other)), ConflictingBarcodes, 2, "", 0},
+		"barcodes only":      {newRead(start, "", end), TooShort, 2, "", 0},
+		"empty":              {fastq.Fastq{}, NoBarcode, 0, "", 0},
+		"adapter dimer only": {newRead(adapter, "", transform.ReverseComplement(adapter)), NoBarcode, 0, "", 0},
+	}
+	for name, test := range tests {
+		assignment := demultiplexer.Classify(test.read)
+		if assignment.Status != test.status || len(assignment.Hits) != test.hits {
+			t.Errorf("%s: expected %s with %d barcodes, got %s with %v", name, test.status, test.hits, assignment.Status, assignment.Hits)
+			continue
+		}
+		if test.status != Classified {
+			if assignment.Sample != -1 || assignment.Read.Sequence != test.read.Sequence {
+				t.Errorf("%s: expected the read to be left as it is", name)
+			}
+			continue
+		}
+		if assignment.Sample != 3 || assignment.Hits[0].Distance != test.distance {
+			t.Errorf("%s: expected barcode04 with %d errors, got sample %d with %d", name, test.distance, assignment.Sample, assignment.Hits[0].Distance)
+		}
+		// barcodes read backwards at the end of reads are reverse complements
+		reverse := name == "reverse barcodes"
+		if len(assignment.Hits) == 2 && (assignment.Hits[0].Reverse != reverse || assignment.Hits[1].Reverse == reverse) {
+			t.Errorf("%s: expected barcodes on the other strand to be found as their reverse complement, got %v", name, assignment.Hits)
+		}
+		if !strings.EqualFold(assignment.Read.Sequence, test.trimmed) || assignment.Read.Quality != strings.Repeat("I", len(test.trimmed)) {
+			t.Errorf("%s: expected the barcodes and adapters to be trimmed off, got %s and %s", name, assignment.Read.Sequence, assignment.Read.Quality)
+		}
+	}
+
+	// reads with a barcode at one end only are left out when both are required
+	options := DefaultOptions()
+	options.RequireBothEnds = true
+	strict, _ := newTestDemultiplexer(t, options)
+	if assignment := strict.Classify(newRead(start, insert, "")); assignment.Status != OneEnd {
+		t.Errorf("expected a read with one barcode to be left out, got %s", assignment.Status)
+	}
+	if assignment := strict.Classify(newRead(start, insert, end)); assignment.Status != Classified {
+		t.Errorf("expected a read with both barcodes to be classified, got %s", assignment.Status)
+	}
+}
+
+func TestNewDemultiplexer(t *testing.T) {
+	barcodes, err := primers.CreateBarcodeSet(primers.DefaultBarcodeConstraints(16, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := map[string][]Sample{
+		"duplicate names":    {{"a", barcodes[0]}, {"a", barcodes[1]}},
+		"reserved name":      {{Unclassified, barcodes[0]}},
+		"parent directory":   {{"..", barcodes[0]}},
+		"path separator":     {{"../a", barcodes[0]}},
+		"duplicate barcodes": {{"a", barcodes[0]}, {"b", barcodes[0]}},
+		"lengths":            {{"a", barcodes[0]}, {"b", barcodes[1][1:]}},
+		"no samples":         nil,
+	}
+	for name, samples := range invalid {
+		if _, err := NewDemultiplexer(samples, DefaultOptions()); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	options := DefaultOptions()
+	options.SearchLength = 0
+	if _, err := NewDemultiplexer(SamplesFromBarcodes(barcodes), options); err == nil {
+		t.Errorf("expected an error for a search length of 0")
+	}
+}
+
+func TestDemultiplex(t *testing.T) {
+	demultiplexer, barcodes := newTestDemultiplexer(t, DefaultOptions())
+	var reads []fastq.Fastq
+	for index := 0; index < 30; index++ {
+		insert, _ := random.DNASequence(200, int64(index))
+		barcode := adapter + barcodes[index%3]
+		reads = append(reads, newRead(barcode, insert, transform.ReverseComplement(barcode)))
+	}
+	unclassified, _ := random.DNASequence(200, 100)
+	reads = append(reads, newRead("", unclassified, ""))
+	runBytes, _ := fastq.Build(reads)
+
+	var barcode01, other bytes.Buffer
+	writers := map[string]io.Writer{"barcode01": &barcode01, Unclassified: &other}
+	summary, err := demultiplexer.Demultiplex(fastq.NewParser(bytes.NewReader(runBytes), 2*32*1024), writers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Reads != 31 || summary.Samples["barcode01"] != 10 || summary.Samples["barcode03"] != 10 || summary.BothEnds != 30 || summary.Unclassified[NoBarcode] != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	written, err := fastq.Parse(&barcode01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 10 || len(written[0].Sequence) != 200 {
+		t.Errorf("expected 10 trimmed reads of barcode01, got %d", len(written))
+	}
+	if written, _ := fastq.Parse(&other); len(written) != 1 {
+		t.Errorf("expected 1 unclassified read, got %d", len(written))
+	}
+
+	directory := t.TempDir()
+	if _, err := demultiplexer.DemultiplexToDirectory(fastq.NewParser(bytes.NewReader(runBytes), 2*32*1024), directory); err != nil {
+		t.Fatal(err)
+	}
+	written, err = fastq.Read(filepath.Join(directory, "barcode02.fastq"))
+	if err != nil || len(written) != 10 {
+		t.Errorf("expected 10 reads in barcode02.fastq, got %d: %v", len(written), err)
+	}
+	if _, err := os.Stat(filepath.Join(directory, Unclassified+".fastq")); err != nil {
+		t.Error(err)
+	}
+}
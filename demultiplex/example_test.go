@@ -0,0 +1,29 @@
+package demultiplex_test
+
+import (
+	"fmt"
+
+	"github.com/bebop/poly/demultiplex"
+	"github.com/bebop/poly/io/fastq"
+	"github.com/bebop/poly/primers"
+	"github.com/bebop/poly/transform"
+)
+
+func Example_basic() {
+	// 12 barcodes of 16 bases, any single error of which is corrected
+	barcodes, _ := primers.CreateBarcodeSet(primers.DefaultBarcodeConstraints(16, 12))
+	demultiplexer, _ := demultiplex.NewDemultiplexer(demultiplex.SamplesFromBarcodes(barcodes), demultiplex.DefaultOptions())
+
+	// a read of the fifth sample with a base of its first barcode deleted,
+	// between the nanopore sequencing adapters
+	adapter := "AATGTACTTCGTTCAGTTACGTATTGCT"
+	insert := "ATGGCTAGCAAAGGAGAAGAACTTTTCACTGGAGTTGTCCCAATTCTTGTTGAATTAGATGGTGATGTTAATGGGCACAAATTTTCTGTC"
+	read := fastq.Fastq{Identifier: "read", Sequence: adapter + barcodes[4][:7] + barcodes[4][8:] + insert + transform.ReverseComplement(adapter+barcodes[4])}
+
+	assignment := demultiplexer.Classify(read)
+	fmt.Println(demultiplexer.Samples[assignment.Sample].Name, assignment.Status, len(assignment.Hits))
+	fmt.Println(assignment.Read.Sequence == insert)
+	// Output:
+	// barcode05 classified 2
+	// true
+}
@@ -2,6 +2,7 @@ package fold_test
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/bebop/poly/fold"
 )
@@ -12,3 +13,52 @@ func ExampleZuker() {
 	fmt.Println(brackets)
 	// Output: .((((.(((......)))....))))
 }
+
+func ExampleZukerWithMaxSpan() {
+	// only pairs between bases at most 10 apart are allowed, so the long
+	// range helix closing the two hairpins can't form
+	result, _ := fold.ZukerWithMaxSpan("GGGGAAGCGCGAAAGCGCAAAGCGCGAAAGCGCAACCCC", 37.0, 10)
+	dotBracket, _ := result.PairTable().DotBracket()
+	fmt.Println(dotBracket)
+	// Output: .......(((....))).....(((....))).......
+}
+
+func ExampleInverseFold() {
+	// design a hairpin closed by a GAAA tetraloop
+	target := "((((((....))))))"
+	constraints := fold.InverseFoldConstraints{Sequence: "NNNNNNGAAANNNNNN"}
+	designs, _ := fold.InverseFold(target, constraints, 37.0, 1, 1)
+
+	result, _ := fold.Zuker(designs[0], 37.0)
+	fmt.Println(result.DotBracket() == target)
+	// Output: true
+}
+
+func ExampleParseDotBracket() {
+	table, _ := fold.ParseDotBracket("((...))")
+	fmt.Println(table)
+	// Output: [6 5 -1 -1 -1 1 0]
+}
+
+func ExampleBasePairDistance() {
+	first, _ := fold.ParseDotBracket("((((....))))")
+	second, _ := fold.ParseDotBracket(".(((....))).")
+	distance, _ := fold.BasePairDistance(first, second)
+	fmt.Println(distance)
+	// Output: 1
+}
+
+func ExampleTreeEditDistance() {
+	first, _ := fold.ParseDotBracket("((((....))))")
+	second, _ := fold.ParseDotBracket("((((.....))))")
+	distance, _ := fold.TreeEditDistance(first, second)
+	fmt.Println(distance)
+	// Output: 1
+}
+
+func ExampleSVG() {
+	table, _ := fold.ParseDotBracket("((((....))))")
+	svg, _ := fold.SVG("GGGGAAAACCCC", table)
+	fmt.Println(strings.HasPrefix(svg, "<svg"))
+	// Output: true
+}
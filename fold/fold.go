@@ -54,20 +54,82 @@ import (
 // Returns a slice of NucleicAcidStructure with the energy and description,
 // i.e. stacks, bulges, hairpins, etc.
 func Zuker(seq string, temp float64) (Result, error) {
-	foldContext, err := newFoldingContext(seq, temp)
+	return ZukerWithMaxSpan(seq, temp, 0)
+}
+
+// ZukerWithMaxSpan folds the sequence like Zuker, but only considers base
+// pairs between bases at most maxSpan apart, like the -L option of RNAplfold
+// from the ViennaRNA package:
+// Bernhart, Hofacker and Stadler, 2006
+// https://doi.org/10.1093/bioinformatics/btk014
+//
+// The time taken then grows linearly with the length of the sequence instead
+// of cubically, which makes folding long sequences such as mRNAs practical.
+// The local structures are chained along the sequence without the multi-branch
+// penalty Zuker applies between the helices of the exterior loop. A maxSpan of
+// zero, or one covering the whole sequence, folds exactly like Zuker.
+func ZukerWithMaxSpan(seq string, temp float64, maxSpan int) (Result, error) {
+	if maxSpan < 0 {
+		return Result{}, fmt.Errorf("the maximum base pair span must not be negative, got %d", maxSpan)
+	}
+	foldContext, err := newFoldingContextWithMaxSpan(seq, temp, maxSpan)
 	if err != nil {
 		return Result{}, fmt.Errorf("error creating folding context: %w", err)
 	}
 
 	// get the minimum free energy structure out of the cache
-
+	sequenceLength := len(foldContext.seq)
+	if foldContext.maxSpan < sequenceLength-1 {
+		return Result{sequence: foldContext.seq, structs: tracebackExterior(foldContext)}, nil
+	}
 	return Result{
-		structs: traceback(0, len(seq)-1, foldContext),
+		sequence: foldContext.seq,
+		structs:  traceback(0, sequenceLength-1, foldContext),
 	}, nil
 }
 
-// unpairedMinimumFreeEnergyW returns the minimum free energy of a subsequence
-// at start and terminating at end.
+// fill computes the V and W caches, see fillPaired and fillUnpaired, from the
+// shortest subsequences to the longest ones so every cell only depends on
+// cells that are already filled.
+func (foldContext *context) fill() error {
+	sequenceLength := len(foldContext.seq)
+
+	// subsequences too short for a hairpin have no structure. Single bases
+	// are only marked as such by multibranch loops, see fillPaired.
+	if sequenceLength-1 < minLenForStruct {
+		foldContext.markInvalid(0, sequenceLength-1)
+	} else {
+		for span := 1; span < minLenForStruct; span++ {
+			for start := 0; start+span < sequenceLength; start++ {
+				foldContext.markInvalid(start, start+span)
+			}
+		}
+	}
+
+	for span := minLenForStruct; span <= foldContext.maxSpan; span++ {
+		for start := 0; start+span < sequenceLength; start++ {
+			end := start + span
+			if err := foldContext.fillPaired(start, end); err != nil {
+				return err
+			}
+			if err := foldContext.fillUnpaired(start, end); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// markInvalid marks the subsequence at start and terminating at end as
+// having no admissible structure in the W cache.
+func (foldContext *context) markInvalid(start, end int) {
+	if index := foldContext.cellIndex(start, end); index >= 0 {
+		foldContext.unpairedCells[index] = unpairedCell{energy: math.Inf(1), loop: invalidLoop}
+	}
+}
+
+// fillUnpaired computes the minimum free energy of a subsequence at start and
+// terminating at end, and stores it in the W cache.
 //
 // From Zuker and Stiegler, 1981: let W(i,j) be the minimum free energy of all
 // possible admissible structures formed from the subsequence Sij.
@@ -75,117 +137,119 @@ func Zuker(seq string, temp float64) (Result, error) {
 // Figure 2B in Zuker and Stiegler, 1981
 // Args:
 //
-//		seq: The sequence being folded
 //		start: The start index
 //		end: The end index (inclusive)
 //	 foldContext: The context for this sequence
-//
-// Returns the free energy for the subsequence from start to end
-func unpairedMinimumFreeEnergyW(start, end int, foldContext context) (nucleicAcidStructure, error) {
-	if !foldContext.unpairedMinimumFreeEnergyW[start][end].Equal(defaultStructure) {
-		return foldContext.unpairedMinimumFreeEnergyW[start][end], nil
-	}
-
-	if end-start < minLenForStruct {
-		foldContext.unpairedMinimumFreeEnergyW[start][end] = invalidStructure
-		return foldContext.unpairedMinimumFreeEnergyW[start][end], nil
-	}
+func (foldContext *context) fillUnpaired(start, end int) error {
+	index := foldContext.cellIndex(start, end)
+	cell := unpairedCell{energy: math.Inf(1), loop: invalidLoop}
 
-	endDanglingLeft, err := unpairedMinimumFreeEnergyW(start+1, end, foldContext)
-	if err != nil {
-		return defaultStructure, fmt.Errorf("w: subsequence (%d, %d): %w", start, end, err)
+	if endDanglingLeft := foldContext.unpairedMinimumFreeEnergyW(start+1, end); endDanglingLeft != math.Inf(-1) && endDanglingLeft < cell.energy {
+		cell = unpairedCell{energy: endDanglingLeft, loop: danglingLeftLoop}
 	}
-	endDanglingRight, err := unpairedMinimumFreeEnergyW(start, end-1, foldContext)
-	if err != nil {
-		return defaultStructure, fmt.Errorf("w: subsequence (%d, %d): %w", start, end, err)
+	if endDanglingRight := foldContext.unpairedMinimumFreeEnergyW(start, end-1); endDanglingRight != math.Inf(-1) && endDanglingRight < cell.energy {
+		cell = unpairedCell{energy: endDanglingRight, loop: danglingRightLoop}
 	}
-	endsPaired, err := pairedMinimumFreeEnergyV(start, end, foldContext)
-	if err != nil {
-		return defaultStructure, fmt.Errorf("w: subsequence (%d, %d): %w", start, end, err)
+	if endsPaired := foldContext.pairedCells[index].energy; endsPaired != math.Inf(-1) && endsPaired < cell.energy {
+		cell = unpairedCell{energy: endsPaired, loop: pairedLoop}
 	}
-
-	endBifurcation := invalidStructure
-	for k := start + 1; k < end-1; k++ {
-		testBranch, err := multibranch(start, k, end, foldContext, false)
+	for mid := start + 1; mid < end-1; mid++ {
+		endBifurcation, ok, err := multibranch(start, mid, end, foldContext, false)
 		if err != nil {
-			return defaultStructure, fmt.Errorf("w: subsequence (%d, %d): %w", start, end, err)
+			return fmt.Errorf("w: subsequence (%d, %d): %w", start, end, err)
 		}
+		if ok && endBifurcation.energy < cell.energy {
+			cell = unpairedCell{energy: endBifurcation.energy, loop: multibranchLoop, mid: int32(mid)}
+		}
+	}
 
-		if testBranch.Valid() && testBranch.energy < endBifurcation.energy {
-			endBifurcation = testBranch
+	// record the branches of the structure for the multi-branch loops
+	// enclosing it
+	switch cell.loop {
+	case danglingLeftLoop:
+		source := foldContext.unpairedCells[foldContext.cellIndex(start+1, end)]
+		cell.branchesStart, cell.branchesEnd = source.branchesStart, source.branchesEnd
+	case danglingRightLoop:
+		source := foldContext.unpairedCells[foldContext.cellIndex(start, end-1)]
+		cell.branchesStart, cell.branchesEnd = source.branchesStart, source.branchesEnd
+	case pairedLoop:
+		paired := foldContext.pairedCells[index]
+		switch paired.loop {
+		case stackLoop, bulgeLoop, interiorLoop:
+			cell.branchesStart = int32(len(foldContext.branchPool))
+			foldContext.branchPool = append(foldContext.branchPool, subsequence{int(paired.innerStart), int(paired.innerEnd)})
+			cell.branchesEnd = int32(len(foldContext.branchPool))
+		case multibranchLoop:
+			mid := int(paired.innerStart)
+			cell.branchesStart, cell.branchesEnd = foldContext.addBranches(start+1, mid, mid+1, end-1)
 		}
+	case multibranchLoop:
+		mid := int(cell.mid)
+		cell.branchesStart, cell.branchesEnd = foldContext.addBranches(start, mid, mid+1, end)
 	}
 
-	minStuctEnergy := minimumStructure(endDanglingLeft, endDanglingRight, endsPaired, endBifurcation)
-	foldContext.unpairedMinimumFreeEnergyW[start][end] = minStuctEnergy
-	return minStuctEnergy, nil
+	foldContext.unpairedCells[index] = cell
+	return nil
 }
 
-// pairedMinimumFreeEnergyV returns the minimum free energy of a subsequence of
-// paired bases and end.
+// fillPaired computes the minimum free energy of a subsequence of paired
+// bases and end, and stores it in the V cache.
 // From Figure 2B of Zuker, 1981: let V(i,j) be the minimum free energy of all
 // possible admissible structures formed from Sij in which Si and Sj base pair
 // with each other. If Si and Sj cannot base pair, then V(i,j) = infinity
 //
-// If start and end don't bp, store and return INF.
+// If start and end don't bp, store INF.
 // See: Figure 2B of Zuker, 1981
 // Args:
 //
 //		start: The start index
 //		end: The end index (inclusive)
 //	 foldContext: The context for this sequence
-//
-// Returns the minimum energy folding structure possible between start and end on seq
-func pairedMinimumFreeEnergyV(start, end int, foldContext context) (nucleicAcidStructure, error) {
-	if !foldContext.pairedMinimumFreeEnergyV[start][end].Equal(defaultStructure) {
-		return foldContext.pairedMinimumFreeEnergyV[start][end], nil
-	}
+func (foldContext *context) fillPaired(start, end int) error {
+	cell := &foldContext.pairedCells[foldContext.cellIndex(start, end)]
+	*cell = pairedCell{energy: math.Inf(1), loop: invalidLoop}
 
 	// the ends must basepair for pairedMinimumFreeEnergyV(start,end)
-	if foldContext.energies.complement(rune(foldContext.seq[start])) != rune(foldContext.seq[end]) {
-		foldContext.pairedMinimumFreeEnergyV[start][end] = invalidStructure
-		return foldContext.pairedMinimumFreeEnergyV[start][end], nil
+	if !foldContext.complementary(start, end) {
+		return nil
 	}
 	// if the basepair is isolated, and the seq large, penalize at 1,600 kcal/mol
 	// heuristic for speeding this up
 	// from https://www.ncbi.nlm.nih.gov/pubmed/10329189
+	sequenceLength := len(foldContext.seq)
 	isolatedOuter := true
-	if start > 0 && end < len(foldContext.seq)-1 {
-		isolatedOuter = foldContext.energies.complement(rune(foldContext.seq[start-1])) != rune(foldContext.seq[end+1])
+	if start > 0 && end < sequenceLength-1 {
+		isolatedOuter = !foldContext.complementary(start-1, end+1)
 	}
-	isolatedInner := foldContext.energies.complement(rune(foldContext.seq[start+1])) != rune(foldContext.seq[end-1])
+	isolatedInner := !foldContext.complementary(start+1, end-1)
 
 	if isolatedOuter && isolatedInner {
-		foldContext.pairedMinimumFreeEnergyV[start][end] = nucleicAcidStructure{energy: isolatedBasePairPenalty}
-		return foldContext.pairedMinimumFreeEnergyV[start][end], nil
+		*cell = pairedCell{energy: isolatedBasePairPenalty, loop: isolatedPair}
+		return nil
 	}
 
-	paired := pair(foldContext.seq, start, start+1, end, end-1)
-	hairpin, err := hairpin(start, end, foldContext)
+	hairpinEnergy, err := hairpin(start, end, foldContext)
 	if err != nil {
-		return defaultStructure, fmt.Errorf("v: subsequence (%d, %d): %w", start, end, err)
+		return fmt.Errorf("v: subsequence (%d, %d): %w", start, end, err)
 	}
-	e1 := nucleicAcidStructure{energy: hairpin, description: "HAIRPIN:" + paired}
+	*cell = pairedCell{energy: hairpinEnergy, loop: hairpinLoop}
 	if end-start == minLenForStruct { // small hairpin; 4bp
-		foldContext.pairedMinimumFreeEnergyV[start][end] = e1
-		foldContext.unpairedMinimumFreeEnergyW[start][end] = e1
-		return foldContext.pairedMinimumFreeEnergyV[start][end], nil
+		return nil
 	}
 
-	n := len(foldContext.seq)
-	e2 := nucleicAcidStructure{energy: math.Inf(1)}
+	pairLeftInner := foldContext.nearestNeighbors.known[foldContext.pairIndex(start, start+1, end, end-1)]
 	for rightOfStart := start + 1; rightOfStart < end-minLenForStruct; rightOfStart++ {
-		for leftOfEnd := rightOfStart + minLenForStruct; leftOfEnd < end; leftOfEnd++ {
+		unpairedLeft := rightOfStart - start - 1
+		if unpairedLeft > maxLoopLength {
+			break
+		}
+		for leftOfEnd := max(rightOfStart+minLenForStruct, end-1-maxLoopLength+unpairedLeft); leftOfEnd < end; leftOfEnd++ {
 			// rightOfStart and leftOfEnd must match
-			if foldContext.energies.complement(rune(foldContext.seq[rightOfStart])) != rune(foldContext.seq[leftOfEnd]) {
+			if !foldContext.complementary(rightOfStart, leftOfEnd) {
 				continue
 			}
 
-			paired := pair(foldContext.seq, start, rightOfStart, end, leftOfEnd)
-			pairLeft := pair(foldContext.seq, start, start+1, end, end-1)
-			pairRight := pair(foldContext.seq, rightOfStart-1, rightOfStart, leftOfEnd+1, leftOfEnd)
-			_, pairLeftInner := foldContext.energies.nearestNeighbors[pairLeft]
-			_, pairRightInner := foldContext.energies.nearestNeighbors[pairRight]
+			pairRightInner := foldContext.nearestNeighbors.known[foldContext.pairIndex(rightOfStart-1, rightOfStart, leftOfEnd+1, leftOfEnd)]
 			pairInner := pairLeftInner || pairRightInner
 
 			isStack := rightOfStart == start+1 && leftOfEnd == end-1
@@ -193,82 +257,55 @@ func pairedMinimumFreeEnergyV(start, end int, foldContext context) (nucleicAcidS
 			bulgeRight := leftOfEnd < end-1
 
 			var (
-				e2Test     float64
-				e2TestType string
+				loopEnergy float64
+				loop       loopType
 				err        error
 			)
 			switch {
 			case isStack:
 				// it's a neighboring/stacking pair in a helix
-				e2Test = stack(start, rightOfStart, end, leftOfEnd, foldContext)
-				e2TestType = fmt.Sprintf("STACK:%s", paired)
-
-				if start > 0 && end == n-1 || start == 0 && end < n-1 {
-					// there's a dangling end
-					e2TestType = fmt.Sprintf("STACKDanglingEnds:%s", paired)
-				}
+				loopEnergy = stack(start, rightOfStart, end, leftOfEnd, foldContext)
+				loop = stackLoop
 			case bulgeLeft && bulgeRight && !pairInner:
 				// it's an interior loop
-				il, err := internalLoop(start, rightOfStart, end, leftOfEnd, foldContext)
-				if err != nil {
-					return defaultStructure, fmt.Errorf("v: subsequence (%d, %d): %w", start, end, err)
-				}
-				e2Test = il
-				e2TestType = fmt.Sprintf("INTERIOR_LOOP:%d/%d", rightOfStart-start, end-leftOfEnd)
-
-				if rightOfStart-start == 2 && end-leftOfEnd == 2 {
-					loopLeftIndex := foldContext.seq[start : rightOfStart+1]
-					loopRightIndex := foldContext.seq[leftOfEnd : end+1]
-					// technically an interior loop of 1. really 1bp mismatch
-					e2TestType = fmt.Sprintf("STACK:%s/%s", loopLeftIndex, transform.Reverse(loopRightIndex))
-				}
-			case bulgeLeft && !bulgeRight:
-				// it's a bulge on the left side
-				e2Test, err = Bulge(start, rightOfStart, end, leftOfEnd, foldContext)
-				if err != nil {
-					return defaultStructure, fmt.Errorf("v: subsequence (%d, %d): %w", start, end, err)
-				}
-				e2TestType = fmt.Sprintf("BULGE:%d", rightOfStart-start)
-			case !bulgeLeft && bulgeRight:
-				// it's a bulge on the right side
-				e2Test, err = Bulge(start, rightOfStart, end, leftOfEnd, foldContext)
-				if err != nil {
-					return defaultStructure, fmt.Errorf("v: subsequence (%d, %d): %w", start, end, err)
-				}
-				e2TestType = fmt.Sprintf("BULGE:%d", end-leftOfEnd)
+				loopEnergy, err = internalLoop(start, rightOfStart, end, leftOfEnd, foldContext)
+				loop = interiorLoop
+			case bulgeLeft != bulgeRight:
+				// it's a bulge on the left or right side
+				loopEnergy, err = Bulge(start, rightOfStart, end, leftOfEnd, foldContext)
+				loop = bulgeLoop
 			default:
 				// it's basically a hairpin, only outside bp match
 				continue
 			}
-
-			// add pairedMinimumFreeEnergyV(start', end')
-			tv, err := pairedMinimumFreeEnergyV(rightOfStart, leftOfEnd, foldContext)
 			if err != nil {
-				return defaultStructure, fmt.Errorf("v: subsequence (%d, %d): %w", start, end, err)
+				return fmt.Errorf("v: subsequence (%d, %d): %w", start, end, err)
 			}
-			e2Test += tv.energy
-			if e2Test != math.Inf(-1) && e2Test < e2.energy {
-				e2 = nucleicAcidStructure{energy: e2Test, description: e2TestType, inner: []subsequence{{rightOfStart, leftOfEnd}}}
+
+			// add pairedMinimumFreeEnergyV(start', end')
+			loopEnergy += foldContext.pairedCells[foldContext.cellIndex(rightOfStart, leftOfEnd)].energy
+			if loopEnergy != math.Inf(-1) && loopEnergy < cell.energy {
+				*cell = pairedCell{energy: loopEnergy, loop: loop, innerStart: int32(rightOfStart), innerEnd: int32(leftOfEnd)}
 			}
 		}
 	}
 
-	e3 := invalidStructure
-	if !isolatedOuter || start == 0 || end == len(foldContext.seq)-1 {
-		for k := start + 1; k < end-1; k++ {
-			e3Test, err := multibranch(start, k, end, foldContext, true)
+	if !isolatedOuter || start == 0 || end == sequenceLength-1 {
+		// the bases next to the closing pair can't be a branch on their own.
+		// This only matters to the traceback of sequences without structure.
+		foldContext.markInvalid(start+1, start+1)
+		foldContext.markInvalid(end-1, end-1)
+		for mid := start + 1; mid < end-1; mid++ {
+			branched, ok, err := multibranch(start, mid, end, foldContext, true)
 			if err != nil {
-				return defaultStructure, fmt.Errorf("v: subsequence (%d, %d): %w", start, end, err)
+				return fmt.Errorf("v: subsequence (%d, %d): %w", start, end, err)
 			}
-
-			if e3Test.Valid() && e3Test.energy < e3.energy {
-				e3 = e3Test
+			if ok && branched.energy < cell.energy {
+				*cell = pairedCell{energy: branched.energy, loop: multibranchLoop, innerStart: int32(mid)}
 			}
 		}
 	}
-	e := minimumStructure(e1, e2, e3)
-	foldContext.pairedMinimumFreeEnergyV[start][end] = e
-	return e, nil
+	return nil
 }
 
 // Bulge calculates the free energy associated with a bulge.
@@ -282,37 +319,26 @@ func pairedMinimumFreeEnergyV(start, end int, foldContext context) (nucleicAcidS
 //	 foldContext: The FoldingContext for this sequence
 //
 // Returns the increment in free energy from the bulge
-func Bulge(start, rightOfStart, end, leftOfEnd int, foldContext context) (float64, error) {
+func Bulge(start, rightOfStart, end, leftOfEnd int, foldContext *context) (float64, error) {
 	loopLength := max(rightOfStart-start-1, end-leftOfEnd-1)
 	if loopLength <= 0 {
 		return 0, fmt.Errorf("bulge: the length of the bulge at (%d, %d) is %d", start, end, loopLength)
 	}
 
-	var dG float64
-
-	// add penalty based on size
-	if foldEnergy, ok := foldContext.energies.bulgeLoops[loopLength]; ok {
-		enthalpyHDifference, entropySDifference := foldEnergy.enthalpyH, foldEnergy.entropyS
-		dG = deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-	} else {
-		// it's too large for pre-calculated list, extrapolate
-		foldEnergy := foldContext.energies.bulgeLoops[maxLenPreCalulated]
-		enthalpyHDifference, entropySDifference := foldEnergy.enthalpyH, foldEnergy.entropyS
-		dG = deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-		dG = jacobsonStockmayer(loopLength, maxLenPreCalulated, dG, foldContext.temp)
-	}
+	// add penalty based on size, extrapolated for bulges too large for the
+	// pre-calculated list
+	dG := foldContext.bulgeLoops[loopLength]
 
 	if loopLength == 1 {
 		// if len 1, include the delta G of intervening nearestNeighbors (SantaLucia 2004)
-		paired := pair(foldContext.seq, start, rightOfStart, end, leftOfEnd)
-		if _, ok := foldContext.energies.nearestNeighbors[paired]; !ok {
-			return 0, fmt.Errorf("bulge: paired %q not in the nearestNeighbors energies", paired)
+		if !foldContext.nearestNeighbors.known[foldContext.pairIndex(start, rightOfStart, end, leftOfEnd)] {
+			return 0, fmt.Errorf("bulge: paired %q not in the nearestNeighbors energies", pair(foldContext.seq, start, rightOfStart, end, leftOfEnd))
 		}
 		dG += stack(start, rightOfStart, end, leftOfEnd, foldContext)
 	}
 
 	// penalize AT terminal bonds
-	for _, k := range []int{start, rightOfStart, end, leftOfEnd} {
+	for _, k := range [4]int{start, rightOfStart, end, leftOfEnd} {
 		if foldContext.seq[k] == 'A' {
 			dG += closingATPenalty
 		}
@@ -321,25 +347,31 @@ func Bulge(start, rightOfStart, end, leftOfEnd int, foldContext context) (float6
 	return dG, nil
 }
 
-func addBranch(structure nucleicAcidStructure, branches *[]subsequence, foldContext context) error {
-	if !structure.Valid() || len(structure.inner) == 0 {
-		return nil
-	}
-	if len(structure.inner) == 1 {
-		*branches = append(*branches, structure.inner[0])
-		return nil
-	}
-	for _, inner := range structure.inner {
-		structure, err := unpairedMinimumFreeEnergyW(inner.start, inner.end, foldContext)
-		if err != nil {
-			return err
-		}
-		err = addBranch(structure, branches, foldContext)
-		if err != nil {
-			return err
+// addBranches gathers the branches of the multi-branch loop made of the
+// structures of W(leftStart, leftEnd) and W(rightStart, rightEnd) in the
+// branch pool, and returns where they start and end in the pool.
+//
+// Every branch of the loop is replaced by the branches of its own W
+// structure, which is how the loop's branches are found when it is itself
+// the branch of an enclosing multi-branch loop.
+func (foldContext *context) addBranches(leftStart, leftEnd, rightStart, rightEnd int) (int32, int32) {
+	branchesStart := int32(len(foldContext.branchPool))
+	for _, side := range [2][]subsequence{foldContext.branches(leftStart, leftEnd), foldContext.branches(rightStart, rightEnd)} {
+		for _, branch := range side {
+			foldContext.branchPool = append(foldContext.branchPool, foldContext.branches(branch.start, branch.end)...)
 		}
 	}
-	return nil
+	return branchesStart, int32(len(foldContext.branchPool))
+}
+
+// branchedLoop is a multi-branch loop found by multibranch.
+type branchedLoop struct {
+	energy float64
+	// unpaired is the number of unpaired bases in the loop.
+	unpaired int
+	// branchCount is the number of helices in the loop, including the
+	// closing one if any.
+	branchCount int
 }
 
 // multibranch calculates a multi-branch foldEnergy penalty using a linear formula.
@@ -355,70 +387,53 @@ func addBranch(structure nucleicAcidStructure, branches *[]subsequence, foldCont
 //		helix: Whether this multibranch is enclosed by a helix
 //		helix: Whether pairedMinimumFreeEnergyV(start, end) bond with one another in a helix
 //
-// Returns a multi-branch structure
-func multibranch(start, mid, end int, foldContext context, helix bool) (nucleicAcidStructure, error) {
-	var (
-		left, right nucleicAcidStructure
-		err         error
-	)
+// Returns a multi-branch loop, and whether it is valid
+func multibranch(start, mid, end int, foldContext *context, helix bool) (branchedLoop, bool, error) {
+	leftStart, leftEnd, rightStart, rightEnd := start, mid, mid+1, end
 	if helix {
-		left, err = unpairedMinimumFreeEnergyW(start+1, mid, foldContext)
-		if err != nil {
-			return defaultStructure, fmt.Errorf("multibranch: subsequence (%d, %d, %d): %w", start, end, mid, err)
-		}
-		right, err = unpairedMinimumFreeEnergyW(mid+1, end-1, foldContext)
-		if err != nil {
-			return defaultStructure, fmt.Errorf("multibranch: subsequence (%d, %d, %d): %w", start, end, mid, err)
-		}
-	} else {
-		left, err = unpairedMinimumFreeEnergyW(start, mid, foldContext)
-		if err != nil {
-			return defaultStructure, fmt.Errorf("multibranch: subsequence (%d, %d, %d): %w", start, end, mid, err)
-		}
-		right, err = unpairedMinimumFreeEnergyW(mid+1, end, foldContext)
-		if err != nil {
-			return defaultStructure, fmt.Errorf("multibranch: subsequence (%d, %d, %d): %w", start, end, mid, err)
-		}
+		leftStart, rightEnd = start+1, end-1
 	}
 
-	if !left.Valid() || !right.Valid() {
-		return invalidStructure, nil
+	left := foldContext.unpairedMinimumFreeEnergyW(leftStart, leftEnd)
+	right := foldContext.unpairedMinimumFreeEnergyW(rightStart, rightEnd)
+	if math.IsInf(left, 0) || math.IsInf(right, 0) {
+		return branchedLoop{}, false, nil
 	}
 
 	// gather all branches of this multi-branch structure
-	var branches []subsequence
-
-	// in python this was a recursive closure, in Go this is not possible so
-	// we pull it out and pass all the parameters
-	err = addBranch(left, &branches, foldContext)
-	if err != nil {
-		return defaultStructure, fmt.Errorf("multibranch: subsequence (%d, %d, %d): %w", start, mid, end, err)
-	}
-	err = addBranch(right, &branches, foldContext)
-	if err != nil {
-		return defaultStructure, fmt.Errorf("multibranch: subsequence (%d, %d, %d): %w", start, mid, end, err)
-	}
+	leftBranches := foldContext.branches(leftStart, leftEnd)
+	rightBranches := foldContext.branches(rightStart, rightEnd)
+	branchCount := len(leftBranches) + len(rightBranches)
 
 	// this isn't multi-branched
-	if len(branches) < 2 {
-		return invalidStructure, nil
+	if branchCount < 2 {
+		return branchedLoop{}, false, nil
 	}
 
 	// if there's a helix, start,end counts as well
+	curSequence := subsequence{start, end}
 	if helix {
-		branches = append(branches, subsequence{start, end})
+		branchCount++
+	}
+	branch := func(index int) subsequence {
+		if index < len(leftBranches) {
+			return leftBranches[index]
+		}
+		if index < len(leftBranches)+len(rightBranches) {
+			return rightBranches[index-len(leftBranches)]
+		}
+		return curSequence
 	}
 
 	// count up unpaired bp and asymmetry
-	branchCount := len(branches)
 	unpaired := 0
 	summedEnergy := 0.0
-	curSequence := subsequence{start, end}
-	for index, currentBranch := range branches {
+	for index := 0; index < branchCount; index++ {
+		currentBranch := branch(index)
 		leftStart, leftEnd := currentBranch.start, currentBranch.end
-		curBranchLeft := branches[abs((index-1)%len(branches))]
+		curBranchLeft := branch(abs((index - 1) % branchCount))
 		leftOfEnd := curBranchLeft.end
-		curBranchRight := branches[abs((index+1)%len(branches))]
+		curBranchRight := branch(abs((index + 1) % branchCount))
 		rightStart, rightEnd := curBranchRight.start, curBranchRight.end
 
 		// add foldEnergy from unpaired bp to the right
@@ -428,7 +443,7 @@ func multibranch(start, mid, end int, foldContext context, helix bool) (nucleicA
 		unpairedLeft := 0
 		unpairedRight := 0
 		danglingEnergy := 0.0
-		if index == len(branches)-1 && !helix {
+		if index == branchCount-1 && !helix {
 			// pass
 		} else if curBranchRight == curSequence {
 			unpairedLeft = leftStart - leftOfEnd - 1
@@ -470,20 +485,16 @@ func multibranch(start, mid, end int, foldContext context, helix bool) (nucleicA
 		summedEnergy += danglingEnergy
 		unpaired += unpairedRight
 		if unpairedRight < 0 {
-			return defaultStructure, fmt.Errorf("multibranch: subsequence (%d, %d, %d): unpairedRight < 0", start, end, mid)
+			return branchedLoop{}, false, fmt.Errorf("multibranch: subsequence (%d, %d, %d): unpairedRight < 0", start, end, mid)
 		}
 
 		if currentBranch != curSequence { // add energy
-			w, err := unpairedMinimumFreeEnergyW(leftStart, leftEnd, foldContext)
-			if err != nil {
-				return defaultStructure, fmt.Errorf("multibranch: subsequence (%d, %d, %d): %w", start, end, mid, err)
-			}
-			summedEnergy += w.energy
+			summedEnergy += foldContext.unpairedMinimumFreeEnergyW(leftStart, leftEnd)
 		}
 	}
 
 	if unpaired < 0 {
-		return defaultStructure, fmt.Errorf("multibranch: subsequence (%d, %d, %d): unpaired < 0", start, end, mid)
+		return branchedLoop{}, false, fmt.Errorf("multibranch: subsequence (%d, %d, %d): unpaired < 0", start, end, mid)
 	}
 
 	// this is just for readability of the formulas below
@@ -495,7 +506,7 @@ func multibranch(start, mid, end int, foldContext context, helix bool) (nucleicA
 	)
 
 	// penalty for unmatched bp and multi-branch
-	multibranchEnergy := helicesCount + unpairedCount*float64(len(branches)) + coaxialStackCount*float64(unpaired)
+	multibranchEnergy := helicesCount + unpairedCount*float64(branchCount) + coaxialStackCount*float64(unpaired)
 
 	if unpaired == 0 {
 		multibranchEnergy = helicesCount + terminalMismatchCount
@@ -504,13 +515,7 @@ func multibranch(start, mid, end int, foldContext context, helix bool) (nucleicA
 	// energy of min-energy neighbors
 	e := multibranchEnergy + summedEnergy
 
-	// pointer to next structures
-	if helix {
-		// branches.pop()
-		branches = branches[:len(branches)-1]
-	}
-
-	return nucleicAcidStructure{energy: e, description: fmt.Sprintf("BIFURCATION:%dn/%dh", unpaired, branchCount), inner: branches}, nil
+	return branchedLoop{energy: e, unpaired: unpaired, branchCount: branchCount}, true, nil
 }
 
 // internalLoop calculates the free energy of an internal loop.
@@ -531,7 +536,7 @@ func multibranch(start, mid, end int, foldContext context, helix bool) (nucleicA
 //	 foldContext: The FoldingContext for this sequence
 //
 // Returns the free energy associated with the internal loop
-func internalLoop(start, rightOfStart, end, leftOfEnd int, foldContext context) (float64, error) {
+func internalLoop(start, rightOfStart, end, leftOfEnd int, foldContext *context) (float64, error) {
 	loopLeftIndex := rightOfStart - start - 1
 	loopRightIndex := end - leftOfEnd - 1
 	loopLength := loopLeftIndex + loopRightIndex
@@ -549,33 +554,17 @@ func internalLoop(start, rightOfStart, end, leftOfEnd int, foldContext context)
 		mismatchRightEnergy := stack(rightOfStart-1, rightOfStart, leftOfEnd+1, leftOfEnd, foldContext)
 		return mismatchLeftEnergy + mismatchRightEnergy, nil
 	}
-	var enthalpyHDifference, entropySDifference, dG float64
-	// apply a penalty based on loop size
-	if foldEnergy, ok := foldContext.energies.internalLoops[loopLength]; ok {
-		enthalpyHDifference, entropySDifference = foldEnergy.enthalpyH, foldEnergy.entropyS
-		dG = deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-	} else {
-		// it's too large an internal loop, extrapolate
-		foldEnergy := foldContext.energies.internalLoops[maxLenPreCalulated]
-		enthalpyHDifference, entropySDifference = foldEnergy.enthalpyH, foldEnergy.entropyS
-		dG = deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-		dG = jacobsonStockmayer(loopLength, maxLenPreCalulated, dG, foldContext.temp)
-	}
+	// apply a penalty based on loop size, extrapolated for loops too large for
+	// the pre-calculated list
+	dG := foldContext.internalLoops[loopLength]
 
 	// apply an asymmetry penalty
 	loopAsymmetry := math.Abs(float64(loopLeftIndex - loopRightIndex))
 	dG += loopsAsymmetryPenalty * loopAsymmetry
 
 	// apply penalty based on the mismatching pairs on either side of the loop
-	pairedMismatchLeftEnergy := pair(foldContext.seq, start, start+1, end, end-1)
-	foldEnergy := foldContext.energies.terminalMismatches[pairedMismatchLeftEnergy]
-	enthalpyHDifference, entropySDifference = foldEnergy.enthalpyH, foldEnergy.entropyS
-	dG += deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-
-	pairedMismatchRightEnergy := pair(foldContext.seq, rightOfStart-1, rightOfStart, leftOfEnd+1, leftOfEnd)
-	foldEnergy = foldContext.energies.terminalMismatches[pairedMismatchRightEnergy]
-	enthalpyHDifference, entropySDifference = foldEnergy.enthalpyH, foldEnergy.entropyS
-	dG += deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
+	dG += foldContext.terminalMismatches.deltaG[foldContext.pairIndex(start, start+1, end, end-1)]
+	dG += foldContext.terminalMismatches.deltaG[foldContext.pairIndex(rightOfStart-1, rightOfStart, leftOfEnd+1, leftOfEnd)]
 
 	return dG, nil
 }
@@ -597,76 +586,40 @@ func internalLoop(start, rightOfStart, end, leftOfEnd int, foldContext context)
 //	 foldContext: The FoldingContext for this sequence
 //
 // Returns the free energy of the nearestNeighbors pairing
-func stack(start, rightOfStart, end, leftOfEnd int, foldContext context) float64 {
-	// if any(x >= len(seq) for x in [start,rightOfStart, end, leftOfEnd]):
-	//    return 0.0
-	for _, indices := range []int{start, rightOfStart, end, leftOfEnd} {
-		if indices >= len(foldContext.seq) {
-			return 0
-		}
+func stack(start, rightOfStart, end, leftOfEnd int, foldContext *context) float64 {
+	sequenceLength := len(foldContext.seq)
+	if start >= sequenceLength || rightOfStart >= sequenceLength || end >= sequenceLength || leftOfEnd >= sequenceLength {
+		return 0
 	}
 
-	paired := pair(foldContext.seq, start, rightOfStart, end, leftOfEnd)
-	// if any(x == -1 for x in [start,rightOfStart, end, leftOfEnd]):
-	for _, indices := range []int{start, rightOfStart, end, leftOfEnd} {
-		if indices == -1 {
-			// it's a dangling end
-			foldEnergy := foldContext.energies.danglingEnds[paired]
-			enthalpyHDifference, entropySDifference := foldEnergy.enthalpyH, foldEnergy.entropyS
-			return deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-		}
+	paired := foldContext.pairIndex(start, rightOfStart, end, leftOfEnd)
+	if start == -1 || rightOfStart == -1 || end == -1 || leftOfEnd == -1 {
+		// it's a dangling end
+		return foldContext.danglingEnds.deltaG[paired]
 	}
 
-	if start > 0 && end < len(foldContext.seq)-1 {
+	// the nearestNeighbors energy, or the internalMismatches one if it's not
+	// a match
+	dG := foldContext.stacks.deltaG[paired]
+	switch {
+	case start > 0 && end < sequenceLength-1:
 		// it's internal
-		foldEnergy, ok := foldContext.energies.nearestNeighbors[paired]
-		if !ok {
-			foldEnergy = foldContext.energies.internalMismatches[paired]
-		}
-		enthalpyHDifference, entropySDifference := foldEnergy.enthalpyH, foldEnergy.entropyS
-		return deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-	}
-	if start == 0 && end == len(foldContext.seq)-1 {
+		return dG
+	case start == 0 && end == sequenceLength-1:
 		// it's terminal
-		foldEnergy, ok := foldContext.energies.nearestNeighbors[paired]
-		if !ok {
-			foldEnergy = foldContext.energies.internalMismatches[paired]
-		}
-		enthalpyHDifference, entropySDifference := foldEnergy.enthalpyH, foldEnergy.entropyS
-		return deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-	}
-
-	if start > 0 && end == len(foldContext.seq)-1 {
+		return dG
+	case start > 0 && end == sequenceLength-1:
 		// it's dangling on left
-		foldEnergy, ok := foldContext.energies.nearestNeighbors[paired]
-		if !ok {
-			foldEnergy = foldContext.energies.internalMismatches[paired]
-		}
-		enthalpyHDifference, entropySDifference := foldEnergy.enthalpyH, foldEnergy.entropyS
-		dG := deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-
-		pairDanglingEnds := fmt.Sprintf("%c%c/.%c", foldContext.seq[start-1], foldContext.seq[start], foldContext.seq[end])
-		if foldEnergy, ok := foldContext.energies.danglingEnds[pairDanglingEnds]; ok {
-			enthalpyHDifference, entropySDifference := foldEnergy.enthalpyH, foldEnergy.entropyS
-			dG += deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
+		pairDanglingEnds := foldContext.pairIndex(start-1, start, -1, end)
+		if foldContext.danglingEnds.known[pairDanglingEnds] {
+			dG += foldContext.danglingEnds.deltaG[pairDanglingEnds]
 		}
 		return dG
-	}
-
-	if start == 0 && end < len(foldContext.seq)-1 {
+	case start == 0 && end < sequenceLength-1:
 		// it's dangling on right
-		foldEnergy, ok := foldContext.energies.nearestNeighbors[paired]
-		if !ok {
-			foldEnergy = foldContext.energies.internalMismatches[paired]
-		}
-		enthalpyHDifference, entropySDifference := foldEnergy.enthalpyH, foldEnergy.entropyS
-		dG := deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-
-		pairDanglingEnds := fmt.Sprintf(".%c/%c%c", +foldContext.seq[start], foldContext.seq[end+1], foldContext.seq[end])
-		if foldEnergy, ok := foldContext.energies.danglingEnds[pairDanglingEnds]; ok {
-			enthalpyHDifference, entropySDifference := foldEnergy.enthalpyH, foldEnergy.entropyS
-			dG += deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-			return dG
+		pairDanglingEnds := foldContext.pairIndex(-1, start, end+1, end)
+		if foldContext.danglingEnds.known[pairDanglingEnds] {
+			return dG + foldContext.danglingEnds.deltaG[pairDanglingEnds]
 		}
 	}
 	return 0
@@ -680,16 +633,15 @@ func stack(start, rightOfStart, end, leftOfEnd int, foldContext context) float64
 //	 foldContext: The FoldingContext for this sequence
 //
 // Returns the free energy increment from the hairpin structure
-func hairpin(start, end int, foldContext context) (float64, error) {
+func hairpin(start, end int, foldContext *context) (float64, error) {
 	if end-start < minLenForStruct {
 		return math.Inf(1), nil
 	}
 
 	hairpinSeq := foldContext.seq[start : end+1]
 	hairpinLength := len(hairpinSeq) - 2
-	paired := pair(foldContext.seq, start, start+1, end, end-1)
 
-	if foldContext.energies.complement(rune(hairpinSeq[0])) != rune(hairpinSeq[len(hairpinSeq)-1]) {
+	if !foldContext.complementary(start, end) {
 		// not known terminal pair, nothing to close "hairpin"
 		return 0, fmt.Errorf("hairpin: subsequence (%d, %d): unknown hairpin terminal pairing %c - %c", start, end, hairpinSeq[0], hairpinSeq[len(hairpinSeq)-1])
 	}
@@ -703,23 +655,14 @@ func hairpin(start, end int, foldContext context) (float64, error) {
 		}
 	}
 
-	// add penalty based on size
-	if energy, ok := foldContext.energies.hairpinLoops[hairpinLength]; ok {
-		enthalpyHDifference, entropySDifference := energy.enthalpyH, energy.entropyS
-		dG += deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-	} else {
-		// it's too large, extrapolate
-		energy := foldContext.energies.hairpinLoops[maxLenPreCalulated]
-		enthalpyHDifference, entropySDifference := energy.enthalpyH, energy.entropyS
-		dGinc := deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
-		dG += jacobsonStockmayer(hairpinLength, maxLenPreCalulated, dGinc, foldContext.temp)
-	}
+	// add penalty based on size, extrapolated for hairpins too large for the
+	// pre-calculated list
+	dG += foldContext.hairpinLoops[hairpinLength]
 
 	// add penalty for a terminal mismatch
-	energy, ok := foldContext.energies.terminalMismatches[paired]
-	if hairpinLength > 3 && ok {
-		enthalpyHDifference, entropySDifference := energy.enthalpyH, energy.entropyS
-		dG += deltaG(enthalpyHDifference, entropySDifference, foldContext.temp)
+	paired := foldContext.pairIndex(start, start+1, end, end-1)
+	if hairpinLength > 3 && foldContext.terminalMismatches.known[paired] {
+		dG += foldContext.terminalMismatches.deltaG[paired]
 	}
 
 	// add penalty if length 3 and AT closing, formula 8 from SantaLucia, 2004
@@ -792,6 +735,100 @@ func pair(s string, start, rightOfStart, end, leftOfEnd int) string {
 	return string(ret)
 }
 
+// unpairedStructure rebuilds the structure cached for W(start, end).
+func (foldContext *context) unpairedStructure(start, end int) nucleicAcidStructure {
+	for {
+		index := foldContext.cellIndex(start, end)
+		if index < 0 {
+			return defaultStructure
+		}
+		cell := foldContext.unpairedCells[index]
+		switch cell.loop {
+		case uncomputedLoop:
+			return defaultStructure
+		case invalidLoop:
+			return invalidStructure
+		case danglingLeftLoop:
+			start++
+		case danglingRightLoop:
+			end--
+		case pairedLoop:
+			return foldContext.pairedStructure(start, end)
+		default:
+			return foldContext.multibranchStructure(start, int(cell.mid), end, false)
+		}
+	}
+}
+
+// pairedStructure rebuilds the structure cached for V(start, end).
+func (foldContext *context) pairedStructure(start, end int) nucleicAcidStructure {
+	index := foldContext.cellIndex(start, end)
+	if index < 0 {
+		return defaultStructure
+	}
+	cell := foldContext.pairedCells[index]
+	switch cell.loop {
+	case uncomputedLoop:
+		return defaultStructure
+	case invalidLoop:
+		return invalidStructure
+	case isolatedPair:
+		return nucleicAcidStructure{energy: cell.energy}
+	case hairpinLoop:
+		return nucleicAcidStructure{energy: cell.energy, description: "HAIRPIN:" + pair(foldContext.seq, start, start+1, end, end-1)}
+	case multibranchLoop:
+		return foldContext.multibranchStructure(start, int(cell.innerStart), end, true)
+	}
+
+	rightOfStart, leftOfEnd := int(cell.innerStart), int(cell.innerEnd)
+	structure := nucleicAcidStructure{energy: cell.energy, inner: []subsequence{{rightOfStart, leftOfEnd}}}
+	switch cell.loop {
+	case stackLoop:
+		paired := pair(foldContext.seq, start, rightOfStart, end, leftOfEnd)
+		structure.description = fmt.Sprintf("STACK:%s", paired)
+		sequenceLength := len(foldContext.seq)
+		if start > 0 && end == sequenceLength-1 || start == 0 && end < sequenceLength-1 {
+			// there's a dangling end
+			structure.description = fmt.Sprintf("STACKDanglingEnds:%s", paired)
+		}
+	case interiorLoop:
+		structure.description = fmt.Sprintf("INTERIOR_LOOP:%d/%d", rightOfStart-start, end-leftOfEnd)
+		if rightOfStart-start == 2 && end-leftOfEnd == 2 {
+			loopLeftIndex := foldContext.seq[start : rightOfStart+1]
+			loopRightIndex := foldContext.seq[leftOfEnd : end+1]
+			// technically an interior loop of 1. really 1bp mismatch
+			structure.description = fmt.Sprintf("STACK:%s/%s", loopLeftIndex, transform.Reverse(loopRightIndex))
+		}
+	case bulgeLoop:
+		structure.description = fmt.Sprintf("BULGE:%d", max(rightOfStart-start, end-leftOfEnd))
+	}
+	return structure
+}
+
+// multibranchStructure rebuilds the multi-branch structure of a cell, whose
+// inner subsequences are its branches (without the closing helix).
+func (foldContext *context) multibranchStructure(start, mid, end int, helix bool) nucleicAcidStructure {
+	// the loop was computed without errors when filling the caches
+	branched, _, _ := multibranch(start, mid, end, foldContext, helix)
+	leftStart, rightEnd := start, end
+	if helix {
+		leftStart, rightEnd = start+1, end-1
+	}
+	var branches []subsequence
+	branches = append(branches, foldContext.branches(leftStart, mid)...)
+	branches = append(branches, foldContext.branches(mid+1, rightEnd)...)
+	return nucleicAcidStructure{energy: branched.energy, description: fmt.Sprintf("BIFURCATION:%dn/%dh", branched.unpaired, branched.branchCount), inner: branches}
+}
+
+// sameUnpairedStructure returns whether the structure cached for W(start, end)
+// equals structure, without rebuilding it if their energies differ.
+func (foldContext *context) sameUnpairedStructure(start, end int, structure nucleicAcidStructure) bool {
+	if foldContext.unpairedMinimumFreeEnergyW(start, end) != structure.energy {
+		return false
+	}
+	return foldContext.unpairedStructure(start, end).Equal(structure)
+}
+
 // Traceback thru the pairedMinimumFreeEnergyV(start,end) and unpairedMinimumFreeEnergyW(start,end) caches to find the structure
 // For each step, get to the lowest energy unpairedMinimumFreeEnergyW(start,end) within that block
 // Store the structure in unpairedMinimumFreeEnergyW(start,end)
@@ -805,21 +842,37 @@ func pair(s string, start, rightOfStart, end, leftOfEnd int) string {
 //	 foldContext: The FoldingContext for this sequence
 //
 // Returns a list of NucleicAcidStructure in the final secondary structure
-func traceback(start, end int, foldContext context) []nucleicAcidStructure {
+func traceback(start, end int, foldContext *context) []nucleicAcidStructure {
 	// move start,end down-left to start coordinates
-	structure := foldContext.unpairedMinimumFreeEnergyW[start][end]
+	structure := foldContext.unpairedStructure(start, end)
 	if !strings.Contains(structure.description, "HAIRPIN") {
-		for foldContext.unpairedMinimumFreeEnergyW[start+1][end].Equal(structure) {
+		for foldContext.sameUnpairedStructure(start+1, end, structure) {
 			start += 1
 		}
-		for foldContext.unpairedMinimumFreeEnergyW[start][end-1].Equal(structure) {
+		for foldContext.sameUnpairedStructure(start, end-1, structure) {
 			end -= 1
 		}
 	}
 
+	// it's an exterior loop with several branches, so there is no pair
+	// closing it in pairedMinimumFreeEnergyV(start,end)
+	if len(structure.inner) > 1 && !structure.Equal(foldContext.pairedStructure(start, end)) {
+		summedEnergy := 0.0
+		branches := []nucleicAcidStructure{}
+		for _, subseq := range structure.inner {
+			tb := traceback(subseq.start, subseq.end, foldContext)
+			if len(tb) > 0 && len(tb[0].inner) > 0 {
+				summedEnergy += foldContext.unpairedMinimumFreeEnergyW(subseq.start, subseq.end)
+				branches = append(branches, tb...)
+			}
+		}
+		exteriorLoop := nucleicAcidStructure{energy: structure.energy - summedEnergy, description: structure.description, inner: structure.inner}
+		return append([]nucleicAcidStructure{exteriorLoop}, branches...)
+	}
+
 	NucleicAcidStructures := []nucleicAcidStructure{}
 	for {
-		structure = foldContext.pairedMinimumFreeEnergyV[start][end]
+		structure = foldContext.pairedStructure(start, end)
 
 		NucleicAcidStructures = append(NucleicAcidStructures, nucleicAcidStructure{energy: structure.energy, description: structure.description, inner: []subsequence{{start: start, end: end}}})
 
@@ -845,9 +898,7 @@ func traceback(start, end int, foldContext context) []nucleicAcidStructure {
 			rightOfStart, leftOfEnd := subseq.start, subseq.end
 			tb := traceback(rightOfStart, leftOfEnd, foldContext)
 			if len(tb) > 0 && len(tb[0].inner) > 0 {
-				subseq := tb[0].inner[0]
-				subStart, subEnd := subseq.start, subseq.end
-				summedEnergy += foldContext.unpairedMinimumFreeEnergyW[subStart][subEnd].energy
+				summedEnergy += foldContext.unpairedMinimumFreeEnergyW(rightOfStart, leftOfEnd)
 				branches = append(branches, tb...)
 			}
 		}
@@ -857,20 +908,49 @@ func traceback(start, end int, foldContext context) []nucleicAcidStructure {
 	}
 }
 
-// Return the struct with the lowest free energy that isn't -inf
-// Args:
-//
-//	structures: NucleicAcidStructure being compared
-//
-// Returns the min free energy structure
-func minimumStructure(structures ...nucleicAcidStructure) nucleicAcidStructure {
-	minimumStructure := invalidStructure
-	for _, structure := range structures {
-		if structure.energy != math.Inf(-1) && structure.energy < minimumStructure.energy {
-			minimumStructure = structure
+// tracebackExterior finds the structure of a sequence folded with a maximum
+// base pair span. The sequence is split into the local structures of the W
+// cache and unpaired bases that give the lowest total free energy, and each
+// local structure is traced back.
+func tracebackExterior(foldContext *context) []nucleicAcidStructure {
+	sequenceLength := len(foldContext.seq)
+	// prefixEnergies[end] is the minimum free energy of the bases before end,
+	// and segmentStarts[end] the start of the local structure ending right
+	// before end in it, or -1 if that base is unpaired
+	prefixEnergies := make([]float64, sequenceLength+1)
+	segmentStarts := make([]int, sequenceLength+1)
+	segmentStarts[0] = -1
+	for end := 0; end < sequenceLength; end++ {
+		prefixEnergies[end+1] = prefixEnergies[end]
+		segmentStarts[end+1] = -1
+		for start := max(0, end-foldContext.maxSpan); start <= end-minLenForStruct; start++ {
+			localEnergy := foldContext.unpairedMinimumFreeEnergyW(start, end)
+			if math.IsInf(localEnergy, 0) {
+				continue
+			}
+			if energy := prefixEnergies[start] + localEnergy; energy < prefixEnergies[end+1] {
+				prefixEnergies[end+1] = energy
+				segmentStarts[end+1] = start
+			}
 		}
 	}
-	return minimumStructure
+
+	var segments []subsequence
+	for end := sequenceLength - 1; end >= 0; {
+		start := segmentStarts[end+1]
+		if start < 0 {
+			end--
+			continue
+		}
+		segments = append(segments, subsequence{start, end})
+		end = start - 1
+	}
+
+	var structures []nucleicAcidStructure
+	for index := len(segments) - 1; index >= 0; index-- {
+		structures = append(structures, traceback(segments[index].start, segments[index].end, foldContext)...)
+	}
+	return structures
 }
 
 // trackbackEnergy add energy to each structure, based on how it's
@@ -2,9 +2,11 @@ package fold
 
 import (
 	"math"
+	"math/rand"
 	"strings"
 	"testing"
 
+	"github.com/bebop/poly/checks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -19,7 +21,7 @@ func TestFold(t *testing.T) {
 		seqDg := res.MinimumFreeEnergy()
 		require.NoError(t, err)
 
-		assert.InDelta(t, seqDg, foldContext.unpairedMinimumFreeEnergyW[0][len(seq)-1].energy, 1)
+		assert.InDelta(t, seqDg, foldContext.unpairedMinimumFreeEnergyW(0, len(seq)-1), 1)
 	})
 	t.Run("FoldDNA", func(t *testing.T) {
 		// unafold's estimates for free energy estimates of DNA oligos
@@ -174,9 +176,8 @@ func TestFold(t *testing.T) {
 		foldContext, err := newFoldingContext(seq, 37)
 		require.NoError(t, err)
 
-		struc, err := unpairedMinimumFreeEnergyW(i, j, foldContext)
-		require.NoError(t, err)
-		assert.InDelta(t, struc.energy, -3.8, 0.2)
+		energy := foldContext.unpairedMinimumFreeEnergyW(i, j)
+		assert.InDelta(t, energy, -3.8, 0.2)
 
 		seq = "CCUGCUUUGCACGCAGG"
 		i = 0
@@ -185,9 +186,8 @@ func TestFold(t *testing.T) {
 		foldContext, err = newFoldingContext(seq, 37)
 		require.NoError(t, err)
 
-		struc, err = unpairedMinimumFreeEnergyW(i, j, foldContext)
-		require.NoError(t, err)
-		assert.InDelta(t, struc.energy, -6.4, 0.2)
+		energy = foldContext.unpairedMinimumFreeEnergyW(i, j)
+		assert.InDelta(t, energy, -6.4, 0.2)
 
 		seq = "GCGGUUCGAUCCCGC"
 		i = 0
@@ -196,8 +196,272 @@ func TestFold(t *testing.T) {
 		foldContext, err = newFoldingContext(seq, 37)
 		require.NoError(t, err)
 
-		struc, err = unpairedMinimumFreeEnergyW(i, j, foldContext)
+		energy = foldContext.unpairedMinimumFreeEnergyW(i, j)
+		assert.InDelta(t, energy, -4.2, 0.2)
+	})
+}
+
+func TestFoldExteriorLoop(t *testing.T) {
+	// two hairpins side by side are joined by the exterior loop rather than
+	// by a closing base pair
+	seq := "GGCGCGAAAGCGCCAAAGCCGCGAAAGCGGC"
+	foldContext, err := newFoldingContext(seq, 37.0)
+	require.NoError(t, err)
+	res, err := Zuker(seq, 37.0)
+	require.NoError(t, err)
+
+	assert.Equal(t, ".((((....)))).....((((....))))", res.DotBracket())
+	assert.InDelta(t, foldContext.unpairedMinimumFreeEnergyW(0, len(seq)-1), res.MinimumFreeEnergy(), 1e-9)
+}
+
+func TestZukerWithMaxSpan(t *testing.T) {
+	seq := "ACCCCCUCCUUCCUUGGAUCAAGGGGCUCAA"
+
+	// a span covering the whole sequence doesn't change the fold
+	full, err := Zuker(seq, 37.0)
+	require.NoError(t, err)
+	windowed, err := ZukerWithMaxSpan(seq, 37.0, len(seq))
+	require.NoError(t, err)
+	assert.Equal(t, full.DotBracket(), windowed.DotBracket())
+	assert.InDelta(t, full.MinimumFreeEnergy(), windowed.MinimumFreeEnergy(), 1e-9)
+
+	// a shorter span only keeps pairs closer than the span
+	seq = benchmarkSequence(300)
+	for _, maxSpan := range []int{20, 50, 100} {
+		res, err := ZukerWithMaxSpan(seq, 37.0, maxSpan)
+		require.NoError(t, err)
+		for index, partner := range res.PairTable() {
+			if partner > index {
+				assert.LessOrEqual(t, partner-index, maxSpan)
+			}
+		}
+		assert.Less(t, res.MinimumFreeEnergy(), 0.0)
+	}
+
+	_, err = ZukerWithMaxSpan(seq, 37.0, -1)
+	assert.Error(t, err)
+}
+
+// benchmarkSequence returns a random, but reproducible, RNA sequence.
+func benchmarkSequence(length int) string {
+	random := rand.New(rand.NewSource(1))
+	var seq strings.Builder
+	for i := 0; i < length; i++ {
+		seq.WriteByte("ACGU"[random.Intn(4)])
+	}
+	return seq.String()
+}
+
+func BenchmarkZuker(b *testing.B) {
+	seq := benchmarkSequence(300)
+	for i := 0; i < b.N; i++ {
+		_, _ = Zuker(seq, 37.0)
+	}
+}
+
+func BenchmarkZukerWithMaxSpan(b *testing.B) {
+	seq := benchmarkSequence(3000)
+	for i := 0; i < b.N; i++ {
+		_, _ = ZukerWithMaxSpan(seq, 37.0, 150)
+	}
+}
+
+func TestInverseFold(t *testing.T) {
+	t.Run("Designs", func(t *testing.T) {
+		targets := []string{
+			"((((((....))))))",
+			"...((((((...))))))...",
+			"..(((((....)))))...(((((....))))).",
+		}
+		for _, target := range targets {
+			designs, err := InverseFold(target, InverseFoldConstraints{MaxIterations: 300}, 37.0, 2, 1)
+			require.NoError(t, err, target)
+			require.Len(t, designs, 2, target)
+			assert.NotEqual(t, designs[0], designs[1])
+			for _, design := range designs {
+				res, err := Zuker(design, 37.0)
+				require.NoError(t, err)
+				folded := res.DotBracket()
+				assert.Equal(t, target, folded+strings.Repeat(".", len(target)-len(folded)), design)
+			}
+		}
+	})
+	t.Run("Constraints", func(t *testing.T) {
+		constraints := InverseFoldConstraints{Sequence: "GNNNNNGAAANNNNNC", MinGcContent: 0.4, MaxGcContent: 0.6}
+		designs, err := InverseFold("((((((....))))))", constraints, 37.0, 3, 2)
+		require.NoError(t, err)
+		for _, design := range designs {
+			assert.Equal(t, byte('G'), design[0])
+			assert.Equal(t, "GAAA", design[6:10])
+			assert.Equal(t, byte('C'), design[15])
+			gcContent := checks.GcContent(design)
+			assert.True(t, gcContent >= 0.4 && gcContent <= 0.6, "GC content %f of %s out of bounds", gcContent, design)
+		}
+
+		// a minimum GC content is checked without a maximum
+		designs, err = InverseFold("((((((....))))))", InverseFoldConstraints{Sequence: "NNNNNNAAAANNNNNN", MinGcContent: 0.6}, 37.0, 3, 2)
 		require.NoError(t, err)
-		assert.InDelta(t, struc.energy, -4.2, 0.2)
+		for _, design := range designs {
+			gcContent := checks.GcContent(design)
+			assert.True(t, gcContent >= 0.6, "GC content %f of %s below the minimum", gcContent, design)
+		}
 	})
+	t.Run("Seeded", func(t *testing.T) {
+		first, err := InverseFold("((((((....))))))", InverseFoldConstraints{}, 37.0, 1, 42)
+		require.NoError(t, err)
+		second, err := InverseFold("((((((....))))))", InverseFoldConstraints{}, 37.0, 1, 42)
+		require.NoError(t, err)
+		assert.Equal(t, first, second)
+	})
+	t.Run("Errors", func(t *testing.T) {
+		_, err := InverseFold("((((....)))", InverseFoldConstraints{}, 37.0, 1, 1)
+		assert.Error(t, err, "unbalanced structure")
+		_, err = InverseFold("((((..))))", InverseFoldConstraints{}, 37.0, 1, 1)
+		assert.Error(t, err, "hairpin too small")
+		_, err = InverseFold("((((....))))", InverseFoldConstraints{Sequence: "NNN"}, 37.0, 1, 1)
+		assert.Error(t, err, "constraint length")
+		_, err = InverseFold("((((....))))", InverseFoldConstraints{Sequence: "ANNNNNNNNNNA"}, 37.0, 1, 1)
+		assert.Error(t, err, "unpairable constraint")
+		_, err = InverseFold("((((....))))", InverseFoldConstraints{Sequence: "NNNNNNNNNNNX"}, 37.0, 1, 1)
+		assert.Error(t, err, "unknown IUPAC code")
+		_, err = InverseFold("((((....))))", InverseFoldConstraints{}, 37.0, 0, 1)
+		assert.Error(t, err, "zero count")
+	})
+}
+
+func TestDotBracket(t *testing.T) {
+	table, err := ParseDotBracket("((..))..(...)")
+	require.NoError(t, err)
+	assert.Equal(t, PairTable{5, 4, -1, -1, 1, 0, -1, -1, 12, -1, -1, -1, 8}, table)
+	dotBracket, err := table.DotBracket()
+	require.NoError(t, err)
+	assert.Equal(t, "((..))..(...)", dotBracket)
+	assert.Equal(t, 3, table.Pairs())
+
+	// crossing pairs are written as square brackets
+	pseudoknot := PairTable{5, 7, -1, -1, -1, 0, -1, 1}
+	dotBracket, err = pseudoknot.DotBracket()
+	require.NoError(t, err)
+	assert.Equal(t, "([...).]", dotBracket)
+
+	for _, invalid := range []string{"(()", "())", "(.x)"} {
+		_, err := ParseDotBracket(invalid)
+		assert.Error(t, err, invalid)
+	}
+	for _, invalid := range []PairTable{{-1, -1, 0}, {1, 2, 0}, {-2, -1}, {0}, {3, -1}} {
+		_, err := invalid.DotBracket()
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestResultPairTable(t *testing.T) {
+	seq := "ACCCCCUCCUUCCUUGGAUCAAGGGGCUCAA"
+	res, err := Zuker(seq, 37.0)
+	require.NoError(t, err)
+	table := res.PairTable()
+	require.Len(t, table, len(seq))
+	dotBracket, err := table.DotBracket()
+	require.NoError(t, err)
+	assert.Equal(t, res.DotBracket(), dotBracket[:len(res.DotBracket())])
+}
+
+func TestBasePairDistance(t *testing.T) {
+	first, _ := ParseDotBracket("((((....))))")
+	second, _ := ParseDotBracket(".(((....))).")
+	third, _ := ParseDotBracket("............")
+
+	distance, err := BasePairDistance(first, first)
+	require.NoError(t, err)
+	assert.Equal(t, 0, distance)
+	distance, err = BasePairDistance(first, second)
+	require.NoError(t, err)
+	// only the outer pair differs
+	assert.Equal(t, 1, distance)
+	distance, err = BasePairDistance(first, third)
+	require.NoError(t, err)
+	assert.Equal(t, 4, distance)
+
+	_, err = BasePairDistance(first, PairTable{-1})
+	assert.Error(t, err)
+	_, err = BasePairDistance(PairTable{-1, -1, 0}, PairTable{-1, -1, -1})
+	assert.Error(t, err)
+}
+
+func TestTreeEditDistance(t *testing.T) {
+	treeEditDistance := func(first, second PairTable) int {
+		distance, err := TreeEditDistance(first, second)
+		require.NoError(t, err)
+		return distance
+	}
+	hairpin, _ := ParseDotBracket("((((....))))")
+	assert.Equal(t, 0, treeEditDistance(hairpin, hairpin))
+
+	// removing the outer pair deletes a pair node and inserts two unpaired bases
+	shorter, _ := ParseDotBracket(".(((....))).")
+	assert.Equal(t, 3, treeEditDistance(hairpin, shorter))
+
+	// adding an unpaired base to the loop is a single insertion
+	longer, _ := ParseDotBracket("((((.....))))")
+	assert.Equal(t, 1, treeEditDistance(hairpin, longer))
+
+	// the distance is symmetric
+	twoHairpins, _ := ParseDotBracket("((...))((...))")
+	assert.Equal(t, treeEditDistance(hairpin, twoHairpins), treeEditDistance(twoHairpins, hairpin))
+
+	// every node is inserted: four pairs and four loop bases
+	empty, _ := ParseDotBracket("")
+	assert.Equal(t, 8, treeEditDistance(empty, hairpin))
+
+	_, err := TreeEditDistance(hairpin, PairTable{2, -1, 1})
+	assert.Error(t, err)
+}
+
+func TestSVG(t *testing.T) {
+	seq := "GGCGCGAAAGCGCCAAAGCCGCGAAAGCGGC"
+	res, err := Zuker(seq, 37.0)
+	require.NoError(t, err)
+	svg, err := res.SVG()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(svg, "<svg"))
+	assert.Equal(t, len(seq), strings.Count(svg, "<circle"))
+	assert.Equal(t, res.PairTable().Pairs(), strings.Count(svg, "<line"))
+
+	// every base is one unit from its neighbors and partner, except in the
+	// exterior loop where helices are spread apart to make room for their loops
+	for _, dotBracket := range []string{"((((....))))", "..((((...))..((...))..))..", "(((...)))...((....)).", "....."} {
+		table, err := ParseDotBracket(dotBracket)
+		require.NoError(t, err)
+		positions := layoutStructure(table)
+		exterior := make([]bool, len(table))
+		for index := 0; index < len(table); index++ {
+			exterior[index] = true
+			if table[index] > index {
+				exterior[table[index]] = true
+				index = table[index]
+			}
+		}
+		for index := 1; index < len(positions); index++ {
+			distance := math.Hypot(positions[index].x-positions[index-1].x, positions[index].y-positions[index-1].y)
+			if exterior[index] && exterior[index-1] {
+				assert.GreaterOrEqual(t, distance, 1.0-1e-6, "%s: bases %d and %d", dotBracket, index-1, index)
+				continue
+			}
+			assert.InDelta(t, 1.0, distance, 1e-6, "%s: bases %d and %d", dotBracket, index-1, index)
+		}
+		for index, partner := range table {
+			if partner > index {
+				distance := math.Hypot(positions[index].x-positions[partner].x, positions[index].y-positions[partner].y)
+				assert.InDelta(t, 1.0, distance, 1e-6, "%s: pair (%d, %d)", dotBracket, index, partner)
+			}
+		}
+	}
+
+	_, err = SVG("GGG", PairTable{-1})
+	assert.Error(t, err, "length mismatch")
+	_, err = SVG("GGG", PairTable{2, -1, -1})
+	assert.Error(t, err, "asymmetric pair")
+	_, err = SVG("GGG", PairTable{-2, -1, -1})
+	assert.Error(t, err, "negative partner")
+	_, err = SVG("", PairTable{})
+	assert.Error(t, err, "empty sequence")
 }
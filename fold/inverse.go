@@ -0,0 +1,305 @@
+package fold
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/bebop/poly/checks"
+)
+
+// defaultInverseFoldIterations is the number of mutations InverseFold tries
+// on a single starting sequence before giving up and restarting.
+const defaultInverseFoldIterations = 1000
+
+// inverseFoldRestarts is the number of random starting sequences InverseFold
+// tries for every sequence it was asked to design.
+const inverseFoldRestarts = 10
+
+// iupacRNA maps IUPAC nucleotide codes to the RNA bases they stand for.
+// https://www.bioinformatics.org/sms/iupac.html
+var iupacRNA = map[byte]string{
+	'A': "A",
+	'C': "C",
+	'G': "G",
+	'U': "U",
+	'T': "U",
+	'R': "AG",
+	'Y': "CU",
+	'S': "CG",
+	'W': "AU",
+	'K': "GU",
+	'M': "AC",
+	'B': "CGU",
+	'D': "AGU",
+	'H': "ACU",
+	'V': "ACG",
+	'N': "ACGU",
+}
+
+// rnaBasePairs are the base pairs that Zuker can fold with the rna energies.
+var rnaBasePairs = [][2]byte{{'G', 'C'}, {'C', 'G'}, {'A', 'U'}, {'U', 'A'}}
+
+// InverseFoldConstraints restricts the sequences proposed by InverseFold.
+type InverseFoldConstraints struct {
+	// Sequence is an IUPAC string as long as the target structure. Concrete
+	// bases (A, C, G, U or T) fix a position while degenerate letters like N,
+	// R or Y limit the bases that may be used there. An empty Sequence leaves
+	// every position unconstrained.
+	Sequence string
+	// MinGcContent and MaxGcContent bound the GC content (0 to 1) of the
+	// designed sequences, as measured by checks.GcContent. Each bound is only
+	// checked if it is above zero.
+	MinGcContent float64
+	MaxGcContent float64
+	// MaxIterations is the number of mutations tried on every starting
+	// sequence. Zero means 1000.
+	MaxIterations int
+}
+
+// InverseFold designs RNA sequences that fold into targetStructure, a
+// dot-bracket string, at temp degrees Celsius.
+//
+// The design uses an adaptive walk similar to RNAinverse from the
+// ViennaRNA package:
+// Hofacker et al, 1994
+// https://doi.org/10.1007/BF00818163
+//
+// A random sequence satisfying the constraints is mutated one position (or
+// base pair) at a time, targeting positions whose pairing differs from the
+// target. Mutations that do not increase the distance to the target structure
+// are kept. A sequence is accepted once the minimum free energy structure
+// found by Zuker matches targetStructure exactly.
+//
+// InverseFold returns up to count distinct sequences. The walk is driven by a
+// random source seeded with seed so results are reproducible. An error is
+// returned if the target or constraints are invalid or no sequence could be
+// designed.
+func InverseFold(targetStructure string, constraints InverseFoldConstraints, temp float64, count int, seed int64) ([]string, error) {
+	if count < 1 {
+		return nil, errors.New("inverse fold: count must be at least 1")
+	}
+	target, err := ParseDotBracket(targetStructure)
+	if err != nil {
+		return nil, fmt.Errorf("inverse fold: %w", err)
+	}
+	for start, end := range target {
+		if end > start && end-start < minLenForStruct {
+			return nil, fmt.Errorf("inverse fold: hairpin closed by (%d, %d) has fewer than %d unpaired bases", start, end, minLenForStruct-1)
+		}
+	}
+
+	allowed, err := allowedBases(constraints.Sequence, len(targetStructure))
+	if err != nil {
+		return nil, fmt.Errorf("inverse fold: %w", err)
+	}
+	pairOptions := make(map[int][][2]byte)
+	for start, end := range target {
+		if end <= start {
+			continue
+		}
+		for _, basePair := range rnaBasePairs {
+			if strings.IndexByte(allowed[start], basePair[0]) >= 0 && strings.IndexByte(allowed[end], basePair[1]) >= 0 {
+				pairOptions[start] = append(pairOptions[start], basePair)
+			}
+		}
+		if len(pairOptions[start]) == 0 {
+			return nil, fmt.Errorf("inverse fold: sequence constraints prevent (%d, %d) from pairing", start, end)
+		}
+	}
+
+	walker := inverseFoldWalker{
+		target:        target,
+		allowed:       allowed,
+		pairOptions:   pairOptions,
+		constraints:   constraints,
+		temp:          temp,
+		randomSource:  rand.New(rand.NewSource(seed)),
+		maxIterations: constraints.MaxIterations,
+	}
+	if walker.maxIterations <= 0 {
+		walker.maxIterations = defaultInverseFoldIterations
+	}
+
+	var designs []string
+	seen := make(map[string]bool)
+	for attempt := 0; len(designs) < count && attempt < count*inverseFoldRestarts; attempt++ {
+		design, ok, err := walker.walk()
+		if err != nil {
+			return nil, fmt.Errorf("inverse fold: %w", err)
+		}
+		if ok && !seen[design] {
+			seen[design] = true
+			designs = append(designs, design)
+		}
+	}
+	if len(designs) == 0 {
+		return nil, fmt.Errorf("inverse fold: no sequence folding into %s was found", targetStructure)
+	}
+	return designs, nil
+}
+
+// inverseFoldWalker holds everything an adaptive walk of InverseFold needs.
+type inverseFoldWalker struct {
+	target        PairTable
+	allowed       []string
+	pairOptions   map[int][][2]byte
+	constraints   InverseFoldConstraints
+	temp          float64
+	randomSource  *rand.Rand
+	maxIterations int
+}
+
+// walk runs a single adaptive walk from a random starting sequence. It
+// returns the designed sequence and whether it folds into the target.
+func (walker inverseFoldWalker) walk() (string, bool, error) {
+	// like RNAinverse, start from unpaired A's and GC pairs when allowed
+	// since they keep loops open and quickly stabilize the target helices
+	sequence := make([]byte, len(walker.target))
+	for position, partner := range walker.target {
+		switch {
+		case partner < 0 && strings.IndexByte(walker.allowed[position], 'A') >= 0:
+			sequence[position] = 'A'
+		case partner < 0 || partner > position:
+			walker.mutate(sequence, position)
+		}
+	}
+
+	cost, structure, err := walker.cost(sequence)
+	if err != nil {
+		return "", false, err
+	}
+	for iteration := 0; iteration < walker.maxIterations; iteration++ {
+		if cost == 0 {
+			return string(sequence), true, nil
+		}
+
+		// prefer positions whose pairing is wrong. Every so often, or when
+		// only the GC content is off, any position that can change is tried
+		// since loops closing a missing helix are themselves unpaired as they
+		// should be.
+		var candidates []int
+		if walker.randomSource.Intn(4) != 0 {
+			for position, partner := range walker.target {
+				if partner != structure[position] && walker.mutable(position) {
+					candidates = append(candidates, position)
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			for position := range walker.target {
+				if walker.mutable(position) {
+					candidates = append(candidates, position)
+				}
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+
+		mutant := make([]byte, len(sequence))
+		copy(mutant, sequence)
+		walker.mutate(mutant, candidates[walker.randomSource.Intn(len(candidates))])
+
+		mutantCost, mutantStructure, err := walker.cost(mutant)
+		if err != nil {
+			return "", false, err
+		}
+		if mutantCost <= cost {
+			sequence, cost, structure = mutant, mutantCost, mutantStructure
+		}
+	}
+	return string(sequence), cost == 0, nil
+}
+
+// mutable returns whether the base at position can be changed.
+func (walker inverseFoldWalker) mutable(position int) bool {
+	partner := walker.target[position]
+	switch {
+	case partner < 0:
+		return len(walker.allowed[position]) > 1
+	case partner > position:
+		return len(walker.pairOptions[position]) > 1
+	default:
+		return len(walker.pairOptions[partner]) > 1
+	}
+}
+
+// mutate randomly picks a base for position, or a base pair for position and
+// its partner if it is paired in the target structure.
+func (walker inverseFoldWalker) mutate(sequence []byte, position int) {
+	partner := walker.target[position]
+	if partner < 0 {
+		bases := walker.allowed[position]
+		sequence[position] = bases[walker.randomSource.Intn(len(bases))]
+		return
+	}
+	start, end := position, partner
+	if start > end {
+		start, end = end, start
+	}
+	// GC pairs are favored since a helix missing from the fold usually needs
+	// to be stabilized before it forms
+	options := walker.pairOptions[start]
+	var strongOptions [][2]byte
+	for _, basePair := range options {
+		if basePair[0] == 'G' || basePair[0] == 'C' {
+			strongOptions = append(strongOptions, basePair)
+		}
+	}
+	if len(strongOptions) > 0 && walker.randomSource.Intn(2) == 0 {
+		options = strongOptions
+	}
+	basePair := options[walker.randomSource.Intn(len(options))]
+	sequence[start], sequence[end] = basePair[0], basePair[1]
+}
+
+// cost folds sequence and returns how far it is from the target, along with
+// the pair table of its minimum free energy structure. A cost of zero means
+// the sequence folds into the target and meets the GC constraints.
+func (walker inverseFoldWalker) cost(sequence []byte) (float64, PairTable, error) {
+	result, err := Zuker(string(sequence), walker.temp)
+	if err != nil {
+		return 0, nil, err
+	}
+	structure := result.PairTable()
+	distance, err := BasePairDistance(walker.target, structure)
+	if err != nil {
+		return 0, nil, err
+	}
+	cost := float64(distance)
+
+	gcContent := checks.GcContent(string(sequence))
+	if walker.constraints.MinGcContent > 0 && gcContent < walker.constraints.MinGcContent {
+		cost += (walker.constraints.MinGcContent - gcContent) * float64(len(sequence))
+	}
+	if walker.constraints.MaxGcContent > 0 && gcContent > walker.constraints.MaxGcContent {
+		cost += (gcContent - walker.constraints.MaxGcContent) * float64(len(sequence))
+	}
+	return cost, structure, nil
+}
+
+// allowedBases expands an IUPAC constraint string into the RNA bases allowed
+// at every position of a sequence of the given length.
+func allowedBases(constraint string, length int) ([]string, error) {
+	allowed := make([]string, length)
+	if constraint == "" {
+		for position := range allowed {
+			allowed[position] = iupacRNA['N']
+		}
+		return allowed, nil
+	}
+	if len(constraint) != length {
+		return nil, fmt.Errorf("sequence constraint is %d long but the target structure is %d long", len(constraint), length)
+	}
+	constraint = strings.ToUpper(constraint)
+	for position := range allowed {
+		bases, ok := iupacRNA[constraint[position]]
+		if !ok {
+			return nil, fmt.Errorf("unknown IUPAC code %q at position %d of the sequence constraint", constraint[position], position)
+		}
+		allowed[position] = bases
+	}
+	return allowed, nil
+}
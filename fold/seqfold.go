@@ -1,6 +1,7 @@
 package fold
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"strings"
@@ -30,6 +31,11 @@ const (
 	// an AT basepair.
 	// Formula 8 from SantaLucia, 2004
 	closingATPenalty = 0.5
+	// maxLoopLength is the largest number of unpaired bases in the bulges
+	// and interior loops considered when folding, like MAXLOOP in ViennaRNA.
+	// Longer loops are practically never favorable, and limiting them keeps
+	// folding cubic in the length of the sequence.
+	maxLoopLength = 30
 )
 
 /*
@@ -133,67 +139,298 @@ var invalidStructure = nucleicAcidStructure{
 	energy:      math.Inf(1),
 }
 
-// context holds the energy caches, energy maps, sequence, and temperature
+// noBase is the encoded base standing for the missing neighbor of a dangling
+// end, written as '.' in the keys returned by pair.
+const noBase = 4
+
+// encodeBase returns the index of a base in the energy tables of a context.
+func encodeBase(base byte) int {
+	switch base {
+	case 'A':
+		return 0
+	case 'C':
+		return 1
+	case 'G':
+		return 2
+	case 'T', 'U':
+		return 3
+	}
+	return noBase
+}
+
+// pairEnergies holds the free energies of a matchingBasepairEnergy at the
+// folding temperature, indexed by the encoded bases of the pair so they can be
+// looked up without building the string keys returned by pair.
+type pairEnergies struct {
+	deltaG [625]float64
+	known  [625]bool
+}
+
+// newPairEnergies computes the free energies of every stack in energyMap at
+// temp degrees Kelvin. Keys that are not stacks, like "init", are skipped.
+func newPairEnergies(energyMap matchingBasepairEnergy, temp float64) pairEnergies {
+	var table pairEnergies
+	for key, foldEnergy := range energyMap {
+		if len(key) != 5 || key[2] != '/' {
+			continue
+		}
+		index := pairIndex(encodeBase(key[0]), encodeBase(key[1]), encodeBase(key[3]), encodeBase(key[4]))
+		table.deltaG[index] = deltaG(foldEnergy.enthalpyH, foldEnergy.entropyS, temp)
+		table.known[index] = true
+	}
+	return table
+}
+
+// pairIndex returns the index of a stack of encoded bases in pairEnergies.
+func pairIndex(start, rightOfStart, end, leftOfEnd int) int {
+	return ((start*5+rightOfStart)*5+end)*5 + leftOfEnd
+}
+
+// newLoopEnergies computes the free energies of loops of every length up to
+// maxLength at temp degrees Kelvin, extrapolating lengths missing from
+// energyMap with the Jacobson-Stockmayer formula.
+func newLoopEnergies(energyMap loopEnergy, maxLength int, temp float64) []float64 {
+	table := make([]float64, maxLength+1)
+	longest := energyMap[maxLenPreCalulated]
+	longestDeltaG := deltaG(longest.enthalpyH, longest.entropyS, temp)
+	for length := 1; length <= maxLength; length++ {
+		if foldEnergy, ok := energyMap[length]; ok {
+			table[length] = deltaG(foldEnergy.enthalpyH, foldEnergy.entropyS, temp)
+		} else {
+			table[length] = jacobsonStockmayer(length, maxLenPreCalulated, longestDeltaG, temp)
+		}
+	}
+	return table
+}
+
+// loopType is the kind of structure stored in a cell of the folding caches.
+type loopType uint8
+
+const (
+	// uncomputedLoop cells were never filled. They behave like
+	// defaultStructure.
+	uncomputedLoop loopType = iota
+	// invalidLoop cells can't hold any structure. They behave like
+	// invalidStructure.
+	invalidLoop
+	hairpinLoop
+	isolatedPair
+	stackLoop
+	bulgeLoop
+	interiorLoop
+	// multibranchLoop cells are multi-branch loops in the V cache and
+	// bifurcations in the W cache.
+	multibranchLoop
+	// danglingLeftLoop W cells hold the structure of W(start+1, end).
+	danglingLeftLoop
+	// danglingRightLoop W cells hold the structure of W(start, end-1).
+	danglingRightLoop
+	// pairedLoop W cells hold the structure of V(start, end).
+	pairedLoop
+)
+
+// pairedCell is a cell of the V cache, holding the minimum free energy
+// structure of a subsequence whose ends pair with each other.
+type pairedCell struct {
+	energy float64
+	loop   loopType
+	// innerStart and innerEnd are the next pair of stacks, bulges and
+	// interior loops. innerStart holds the split point of multi-branch loops.
+	innerStart, innerEnd int32
+}
+
+// unpairedCell is a cell of the W cache, holding the minimum free energy
+// structure of a subsequence.
+type unpairedCell struct {
+	energy float64
+	loop   loopType
+	// mid is the split point of bifurcations.
+	mid int32
+	// branchesStart and branchesEnd delimit the branches of the structure in
+	// the branch pool of the context, see multibranch.
+	branchesStart, branchesEnd int32
+}
+
+// context holds the energy caches, energy tables, sequence, and temperature
 // needed in order to compute the folding energy and structures.
+//
+// The caches are flat slices holding, for every start, the cells of all
+// subsequences from start that are at most maxSpan long. Cells only record
+// which structure was chosen, structures themselves are rebuilt by traceback
+// once the caches are filled.
 type context struct {
-	energies                   energies
-	seq                        string
-	pairedMinimumFreeEnergyV   [][]nucleicAcidStructure
-	unpairedMinimumFreeEnergyW [][]nucleicAcidStructure
-	temp                       float64
+	energies energies
+	seq      string
+	temp     float64
+
+	// bases holds the encoded bases of seq.
+	bases []int
+	// canPair holds whether two encoded bases form a base pair.
+	canPair [5][5]bool
+
+	nearestNeighbors pairEnergies
+	// stacks holds the nearestNeighbors energies, falling back to
+	// internalMismatches for stacks that aren't matched.
+	stacks             pairEnergies
+	terminalMismatches pairEnergies
+	danglingEnds       pairEnergies
+	hairpinLoops       []float64
+	bulgeLoops         []float64
+	internalLoops      []float64
+
+	maxSpan       int
+	rowOffsets    []int
+	pairedCells   []pairedCell
+	unpairedCells []unpairedCell
+	// branchPool holds the branches of the structures in the W cache.
+	branchPool []subsequence
+}
+
+// newFoldingContext returns a context ready to use, with its caches filled
+// for the whole sequence. In case of error the returned context is nil.
+func newFoldingContext(seq string, temp float64) (*context, error) {
+	return newFoldingContextWithMaxSpan(seq, temp, 0)
 }
 
-// newFoldingContext returns a context ready to use, in case of error
-// the returned FoldingContext is empty.
-func newFoldingContext(seq string, temp float64) (context, error) {
+// newFoldingContextWithMaxSpan returns a context ready to use, with its caches
+// filled for subsequences at most maxSpan long. A maxSpan of zero fills the
+// caches for the whole sequence.
+func newFoldingContextWithMaxSpan(seq string, temp float64, maxSpan int) (*context, error) {
 	seq = strings.ToUpper(seq)
+	if len(seq) == 0 {
+		return nil, errors.New("the sequence is empty")
+	}
 
 	// figure out whether it's DNA or rna, choose energy map
-	var energyMap energies
+	var (
+		energyMap energies
+		alphabet  string
+	)
 	switch {
 	case checks.IsDNA(seq):
 		energyMap = dnaEnergies
+		alphabet = "ACGT"
 	case checks.IsRNA(seq):
 		energyMap = rnaEnergies
+		alphabet = "ACGU"
 	default:
-		return context{}, fmt.Errorf("the sequence %s is not RNA or DNA", seq)
+		return nil, fmt.Errorf("the sequence %s is not RNA or DNA", seq)
 	}
 
-	var (
-		sequenceLength = len(seq)
-		vCache         = make([][]nucleicAcidStructure, sequenceLength)
-		wCache         = make([][]nucleicAcidStructure, sequenceLength)
-		row            = make([]nucleicAcidStructure, sequenceLength)
-	)
-	for nucleicAcidIndex := 0; nucleicAcidIndex < sequenceLength; nucleicAcidIndex++ {
-		row[nucleicAcidIndex] = defaultStructure
+	sequenceLength := len(seq)
+	if maxSpan <= 0 || maxSpan > sequenceLength-1 {
+		maxSpan = sequenceLength - 1
+	}
+	kelvin := temp + 273.15
+	longestLoop := max(sequenceLength, maxLenPreCalulated)
+	foldContext := &context{
+		energies:           energyMap,
+		seq:                seq,
+		temp:               kelvin,
+		bases:              make([]int, sequenceLength),
+		nearestNeighbors:   newPairEnergies(energyMap.nearestNeighbors, kelvin),
+		stacks:             newPairEnergies(energyMap.internalMismatches, kelvin),
+		terminalMismatches: newPairEnergies(energyMap.terminalMismatches, kelvin),
+		danglingEnds:       newPairEnergies(energyMap.danglingEnds, kelvin),
+		hairpinLoops:       newLoopEnergies(energyMap.hairpinLoops, longestLoop, kelvin),
+		bulgeLoops:         newLoopEnergies(energyMap.bulgeLoops, longestLoop, kelvin),
+		internalLoops:      newLoopEnergies(energyMap.internalLoops, longestLoop, kelvin),
+		maxSpan:            maxSpan,
+		rowOffsets:         make([]int, sequenceLength+1),
+	}
+	for index := range foldContext.stacks.deltaG {
+		if foldContext.nearestNeighbors.known[index] {
+			foldContext.stacks.deltaG[index] = foldContext.nearestNeighbors.deltaG[index]
+		}
+	}
+	for index := range seq {
+		foldContext.bases[index] = encodeBase(seq[index])
+	}
+	for _, base := range []byte(alphabet) {
+		for _, other := range []byte(alphabet) {
+			foldContext.canPair[encodeBase(base)][encodeBase(other)] = energyMap.complement(rune(base)) == rune(other)
+		}
 	}
-	for j := 0; j < sequenceLength; j++ {
-		vCache[j] = make([]nucleicAcidStructure, sequenceLength)
-		copy(vCache[j], row)
 
-		wCache[j] = make([]nucleicAcidStructure, sequenceLength)
-		copy(wCache[j], row)
+	for start := 0; start < sequenceLength; start++ {
+		foldContext.rowOffsets[start+1] = foldContext.rowOffsets[start] + min(maxSpan+1, sequenceLength-start)
 	}
-	ret := context{
-		energies:                   energyMap,
-		seq:                        seq,
-		pairedMinimumFreeEnergyV:   vCache,
-		unpairedMinimumFreeEnergyW: wCache,
-		temp:                       temp + 273.15, // kelvin
+	foldContext.pairedCells = make([]pairedCell, foldContext.rowOffsets[sequenceLength])
+	foldContext.unpairedCells = make([]unpairedCell, foldContext.rowOffsets[sequenceLength])
+	for index := range foldContext.pairedCells {
+		foldContext.pairedCells[index].energy = math.Inf(-1)
+		foldContext.unpairedCells[index].energy = math.Inf(-1)
 	}
 
 	// fill the cache
-	_, err := unpairedMinimumFreeEnergyW(0, sequenceLength-1, ret)
+	err := foldContext.fill()
 	if err != nil {
-		return context{}, fmt.Errorf("error filling the caches for the FoldingContext: %w", err)
+		return nil, fmt.Errorf("error filling the caches for the FoldingContext: %w", err)
+	}
+	return foldContext, nil
+}
+
+// cellIndex returns the index of the cells of the subsequence from start to
+// end (inclusive) in the caches, or -1 if the caches don't hold it.
+func (foldContext *context) cellIndex(start, end int) int {
+	if start < 0 || end >= len(foldContext.seq) || start > end || end-start > foldContext.maxSpan {
+		return -1
+	}
+	return foldContext.rowOffsets[start] + end - start
+}
+
+// pairIndex returns the index in pairEnergies of the stack formed by the
+// bases at the given indices of the sequence. Indices of -1 stand for the
+// missing neighbor of a dangling end.
+func (foldContext *context) pairIndex(start, rightOfStart, end, leftOfEnd int) int {
+	encoded := func(index int) int {
+		if index < 0 {
+			return noBase
+		}
+		return foldContext.bases[index]
 	}
-	return ret, nil
+	return pairIndex(encoded(start), encoded(rightOfStart), encoded(end), encoded(leftOfEnd))
+}
+
+// complementary returns whether the bases at start and end pair.
+func (foldContext *context) complementary(start, end int) bool {
+	return foldContext.canPair[foldContext.bases[start]][foldContext.bases[end]]
+}
+
+// pairedMinimumFreeEnergyV returns the energy cached for V(start, end), see
+// fillPaired.
+func (foldContext *context) pairedMinimumFreeEnergyV(start, end int) float64 {
+	index := foldContext.cellIndex(start, end)
+	if index < 0 {
+		return math.Inf(-1)
+	}
+	return foldContext.pairedCells[index].energy
+}
+
+// unpairedMinimumFreeEnergyW returns the energy cached for W(start, end), see
+// fillUnpaired.
+func (foldContext *context) unpairedMinimumFreeEnergyW(start, end int) float64 {
+	index := foldContext.cellIndex(start, end)
+	if index < 0 {
+		return math.Inf(-1)
+	}
+	return foldContext.unpairedCells[index].energy
+}
+
+// branches returns the branches of the structure cached for W(start, end).
+func (foldContext *context) branches(start, end int) []subsequence {
+	index := foldContext.cellIndex(start, end)
+	if index < 0 {
+		return nil
+	}
+	cell := foldContext.unpairedCells[index]
+	return foldContext.branchPool[cell.branchesStart:cell.branchesEnd]
 }
 
 // Result holds the resulting structures of the folded s
 type Result struct {
-	structs []nucleicAcidStructure
+	sequence string
+	structs  []nucleicAcidStructure
 }
 
 // DotBracket returns the dot-bracket notation of the secondary nucleic acid
@@ -245,3 +482,29 @@ func (r Result) MinimumFreeEnergy() float64 {
 	}
 	return summedEnergy
 }
+
+// PairTable returns the PairTable of the secondary nucleic acid structure
+// resulting from folding a sequence. Unlike DotBracket, the PairTable always
+// covers the whole folded sequence. Sequences without a stable structure are
+// returned fully unpaired.
+func (r Result) PairTable() PairTable {
+	length := len(r.sequence)
+	if length == 0 {
+		length = len(r.DotBracket())
+	}
+	table := make(PairTable, length)
+	for index := range table {
+		table[index] = -1
+	}
+	if math.IsInf(r.MinimumFreeEnergy(), 0) {
+		return table
+	}
+	for _, structure := range r.structs {
+		if len(structure.inner) == 1 {
+			innerSubsequence := structure.inner[0]
+			table[innerSubsequence.start] = innerSubsequence.end
+			table[innerSubsequence.end] = innerSubsequence.start
+		}
+	}
+	return table
+}
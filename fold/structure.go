@@ -0,0 +1,274 @@
+package fold
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PairTable represents a secondary structure as a slice as long as the folded
+// sequence, where each index holds the index of the base it pairs with or -1
+// if the base is unpaired.
+type PairTable []int
+
+// ParseDotBracket parses a dot-bracket string, like the one returned by
+// Result.DotBracket, into a PairTable.
+func ParseDotBracket(dotBracket string) (PairTable, error) {
+	table := make(PairTable, len(dotBracket))
+	var openings []int
+	for index, symbol := range dotBracket {
+		switch symbol {
+		case '.':
+			table[index] = -1
+		case '(':
+			openings = append(openings, index)
+		case ')':
+			if len(openings) == 0 {
+				return nil, fmt.Errorf("unbalanced dot-bracket: unexpected ')' at position %d", index)
+			}
+			opening := openings[len(openings)-1]
+			openings = openings[:len(openings)-1]
+			table[opening], table[index] = index, opening
+		default:
+			return nil, fmt.Errorf("invalid dot-bracket character %q at position %d", symbol, index)
+		}
+	}
+	if len(openings) != 0 {
+		return nil, fmt.Errorf("unbalanced dot-bracket: unclosed '(' at position %d", openings[len(openings)-1])
+	}
+	return table, nil
+}
+
+// PairError is the error of a base of a PairTable with an invalid partner.
+type PairError struct {
+	Base, Partner int
+	// OutOfRange is true for partners outside of the table, and false for
+	// partners that don't pair back.
+	OutOfRange bool
+}
+
+// Error returns the bases of a PairError.
+func (e *PairError) Error() string {
+	if e.OutOfRange {
+		return fmt.Sprintf("base %d pairs with out of range base %d", e.Base, e.Partner)
+	}
+	return fmt.Sprintf("base %d pairs with %d which does not pair back", e.Base, e.Partner)
+}
+
+// Validate returns a *PairError if a base of the structure pairs with a base
+// out of range, or with one that doesn't pair back.
+func (table PairTable) Validate() error {
+	for index, partner := range table {
+		if partner < -1 || partner >= len(table) {
+			return &PairError{Base: index, Partner: partner, OutOfRange: true}
+		}
+		if partner >= 0 && (partner == index || table[partner] != index) {
+			return &PairError{Base: index, Partner: partner}
+		}
+	}
+	return nil
+}
+
+// DotBracket returns the dot-bracket notation of the structure. Pseudoknots
+// can't be written in dot-bracket notation, so pairs crossing an earlier pair
+// are written with square brackets. It returns an error if the structure
+// isn't valid.
+func (table PairTable) DotBracket() (string, error) {
+	if err := table.Validate(); err != nil {
+		return "", fmt.Errorf("dot-bracket: %w", err)
+	}
+	result := []byte(strings.Repeat(".", len(table)))
+	// closings holds where the currently open pairs close, innermost last
+	var closings []int
+	for index, partner := range table {
+		switch {
+		case partner > index:
+			closings = append(closings, partner)
+		case partner >= 0 && partner < index && result[index] == '.':
+			// pairs opened after this one but still open cross it
+			opening := len(closings) - 1
+			for closings[opening] != index {
+				opening--
+			}
+			for _, closing := range closings[opening+1:] {
+				result[table[closing]], result[closing] = '[', ']'
+			}
+			closings = closings[:opening]
+			result[partner], result[index] = '(', ')'
+		}
+	}
+	return string(result), nil
+}
+
+// Pairs returns the number of base pairs in the structure.
+func (table PairTable) Pairs() int {
+	pairs := 0
+	for index, partner := range table {
+		if partner > index {
+			pairs++
+		}
+	}
+	return pairs
+}
+
+// BasePairDistance returns the number of base pairs present in only one of
+// two structures of the same sequence. It is the most common way of comparing
+// secondary structures, for example in RNAdistance from the ViennaRNA package:
+// Lorenz et al, 2011
+// https://doi.org/10.1186/1748-7188-6-26
+func BasePairDistance(first, second PairTable) (int, error) {
+	if len(first) != len(second) {
+		return 0, fmt.Errorf("base pair distance: structures are of different lengths %d and %d", len(first), len(second))
+	}
+	for _, table := range []PairTable{first, second} {
+		if err := table.Validate(); err != nil {
+			return 0, fmt.Errorf("base pair distance: %w", err)
+		}
+	}
+	distance := 0
+	for index := range first {
+		if first[index] > index && first[index] != second[index] {
+			distance++
+		}
+		if second[index] > index && second[index] != first[index] {
+			distance++
+		}
+	}
+	return distance, nil
+}
+
+// TreeEditDistance returns the edit distance between two structures
+// represented as ordered trees, where every base pair is a node whose
+// children are the pairs and unpaired bases it encloses. Inserting, deleting
+// or relabeling (pair to unpaired base and vice versa) a node costs 1.
+// Unlike BasePairDistance, the structures may be of different lengths. It
+// returns an error if either structure isn't valid.
+//
+// Shapiro and Zhang, 1990
+// https://doi.org/10.1093/bioinformatics/6.4.309
+//
+// The distance is computed with the algorithm from:
+// Zhang and Shasha, 1989
+// https://doi.org/10.1137/0218082
+func TreeEditDistance(first, second PairTable) (int, error) {
+	for _, table := range []PairTable{first, second} {
+		if err := table.Validate(); err != nil {
+			return 0, fmt.Errorf("tree edit distance: %w", err)
+		}
+	}
+	firstTree := newStructureTree(first)
+	secondTree := newStructureTree(second)
+
+	distances := make([][]int, len(firstTree.labels))
+	for row := range distances {
+		distances[row] = make([]int, len(secondTree.labels))
+	}
+	for _, firstKeyroot := range firstTree.keyroots {
+		for _, secondKeyroot := range secondTree.keyroots {
+			forestDistance(firstTree, secondTree, firstKeyroot, secondKeyroot, distances)
+		}
+	}
+	return distances[len(firstTree.labels)-1][len(secondTree.labels)-1], nil
+}
+
+// structureTree is a secondary structure as an ordered tree, with its nodes
+// numbered in postorder as needed by the Zhang-Shasha algorithm.
+type structureTree struct {
+	// labels holds whether each node is a base pair (true) or unpaired base.
+	labels []bool
+	// leftmost holds the leftmost leaf descendant of each node.
+	leftmost []int
+	// keyroots are the nodes that have a left sibling, plus the root.
+	keyroots []int
+}
+
+// newStructureTree builds the tree of a structure below a virtual root node.
+func newStructureTree(table PairTable) structureTree {
+	var tree structureTree
+	var addChildren func(start, end int) []int
+	addNode := func(pair bool, leftmost int) int {
+		tree.labels = append(tree.labels, pair)
+		tree.leftmost = append(tree.leftmost, leftmost)
+		return len(tree.labels) - 1
+	}
+	// addChildren adds the subtrees of the bases between start and end
+	// (inclusive) and returns their roots
+	addChildren = func(start, end int) []int {
+		var children []int
+		for index := start; index <= end; index++ {
+			partner := table[index]
+			if partner <= index || partner > end {
+				children = append(children, addNode(false, len(tree.labels)))
+				continue
+			}
+			firstNode := len(tree.labels)
+			grandchildren := addChildren(index+1, partner-1)
+			leftmost := firstNode
+			if len(grandchildren) > 0 {
+				leftmost = tree.leftmost[grandchildren[0]]
+			}
+			children = append(children, addNode(true, leftmost))
+			index = partner
+		}
+		return children
+	}
+
+	children := addChildren(0, len(table)-1)
+	rootLeftmost := len(tree.labels)
+	if len(children) > 0 {
+		rootLeftmost = tree.leftmost[children[0]]
+	}
+	root := addNode(true, rootLeftmost)
+
+	// a node is a keyroot if no node later in postorder shares its leftmost leaf
+	seen := make(map[int]bool)
+	for node := root; node >= 0; node-- {
+		if !seen[tree.leftmost[node]] {
+			seen[tree.leftmost[node]] = true
+			tree.keyroots = append(tree.keyroots, node)
+		}
+	}
+	// keyroots must be processed in increasing order
+	for left, right := 0, len(tree.keyroots)-1; left < right; left, right = left+1, right-1 {
+		tree.keyroots[left], tree.keyroots[right] = tree.keyroots[right], tree.keyroots[left]
+	}
+	return tree
+}
+
+// forestDistance fills in the tree distances between all subtrees of the
+// first and second keyroots.
+func forestDistance(first, second structureTree, firstKeyroot, secondKeyroot int, distances [][]int) {
+	firstLeftmost := first.leftmost[firstKeyroot]
+	secondLeftmost := second.leftmost[secondKeyroot]
+	rows := firstKeyroot - firstLeftmost + 2
+	columns := secondKeyroot - secondLeftmost + 2
+
+	forest := make([][]int, rows)
+	for row := range forest {
+		forest[row] = make([]int, columns)
+		forest[row][0] = row
+	}
+	for column := range forest[0] {
+		forest[0][column] = column
+	}
+
+	for row := 1; row < rows; row++ {
+		firstNode := firstLeftmost + row - 1
+		for column := 1; column < columns; column++ {
+			secondNode := secondLeftmost + column - 1
+			deletion := forest[row-1][column] + 1
+			insertion := forest[row][column-1] + 1
+			if first.leftmost[firstNode] == firstLeftmost && second.leftmost[secondNode] == secondLeftmost {
+				relabel := 0
+				if first.labels[firstNode] != second.labels[secondNode] {
+					relabel = 1
+				}
+				forest[row][column] = min(deletion, insertion, forest[row-1][column-1]+relabel)
+				distances[firstNode][secondNode] = forest[row][column]
+				continue
+			}
+			subtreeRow := first.leftmost[firstNode] - firstLeftmost
+			subtreeColumn := second.leftmost[secondNode] - secondLeftmost
+			forest[row][column] = min(deletion, insertion, forest[subtreeRow][subtreeColumn]+distances[firstNode][secondNode])
+		}
+	}
+}
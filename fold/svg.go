@@ -0,0 +1,228 @@
+package fold
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// svgBaseSpacing is the distance between neighboring bases in SVG units.
+const svgBaseSpacing = 15.0
+
+// svgMargin is the empty space around the drawn structure in SVG units.
+const svgMargin = 20.0
+
+// svgBaseColors are the fill colors of each nucleotide in SVG renderings.
+var svgBaseColors = map[byte]string{
+	'A': "#64b964",
+	'C': "#6495ed",
+	'G': "#f0c850",
+	'U': "#e06666",
+	'T': "#e06666",
+}
+
+// point is a position in a secondary structure layout.
+type point struct {
+	x, y float64
+}
+
+// SVG renders a secondary structure of sequence as an SVG image.
+//
+// Bases are laid out with a radial layout: every loop is drawn as a circle
+// with its bases evenly spaced along the circumference and every helix as a
+// straight ladder leaving its loop. This is similar to the layout described in:
+// Bruccoleri and Heinrich, 1988
+// https://doi.org/10.1093/bioinformatics/4.1.167
+//
+// Large multiloops may overlap their neighbors, since no overlap removal is
+// done.
+func SVG(sequence string, structure PairTable) (string, error) {
+	if len(sequence) != len(structure) {
+		return "", fmt.Errorf("svg: sequence is %d long but structure is %d long", len(sequence), len(structure))
+	}
+	if len(sequence) == 0 {
+		return "", errors.New("svg: empty sequence")
+	}
+	if err := structure.Validate(); err != nil {
+		return "", fmt.Errorf("svg: %w", err)
+	}
+
+	positions := layoutStructure(structure)
+
+	// shift the layout so it starts at the margin
+	minimum := point{math.Inf(1), math.Inf(1)}
+	maximum := point{math.Inf(-1), math.Inf(-1)}
+	for _, position := range positions {
+		minimum.x, minimum.y = math.Min(minimum.x, position.x), math.Min(minimum.y, position.y)
+		maximum.x, maximum.y = math.Max(maximum.x, position.x), math.Max(maximum.y, position.y)
+	}
+	for index := range positions {
+		positions[index].x = (positions[index].x-minimum.x)*svgBaseSpacing + svgMargin
+		positions[index].y = (positions[index].y-minimum.y)*svgBaseSpacing + svgMargin
+	}
+	width := (maximum.x-minimum.x)*svgBaseSpacing + 2*svgMargin
+	height := (maximum.y-minimum.y)*svgBaseSpacing + 2*svgMargin
+
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%.0f\" viewBox=\"0 0 %.0f %.0f\">\n", width, height, width, height)
+
+	// backbone
+	svg.WriteString("<polyline fill=\"none\" stroke=\"#999999\" stroke-width=\"1\" points=\"")
+	for index, position := range positions {
+		if index > 0 {
+			svg.WriteString(" ")
+		}
+		fmt.Fprintf(&svg, "%.2f,%.2f", position.x, position.y)
+	}
+	svg.WriteString("\"/>\n")
+
+	// base pairs
+	for index, partner := range structure {
+		if partner > index {
+			fmt.Fprintf(&svg, "<line x1=\"%.2f\" y1=\"%.2f\" x2=\"%.2f\" y2=\"%.2f\" stroke=\"#333333\" stroke-width=\"2\"/>\n", positions[index].x, positions[index].y, positions[partner].x, positions[partner].y)
+		}
+	}
+
+	// bases
+	for index, position := range positions {
+		base := sequence[index]
+		if base >= 'a' && base <= 'z' {
+			base -= 'a' - 'A'
+		}
+		color, ok := svgBaseColors[base]
+		if !ok {
+			color = "#cccccc"
+		}
+		fmt.Fprintf(&svg, "<circle cx=\"%.2f\" cy=\"%.2f\" r=\"%.2f\" fill=\"%s\"/>\n", position.x, position.y, svgBaseSpacing*0.4, color)
+		fmt.Fprintf(&svg, "<text x=\"%.2f\" y=\"%.2f\" font-family=\"monospace\" font-size=\"10\" text-anchor=\"middle\" dominant-baseline=\"central\">%c</text>\n", position.x, position.y, base)
+	}
+	svg.WriteString("</svg>\n")
+	return svg.String(), nil
+}
+
+// SVG renders the secondary nucleic acid structure resulting from folding a
+// sequence as an SVG image. See the SVG function for details.
+func (r Result) SVG() (string, error) {
+	return SVG(r.sequence, r.PairTable())
+}
+
+// layoutStructure returns the position of each base of a structure, in units
+// of the distance between neighboring bases.
+func layoutStructure(structure PairTable) []point {
+	positions := make([]point, len(structure))
+
+	// the exterior loop is laid out on a straight line with its helices
+	// pointing up (negative y)
+	x := 0.0
+	for index := 0; index < len(structure); index++ {
+		partner := structure[index]
+		if partner <= index {
+			positions[index] = point{x, 0}
+			x++
+			continue
+		}
+		// leave room for the loop at the end of the helix so neighboring
+		// helices don't overlap
+		radius, _ := loopRadius(structure, helixEnd(structure, index))
+		offset := math.Max(0, radius-0.5)
+		x += offset
+		positions[index] = point{x, 0}
+		positions[partner] = point{x + 1, 0}
+		layoutHelix(structure, index, point{0, -1}, positions)
+		x += 2 + offset
+		index = partner
+	}
+	return positions
+}
+
+// helixEnd returns the start of the innermost pair of the helix starting with
+// the pair opened at start.
+func helixEnd(structure PairTable, start int) int {
+	end := structure[start]
+	for start+1 < end-1 && structure[start+1] == end-1 {
+		start, end = start+1, end-1
+	}
+	return start
+}
+
+// loopRadius returns the radius of the circle of the loop closed by the pair
+// opened at start, along with the number of bases on it.
+func loopRadius(structure PairTable, start int) (float64, int) {
+	bases := 2
+	for index := start + 1; index < structure[start]; index++ {
+		bases++
+		if structure[index] > index {
+			// the enclosed helix adds both its first and last base
+			bases++
+			index = structure[index]
+		}
+	}
+	return 0.5 / math.Sin(math.Pi/float64(bases)), bases
+}
+
+// layoutHelix lays out the helix starting with the pair opened at start,
+// whose bases must already be positioned, growing in direction, and then the
+// loop that closes it.
+func layoutHelix(structure PairTable, start int, direction point, positions []point) {
+	end := structure[start]
+	for start+1 < end-1 && structure[start+1] == end-1 {
+		positions[start+1] = point{positions[start].x + direction.x, positions[start].y + direction.y}
+		positions[end-1] = point{positions[end].x + direction.x, positions[end].y + direction.y}
+		start, end = start+1, end-1
+	}
+	layoutLoop(structure, start, direction, positions)
+}
+
+// layoutLoop lays out the loop closed by the pair opened at start, whose
+// bases must already be positioned, on a circle in direction from the pair.
+// The helices leaving the loop are laid out recursively.
+func layoutLoop(structure PairTable, start int, direction point, positions []point) {
+	end := structure[start]
+	radius, bases := loopRadius(structure, start)
+	step := 2 * math.Pi / float64(bases)
+
+	// the center is on the perpendicular bisector of the closing pair
+	middle := point{(positions[start].x + positions[end].x) / 2, (positions[start].y + positions[end].y) / 2}
+	centerDistance := radius * math.Cos(step/2)
+	center := point{middle.x + direction.x*centerDistance, middle.y + direction.y*centerDistance}
+
+	// walk around the circle away from the closing pair, so that the last
+	// base of the loop ends up next to the end of the closing pair
+	startAngle := math.Atan2(positions[start].y-center.y, positions[start].x-center.x)
+	endAngle := math.Atan2(positions[end].y-center.y, positions[end].x-center.x)
+	turn := 1.0
+	if angleDifference(startAngle-step, endAngle) > angleDifference(startAngle+step, endAngle) {
+		turn = -1.0
+	}
+	vertex := func(count int) point {
+		angle := startAngle + turn*step*float64(count)
+		return point{center.x + radius*math.Cos(angle), center.y + radius*math.Sin(angle)}
+	}
+
+	count := 1
+	for index := start + 1; index < end; index++ {
+		positions[index] = vertex(count)
+		count++
+		partner := structure[index]
+		if partner <= index {
+			continue
+		}
+		positions[partner] = vertex(count)
+		count++
+		helixMiddle := point{(positions[index].x + positions[partner].x) / 2, (positions[index].y + positions[partner].y) / 2}
+		length := math.Hypot(helixMiddle.x-center.x, helixMiddle.y-center.y)
+		layoutHelix(structure, index, point{(helixMiddle.x - center.x) / length, (helixMiddle.y - center.y) / length}, positions)
+		index = partner
+	}
+}
+
+// angleDifference returns the absolute difference between two angles in
+// radians, between 0 and pi.
+func angleDifference(first, second float64) float64 {
+	difference := math.Mod(math.Abs(first-second), 2*math.Pi)
+	if difference > math.Pi {
+		difference = 2*math.Pi - difference
+	}
+	return difference
+}
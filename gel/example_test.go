@@ -0,0 +1,24 @@
+package gel_test
+
+import (
+	"fmt"
+
+	"github.com/bebop/poly/clone"
+	"github.com/bebop/poly/gel"
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/io/rebase"
+)
+
+func ExampleRun() {
+	puc19, _ := genbank.Read("../data/puc19.gbk")
+	pvuII, _ := clone.EnzymeFromRebase(rebase.Enzyme{Name: "PvuII", RecognitionSequence: "CAG^CTG"})
+	fragments := clone.CutWithEnzyme(clone.Part{Sequence: puc19.Sequence, Circular: true}, false, pvuII)
+
+	digest, _ := gel.Run(1.5, "1kb Plus", gel.Sample{Name: "pUC19 PvuII", Sizes: gel.FragmentSizes(fragments)})
+	for _, band := range digest.Lanes[1].Bands {
+		fmt.Printf("%d bp at %.2f\n", band.Sizes[0], band.Distance)
+	}
+	// Output:
+	// 2364 bp at 0.17
+	// 322 bp at 0.76
+}
@@ -0,0 +1,202 @@
+/*
+Package gel simulates agarose gel electrophoresis of DNA fragments.
+
+After a digest or a PCR, the first thing to check is usually whether the bands
+on a gel have the expected sizes. Run predicts where the fragments from
+clone.CutWithEnzyme or pcr.Simulate run next to a DNA ladder, and the gel it
+returns can be drawn as an SVG or PNG image to compare with the real one.
+
+Linear double stranded DNA migrates about linearly with the logarithm of its
+size within the resolving range of a gel, which gets smaller and shifts to
+shorter fragments as the agarose percentage goes up. Fragments larger than
+the range are compressed near the wells, and fragments smaller than it run
+off the end of the gel. Supercoiled and nicked circular DNA, like an uncut
+plasmid, don't follow this model.
+*/
+package gel
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/bebop/poly/clone"
+)
+
+// resolvingRange is the range of linear DNA sizes, in base pairs, a gel of a
+// given agarose percentage separates well.
+type resolvingRange struct {
+	agarosePercent float64
+	minSize        float64
+	maxSize        float64
+}
+
+// resolvingRanges are the commonly recommended resolving ranges of agarose
+// gels, from lowest to highest agarose percentage.
+var resolvingRanges = []resolvingRange{
+	{0.5, 1000, 30000},
+	{0.7, 800, 12000},
+	{1.0, 500, 10000},
+	{1.2, 400, 7000},
+	{1.5, 200, 3000},
+	{2.0, 50, 2000},
+}
+
+// The resolving range of a gel runs from rangeStart to rangeEnd, as fractions
+// of the length of the gel.
+const (
+	rangeStart = 0.1
+	rangeEnd   = 0.9
+)
+
+// bandWidth is the distance, as a fraction of the length of the gel, under
+// which fragments co-migrate into a single band.
+const bandWidth = 0.01
+
+// Ladders are the sizes of the bands of common DNA ladders, in base pairs.
+var Ladders = map[string][]int{
+	"1kb":      {10000, 8000, 6000, 5000, 4000, 3000, 2000, 1500, 1000, 500},
+	"1kb Plus": {10000, 8000, 6000, 5000, 4000, 3000, 2000, 1500, 1200, 1000, 900, 800, 700, 600, 500, 400, 300, 200, 100},
+	"100bp":    {1517, 1200, 1000, 900, 800, 700, 600, 517, 500, 400, 300, 200, 100},
+}
+
+// Sample is a named set of linear DNA fragments loaded in a lane.
+type Sample struct {
+	Name  string
+	Sizes []int
+}
+
+// Band is a band in a lane of a gel.
+type Band struct {
+	// Sizes are the sizes of the fragments in the band, more than one when
+	// fragments co-migrate.
+	Sizes []int
+	// Distance is how far the band migrated, as a fraction of the length of
+	// the gel from the wells.
+	Distance float64
+	// Mass is the total size of the fragments in the band, proportional to the
+	// amount of DNA, and so the brightness of the band, when all fragments
+	// come from the same molecules.
+	Mass int
+}
+
+// Lane is a lane of a gel.
+type Lane struct {
+	Name  string
+	Bands []Band
+	// RanOff are the sizes of the fragments that ran off the end of the gel.
+	RanOff []int
+}
+
+// Gel is a simulated agarose gel.
+type Gel struct {
+	AgarosePercent float64
+	Lanes          []Lane
+}
+
+// FragmentSizes returns the sizes of fragments from a digest, the length of
+// their longer strand. Each overhang is single stranded, so it is only counted
+// on the strand that carries it: the top strand for 5' overhangs of the
+// forward end and 3' overhangs of the reverse end, and the bottom strand
+// otherwise.
+func FragmentSizes(fragments []clone.Fragment) []int {
+	sizes := make([]int, len(fragments))
+	for index, fragment := range fragments {
+		topStrand, bottomStrand := len(fragment.Sequence), len(fragment.Sequence)
+		if fragment.ForwardThreePrime {
+			bottomStrand += len(fragment.ForwardOverhang)
+		} else {
+			topStrand += len(fragment.ForwardOverhang)
+		}
+		if fragment.ReverseThreePrime {
+			topStrand += len(fragment.ReverseOverhang)
+		} else {
+			bottomStrand += len(fragment.ReverseOverhang)
+		}
+		sizes[index] = max(topStrand, bottomStrand)
+	}
+	return sizes
+}
+
+// SequenceSizes returns the sizes of linear sequences, like PCR products.
+func SequenceSizes(sequences []string) []int {
+	sizes := make([]int, len(sequences))
+	for index, sequence := range sequences {
+		sizes[index] = len(sequence)
+	}
+	return sizes
+}
+
+// Run simulates a gel of a given agarose percentage with a lane for each
+// sample, after a lane for the named ladder from Ladders. An empty ladder name
+// runs the gel without a ladder.
+func Run(agarosePercent float64, ladder string, samples ...Sample) (Gel, error) {
+	if agarosePercent < resolvingRanges[0].agarosePercent || agarosePercent > resolvingRanges[len(resolvingRanges)-1].agarosePercent {
+		return Gel{}, fmt.Errorf("gel: agarose percentage %.2f is outside of %.1f to %.1f", agarosePercent, resolvingRanges[0].agarosePercent, resolvingRanges[len(resolvingRanges)-1].agarosePercent)
+	}
+	if ladder != "" {
+		sizes, ok := Ladders[ladder]
+		if !ok {
+			return Gel{}, fmt.Errorf("gel: unknown ladder %q", ladder)
+		}
+		samples = append([]Sample{{Name: ladder, Sizes: sizes}}, samples...)
+	}
+
+	gel := Gel{AgarosePercent: agarosePercent}
+	for _, sample := range samples {
+		lane := Lane{Name: sample.Name}
+		sizes := append([]int{}, sample.Sizes...)
+		sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+		for _, size := range sizes {
+			if size <= 0 {
+				return Gel{}, fmt.Errorf("gel: sample %q has a fragment of size %d", sample.Name, size)
+			}
+			distance := Migration(size, agarosePercent)
+			if distance > 1 {
+				lane.RanOff = append(lane.RanOff, size)
+				continue
+			}
+			// fragments are sorted by size, so co-migrating fragments follow
+			// each other
+			if last := len(lane.Bands) - 1; last >= 0 && distance-lane.Bands[last].Distance < bandWidth {
+				lane.Bands[last].Sizes = append(lane.Bands[last].Sizes, size)
+				lane.Bands[last].Mass += size
+				continue
+			}
+			lane.Bands = append(lane.Bands, Band{Sizes: []int{size}, Distance: distance, Mass: size})
+		}
+		gel.Lanes = append(gel.Lanes, lane)
+	}
+	return gel, nil
+}
+
+// Migration returns how far a linear DNA fragment of a given size migrates in
+// a gel of a given agarose percentage, as a fraction of the length of the gel
+// from the wells. Fragments migrating further than 1 run off the gel.
+//
+// The resolving range of the gel, interpolated between commonly recommended
+// ranges, spans 0.1 to 0.9 of its length, and fragments migrate linearly with
+// the logarithm of their size within it. Larger fragments are compressed
+// towards the wells.
+func Migration(size int, agarosePercent float64) float64 {
+	index := sort.Search(len(resolvingRanges)-1, func(i int) bool {
+		return resolvingRanges[i+1].agarosePercent >= agarosePercent
+	})
+	lower, upper := resolvingRanges[index], resolvingRanges[min(index+1, len(resolvingRanges)-1)]
+	fraction := 0.0
+	if upper.agarosePercent > lower.agarosePercent {
+		fraction = (agarosePercent - lower.agarosePercent) / (upper.agarosePercent - lower.agarosePercent)
+	}
+	fraction = math.Max(0, math.Min(1, fraction))
+	logMin := math.Log10(lower.minSize) + fraction*(math.Log10(upper.minSize)-math.Log10(lower.minSize))
+	logMax := math.Log10(lower.maxSize) + fraction*(math.Log10(upper.maxSize)-math.Log10(lower.maxSize))
+
+	position := (logMax - math.Log10(float64(size))) / (logMax - logMin)
+	if position >= 0 {
+		return rangeStart + position*(rangeEnd-rangeStart)
+	}
+	// compress larger fragments towards the wells, keeping the same slope at
+	// the edge of the resolving range
+	slope := (rangeEnd - rangeStart) / rangeStart
+	return rangeStart / (1 - slope*position)
+}
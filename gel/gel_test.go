@@ -0,0 +1,115 @@
+package gel
+
+import (
+	"bytes"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/clone"
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/io/rebase"
+)
+
+func TestMigration(t *testing.T) {
+	// the resolving range of a 1% gel spans most of its length
+	if distance := Migration(10000, 1.0); math.Abs(distance-rangeStart) > 1e-9 {
+		t.Errorf("10 kb should migrate to the start of the range of a 1%% gel, got %f", distance)
+	}
+	if distance := Migration(500, 1.0); math.Abs(distance-rangeEnd) > 1e-9 {
+		t.Errorf("500 bp should migrate to the end of the range of a 1%% gel, got %f", distance)
+	}
+	previous := 0.0
+	for _, size := range []int{50000, 20000, 10000, 3000, 1000, 300, 100} {
+		distance := Migration(size, 0.8)
+		if distance <= previous || distance <= 0 {
+			t.Errorf("Fragments of %d bp should migrate further than larger ones, got %f", size, distance)
+		}
+		previous = distance
+	}
+	// small fragments are resolved by higher percentage gels
+	if Migration(100, 2.0) >= Migration(100, 1.0) {
+		t.Errorf("100 bp should migrate less in a 2%% gel than in a 1%% gel")
+	}
+}
+
+func TestFragmentSizes(t *testing.T) {
+	// the fragments of a digest add up to the length of the plasmid
+	puc19, err := genbank.Read("../data/puc19.gbk")
+	if err != nil {
+		t.Fatalf("Failed to read pUC19: %s", err)
+	}
+	ecoRI, _ := clone.EnzymeFromRebase(rebase.Enzyme{Name: "EcoRI", RecognitionSequence: "G^AATTC"})
+	hindIII, _ := clone.EnzymeFromRebase(rebase.Enzyme{Name: "HindIII", RecognitionSequence: "A^AGCTT"})
+	sizes := FragmentSizes(clone.CutWithEnzyme(clone.Part{Sequence: puc19.Sequence, Circular: true}, false, ecoRI, hindIII))
+	if len(sizes) != 2 || sizes[0]+sizes[1] != len(puc19.Sequence) {
+		t.Errorf("Expected 2 fragments adding up to %d bp, got %v", len(puc19.Sequence), sizes)
+	}
+
+	// a 3' overhang at the forward end and a 5' overhang at the reverse end
+	// are both carried by the bottom strand
+	fragment := clone.Fragment{Sequence: strings.Repeat("A", 100), ForwardOverhang: "TGCA", ReverseOverhang: "AATT", ForwardThreePrime: true}
+	if sizes := FragmentSizes([]clone.Fragment{fragment}); sizes[0] != 108 {
+		t.Errorf("Expected 108 bp, got %d", sizes[0])
+	}
+}
+
+func TestRun(t *testing.T) {
+	fragments := []clone.Fragment{
+		{Sequence: strings.Repeat("A", 2996), ForwardOverhang: "AATT", ReverseOverhang: ""},
+		{Sequence: strings.Repeat("C", 3000)},
+		{Sequence: strings.Repeat("G", 800)},
+		{Sequence: strings.Repeat("T", 20)},
+	}
+	gel, err := Run(1.0, "1kb", Sample{Name: "digest", Sizes: FragmentSizes(fragments)}, Sample{Name: "pcr", Sizes: SequenceSizes([]string{strings.Repeat("A", 1500)})})
+	if err != nil {
+		t.Fatalf("Run failed: %s", err)
+	}
+	if len(gel.Lanes) != 3 || gel.Lanes[0].Name != "1kb" || len(gel.Lanes[0].Bands) != len(Ladders["1kb"]) {
+		t.Fatalf("Expected a ladder lane and 2 sample lanes, got %+v", gel.Lanes)
+	}
+
+	// the two 3 kb fragments co-migrate, and 20 bp runs off the gel
+	digest := gel.Lanes[1]
+	if len(digest.Bands) != 2 || len(digest.Bands[0].Sizes) != 2 || digest.Bands[0].Mass != 6000 || digest.Bands[1].Sizes[0] != 800 {
+		t.Errorf("Unexpected digest bands %+v", digest.Bands)
+	}
+	if len(digest.RanOff) != 1 || digest.RanOff[0] != 20 {
+		t.Errorf("Expected 20 bp to run off, got %v", digest.RanOff)
+	}
+	// bands run level with ladder bands of the same size
+	if gel.Lanes[2].Bands[0].Distance != gel.Lanes[0].Bands[7].Distance {
+		t.Errorf("1500 bp should run level with the 1.5 kb band of the ladder")
+	}
+
+	for _, test := range []struct {
+		agarosePercent float64
+		ladder         string
+		sizes          []int
+	}{{3.0, "1kb", nil}, {1.0, "10kb", nil}, {1.0, "", []int{0}}} {
+		if _, err := Run(test.agarosePercent, test.ladder, Sample{Name: "sample", Sizes: test.sizes}); err == nil {
+			t.Errorf("Run(%.1f, %q, %v) should have failed", test.agarosePercent, test.ladder, test.sizes)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	gel, _ := Run(1.0, "100bp", Sample{Name: "<pcr>", Sizes: []int{600}})
+	svg := gel.SVG()
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, "&lt;pcr&gt;") || !strings.Contains(svg, ">1517<") {
+		t.Errorf("SVG should have lane names and ladder sizes, got %s", svg)
+	}
+
+	var buffer bytes.Buffer
+	if err := gel.PNG(&buffer); err != nil {
+		t.Fatalf("PNG failed: %s", err)
+	}
+	config, err := png.DecodeConfig(&buffer)
+	if err != nil {
+		t.Fatalf("PNG should decode: %s", err)
+	}
+	if config.Width != 2*renderMargin+2*(renderLaneWidth+renderLaneSpacing)+renderLaneSpacing || config.Height != 2*renderMargin+renderGelLength {
+		t.Errorf("Unexpected PNG size %dx%d", config.Width, config.Height)
+	}
+}
@@ -0,0 +1,101 @@
+package gel
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// Dimensions of rendered gels, in pixels.
+const (
+	renderLaneWidth   = 60
+	renderLaneSpacing = 20
+	renderBandHeight  = 4
+	renderGelLength   = 400
+	renderMargin      = 20
+	// renderLabelSpace is the space above the wells for lane names, and left
+	// of the gel for ladder sizes, in SVG renderings.
+	renderLabelSpace = 40
+)
+
+// SVG renders the gel as an SVG image, with lanes labeled by name and the
+// bands of the first lane labeled by size, to read the ladder.
+func (gel Gel) SVG() string {
+	width := 2*renderMargin + renderLabelSpace + len(gel.Lanes)*(renderLaneWidth+renderLaneSpacing) + renderLaneSpacing
+	height := 2*renderMargin + renderLabelSpace + renderGelLength
+	top := renderMargin + renderLabelSpace
+	left := renderMargin + renderLabelSpace
+
+	var svg bytes.Buffer
+	fmt.Fprintf(&svg, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\">\n", width, height, width, height)
+	fmt.Fprintf(&svg, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"#1a1a1a\"/>\n", left, top, width-left-renderMargin, renderGelLength)
+	for laneIndex, lane := range gel.Lanes {
+		x := left + renderLaneSpacing + laneIndex*(renderLaneWidth+renderLaneSpacing)
+		fmt.Fprintf(&svg, "<text x=\"%d\" y=\"%d\" font-family=\"sans-serif\" font-size=\"10\" text-anchor=\"middle\">%s</text>\n", x+renderLaneWidth/2, top-8, html.EscapeString(lane.Name))
+		fmt.Fprintf(&svg, "<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" fill=\"#444444\"/>\n", x, top, renderLaneWidth, renderBandHeight)
+		maxMass := laneMaxMass(lane)
+		for _, band := range lane.Bands {
+			y := float64(top) + band.Distance*renderGelLength
+			fmt.Fprintf(&svg, "<rect x=\"%d\" y=\"%.2f\" width=\"%d\" height=\"%d\" fill=\"#ffffff\" fill-opacity=\"%.2f\"/>\n", x, y-renderBandHeight/2, renderLaneWidth, renderBandHeight, bandBrightness(band, maxMass))
+			if laneIndex == 0 {
+				fmt.Fprintf(&svg, "<text x=\"%d\" y=\"%.2f\" font-family=\"sans-serif\" font-size=\"9\" text-anchor=\"end\" dominant-baseline=\"central\">%d</text>\n", left-4, y, band.Sizes[0])
+			}
+		}
+	}
+	svg.WriteString("</svg>\n")
+	return svg.String()
+}
+
+// PNG renders the gel as a PNG image. Unlike SVG renderings, PNG renderings
+// have no labels.
+func (gel Gel) PNG(writer io.Writer) error {
+	width := 2*renderMargin + len(gel.Lanes)*(renderLaneWidth+renderLaneSpacing) + renderLaneSpacing
+	height := 2*renderMargin + renderGelLength
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	fillRect(img, 0, 0, width, height, 255)
+	fillRect(img, renderMargin, renderMargin, width-renderMargin, renderMargin+renderGelLength, 26)
+	for laneIndex, lane := range gel.Lanes {
+		x := renderMargin + renderLaneSpacing + laneIndex*(renderLaneWidth+renderLaneSpacing)
+		fillRect(img, x, renderMargin, x+renderLaneWidth, renderMargin+renderBandHeight, 68)
+		maxMass := laneMaxMass(lane)
+		for _, band := range lane.Bands {
+			y := renderMargin + int(band.Distance*renderGelLength) - renderBandHeight/2
+			level := 26 + uint8(bandBrightness(band, maxMass)*(255-26))
+			fillRect(img, x, y, x+renderLaneWidth, y+renderBandHeight, level)
+		}
+	}
+	if err := png.Encode(writer, img); err != nil {
+		return fmt.Errorf("gel: %w", err)
+	}
+	return nil
+}
+
+// laneMaxMass returns the mass of the brightest band of a lane.
+func laneMaxMass(lane Lane) int {
+	maxMass := 1
+	for _, band := range lane.Bands {
+		maxMass = max(maxMass, band.Mass)
+	}
+	return maxMass
+}
+
+// bandBrightness returns the brightness of a band, from 0 to 1, relative to
+// the brightest band of its lane. Faint bands stay visible.
+func bandBrightness(band Band, maxMass int) float64 {
+	return 0.3 + 0.7*float64(band.Mass)/float64(maxMass)
+}
+
+// fillRect fills the rectangle from (x0, y0) to (x1, y1) of an image with a
+// gray level.
+func fillRect(img *image.Gray, x0, y0, x1, y1 int, level uint8) {
+	bounds := img.Bounds()
+	for y := max(y0, bounds.Min.Y); y < min(y1, bounds.Max.Y); y++ {
+		for x := max(x0, bounds.Min.X); x < min(x1, bounds.Max.X); x++ {
+			img.SetGray(x, y, color.Gray{Y: level})
+		}
+	}
+}
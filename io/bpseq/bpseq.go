@@ -0,0 +1,138 @@
+/*
+Package bpseq contains a parser and writer for BPSEQ secondary structure files.
+
+BPSEQ is the format used by the Comparative RNA Web site and many structure
+databases. A file holds a single structure, with optional header lines
+followed by one line per base:
+
+	index base partner
+
+where partner is the 1-based index of the base it pairs with or 0 if it is
+unpaired.
+
+https://rna.urmc.rochester.edu/Text/File_Formats.html#bpseq
+*/
+package bpseq
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bebop/poly/fold"
+)
+
+// Bpseq is the structure of a BPSEQ file.
+type Bpseq struct {
+	// Header holds the lines before the first base, like "Filename: ..." or
+	// "Organism: ...".
+	Header []string `json:"header"`
+	// Sequence holds the bases of the structure.
+	Sequence string `json:"sequence"`
+	// Pairs is as long as Sequence and holds the 0-based index of the base
+	// each base pairs with, or -1 if it is unpaired. It can be used as a
+	// fold.PairTable.
+	Pairs []int `json:"pairs"`
+}
+
+// Parse parses a BPSEQ file. Lines before the first line starting with an
+// integer are kept as header lines.
+func Parse(r io.Reader) (Bpseq, error) {
+	scanner := bufio.NewScanner(r)
+	var structure Bpseq
+	var sequence strings.Builder
+	var partners []int
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		position, err := strconv.Atoi(fields[0])
+		if err != nil {
+			if len(partners) > 0 {
+				return Bpseq{}, fmt.Errorf("line %d: expected base index, found %q", lineNumber, fields[0])
+			}
+			structure.Header = append(structure.Header, line)
+			continue
+		}
+		if len(fields) != 3 {
+			return Bpseq{}, fmt.Errorf("line %d: expected 3 columns, found %d", lineNumber, len(fields))
+		}
+		if position != len(partners)+1 {
+			return Bpseq{}, fmt.Errorf("line %d: expected base index %d, found %d", lineNumber, len(partners)+1, position)
+		}
+		partner, err := strconv.Atoi(fields[2])
+		if err != nil || partner < 0 {
+			return Bpseq{}, fmt.Errorf("line %d: invalid pairing partner %q", lineNumber, fields[2])
+		}
+		sequence.WriteString(fields[1])
+		partners = append(partners, partner-1)
+	}
+	if err := scanner.Err(); err != nil {
+		return Bpseq{}, err
+	}
+	structure.Sequence = sequence.String()
+	structure.Pairs = partners
+	if err := validatePairs(structure.Pairs); err != nil {
+		return Bpseq{}, err
+	}
+	return structure, nil
+}
+
+// Read reads a BPSEQ file.
+func Read(path string) (Bpseq, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Bpseq{}, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Build converts a structure into the bytes of a BPSEQ file.
+func Build(structure Bpseq) ([]byte, error) {
+	if len(structure.Sequence) != len(structure.Pairs) {
+		return nil, fmt.Errorf("sequence is %d long but has %d pairs", len(structure.Sequence), len(structure.Pairs))
+	}
+	if err := validatePairs(structure.Pairs); err != nil {
+		return nil, err
+	}
+	var bpseqBuffer bytes.Buffer
+	for _, line := range structure.Header {
+		bpseqBuffer.WriteString(line)
+		bpseqBuffer.WriteString("\n")
+	}
+	for index, partner := range structure.Pairs {
+		fmt.Fprintf(&bpseqBuffer, "%d %c %d\n", index+1, structure.Sequence[index], partner+1)
+	}
+	return bpseqBuffer.Bytes(), nil
+}
+
+// Write writes a structure to a BPSEQ file.
+func Write(structure Bpseq, path string) error {
+	bpseqBytes, err := Build(structure)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, bpseqBytes, 0644)
+}
+
+// validatePairs checks that every base pairs back with its partner, reporting
+// bases by their number in the file, from 1.
+func validatePairs(pairs []int) error {
+	err := fold.PairTable(pairs).Validate()
+	var pairError *fold.PairError
+	if errors.As(err, &pairError) {
+		pairError.Base++
+		pairError.Partner++
+	}
+	return err
+}
@@ -0,0 +1,72 @@
+package bpseq
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	structure, err := Read("data/hairpin.bpseq")
+	if err != nil {
+		t.Fatalf("failed to read hairpin.bpseq: %s", err)
+	}
+	if len(structure.Header) != 2 || structure.Header[1] != "Organism: synthetic" {
+		t.Errorf("unexpected header %v", structure.Header)
+	}
+	if structure.Sequence != "GGGAAACAUCAUUCCCAAAA" {
+		t.Errorf("unexpected sequence %q", structure.Sequence)
+	}
+	if structure.Pairs[0] != 15 || structure.Pairs[15] != 0 || structure.Pairs[5] != -1 {
+		t.Errorf("unexpected pairs %v", structure.Pairs)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	structure, err := Read("data/hairpin.bpseq")
+	if err != nil {
+		t.Fatalf("failed to read hairpin.bpseq: %s", err)
+	}
+	bpseqBytes, err := Build(structure)
+	if err != nil {
+		t.Fatalf("failed to build: %s", err)
+	}
+	reparsed, err := Parse(strings.NewReader(string(bpseqBytes)))
+	if err != nil {
+		t.Fatalf("failed to parse built BPSEQ: %s", err)
+	}
+	if !reflect.DeepEqual(structure, reparsed) {
+		t.Errorf("round trip changed structure: %v != %v", structure, reparsed)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"header after bases": "1 G 0\nheader\n",
+		"wrong columns":      "1 G\n",
+		"bad index":          "2 G 0\n",
+		"bad partner":        "1 G x\n",
+		"asymmetric pair":    "1 G 3\n2 A 0\n3 C 0\n",
+		"partner range":      "1 G 5\n",
+	}
+	for name, file := range tests {
+		if _, err := Parse(strings.NewReader(file)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// bases are reported by their number in the file
+	_, err := Parse(strings.NewReader("1 G 3\n2 A 0\n3 C 0\n"))
+	if err == nil || !strings.Contains(err.Error(), "base 1 pairs with 3 which does not pair back") {
+		t.Errorf("expected base 1 to be reported, got %v", err)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	if _, err := Build(Bpseq{Sequence: "GC", Pairs: []int{-1}}); err == nil {
+		t.Error("expected an error for mismatched lengths")
+	}
+	if _, err := Build(Bpseq{Sequence: "GC", Pairs: []int{1, -1}}); err == nil || !strings.Contains(err.Error(), "base 1 pairs with 2 which does not pair back") {
+		t.Errorf("expected an error for asymmetric pairs at base 1, got %v", err)
+	}
+}
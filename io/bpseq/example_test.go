@@ -0,0 +1,47 @@
+package bpseq_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bebop/poly/io/bpseq"
+)
+
+func ExampleRead() {
+	structure, _ := bpseq.Read("data/hairpin.bpseq")
+	fmt.Println(structure.Sequence)
+	// Output: GGGAAACAUCAUUCCCAAAA
+}
+
+func ExampleParse() {
+	file, _ := os.Open("data/hairpin.bpseq")
+	defer file.Close()
+	structure, _ := bpseq.Parse(file)
+	fmt.Println(structure.Header[0])
+	// Output: Filename: hairpin.bpseq
+}
+
+func ExampleBuild() {
+	structure := bpseq.Bpseq{Sequence: "GGGAAACCC", Pairs: []int{8, 7, 6, -1, -1, -1, 2, 1, 0}}
+	bpseqBytes, _ := bpseq.Build(structure)
+	fmt.Print(string(bytes.SplitAfter(bpseqBytes, []byte("\n"))[0]))
+	// Output: 1 G 9
+}
+
+func ExampleWrite() {
+	tmpDataDir, err := os.MkdirTemp("", "data-*")
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	defer os.RemoveAll(tmpDataDir)
+
+	structure, _ := bpseq.Read("data/hairpin.bpseq")
+	path := filepath.Join(tmpDataDir, "hairpin.bpseq")
+	_ = bpseq.Write(structure, path)
+
+	rewritten, _ := bpseq.Read(path)
+	fmt.Println(rewritten.Sequence == structure.Sequence)
+	// Output: true
+}
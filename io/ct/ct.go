@@ -0,0 +1,154 @@
+/*
+Package ct contains a parser and writer for Connectivity Table (CT) files.
+
+CT is the secondary structure format used by mfold, RNAstructure and the
+ViennaRNA package. A file holds one or more structures, each starting with a
+header line giving the sequence length and a title, followed by one line per
+base:
+
+	index base previous next partner natural-index
+
+where partner is the 1-based index of the base it pairs with or 0 if it is
+unpaired.
+
+https://rna.urmc.rochester.edu/Text/File_Formats.html#CT
+*/
+package ct
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/bebop/poly/fold"
+)
+
+// Ct is a single structure of a CT file.
+type Ct struct {
+	// Name is the title on the header line, after the sequence length, with
+	// runs of whitespace collapsed to single spaces.
+	Name string `json:"name"`
+	// Sequence holds the bases of the structure.
+	Sequence string `json:"sequence"`
+	// Pairs is as long as Sequence and holds the 0-based index of the base
+	// each base pairs with, or -1 if it is unpaired. It can be used as a
+	// fold.PairTable.
+	Pairs []int `json:"pairs"`
+}
+
+// Parse parses all structures of a CT file.
+func Parse(r io.Reader) ([]Ct, error) {
+	scanner := bufio.NewScanner(r)
+	var structures []Ct
+	lineNumber := 0
+	nextLine := func() ([]string, bool) {
+		for scanner.Scan() {
+			lineNumber++
+			fields := strings.Fields(scanner.Text())
+			if len(fields) > 0 {
+				return fields, true
+			}
+		}
+		return nil, false
+	}
+
+	for {
+		header, ok := nextLine()
+		if !ok {
+			break
+		}
+		length, err := strconv.Atoi(header[0])
+		if err != nil || length < 0 {
+			return nil, fmt.Errorf("line %d: invalid sequence length %q in header", lineNumber, header[0])
+		}
+		structure := Ct{Name: strings.Join(header[1:], " "), Pairs: make([]int, length)}
+		var sequence strings.Builder
+		for index := 0; index < length; index++ {
+			fields, ok := nextLine()
+			if !ok {
+				return nil, fmt.Errorf("structure %q: expected %d bases, found %d", structure.Name, length, index)
+			}
+			if len(fields) < 6 {
+				return nil, fmt.Errorf("line %d: expected 6 columns, found %d", lineNumber, len(fields))
+			}
+			position, err := strconv.Atoi(fields[0])
+			if err != nil || position != index+1 {
+				return nil, fmt.Errorf("line %d: expected base index %d, found %q", lineNumber, index+1, fields[0])
+			}
+			partner, err := strconv.Atoi(fields[4])
+			if err != nil || partner < 0 || partner > length {
+				return nil, fmt.Errorf("line %d: invalid pairing partner %q", lineNumber, fields[4])
+			}
+			sequence.WriteString(fields[1])
+			structure.Pairs[index] = partner - 1
+		}
+		structure.Sequence = sequence.String()
+		if err := validatePairs(structure.Pairs); err != nil {
+			return nil, fmt.Errorf("structure %q: %w", structure.Name, err)
+		}
+		structures = append(structures, structure)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return structures, nil
+}
+
+// Read reads all structures of a CT file.
+func Read(path string) ([]Ct, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Parse(file)
+}
+
+// Build converts structures into the bytes of a CT file.
+func Build(structures []Ct) ([]byte, error) {
+	var ctBuffer bytes.Buffer
+	for _, structure := range structures {
+		if len(structure.Sequence) != len(structure.Pairs) {
+			return nil, fmt.Errorf("structure %q: sequence is %d long but has %d pairs", structure.Name, len(structure.Sequence), len(structure.Pairs))
+		}
+		if err := validatePairs(structure.Pairs); err != nil {
+			return nil, fmt.Errorf("structure %q: %w", structure.Name, err)
+		}
+		length := len(structure.Sequence)
+		fmt.Fprintf(&ctBuffer, "%5d %s\n", length, structure.Name)
+		for index := 0; index < length; index++ {
+			next := index + 2
+			if index == length-1 {
+				next = 0
+			}
+			fmt.Fprintf(&ctBuffer, "%5d %c %5d %5d %5d %5d\n", index+1, structure.Sequence[index], index, next, structure.Pairs[index]+1, index+1)
+		}
+	}
+	return ctBuffer.Bytes(), nil
+}
+
+// Write writes structures to a CT file.
+func Write(structures []Ct, path string) error {
+	ctBytes, err := Build(structures)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, ctBytes, 0644)
+}
+
+// validatePairs checks that every base pairs back with its partner, reporting
+// bases by their number in the file, from 1.
+func validatePairs(pairs []int) error {
+	err := fold.PairTable(pairs).Validate()
+	var pairError *fold.PairError
+	if errors.As(err, &pairError) {
+		pairError.Base++
+		pairError.Partner++
+	}
+	return err
+}
@@ -0,0 +1,77 @@
+package ct
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	structures, err := Read("data/hairpin.ct")
+	if err != nil {
+		t.Fatalf("failed to read hairpin.ct: %s", err)
+	}
+	if len(structures) != 1 {
+		t.Fatalf("expected 1 structure, got %d", len(structures))
+	}
+	structure := structures[0]
+	if structure.Name != "ENERGY = -4.5 hairpin" {
+		t.Errorf("unexpected name %q", structure.Name)
+	}
+	if structure.Sequence != "GGGAAACAUCAUUCCCAAAA" {
+		t.Errorf("unexpected sequence %q", structure.Sequence)
+	}
+	if structure.Pairs[0] != 15 || structure.Pairs[15] != 0 || structure.Pairs[5] != -1 {
+		t.Errorf("unexpected pairs %v", structure.Pairs)
+	}
+}
+
+func TestBuild(t *testing.T) {
+	structures, err := Read("data/hairpin.ct")
+	if err != nil {
+		t.Fatalf("failed to read hairpin.ct: %s", err)
+	}
+	structures = append(structures, Ct{Name: "second", Sequence: "GGGAAACCC", Pairs: []int{8, 7, 6, -1, -1, -1, 2, 1, 0}})
+	ctBytes, err := Build(structures)
+	if err != nil {
+		t.Fatalf("failed to build: %s", err)
+	}
+	reparsed, err := Parse(strings.NewReader(string(ctBytes)))
+	if err != nil {
+		t.Fatalf("failed to parse built CT: %s", err)
+	}
+	if !reflect.DeepEqual(structures, reparsed) {
+		t.Errorf("round trip changed structures: %v != %v", structures, reparsed)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := map[string]string{
+		"bad length":      "x name\n",
+		"missing bases":   "3 name\n1 G 0 2 0 1\n",
+		"bad index":       "1 name\n2 G 0 0 0 1\n",
+		"short line":      "1 name\n1 G 0 0\n",
+		"asymmetric pair": "3 name\n1 G 0 2 3 1\n2 A 1 3 0 2\n3 C 2 0 0 3\n",
+		"partner range":   "1 name\n1 G 0 0 5 1\n",
+	}
+	for name, file := range tests {
+		if _, err := Parse(strings.NewReader(file)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// bases are reported by their number in the file
+	_, err := Parse(strings.NewReader("3 name\n1 G 0 2 3 1\n2 A 1 3 0 2\n3 C 2 0 0 3\n"))
+	if err == nil || !strings.Contains(err.Error(), "base 1 pairs with 3 which does not pair back") {
+		t.Errorf("expected base 1 to be reported, got %v", err)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	if _, err := Build([]Ct{{Sequence: "GC", Pairs: []int{-1}}}); err == nil {
+		t.Error("expected an error for mismatched lengths")
+	}
+	if _, err := Build([]Ct{{Sequence: "GC", Pairs: []int{1, -1}}}); err == nil || !strings.Contains(err.Error(), "base 1 pairs with 2 which does not pair back") {
+		t.Errorf("expected an error for asymmetric pairs at base 1, got %v", err)
+	}
+}
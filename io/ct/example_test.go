@@ -0,0 +1,48 @@
+package ct_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bebop/poly/io/ct"
+)
+
+func ExampleRead() {
+	structures, _ := ct.Read("data/hairpin.ct")
+	fmt.Println(structures[0].Sequence)
+	// Output: GGGAAACAUCAUUCCCAAAA
+}
+
+func ExampleParse() {
+	file, _ := os.Open("data/hairpin.ct")
+	defer file.Close()
+	structures, _ := ct.Parse(file)
+	fmt.Println(structures[0].Pairs[0])
+	// Output: 15
+}
+
+func ExampleBuild() {
+	structures := []ct.Ct{{Name: "hairpin", Sequence: "GGGAAACCC", Pairs: []int{8, 7, 6, -1, -1, -1, 2, 1, 0}}}
+	ctBytes, _ := ct.Build(structures)
+	reparsed, _ := ct.Parse(bytes.NewReader(ctBytes))
+	fmt.Println(reparsed[0].Name)
+	// Output: hairpin
+}
+
+func ExampleWrite() {
+	tmpDataDir, err := os.MkdirTemp("", "data-*")
+	if err != nil {
+		fmt.Println(err.Error())
+	}
+	defer os.RemoveAll(tmpDataDir)
+
+	structures, _ := ct.Read("data/hairpin.ct")
+	path := filepath.Join(tmpDataDir, "hairpin.ct")
+	_ = ct.Write(structures, path)
+
+	rewritten, _ := ct.Read(path)
+	fmt.Println(rewritten[0].Sequence == structures[0].Sequence)
+	// Output: true
+}
@@ -0,0 +1,353 @@
+package primers
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/bebop/poly/checks"
+	"github.com/bebop/poly/primers/internal/bases"
+	"github.com/bebop/poly/transform"
+)
+
+/******************************************************************************
+
+Barcode set design begins here.
+
+Barcodes cut from a De Bruijn sequence never share a substring, but they can
+still be one or two bases apart, so a single sequencing error can turn one of
+them into another. CreateBarcodeSet picks barcodes at least some number of
+edits apart instead: a set with a minimum distance d detects up to d-1 errors
+and corrects up to (d-1)/2 of them, which is what a BarcodeDecoder does.
+
+Hamming distance only counts substitutions, which is what Illumina reads
+mostly have. Nanopore reads have as many insertions and deletions, which
+Levenshtein distance counts too.
+
+Barcodes are picked greedily from random candidates, which is far from the
+largest set possible but works well for the hundreds of barcodes needed to
+multiplex a run.
+
+******************************************************************************/
+
+// DistanceMetric is the distance between barcodes.
+type DistanceMetric int
+
+// Distance metrics of barcode sets.
+const (
+	// Hamming counts substitutions.
+	Hamming DistanceMetric = iota
+	// Levenshtein counts substitutions, insertions and deletions.
+	Levenshtein
+)
+
+// Distance returns the distance between two sequences. The Hamming distance
+// of sequences of different lengths counts the extra bases as substitutions.
+func (metric DistanceMetric) Distance(sequence, otherSequence string) int {
+	return metric.boundedDistance(sequence, otherSequence, len(sequence)+len(otherSequence))
+}
+
+// boundedDistance returns the distance between two sequences, or any
+// distance of at least bound if they are at least that far apart, which is
+// faster to find.
+func (metric DistanceMetric) boundedDistance(sequence, otherSequence string, bound int) int {
+	if metric == Hamming {
+		distance := max(len(sequence), len(otherSequence)) - min(len(sequence), len(otherSequence))
+		for index := 0; index < min(len(sequence), len(otherSequence)) && distance < bound; index++ {
+			if sequence[index] != otherSequence[index] {
+				distance++
+			}
+		}
+		return distance
+	}
+
+	// rows of the edit distance matrix only grow, so the distance is at
+	// least the smallest value of any row
+	previous, current := make([]int, len(otherSequence)+1), make([]int, len(otherSequence)+1)
+	for index := range previous {
+		previous[index] = index
+	}
+	for i := 1; i <= len(sequence); i++ {
+		current[0] = i
+		rowMinimum := current[0]
+		for j := 1; j <= len(otherSequence); j++ {
+			substitution := previous[j-1]
+			if sequence[i-1] != otherSequence[j-1] {
+				substitution++
+			}
+			current[j] = min(substitution, previous[j]+1, current[j-1]+1)
+			rowMinimum = min(rowMinimum, current[j])
+		}
+		if rowMinimum >= bound {
+			return rowMinimum
+		}
+		previous, current = current, previous
+	}
+	return previous[len(otherSequence)]
+}
+
+// BarcodeConstraints are the constraints of a barcode set.
+type BarcodeConstraints struct {
+	// Length is the length of barcodes, and Count the number of barcodes of
+	// the set.
+	Length, Count int
+	// Metric is the distance barcodes are at least MinDistance apart by.
+	Metric      DistanceMetric
+	MinDistance int
+	// BothStrands keeps barcodes MinDistance apart from the reverse
+	// complements of all barcodes too, their own included, for reads of
+	// either strand.
+	BothStrands bool
+	// MinGcContent and MaxGcContent are fractions of G and C bases.
+	MinGcContent, MaxGcContent float64
+	// MaxHomopolymer is the longest run of a single base barcodes may have.
+	MaxHomopolymer int
+	// BannedSequences may not occur in barcodes, on either strand, like the
+	// recognition sites of the enzymes of a library prep.
+	BannedSequences []string
+	// Seed seeds the random candidates, and MaxAttempts is the number of
+	// candidates tried before giving up.
+	Seed        int64
+	MaxAttempts int
+}
+
+// DefaultBarcodeConstraints returns constraints for a set of barcodes at least
+// three edits apart on either strand, so any single error is corrected, with
+// 40 to 60% GC and no runs of more than two bases.
+func DefaultBarcodeConstraints(length, count int) BarcodeConstraints {
+	return BarcodeConstraints{
+		Length:         length,
+		Count:          count,
+		Metric:         Levenshtein,
+		MinDistance:    3,
+		BothStrands:    true,
+		MinGcContent:   0.4,
+		MaxGcContent:   0.6,
+		MaxHomopolymer: 2,
+		MaxAttempts:    100000,
+	}
+}
+
+// CreateBarcodeSet creates a set of barcodes meeting the constraints, all of
+// them at least the minimum distance apart. It returns an error if fewer
+// barcodes than asked for were found within the maximum number of attempts.
+func CreateBarcodeSet(constraints BarcodeConstraints) ([]string, error) {
+	if constraints.Length <= 0 || constraints.Count <= 0 {
+		return nil, errors.New("barcode length and count must be positive")
+	}
+	var bannedSequences []string
+	for _, bannedSequence := range constraints.BannedSequences {
+		bannedSequence = strings.ToUpper(bannedSequence)
+		bannedSequences = append(bannedSequences, bannedSequence, transform.ReverseComplement(bannedSequence))
+	}
+
+	randomSource := rand.New(rand.NewSource(constraints.Seed))
+	candidate := make([]byte, constraints.Length)
+	var barcodes, reverseComplements []string
+	for attempt := 0; attempt < constraints.MaxAttempts && len(barcodes) < constraints.Count; attempt++ {
+		for index := range candidate {
+			candidate[index] = "ACGT"[randomSource.Intn(4)]
+		}
+		barcode := string(candidate)
+		if !barcodeMeetsConstraints(barcode, bannedSequences, constraints) {
+			continue
+		}
+
+		others := barcodes
+		if constraints.BothStrands {
+			reverseComplement := transform.ReverseComplement(barcode)
+			if constraints.Metric.boundedDistance(barcode, reverseComplement, constraints.MinDistance) < constraints.MinDistance {
+				continue
+			}
+			others = append(append([]string{}, barcodes...), reverseComplements...)
+		}
+		tooClose := false
+		for _, other := range others {
+			if constraints.Metric.boundedDistance(barcode, other, constraints.MinDistance) < constraints.MinDistance {
+				tooClose = true
+				break
+			}
+		}
+		if tooClose {
+			continue
+		}
+		barcodes = append(barcodes, barcode)
+		reverseComplements = append(reverseComplements, transform.ReverseComplement(barcode))
+	}
+	if len(barcodes) < constraints.Count {
+		return nil, fmt.Errorf("found %d barcodes out of %d in %d attempts", len(barcodes), constraints.Count, constraints.MaxAttempts)
+	}
+	return barcodes, nil
+}
+
+// barcodeMeetsConstraints returns whether a barcode meets the constraints not
+// depending on the other barcodes.
+func barcodeMeetsConstraints(barcode string, bannedSequences []string, constraints BarcodeConstraints) bool {
+	gcContent := checks.GcContent(barcode)
+	if gcContent < constraints.MinGcContent || gcContent > constraints.MaxGcContent {
+		return false
+	}
+	if bases.LongestRun(barcode) > constraints.MaxHomopolymer {
+		return false
+	}
+	for _, bannedSequence := range bannedSequences {
+		if strings.Contains(barcode, bannedSequence) {
+			return false
+		}
+	}
+	return true
+}
+
+// BarcodeMatch is a barcode an observed sequence decodes to.
+type BarcodeMatch struct {
+	// Barcode is the index of the barcode in the set, and Distance the
+	// number of errors corrected.
+	Barcode  int
+	Distance int
+}
+
+// BarcodeDecoder decodes observed barcodes with errors into the barcodes of a
+// set.
+type BarcodeDecoder struct {
+	Barcodes  []string
+	Metric    DistanceMetric
+	MaxErrors int
+	// Table maps every sequence within the maximum number of errors of a
+	// single barcode to it. Sequences as close to several barcodes are left
+	// out, so decoding never guesses.
+	Table map[string]BarcodeMatch
+}
+
+// NewBarcodeDecoder returns a decoder correcting up to a maximum number of
+// errors in the barcodes of a set, which is always unambiguous for sets with
+// a minimum distance of at least twice that plus one. Barcodes must be unique
+// and all have the same length.
+func NewBarcodeDecoder(barcodes []string, metric DistanceMetric, maxErrors int) (*BarcodeDecoder, error) {
+	if len(barcodes) == 0 {
+		return nil, errors.New("no barcodes to decode")
+	}
+	if maxErrors < 0 {
+		return nil, fmt.Errorf("maximum number of errors %d is negative", maxErrors)
+	}
+	decoder := &BarcodeDecoder{Metric: metric, MaxErrors: maxErrors, Table: make(map[string]BarcodeMatch)}
+	for _, barcode := range barcodes {
+		decoder.Barcodes = append(decoder.Barcodes, strings.ToUpper(barcode))
+	}
+	indexes := make(map[string]int)
+	for index, barcode := range decoder.Barcodes {
+		if len(barcode) != len(decoder.Barcodes[0]) {
+			return nil, errors.New("barcodes must all have the same length")
+		}
+		if previous, ok := indexes[barcode]; ok {
+			return nil, fmt.Errorf("barcodes %d and %d are both %s", previous, index, barcode)
+		}
+		indexes[barcode] = index
+	}
+
+	// sequences are first reached with the fewest edits
+	ambiguous := make(map[string]bool)
+	for index, barcode := range decoder.Barcodes {
+		seen := map[string]bool{barcode: true}
+		level := []string{barcode}
+		for distance := 0; distance <= maxErrors; distance++ {
+			for _, sequence := range level {
+				match, ok := decoder.Table[sequence]
+				switch {
+				case !ok || distance < match.Distance:
+					decoder.Table[sequence] = BarcodeMatch{Barcode: index, Distance: distance}
+					delete(ambiguous, sequence)
+				case distance == match.Distance && match.Barcode != index:
+					ambiguous[sequence] = true
+				}
+			}
+			if distance == maxErrors {
+				break
+			}
+			var nextLevel []string
+			for _, sequence := range level {
+				for _, edited := range singleEdits(sequence, metric) {
+					if !seen[edited] {
+						seen[edited] = true
+						nextLevel = append(nextLevel, edited)
+					}
+				}
+			}
+			level = nextLevel
+		}
+	}
+	for sequence := range ambiguous {
+		delete(decoder.Table, sequence)
+	}
+	return decoder, nil
+}
+
+// singleEdits returns the sequences one edit of a metric away from a
+// sequence.
+func singleEdits(sequence string, metric DistanceMetric) []string {
+	var edits []string
+	for index := 0; index <= len(sequence); index++ {
+		for _, base := range []string{"A", "C", "G", "T"} {
+			if index < len(sequence) && sequence[index] != base[0] {
+				edits = append(edits, sequence[:index]+base+sequence[index+1:])
+			}
+			if metric == Levenshtein {
+				edits = append(edits, sequence[:index]+base+sequence[index:])
+			}
+		}
+		if metric == Levenshtein && index < len(sequence) {
+			edits = append(edits, sequence[:index]+sequence[index+1:])
+		}
+	}
+	return edits
+}
+
+// Decode returns the barcode an observed sequence decodes to, and whether it
+// decodes to one.
+func (decoder *BarcodeDecoder) Decode(sequence string) (BarcodeMatch, bool) {
+	match, ok := decoder.Table[strings.ToUpper(sequence)]
+	return match, ok
+}
+
+// DecodePrefix returns the barcode the start of a read decodes to, the number
+// of bases of the read it spans, and whether it decodes to one. Barcodes with
+// insertions or deletions span more or fewer bases than their length, and the
+// span with the fewest errors is picked, closest to the barcode length if
+// several have as few.
+func (decoder *BarcodeDecoder) DecodePrefix(read string) (BarcodeMatch, int, bool) {
+	read = strings.ToUpper(read)
+	barcodeLength := len(decoder.Barcodes[0])
+	minLength, maxLength := barcodeLength, barcodeLength
+	if decoder.Metric == Levenshtein {
+		minLength, maxLength = barcodeLength-decoder.MaxErrors, barcodeLength+decoder.MaxErrors
+	}
+
+	var best BarcodeMatch
+	bestLength, found := 0, false
+	for length := max(1, minLength); length <= min(maxLength, len(read)); length++ {
+		match, ok := decoder.Table[read[:length]]
+		if !ok {
+			continue
+		}
+		if found && match.Distance == best.Distance && match.Barcode != best.Barcode {
+			// spans as close to different barcodes make the read ambiguous,
+			// unless a closer span turns up
+			best.Barcode = -1
+		}
+		if !found || match.Distance < best.Distance || (match.Barcode == best.Barcode && abs(length-barcodeLength) < abs(bestLength-barcodeLength)) {
+			best, bestLength, found = match, length, true
+		}
+	}
+	if !found || best.Barcode < 0 {
+		return BarcodeMatch{}, 0, false
+	}
+	return best, bestLength, true
+}
+
+// abs returns the absolute value of an integer.
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
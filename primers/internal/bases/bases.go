@@ -0,0 +1,36 @@
+/*
+Package bases holds the helpers the primer design packages share to read the
+bases of templates.
+*/
+package bases
+
+import "strings"
+
+// Window returns the bases [start, end) of a sequence, wrapping around the
+// origin of circular sequences. Windows of linear sequences are clipped to
+// their ends.
+func Window(sequence string, circular bool, start, end int) string {
+	if !circular {
+		return sequence[max(0, start):min(len(sequence), end)]
+	}
+	var window strings.Builder
+	for position := start; position < end; position++ {
+		window.WriteByte(sequence[((position%len(sequence))+len(sequence))%len(sequence)])
+	}
+	return window.String()
+}
+
+// LongestRun returns the length of the longest run of a single base in a
+// sequence.
+func LongestRun(sequence string) int {
+	longest, run := 0, 0
+	for index := range sequence {
+		if index > 0 && sequence[index] == sequence[index-1] {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+	}
+	return longest
+}
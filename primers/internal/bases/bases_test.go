@@ -0,0 +1,31 @@
+package bases
+
+import "testing"
+
+func TestWindow(t *testing.T) {
+	tests := []struct {
+		circular   bool
+		start, end int
+		expected   string
+	}{
+		{false, 2, 5, "GTA"},
+		{false, -2, 3, "ACG"},
+		{false, 6, 10, "GT"},
+		{true, 6, 10, "GTAC"},
+		{true, -2, 2, "GTAC"},
+		{true, 0, 12, "ACGTACGTACGT"},
+	}
+	for _, test := range tests {
+		if window := Window("ACGTACGT", test.circular, test.start, test.end); window != test.expected {
+			t.Errorf("expected %s for %d..%d, got %s", test.expected, test.start, test.end, window)
+		}
+	}
+}
+
+func TestLongestRun(t *testing.T) {
+	for sequence, expected := range map[string]int{"ACCCTTTTG": 4, "": 0, "A": 1, "ACGT": 1} {
+		if longest := LongestRun(sequence); longest != expected {
+			t.Errorf("expected a longest run of %d in %q, got %d", expected, sequence, longest)
+		}
+	}
+}
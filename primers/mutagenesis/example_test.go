@@ -0,0 +1,36 @@
+package mutagenesis_test
+
+import (
+	"fmt"
+
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/primers/mutagenesis"
+	"github.com/bebop/poly/synthesis/codon"
+)
+
+func Example_basic() {
+	puc19, _ := genbank.Read("../../data/puc19.gbk")
+	var bla genbank.Feature
+	for _, feature := range puc19.Features {
+		if feature.Attributes["label"] == "AmpR" {
+			bla = feature
+		}
+	}
+
+	// the serine at residue 68 of beta-lactamase is its catalytic residue
+	edit, _ := mutagenesis.AminoAcidSubstitution(puc19, bla, "S68A", codon.NewTranslationTable(11))
+	quikChange, _ := mutagenesis.DesignQuikChange(puc19, edit)
+	backToBack, _ := mutagenesis.DesignBackToBack(puc19, edit, 60)
+
+	fmt.Printf("QuikChange: %s %.1f°C\n", quikChange.Forward.Sequence, quikChange.Forward.MeltingTemp)
+	fmt.Printf("Q5: %s %.1f°C / %s %.1f°C\n", backToBack.Forward.Sequence, backToBack.Forward.MeltingTemp, backToBack.Reverse.Sequence, backToBack.Reverse.MeltingTemp)
+	for _, feature := range backToBack.Plasmid.Features {
+		if feature.Attributes["label"] == "AmpR" {
+			fmt.Println(feature.Attributes["translation"][60:75])
+		}
+	}
+	// Output:
+	// QuikChange: GAAGAACGTTTTCCAATGATGGCCACTTTTAAAGTTCTGCTATGT 77.5°C
+	// Q5: TCCAATGATGGCCACTTTTAAAGTTCTGCTATGTGGCGCG 60.9°C / AAACGTTCTTCGGGGCGAAAACT 60.3°C
+	// EERFPMMATFKVLLC
+}
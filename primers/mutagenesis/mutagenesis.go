@@ -0,0 +1,448 @@
+/*
+Package mutagenesis designs primers for site-directed mutagenesis of plasmids.
+
+Site-directed mutagenesis amplifies a whole plasmid with primers carrying an
+edit, a substitution, insertion or deletion of a few bases, so every copy made
+carries it too. There are two common ways of doing it:
+
+QuikChange uses a pair of complementary primers, both carrying the edit in
+their middle. The product is nicked circular DNA, which is transformed after
+digesting the methylated template with DpnI.
+
+Q5 site-directed mutagenesis, like the other back-to-back protocols, uses
+primers whose 5' ends meet at the edit, pointing away from each other. The
+edit is carried in the 5' end of the forward primer, or split between both
+primers for longer insertions, and the linear product is circularized with a
+kinase, ligase and DpnI mix.
+
+QuikChange: https://www.agilent.com/cs/library/usermanuals/public/200518.pdf
+Q5 site-directed mutagenesis: https://www.neb.com/en-us/protocols/2013/01/26/q5-site-directed-mutagenesis-kit-protocol-e0554
+*/
+package mutagenesis
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bebop/poly/checks"
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/primers"
+	"github.com/bebop/poly/primers/internal/bases"
+	"github.com/bebop/poly/synthesis/codon"
+	"github.com/bebop/poly/transform"
+)
+
+// Primer lengths and melting temperatures recommended by the QuikChange and Q5
+// site-directed mutagenesis manuals.
+const (
+	minQuikChangeLength = 25
+	maxQuikChangeLength = 45
+	minQuikChangeTm     = 78
+	// maxQuikChangeFlank is the number of bases primers are extended by to
+	// end with a G or C.
+	maxQuikChangeFlank = 2
+
+	minAnnealingLength = 10
+	maxAnnealingLength = 40
+	// maxForwardInsertion is the longest insertion carried by the forward
+	// primer alone in back-to-back designs, longer ones being split between
+	// both primers.
+	maxForwardInsertion = 6
+	// substitutionFlank is the number of bases annealing to the template
+	// before substitutions in back-to-back designs.
+	substitutionFlank = 10
+)
+
+// Edit replaces the bases [Start, End) of a plasmid with Sequence. Start and
+// End are 0-based positions on the top strand, so an insertion has Start equal
+// to End, and a deletion has an empty Sequence.
+type Edit struct {
+	Start, End int
+	Sequence   string
+}
+
+// Substitution returns the edit replacing the bases of a plasmid starting at
+// a position with others.
+func Substitution(position int, bases string) Edit {
+	return Edit{Start: position, End: position + len(bases), Sequence: bases}
+}
+
+// Insertion returns the edit inserting a sequence before a position of a
+// plasmid.
+func Insertion(position int, sequence string) Edit {
+	return Edit{Start: position, End: position, Sequence: sequence}
+}
+
+// Deletion returns the edit deleting the bases [start, end) of a plasmid.
+func Deletion(start, end int) Edit {
+	return Edit{Start: start, End: end}
+}
+
+// mutationRegex matches amino acid substitutions like S70A.
+var mutationRegex = regexp.MustCompile(`^([A-Z*])(\d+)([A-Z*])$`)
+
+// AminoAcidSubstitution returns the edit making an amino acid substitution in
+// a CDS feature of a plasmid, written like S70A for the serine at residue 70
+// turning into an alanine. The codon picked for the new amino acid is the one
+// with the fewest changes from the current codon, and the most frequent in the
+// codon table of those.
+func AminoAcidSubstitution(plasmid genbank.Genbank, cds genbank.Feature, mutation string, table codon.Table) (Edit, error) {
+	match := mutationRegex.FindStringSubmatch(strings.ToUpper(mutation))
+	if match == nil {
+		return Edit{}, fmt.Errorf("mutation %q isn't written like S70A", mutation)
+	}
+	residue, _ := strconv.Atoi(match[2])
+	location := cds.Location
+	if len(location.SubLocations) > 0 {
+		return Edit{}, errors.New("substitutions in joined features aren't supported")
+	}
+	if location.Start < 0 || location.End > len(plasmid.Sequence) || location.Start >= location.End {
+		return Edit{}, fmt.Errorf("feature %d..%d isn't within the plasmid", location.Start, location.End)
+	}
+	offset := (residue - 1) * 3
+	if residue < 1 || offset+3 > location.End-location.Start {
+		return Edit{}, fmt.Errorf("residue %d is outside of the feature", residue)
+	}
+
+	// codons of features on the bottom strand are read backwards from their
+	// end
+	start := location.Start + offset
+	if location.Complement {
+		start = location.End - offset - 3
+	}
+	currentCodon := strings.ToUpper(plasmid.Sequence[start : start+3])
+	if location.Complement {
+		currentCodon = transform.ReverseComplement(currentCodon)
+	}
+	currentAminoAcid, err := table.Translate(currentCodon)
+	if err != nil {
+		return Edit{}, err
+	}
+	// alternative start codons, like GTG, start proteins with methionine too
+	if translationTable, ok := table.(*codon.TranslationTable); ok && residue == 1 && !location.FivePrimePartial {
+		if start, ok := translationTable.StartCodonTable[currentCodon]; ok {
+			currentAminoAcid = start
+		}
+	}
+	if currentAminoAcid != match[1] {
+		return Edit{}, fmt.Errorf("residue %d is %s, not %s", residue, currentAminoAcid, match[1])
+	}
+
+	newCodon := ""
+	fewestChanges, highestWeight := 4, -1
+	for _, aminoAcid := range table.GetWeightedAminoAcids() {
+		if aminoAcid.Letter != match[3] {
+			continue
+		}
+		for _, candidate := range aminoAcid.Codons {
+			changes := 0
+			for index := range candidate.Triplet {
+				if candidate.Triplet[index] != currentCodon[index] {
+					changes++
+				}
+			}
+			if changes < fewestChanges || (changes == fewestChanges && candidate.Weight > highestWeight) {
+				newCodon, fewestChanges, highestWeight = candidate.Triplet, changes, candidate.Weight
+			}
+		}
+	}
+	if newCodon == "" {
+		return Edit{}, fmt.Errorf("amino acid %s is missing from the codon table", match[3])
+	}
+	if location.Complement {
+		newCodon = transform.ReverseComplement(newCodon)
+	}
+	return Substitution(start, newCodon), nil
+}
+
+// Primer is a mutagenesis primer.
+type Primer struct {
+	Sequence string
+	// MeltingTemp is the melting temperature of the whole primer for
+	// QuikChange primers, calculated like its manual does, and that of the
+	// part annealing to the template at the 3' end for back-to-back primers.
+	MeltingTemp float64
+}
+
+// Design is a pair of mutagenesis primers.
+type Design struct {
+	Forward, Reverse Primer
+	// Plasmid is the plasmid the primers make.
+	Plasmid genbank.Genbank
+}
+
+// DesignQuikChange designs a pair of complementary QuikChange primers making
+// an edit. The primers carry the edit in their middle and are extended on both
+// sides until they reach 78°C and 25 bases, or 45 bases in AT rich regions,
+// and then to end with a G or C where possible.
+func DesignQuikChange(plasmid genbank.Genbank, edit Edit) (Design, error) {
+	mutated, err := Apply(plasmid, edit)
+	if err != nil {
+		return Design{}, err
+	}
+	sequence := strings.ToUpper(mutated.Sequence)
+	circular := mutated.Meta.Locus.Circular
+
+	// substitutions bind the template with mismatches, while the inserted
+	// bases of other edits don't bind it at all
+	editStart, editEnd := edit.Start, edit.Start+len(edit.Sequence)
+	mismatches, unpaired := 0, len(edit.Sequence)
+	if len(edit.Sequence) == edit.End-edit.Start {
+		original := strings.ToUpper(plasmid.Sequence[edit.Start:edit.End])
+		for index := range original {
+			if original[index] != strings.ToUpper(edit.Sequence)[index] {
+				mismatches++
+			}
+		}
+		unpaired = 0
+	}
+	meltingTemp := func(primer string) float64 {
+		length := float64(len(primer) - unpaired)
+		gcContent := checks.GcContent(primer) * 100
+		return 81.5 + 0.41*gcContent - 675/length - 100*float64(mismatches)/length
+	}
+
+	start, end := editStart, editEnd
+	for grow := 0; end-start < minQuikChangeLength || (end-start < maxQuikChangeLength && meltingTemp(bases.Window(sequence, circular, start, end)) < minQuikChangeTm); grow++ {
+		if grow%2 == 0 {
+			start--
+		} else {
+			end++
+		}
+		if !circular && (start < 0 || end > len(sequence)) {
+			return Design{}, errors.New("the edit is too close to the end of a linear plasmid")
+		}
+	}
+	// primers ending with a G or C on both ends anneal better
+	for flank := 0; flank < maxQuikChangeFlank && end-start < maxQuikChangeLength && !strings.ContainsAny(bases.Window(sequence, circular, start, start+1), "GC"); flank++ {
+		if !circular && start == 0 {
+			break
+		}
+		start--
+	}
+	for flank := 0; flank < maxQuikChangeFlank && end-start < maxQuikChangeLength && !strings.ContainsAny(bases.Window(sequence, circular, end-1, end), "GC"); flank++ {
+		if !circular && end == len(sequence) {
+			break
+		}
+		end++
+	}
+
+	forward := bases.Window(sequence, circular, start, end)
+	return Design{
+		Forward: Primer{Sequence: forward, MeltingTemp: meltingTemp(forward)},
+		Reverse: Primer{Sequence: transform.ReverseComplement(forward), MeltingTemp: meltingTemp(forward)},
+		Plasmid: mutated,
+	}, nil
+}
+
+// DesignBackToBack designs a pair of back-to-back primers making an edit, like
+// for Q5 site-directed mutagenesis. The primers anneal to the template over
+// their shortest 3' end reaching the target melting temperature, calculated by
+// primers.MeltingTemp. Substitutions are carried by the forward primer after
+// ten bases annealing to the template, and insertions at its 5' end, split
+// between both primers when longer than six bases.
+func DesignBackToBack(plasmid genbank.Genbank, edit Edit, targetTm float64) (Design, error) {
+	mutated, err := Apply(plasmid, edit)
+	if err != nil {
+		return Design{}, err
+	}
+	sequence := strings.ToUpper(mutated.Sequence)
+	circular := mutated.Meta.Locus.Circular
+	editStart, editEnd := edit.Start, edit.Start+len(edit.Sequence)
+
+	// the 3' end of the forward primer anneals after the edit
+	forwardLength, err := annealingLength(sequence, circular, editEnd, 1, targetTm)
+	if err != nil {
+		return Design{}, fmt.Errorf("forward primer: %w", err)
+	}
+	split := editStart
+	switch {
+	case len(edit.Sequence) == edit.End-edit.Start:
+		// the forward primer anneals on both sides of substitutions, so
+		// mismatches don't melt its 5' end
+		split = editStart - substitutionFlank
+	case len(edit.Sequence) > maxForwardInsertion:
+		split = editStart + len(edit.Sequence)/2
+	}
+	// the 3' end of the reverse primer anneals before the edit
+	reverseLength, err := annealingLength(sequence, circular, min(split, editStart), -1, targetTm)
+	if err != nil {
+		return Design{}, fmt.Errorf("reverse primer: %w", err)
+	}
+	reverseStart := min(split, editStart) - reverseLength
+	if !circular && (split < 0 || reverseStart < 0) {
+		return Design{}, errors.New("the edit is too close to the end of a linear plasmid")
+	}
+
+	forward := bases.Window(sequence, circular, split, editEnd+forwardLength)
+	reverse := transform.ReverseComplement(bases.Window(sequence, circular, reverseStart, split))
+	return Design{
+		Forward: Primer{Sequence: forward, MeltingTemp: primers.MeltingTemp(forward[len(forward)-forwardLength:])},
+		Reverse: Primer{Sequence: reverse, MeltingTemp: primers.MeltingTemp(reverse[len(reverse)-reverseLength:])},
+		Plasmid: mutated,
+	}, nil
+}
+
+// annealingLength returns the length of the shortest run of bases of a
+// sequence from a position, forwards or backwards, reaching a melting
+// temperature.
+func annealingLength(sequence string, circular bool, position, direction int, targetTm float64) (int, error) {
+	for length := minAnnealingLength; length <= maxAnnealingLength; length++ {
+		start, end := position, position+length
+		if direction < 0 {
+			start, end = position-length, position
+		}
+		if !circular && (start < 0 || end > len(sequence)) {
+			return 0, errors.New("the edit is too close to the end of a linear plasmid")
+		}
+		if primers.MeltingTemp(bases.Window(sequence, circular, start, end)) >= targetTm {
+			return length, nil
+		}
+	}
+	return 0, fmt.Errorf("primers reach %d bases before %.1f°C", maxAnnealingLength, targetTm)
+}
+
+// Apply returns a copy of a plasmid with an edit made. Features are moved
+// along with the bases they annotate, resized if the edit is within them, and
+// dropped if the edit deletes all of their bases. The translations of CDS
+// features the edit changes are updated.
+func Apply(plasmid genbank.Genbank, edit Edit) (genbank.Genbank, error) {
+	if edit.Start < 0 || edit.End > len(plasmid.Sequence) || edit.Start > edit.End {
+		return genbank.Genbank{}, fmt.Errorf("edit %d..%d isn't within the plasmid of %d bases", edit.Start, edit.End, len(plasmid.Sequence))
+	}
+	if strings.Trim(strings.ToUpper(edit.Sequence), "ACGT") != "" {
+		return genbank.Genbank{}, fmt.Errorf("edit sequence %q has bases other than A, C, G and T", edit.Sequence)
+	}
+	if strings.EqualFold(plasmid.Sequence[edit.Start:edit.End], edit.Sequence) {
+		return genbank.Genbank{}, errors.New("edit doesn't change the plasmid")
+	}
+	// edited bases follow the case of the plasmid
+	inserted := strings.ToUpper(edit.Sequence)
+	if strings.ToLower(plasmid.Sequence) == plasmid.Sequence {
+		inserted = strings.ToLower(edit.Sequence)
+	}
+
+	mutated := plasmid
+	mutated.Sequence = plasmid.Sequence[:edit.Start] + inserted + plasmid.Sequence[edit.End:]
+	mutated.Meta.Locus.SequenceLength = strconv.Itoa(len(mutated.Sequence))
+	mutated.Features = nil
+	for _, feature := range plasmid.Features {
+		location, ok := editLocation(feature.Location, edit)
+		if !ok {
+			continue
+		}
+		previousSequence := featureSequence(plasmid.Sequence, feature.Location)
+
+		feature.Location = location
+		feature.ParentSequence = &mutated
+		attributes := make(map[string]string, len(feature.Attributes))
+		for key, value := range feature.Attributes {
+			attributes[key] = value
+		}
+		feature.Attributes = attributes
+		sequence := featureSequence(mutated.Sequence, location)
+		if feature.Sequence != "" {
+			feature.Sequence = sequence
+		}
+		if _, ok := feature.Attributes["translation"]; ok && feature.Type == "CDS" && sequence != previousSequence {
+			updateTranslation(&feature, sequence)
+		}
+		mutated.Features = append(mutated.Features, feature)
+	}
+	return mutated, nil
+}
+
+// editLocation returns a location moved and resized by an edit, and false if
+// the edit deletes all of its bases.
+func editLocation(location genbank.Location, edit Edit) (genbank.Location, bool) {
+	shift := len(edit.Sequence) - (edit.End - edit.Start)
+	// positions within the edit are kept if the edit still reaches them, like
+	// for substitutions, and moved to its end otherwise
+	editPosition := func(position int, isStart bool) int {
+		switch {
+		case position > edit.End || (position == edit.End && (isStart || edit.Start < edit.End)):
+			return position + shift
+		case position < edit.Start || (position == edit.Start && !isStart):
+			return position
+		}
+		return min(position, edit.Start+len(edit.Sequence))
+	}
+
+	if len(location.SubLocations) > 0 {
+		var subLocations []genbank.Location
+		for _, subLocation := range location.SubLocations {
+			if subLocation, ok := editLocation(subLocation, edit); ok {
+				subLocations = append(subLocations, subLocation)
+			}
+		}
+		if len(subLocations) == 0 {
+			return genbank.Location{}, false
+		}
+		location.SubLocations = subLocations
+	} else {
+		// locations spanning the origin of circular plasmids end before they
+		// start
+		spansOrigin := location.End < location.Start
+		location.Start, location.End = editPosition(location.Start, true), editPosition(location.End, false)
+		if !spansOrigin && location.Start >= location.End {
+			return genbank.Location{}, false
+		}
+	}
+	if location.GbkLocationString != "" {
+		location.GbkLocationString = genbank.BuildLocationString(location)
+	}
+	return location, true
+}
+
+// featureSequence returns the sequence of a feature location, which may span
+// the origin of a circular plasmid.
+func featureSequence(sequence string, location genbank.Location) string {
+	var bases string
+	if len(location.SubLocations) > 0 {
+		for _, subLocation := range location.SubLocations {
+			bases += featureSequence(sequence, subLocation)
+		}
+	} else if location.End < location.Start {
+		bases = sequence[location.Start:] + sequence[:location.End]
+	} else {
+		bases = sequence[location.Start:location.End]
+	}
+	if location.Complement {
+		return transform.ReverseComplement(bases)
+	}
+	return bases
+}
+
+// updateTranslation translates the edited sequence of a CDS feature with the
+// genetic code of its transl_table qualifier, or the standard code, starting
+// with methionine if it starts with a start codon of the code. The translation
+// is dropped if the edit shifted the frame, or if there is no such code.
+func updateTranslation(feature *genbank.Feature, sequence string) {
+	tableIndex := 1
+	if index, err := strconv.Atoi(feature.Attributes["transl_table"]); err == nil {
+		tableIndex = index
+	}
+	startCodon := feature.Attributes["codon_start"]
+	if len(sequence)%3 != 0 || (startCodon != "" && startCodon != "1") {
+		delete(feature.Attributes, "translation")
+		return
+	}
+	table := codon.NewTranslationTable(tableIndex)
+	if table == nil {
+		delete(feature.Attributes, "translation")
+		return
+	}
+	translation, err := table.Translate(sequence)
+	if err != nil {
+		delete(feature.Attributes, "translation")
+		return
+	}
+	// alternative start codons, like GTG, start proteins with methionine too
+	if start, ok := table.StartCodonTable[strings.ToUpper(sequence[:3])]; ok && !feature.Location.FivePrimePartial {
+		translation = start + translation[1:]
+	}
+	feature.Attributes["translation"] = strings.TrimSuffix(translation, "*")
+}
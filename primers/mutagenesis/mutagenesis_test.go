@@ -0,0 +1,240 @@
+package mutagenesis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/synthesis/codon"
+	"github.com/bebop/poly/transform"
+)
+
+func readPuc19(t *testing.T) genbank.Genbank {
+	t.Helper()
+	puc19, err := genbank.Read("../../data/puc19.gbk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return puc19
+}
+
+func findFeature(plasmid genbank.Genbank, label string) (genbank.Feature, bool) {
+	for _, feature := range plasmid.Features {
+		if feature.Attributes["label"] == label {
+			return feature, true
+		}
+	}
+	return genbank.Feature{}, false
+}
+
+func TestAminoAcidSubstitution(t *testing.T) {
+	puc19 := readPuc19(t)
+	bla, _ := findFeature(puc19, "AmpR")
+	table := codon.NewTranslationTable(11)
+
+	edit, err := AminoAcidSubstitution(puc19, bla, "S68A", table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// AGC turns into GCC, the only alanine codon two changes away
+	if edit.Start != bla.Location.Start+67*3 || edit.Sequence != "GCC" {
+		t.Errorf("expected GCC at %d, got %s at %d", bla.Location.Start+67*3, edit.Sequence, edit.Start)
+	}
+	mutated, err := Apply(puc19, edit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutatedBla, _ := findFeature(mutated, "AmpR")
+	translation := mutatedBla.Attributes["translation"]
+	if translation[67] != 'A' || translation[:67] != bla.Attributes["translation"][:67] || translation[68:] != bla.Attributes["translation"][68:] {
+		t.Errorf("expected a translation with S68A, got %s", translation)
+	}
+
+	// codons of features on the bottom strand are read backwards
+	complementPlasmid := puc19
+	complementPlasmid.Sequence = transform.ReverseComplement(puc19.Sequence)
+	complementBla := bla
+	complementBla.Location = genbank.Location{Start: len(puc19.Sequence) - bla.Location.End, End: len(puc19.Sequence) - bla.Location.Start, Complement: true}
+	complementEdit, err := AminoAcidSubstitution(complementPlasmid, complementBla, "S68A", table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if complementEdit.Sequence != "GGC" || complementEdit.End != len(puc19.Sequence)-edit.Start {
+		t.Errorf("expected GGC ending at %d, got %s ending at %d", len(puc19.Sequence)-edit.Start, complementEdit.Sequence, complementEdit.End)
+	}
+
+	for _, mutation := range []string{"A68S", "S1000A", "S68", "S68B"} {
+		if _, err := AminoAcidSubstitution(puc19, bla, mutation, table); err == nil {
+			t.Errorf("expected an error for %s", mutation)
+		}
+	}
+
+	// a GTG start codon is read as methionine, unless the CDS is 5' partial
+	gtgPlasmid := puc19
+	gtgPlasmid.Sequence = puc19.Sequence[:bla.Location.Start] + "GTG" + puc19.Sequence[bla.Location.Start+3:]
+	if edit, err := AminoAcidSubstitution(gtgPlasmid, bla, "M1L", table); err != nil || edit.Start != bla.Location.Start {
+		t.Errorf("expected M1L to change the GTG start codon, got %+v, %v", edit, err)
+	}
+	partialBla := bla
+	partialBla.Location.FivePrimePartial = true
+	if _, err := AminoAcidSubstitution(gtgPlasmid, partialBla, "M1L", table); err == nil {
+		t.Errorf("expected GTG to be read as valine in a 5' partial CDS")
+	}
+}
+
+func TestApply(t *testing.T) {
+	puc19 := readPuc19(t)
+	bla, _ := findFeature(puc19, "AmpR")
+	lacZ, _ := findFeature(puc19, "lacZ-alpha")
+
+	// an insertion in frame within bla moves the features after it
+	inserted, err := Apply(puc19, Insertion(bla.Location.Start+30, "GGATCC"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inserted.Sequence) != len(puc19.Sequence)+6 || inserted.Meta.Locus.SequenceLength != "2692" {
+		t.Errorf("expected 2692 bases, got %d", len(inserted.Sequence))
+	}
+	insertedBla, _ := findFeature(inserted, "AmpR")
+	if insertedBla.Location.Start != bla.Location.Start || insertedBla.Location.End != bla.Location.End+6 {
+		t.Errorf("expected bla to grow by 6 bases, got %d..%d", insertedBla.Location.Start, insertedBla.Location.End)
+	}
+	if !strings.Contains(insertedBla.Attributes["translation"], "VALGSIPF") {
+		t.Errorf("expected GS in the translation of bla, got %s", insertedBla.Attributes["translation"])
+	}
+	insertedLacZ, _ := findFeature(inserted, "lacZ-alpha")
+	if insertedLacZ.Location.Start != lacZ.Location.Start || insertedLacZ.Location.End != lacZ.Location.End {
+		t.Errorf("expected lacZ-alpha before the insertion not to move")
+	}
+	ampR, _ := findFeature(inserted, "Amp-R")
+	if ampR.Location.GbkLocationString != "complement(1508..1527)" {
+		t.Errorf("expected the location string of a moved feature to be rebuilt, got %s", ampR.Location.GbkLocationString)
+	}
+
+	// a frameshift drops the translation
+	frameshifted, err := Apply(puc19, Deletion(bla.Location.Start+30, bla.Location.Start+31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	frameshiftedBla, _ := findFeature(frameshifted, "AmpR")
+	if _, ok := frameshiftedBla.Attributes["translation"]; ok {
+		t.Errorf("expected the translation of a frameshifted CDS to be dropped")
+	}
+	if _, ok := bla.Attributes["translation"]; !ok {
+		t.Errorf("expected the translation of the original plasmid to be kept")
+	}
+
+	// alternative start codons are translated as methionine, and unknown
+	// genetic codes drop the translation
+	withBla := func(sequence, table string) genbank.Genbank {
+		plasmid := puc19
+		plasmid.Sequence = sequence
+		plasmid.Features = append([]genbank.Feature{}, puc19.Features...)
+		for index, feature := range plasmid.Features {
+			if feature.Attributes["label"] == "AmpR" {
+				plasmid.Features[index].Attributes = map[string]string{"label": "AmpR", "transl_table": table, "translation": "M"}
+			}
+		}
+		return plasmid
+	}
+	gtgSequence := puc19.Sequence[:bla.Location.Start] + "GTG" + puc19.Sequence[bla.Location.Start+3:]
+	for _, table := range []string{"11", "99", "0"} {
+		mutated, err := Apply(withBla(gtgSequence, table), Substitution(bla.Location.Start+201, "GCC"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		mutatedBla, _ := findFeature(mutated, "AmpR")
+		translation, ok := mutatedBla.Attributes["translation"]
+		if table == "11" && !strings.HasPrefix(translation, "MS") {
+			t.Errorf("expected a CDS starting with GTG to be translated from methionine, got %s", translation)
+		}
+		if table != "11" && ok {
+			t.Errorf("expected the translation of a CDS with genetic code %s to be dropped", table)
+		}
+	}
+
+	// deleting all of a feature drops it
+	deleted, err := Apply(puc19, Deletion(lacZ.Location.Start, lacZ.Location.End))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := findFeature(deleted, "lacZ-alpha"); ok {
+		t.Errorf("expected a deleted feature to be dropped")
+	}
+	if len(deleted.Features) >= len(puc19.Features) {
+		t.Errorf("expected features within lacZ-alpha to be dropped")
+	}
+
+	for _, edit := range []Edit{Deletion(10, 5), Insertion(len(puc19.Sequence)+1, "A"), Insertion(10, "ANA"), Substitution(0, puc19.Sequence[:3])} {
+		if _, err := Apply(puc19, edit); err == nil {
+			t.Errorf("expected an error for %v", edit)
+		}
+	}
+}
+
+func TestDesignQuikChange(t *testing.T) {
+	puc19 := readPuc19(t)
+	bla, _ := findFeature(puc19, "AmpR")
+	edits := map[string]Edit{
+		"substitution": Substitution(bla.Location.Start+201, "GCC"),
+		"insertion":    Insertion(bla.Location.Start+30, "GGATCC"),
+		"deletion":     Deletion(bla.Location.Start+30, bla.Location.Start+36),
+		// sites spanning the origin of circular plasmids
+		"origin": Substitution(2, "T"),
+	}
+	for name, edit := range edits {
+		design, err := DesignQuikChange(puc19, edit)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		forward := design.Forward.Sequence
+		if len(forward) < minQuikChangeLength || len(forward) > maxQuikChangeLength || (design.Forward.MeltingTemp < minQuikChangeTm && len(forward) < maxQuikChangeLength) {
+			t.Errorf("%s: %s of %d bases and %.1f°C isn't a QuikChange primer", name, forward, len(forward), design.Forward.MeltingTemp)
+		}
+		if design.Reverse.Sequence != transform.ReverseComplement(forward) {
+			t.Errorf("%s: expected complementary primers", name)
+		}
+		mutated := strings.ToUpper(design.Plasmid.Sequence)
+		if !strings.Contains(mutated+mutated, forward) || strings.Contains(strings.ToUpper(puc19.Sequence+puc19.Sequence), forward) {
+			t.Errorf("%s: expected %s to bind the mutated plasmid only", name, forward)
+		}
+	}
+
+	linear := puc19
+	linear.Meta.Locus.Circular = false
+	if _, err := DesignQuikChange(linear, Substitution(2, "T")); err == nil {
+		t.Errorf("expected an error for an edit at the end of a linear plasmid")
+	}
+}
+
+func TestDesignBackToBack(t *testing.T) {
+	puc19 := readPuc19(t)
+	bla, _ := findFeature(puc19, "AmpR")
+	edits := map[string]Edit{
+		"substitution":   Substitution(bla.Location.Start+201, "GCC"),
+		"insertion":      Insertion(bla.Location.Start+30, "GGATCC"),
+		"long insertion": Insertion(bla.Location.Start+30, "CATCACCATCACCATCAC"),
+		"deletion":       Deletion(bla.Location.Start+30, bla.Location.Start+36),
+	}
+	for name, edit := range edits {
+		design, err := DesignBackToBack(puc19, edit, 60)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+		if design.Forward.MeltingTemp < 60 || design.Reverse.MeltingTemp < 60 {
+			t.Errorf("%s: expected primers annealing at 60°C, got %.1f and %.1f", name, design.Forward.MeltingTemp, design.Reverse.MeltingTemp)
+		}
+		// the 5' ends of the primers meet, so the product ligates into the
+		// mutated plasmid
+		mutated := strings.ToUpper(design.Plasmid.Sequence)
+		product := transform.ReverseComplement(design.Reverse.Sequence) + design.Forward.Sequence
+		if !strings.Contains(mutated+mutated, product) || strings.Contains(strings.ToUpper(puc19.Sequence+puc19.Sequence), product) {
+			t.Errorf("%s: expected primers meeting on the mutated plasmid only", name)
+		}
+	}
+
+	design, _ := DesignBackToBack(puc19, edits["long insertion"], 60)
+	if !strings.HasPrefix(design.Forward.Sequence, "CACCATCAC") || !strings.HasPrefix(design.Reverse.Sequence, "ATGGTGATG") {
+		t.Errorf("expected a long insertion to be split between the primers, got %s and %s", design.Forward.Sequence, design.Reverse.Sequence)
+	}
+}
@@ -0,0 +1,316 @@
+package pcr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bebop/poly/primers"
+	"github.com/bebop/poly/transform"
+	"github.com/bebop/poly/transform/variants"
+)
+
+/******************************************************************************
+
+Mismatch tolerant PCR simulation begins here.
+
+SimulateSimple only finds binding sites exactly matching the 3' end of
+primers. Primers with a mismatch, or with degenerate bases, still amplify
+templates though, as long as the mismatches are far enough from their 3' end
+for the polymerase to extend them. SimulateWithMismatches finds binding sites
+with mismatches, penalizing the ones closest to the 3' end most, and returns
+the products along with where and how their primers bound.
+
+******************************************************************************/
+
+// maxPrimerVariants is the largest number of sequences a degenerate primer
+// may expand into.
+const maxPrimerVariants = 4096
+
+// Template is a named sequence primers bind to.
+type Template struct {
+	Name     string
+	Sequence string
+	Circular bool
+}
+
+// BindingOptions are the mismatches primers bind with.
+type BindingOptions struct {
+	// TargetTm is the melting temperature the 3' end of a primer needs to
+	// bind a template. Primers anneal over their shortest 3' end reaching it,
+	// of at least 15 bases, like in SimulateSimple.
+	TargetTm float64
+	// MaxMismatches is the largest number of mismatches primers bind with.
+	MaxMismatches int
+	// ThreePrimeLength is the number of bases at the 3' end of primers
+	// where mismatches are penalized more. A mismatch d bases from the 3' end
+	// is penalized ThreePrimeLength - d, or 1 past the 3' end.
+	ThreePrimeLength int
+	// MaxPenalty is the largest sum of mismatch penalties primers bind with.
+	MaxPenalty int
+}
+
+// DefaultBindingOptions returns options binding primers with up to two
+// mismatches, none of them in the last two bases of their 3' end.
+func DefaultBindingOptions(targetTm float64) BindingOptions {
+	return BindingOptions{TargetTm: targetTm, MaxMismatches: 2, ThreePrimeLength: 5, MaxPenalty: 3}
+}
+
+// BindingSite is a site a primer binds on a template.
+type BindingSite struct {
+	Template string
+	// Primer is the index of the primer in the primers simulated, and
+	// Sequence the sequence it bound with, one of its variants if it is
+	// degenerate.
+	Primer   int
+	Sequence string
+	// Reverse is true for primers binding the top strand of the template,
+	// extending towards its start.
+	Reverse bool
+	// Start and End are the positions of the annealed bases on the top
+	// strand of the template, End being exclusive. Sites spanning the origin
+	// of a circular template end past its length.
+	Start, End int
+	// Mismatches are the positions of the mismatched bases in the primer.
+	Mismatches []int
+	Penalty    int
+	// MeltingTemp is the melting temperature of the longest run of annealed
+	// bases without mismatches, an estimate of the melting temperature of
+	// the site.
+	MeltingTemp float64
+}
+
+// Product is a product of a PCR.
+type Product struct {
+	Template string
+	Sequence string
+	// Forward and Reverse are the binding sites of the primers on the bottom
+	// and top strands of the template.
+	Forward, Reverse BindingSite
+	// Start and End are the positions the product spans on the template, End
+	// being exclusive and past its length if it spans the origin of a
+	// circular template. Tails of the primers make the product longer.
+	Start, End int
+	Length     int
+}
+
+// SimulateWithMismatches simulates a PCR like SimulateSimple, except primers
+// bind templates with mismatches and may be degenerate, expanding into every
+// sequence they stand for. Every pair of a forward and a reverse binding site
+// makes a product, carrying the sequences of the primers that bound.
+func SimulateWithMismatches(templates []Template, primerList []string, options BindingOptions) ([]Product, error) {
+	var products []Product
+	for _, template := range templates {
+		sites, err := FindBindingSites(template, primerList, options)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, siteProducts(template, sites, math.MaxInt)...)
+	}
+	return products, nil
+}
+
+// siteProducts returns the products of every pair of a forward and a reverse
+// binding site on a template, up to a maximum length.
+func siteProducts(template Template, sites []BindingSite, maxLength int) []Product {
+	sequence := strings.ToUpper(template.Sequence)
+	if template.Circular {
+		sequence += sequence
+	}
+	var products []Product
+	for _, forward := range sites {
+		if forward.Reverse {
+			continue
+		}
+		for _, reverse := range sites {
+			if !reverse.Reverse {
+				continue
+			}
+			if template.Circular && reverse.Start < forward.End {
+				reverse.Start += len(template.Sequence)
+				reverse.End += len(template.Sequence)
+			}
+			if reverse.Start < forward.End || reverse.End > len(sequence) {
+				continue
+			}
+			// products are only built once they're known to be short enough,
+			// as they span whole genomes for sites far apart
+			if len(forward.Sequence)+reverse.Start-forward.End+len(reverse.Sequence) > maxLength {
+				continue
+			}
+			product := Product{
+				Template: template.Name,
+				Sequence: forward.Sequence + sequence[forward.End:reverse.Start] + transform.ReverseComplement(reverse.Sequence),
+				Forward:  forward,
+				Reverse:  reverse,
+				Start:    forward.Start,
+				End:      reverse.End,
+			}
+			product.Length = len(product.Sequence)
+			products = append(products, product)
+		}
+	}
+	return products
+}
+
+// FindBindingSites returns the sites primers bind on a template with the
+// mismatches allowed by the options, sorted by position. Degenerate primers
+// are expanded, and only the sequence binding each site with the lowest
+// penalty is kept.
+func FindBindingSites(template Template, primerList []string, options BindingOptions) ([]BindingSite, error) {
+	sequence := strings.ToUpper(template.Sequence)
+	var sites []BindingSite
+	for primerIndex, primer := range primerList {
+		primerVariants, err := variants.AllVariantsIUPAC(primer)
+		if err != nil {
+			return nil, fmt.Errorf("primer %d: %w", primerIndex, err)
+		}
+		if len(primerVariants) > maxPrimerVariants {
+			return nil, fmt.Errorf("primer %d expands into %d sequences, more than %d", primerIndex, len(primerVariants), maxPrimerVariants)
+		}
+
+		bestSites := make(map[[2]int]BindingSite)
+		for _, variant := range primerVariants {
+			for _, site := range variantBindingSites(sequence, template.Circular, variant, options) {
+				site.Template, site.Primer = template.Name, primerIndex
+				// variants binding the same site put their 3' end on the
+				// same base, and may anneal over different lengths
+				key := [2]int{site.End, 0}
+				if site.Reverse {
+					key = [2]int{site.Start, 1}
+				}
+				if best, ok := bestSites[key]; !ok || site.Penalty < best.Penalty {
+					bestSites[key] = site
+				}
+			}
+		}
+		for _, site := range bestSites {
+			sites = append(sites, site)
+		}
+	}
+	sort.Slice(sites, func(i, j int) bool {
+		if sites[i].Start != sites[j].Start {
+			return sites[i].Start < sites[j].Start
+		}
+		if sites[i].Reverse != sites[j].Reverse {
+			return !sites[i].Reverse
+		}
+		return sites[i].Primer < sites[j].Primer
+	})
+	return sites, nil
+}
+
+// annealingLength returns the length of the shortest 3' end of a primer
+// reaching a melting temperature, of at least MinimalPrimerLength bases.
+func annealingLength(primer string, targetTm float64) int {
+	length := min(MinimalPrimerLength, len(primer))
+	for length < len(primer) && primers.MeltingTemp(primer[len(primer)-length:]) < targetTm {
+		length++
+	}
+	return length
+}
+
+// variantBindingSites returns the sites a primer without degenerate bases
+// binds on a sequence.
+func variantBindingSites(sequence string, circular bool, primer string, options BindingOptions) []BindingSite {
+	primer = strings.ToUpper(primer)
+	if len(sequence) == 0 || len(primer) == 0 {
+		return nil
+	}
+	length := annealingLength(primer, options.TargetTm)
+	if len(sequence) < length {
+		// the primer can't anneal to more bases than there are, even around
+		// a circular sequence
+		return nil
+	}
+	threePrimeEnd := primer[len(primer)-length:]
+	reversePrimer := transform.ReverseComplement(primer)
+	reverseThreePrimeEnd := reversePrimer[:length]
+
+	// sites of circular sequences may span their origin
+	searched := sequence
+	positions := len(sequence) - length + 1
+	if circular {
+		searched += sequence[:min(len(primer), len(sequence))-1]
+		positions = len(sequence)
+	}
+
+	var sites []BindingSite
+	for position := 0; position < positions; position++ {
+		window := searched[position : position+length]
+		if site, ok := bindWindow(window, threePrimeEnd, false, options); ok {
+			// the annealed bases extend towards the 5' end of the primer as
+			// long as they match, primer base k lying on base offset+k
+			offset := position + length - len(primer)
+			for site.Start = position; site.Start > 0 && site.Start-1 >= offset && searched[site.Start-1] == primer[site.Start-1-offset]; site.Start-- {
+			}
+			site.End = position + length
+			site.Sequence = primer
+			site.Mismatches = shiftMismatches(site.Mismatches, len(primer)-length)
+			site.MeltingTemp = siteMeltingTemp(primer, site)
+			sites = append(sites, site)
+		}
+		if site, ok := bindWindow(window, reverseThreePrimeEnd, true, options); ok {
+			site.Start = position
+			for site.End = position + length; site.End < len(searched) && site.End-position < len(primer) && searched[site.End] == reversePrimer[site.End-position]; site.End++ {
+			}
+			site.Sequence = primer
+			site.Mismatches = shiftMismatches(site.Mismatches, len(primer)-length)
+			site.MeltingTemp = siteMeltingTemp(primer, site)
+			sites = append(sites, site)
+		}
+	}
+	return sites
+}
+
+// bindWindow returns the mismatches of the 3' end of a primer, written on the
+// top strand, against a window of a template, and whether they are allowed.
+// Mismatches are positions in the 3' end of the primer.
+func bindWindow(window, threePrimeEnd string, reverse bool, options BindingOptions) (BindingSite, bool) {
+	site := BindingSite{Reverse: reverse}
+	for index := range window {
+		if window[index] == threePrimeEnd[index] {
+			continue
+		}
+		// the 3' end is written on the top strand, so it reads backwards for
+		// reverse primers
+		primerIndex, distance := index, len(window)-1-index
+		if reverse {
+			primerIndex, distance = len(window)-1-index, index
+		}
+		site.Mismatches = append(site.Mismatches, primerIndex)
+		site.Penalty += max(1, options.ThreePrimeLength-distance)
+		if len(site.Mismatches) > options.MaxMismatches || site.Penalty > options.MaxPenalty {
+			return BindingSite{}, false
+		}
+	}
+	sort.Ints(site.Mismatches)
+	return site, true
+}
+
+// siteMeltingTemp returns the melting temperature of the longest run of
+// annealed bases of a primer between the mismatches of a site.
+func siteMeltingTemp(primer string, site BindingSite) float64 {
+	runStart := len(primer) - (site.End - site.Start)
+	longestRun := ""
+	for _, runEnd := range append(site.Mismatches, len(primer)) {
+		if runEnd-runStart > len(longestRun) {
+			longestRun = primer[runStart:runEnd]
+		}
+		runStart = runEnd + 1
+	}
+	if len(longestRun) < 2 {
+		return 0
+	}
+	return primers.MeltingTemp(longestRun)
+}
+
+// shiftMismatches returns positions of mismatches in the 3' end of a primer
+// as positions in the whole primer.
+func shiftMismatches(mismatches []int, offset int) []int {
+	for index := range mismatches {
+		mismatches[index] += offset
+	}
+	return mismatches
+}
@@ -0,0 +1,316 @@
+package pcr
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bebop/poly/checks"
+	"github.com/bebop/poly/fold"
+	"github.com/bebop/poly/primers"
+	"github.com/bebop/poly/primers/internal/bases"
+	"github.com/bebop/poly/transform"
+)
+
+/******************************************************************************
+
+Primer design begins here.
+
+DesignPrimers grows primers from the ends of a sequence until they reach a
+melting temperature, which works for amplifying whole genes but doesn't look
+at anything else about the primers. DesignPrimerPairs searches every primer
+around a target region instead, rejects the ones that break any of a set of
+constraints like Primer3 does, and ranks the pairs left by how far they are
+from the optimal primer.
+
+Primer3: https://doi.org/10.1093/nar/gks596
+
+******************************************************************************/
+
+// DesignConstraints are the constraints primers designed by DesignPrimerPairs
+// must meet. Free energies are in kcal/mol at 37°C, and melting temperatures
+// are calculated by primers.MeltingTemp.
+type DesignConstraints struct {
+	MinLength, OptimalLength, MaxLength int
+	MinTm, OptimalTm, MaxTm             float64
+	// MaxTmDifference is the largest difference between the melting
+	// temperatures of the primers of a pair.
+	MaxTmDifference float64
+	// MinGcContent and MaxGcContent are fractions of G and C bases.
+	MinGcContent, MaxGcContent float64
+	// GcClamp is the number of G or C bases primers must end with.
+	GcClamp int
+	// MaxPolyX is the longest run of a single base primers may have.
+	MaxPolyX int
+	// MaxEndStability is the most stable the five bases of the 3' end of
+	// primers may bind, as the opposite of their free energy.
+	MaxEndStability float64
+	// MinHairpinDeltaG is the most stable hairpin primers may fold into.
+	MinHairpinDeltaG float64
+	// MinDimerDeltaG is the most stable duplex primers may make with
+	// themselves or the other primer of their pair, and MinEndDimerDeltaG the
+	// most stable one leaving a 3' end paired and ready to be extended.
+	MinDimerDeltaG, MinEndDimerDeltaG float64
+	// MinProductSize and MaxProductSize bound the length of the product,
+	// primers included.
+	MinProductSize, MaxProductSize int
+	// MaxPairs is the number of pairs returned.
+	MaxPairs int
+}
+
+// DefaultDesignConstraints returns constraints close to the defaults of
+// Primer3.
+func DefaultDesignConstraints() DesignConstraints {
+	return DesignConstraints{
+		MinLength:         18,
+		OptimalLength:     20,
+		MaxLength:         27,
+		MinTm:             57,
+		OptimalTm:         60,
+		MaxTm:             63,
+		MaxTmDifference:   5,
+		MinGcContent:      0.2,
+		MaxGcContent:      0.8,
+		GcClamp:           1,
+		MaxPolyX:          4,
+		MaxEndStability:   9,
+		MinHairpinDeltaG:  -3,
+		MinDimerDeltaG:    -9,
+		MinEndDimerDeltaG: -5,
+		MinProductSize:    100,
+		MaxProductSize:    1000,
+		MaxPairs:          5,
+	}
+}
+
+// Primer is a primer designed on a template.
+type Primer struct {
+	Sequence string
+	// Start and End are the positions of the primer on the top strand of the
+	// template, End being exclusive, whichever strand it binds.
+	Start, End int
+	// Reverse is true for primers binding the top strand, which are the
+	// reverse complement of the template.
+	Reverse       bool
+	MeltingTemp   float64
+	GcContent     float64
+	EndStability  float64
+	HairpinDeltaG float64
+	// SelfDimerDeltaG and SelfEndDimerDeltaG are the free energies of the
+	// most stable duplex of the primer with itself, and of the most stable one
+	// pairing its 3' end.
+	SelfDimerDeltaG, SelfEndDimerDeltaG float64
+	// Penalty is the sum of the differences of the melting temperature and
+	// length of the primer from their optimal values.
+	Penalty float64
+}
+
+// PrimerPair is a pair of primers amplifying a target.
+type PrimerPair struct {
+	Forward, Reverse Primer
+	ProductSize      int
+	// CrossDimerDeltaG and CrossEndDimerDeltaG are the free energies of the
+	// most stable duplex of the primers with each other, and of the most
+	// stable one pairing a 3' end.
+	CrossDimerDeltaG, CrossEndDimerDeltaG float64
+	// Penalty is the sum of the penalties of the primers and of the
+	// difference between their melting temperatures.
+	Penalty float64
+}
+
+// DesignPrimerPairs designs pairs of primers amplifying the target region
+// [targetStart, targetEnd) of a template, forward primers binding before it
+// and reverse primers after it. All the primers meeting the constraints are
+// paired, and the pairs meeting the constraints are returned from the lowest
+// to the highest penalty, up to the maximum number of pairs asked for.
+func DesignPrimerPairs(template string, targetStart, targetEnd int, constraints DesignConstraints) ([]PrimerPair, error) {
+	template = strings.ToUpper(template)
+	if err := constraints.validate(template, "target", targetStart, targetEnd); err != nil {
+		return nil, err
+	}
+	if constraints.MaxPairs <= 0 {
+		return nil, fmt.Errorf("maximum number of pairs %d isn't positive", constraints.MaxPairs)
+	}
+
+	// forward primers end before the target and reverse primers start after
+	// it, within the largest product
+	forwardPrimers := enumeratePrimers(template, max(0, targetEnd-constraints.MaxProductSize), targetStart, false, constraints)
+	reversePrimers := enumeratePrimers(template, targetEnd, min(len(template), targetStart+constraints.MaxProductSize), true, constraints)
+
+	var designedPairs []PrimerPair
+	rankPairs(forwardPrimers, reversePrimers, constraints, func(pair PrimerPair) bool {
+		designedPairs = append(designedPairs, pair)
+		return len(designedPairs) < constraints.MaxPairs
+	})
+	if len(designedPairs) == 0 {
+		return nil, fmt.Errorf("no primer pairs meet the constraints, out of %d forward and %d reverse primers", len(forwardPrimers), len(reversePrimers))
+	}
+	return designedPairs, nil
+}
+
+// validate returns an error if [start, end) isn't a region of a template, or
+// if the primer lengths of the constraints are invalid.
+func (constraints DesignConstraints) validate(template, region string, start, end int) error {
+	if start < 0 || end > len(template) || start >= end {
+		return fmt.Errorf("%s [%d, %d) is not within the template of %d bases", region, start, end, len(template))
+	}
+	if constraints.MinLength <= 0 || constraints.MinLength > constraints.MaxLength {
+		return errors.New("primer lengths must be positive, with the minimum length at most the maximum length")
+	}
+	return nil
+}
+
+// enumeratePrimers returns the primers meeting the constraints that bind
+// within [start, end) of a template, forward primers binding its bottom
+// strand and reverse primers its top strand.
+func enumeratePrimers(template string, start, end int, reverse bool, constraints DesignConstraints) []Primer {
+	var designed []Primer
+	for primerStart := start; primerStart < end; primerStart++ {
+		for length := constraints.MinLength; length <= constraints.MaxLength && primerStart+length <= end; length++ {
+			if primer, ok := newPrimer(template, primerStart, primerStart+length, reverse, constraints); ok {
+				designed = append(designed, primer)
+			}
+		}
+	}
+	return designed
+}
+
+// rankPairs pairs forward and reverse primers meeting the constraints, and
+// passes the pairs to accept from the lowest to the highest penalty until it
+// returns false.
+func rankPairs(forwardPrimers, reversePrimers []Primer, constraints DesignConstraints, accept func(PrimerPair) bool) {
+	// pairs are ranked by index, as there are a lot of them
+	type candidatePair struct {
+		forward, reverse int
+		penalty          float64
+	}
+	var candidates []candidatePair
+	for forwardIndex, forward := range forwardPrimers {
+		for reverseIndex, reverse := range reversePrimers {
+			productSize := reverse.End - forward.Start
+			tmDifference := math.Abs(forward.MeltingTemp - reverse.MeltingTemp)
+			if productSize < constraints.MinProductSize || productSize > constraints.MaxProductSize || tmDifference > constraints.MaxTmDifference {
+				continue
+			}
+			candidates = append(candidates, candidatePair{forwardIndex, reverseIndex, forward.Penalty + reverse.Penalty + tmDifference})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].penalty < candidates[j].penalty
+	})
+
+	// cross dimers are only checked on the best pairs, as it is the slowest
+	// check
+	for _, candidate := range candidates {
+		pair := PrimerPair{Forward: forwardPrimers[candidate.forward], Reverse: reversePrimers[candidate.reverse], Penalty: candidate.penalty}
+		pair.ProductSize = pair.Reverse.End - pair.Forward.Start
+		pair.CrossDimerDeltaG, pair.CrossEndDimerDeltaG = dimerDeltaG(pair.Forward.Sequence, pair.Reverse.Sequence)
+		if pair.CrossDimerDeltaG < constraints.MinDimerDeltaG || pair.CrossEndDimerDeltaG < constraints.MinEndDimerDeltaG {
+			continue
+		}
+		if !accept(pair) {
+			return
+		}
+	}
+}
+
+// newPrimer returns the primer binding [start, end) of a template, and
+// whether it meets the constraints. The cheapest checks are done first.
+func newPrimer(template string, start, end int, reverse bool, constraints DesignConstraints) (Primer, bool) {
+	sequence := template[start:end]
+	if reverse {
+		sequence = transform.ReverseComplement(sequence)
+	}
+	primer := Primer{Sequence: sequence, Start: start, End: end, Reverse: reverse}
+	if strings.Trim(sequence, "ACGT") != "" || bases.LongestRun(sequence) > constraints.MaxPolyX {
+		return Primer{}, false
+	}
+	if constraints.GcClamp > 0 && strings.TrimRight(sequence[len(sequence)-constraints.GcClamp:], "GC") != "" {
+		return Primer{}, false
+	}
+	primer.GcContent = checks.GcContent(sequence)
+	if primer.GcContent < constraints.MinGcContent || primer.GcContent > constraints.MaxGcContent {
+		return Primer{}, false
+	}
+	primer.MeltingTemp = primers.MeltingTemp(sequence)
+	if primer.MeltingTemp < constraints.MinTm || primer.MeltingTemp > constraints.MaxTm {
+		return Primer{}, false
+	}
+	primer.EndStability = -duplexDeltaG(sequence[max(0, len(sequence)-5):])
+	if primer.EndStability > constraints.MaxEndStability {
+		return Primer{}, false
+	}
+	primer.SelfDimerDeltaG, primer.SelfEndDimerDeltaG = dimerDeltaG(sequence, sequence)
+	if primer.SelfDimerDeltaG < constraints.MinDimerDeltaG || primer.SelfEndDimerDeltaG < constraints.MinEndDimerDeltaG {
+		return Primer{}, false
+	}
+	primer.HairpinDeltaG = hairpinDeltaG(sequence)
+	if primer.HairpinDeltaG < constraints.MinHairpinDeltaG {
+		return Primer{}, false
+	}
+	primer.Penalty = math.Abs(primer.MeltingTemp-constraints.OptimalTm) + math.Abs(float64(len(sequence)-constraints.OptimalLength))
+	return primer, true
+}
+
+// duplexDeltaG returns the free energy at 37°C of a sequence paired to its
+// reverse complement, at the concentrations of primers.MeltingTemp.
+func duplexDeltaG(sequence string) float64 {
+	_, dH, dS := primers.SantaLucia(sequence, 500e-9, 50e-3, 0)
+	return dH - (37+273.15)*dS/1000
+}
+
+// hairpinDeltaG returns the free energy of the most stable structure a
+// primer folds into at 37°C, or zero if it doesn't fold.
+func hairpinDeltaG(sequence string) float64 {
+	return foldDeltaG(sequence, 37)
+}
+
+// foldDeltaG returns the free energy of the most stable structure a sequence
+// folds into at a temperature, or zero if it doesn't fold.
+func foldDeltaG(sequence string, temperature float64) float64 {
+	result, err := fold.Zuker(sequence, temperature)
+	if err != nil {
+		return 0
+	}
+	deltaG := result.MinimumFreeEnergy()
+	if math.IsInf(deltaG, 0) || math.IsNaN(deltaG) || deltaG > 0 {
+		return 0
+	}
+	return deltaG
+}
+
+// dimerDeltaG returns the free energy of the most stable duplex of two
+// sequences, and of the most stable one pairing the 3' end of either of them,
+// or zero if they don't pair. Sequences are aligned antiparallel without
+// gaps, and the free energy of an alignment is that of its most stable run of
+// paired bases.
+func dimerDeltaG(sequence, otherSequence string) (deltaG, endDeltaG float64) {
+	complement := transform.ReverseComplement(otherSequence)
+	// base i of sequence pairs with base j of otherSequence when it is equal
+	// to base len(otherSequence)-1-j of its reverse complement
+	for offset := -len(otherSequence) + 1; offset < len(sequence); offset++ {
+		runStart := -1
+		for index := max(0, offset); index <= min(len(sequence), offset+len(complement)); index++ {
+			paired := index < len(sequence) && index-offset < len(complement) && sequence[index] == complement[index-offset]
+			if paired && runStart < 0 {
+				runStart = index
+			}
+			if paired || runStart < 0 {
+				continue
+			}
+			if index-runStart >= 2 {
+				runDeltaG := duplexDeltaG(sequence[runStart:index])
+				deltaG = min(deltaG, runDeltaG)
+				// the run ends at the 3' end of sequence, or starts at the 3'
+				// end of otherSequence
+				if index == len(sequence) || runStart-offset == 0 {
+					endDeltaG = min(endDeltaG, runDeltaG)
+				}
+			}
+			runStart = -1
+		}
+	}
+	return deltaG, endDeltaG
+}
@@ -3,6 +3,7 @@ package pcr_test
 import (
 	"fmt"
 
+	"github.com/bebop/poly/io/genbank"
 	"github.com/bebop/poly/primers/pcr"
 )
 
@@ -62,3 +63,93 @@ func ExampleSimulate() {
 	fmt.Println(fragments)
 	// Output: [TTATAGGTCTCATACTAATAATTACACCGAGATAACACATCATGGATAAACCGATACTCAAAGATTCTATGAAGCTATTTGAGGCACTTGGTACGATCAAGTCGCGCTCAATGTTTGGTGGCTTCGGACTTTTCGCTGATGAAACGATGTTTGCACTGGTTGTGAATGATCAACTTCACATACGAGCAGACCAGCAAACTTCATCTAACTTCGAGAAGCAAGGGCTAAAACCGTACGTTTATAAAAAGCGTGGTTTTCCAGTCGTTACTAAGTACTACGCGATTTCCGACGACTTGTGGGAATCCAGTGAACGCTTGATAGAAGTAGCGAAGAAGTCGTTAGAACAAGCCAATTTGGAAAAAAAGCAACAGGCAAGTAGTAAGCCCGACAGGTTGAAAGACCTGCCTAACTTACGACTAGCGACTGAACGAATGCTTAAGAAAGCTGGTATAAAATCAGTTGAACAACTTGAAGAGAAAGGTGCATTGAATGCTTACAAAGCGATACGTGACTCTCACTCCGCAAAAGTAAGTATTGAGCTACTCTGGGCTTTAGAAGGAGCGATAAACGGCACGCACTGGAGCGTCGTTCCTCAATCTCGCAGAGAAGAGCTGGAAAATGCGCTTTCTTAAATGAAGAGACCATATA]
 }
+
+func ExampleDesignPrimerPairs() {
+	gene := "aataattacaccgagataacacatcatggataaaccgatactcaaagattctatgaagctatttgaggcacttggtacgatcaagtcgcgctcaatgtttggtggcttcggacttttcgctgatgaaacgatgtttgcactggttgtgaatgatcaacttcacatacgagcagaccagcaaacttcatctaacttcgagaagcaagggctaaaaccgtacgtttataaaaagcgtggttttccagtcgttactaagtactacgcgatttccgacgacttgtgggaatccagtgaacgcttgatagaagtagcgaagaagtcgttagaacaagccaatttggaaaaaaagcaacaggcaagtagtaagcccgacaggttgaaagacctgcctaacttacgactagcgactgaacgaatgcttaagaaagctggtataaaatcagttgaacaacttgaagagaaaggtgcattgaatgcttacaaagcgatacgtgactctcactccgcaaaagtaagtattgagctactctgggctttagaaggagcgataaacggcacgcactggagcgtcgttcctcaatctcgcagagaagagctggaaaatgcgctttcttaa"
+
+	// amplify bases 300 to 500 of the gene in a product of at most 400 bases
+	constraints := pcr.DefaultDesignConstraints()
+	constraints.MaxProductSize = 400
+	constraints.MaxPairs = 1
+	pairs, _ := pcr.DesignPrimerPairs(gene, 300, 500, constraints)
+
+	pair := pairs[0]
+	fmt.Printf("%s %.1f\n%s %.1f\n%d bp\n", pair.Forward.Sequence, pair.Forward.MeltingTemp, pair.Reverse.Sequence, pair.Reverse.MeltingTemp, pair.ProductSize)
+	// Output:
+	// CGCGATTTCCGACGACTTGTG 59.7
+	// CCAGTGCGTGCCGTTTATCG 59.7
+	// 304 bp
+}
+
+func ExampleSimulateWithMismatches() {
+	gene := "aataattacaccgagataacacatcatggataaaccgatactcaaagattctatgaagctatttgaggcacttggtacgatcaagtcgcgctcaatgtttggtggcttcggacttttcgctgatgaaacgatgtttgcactggttgtgaatgatcaacttcacatacgagcagaccagcaaacttcatctaacttcgagaagcaagggctaaaaccgtacgtttataaaaagcgtggttttccagtcgttactaagtactacgcgatttccgacgacttgtgggaatccagtgaacgcttgatagaagtagcgaagaagtcgttagaacaagccaatttggaaaaaaagcaacaggcaagtagtaagcccgacaggttgaaagacctgcctaacttacgactagcgactgaacgaatgcttaagaaagctggtataaaatcagttgaacaacttgaagagaaaggtgcattgaatgcttacaaagcgatacgtgactctcactccgcaaaagtaagtattgagctactctgggctttagaaggagcgataaacggcacgcactggagcgtcgttcctcaatctcgcagagaagagctggaaaatgcgctttcttaa"
+
+	// the forward primer has a mismatch, and the reverse primer a degenerate
+	// base
+	primerList := []string{"AATAATTACACCCAGATAACACATCATGG", "TTAAGAAAGCGCATTTTCCAKC"}
+	products, _ := pcr.SimulateWithMismatches([]pcr.Template{{Name: "gene", Sequence: gene}}, primerList, pcr.DefaultBindingOptions(55.0))
+
+	for _, product := range products {
+		fmt.Println(product.Template, product.Start, product.End, product.Length)
+		fmt.Println(product.Forward.Sequence, product.Forward.Mismatches)
+		fmt.Println(product.Reverse.Sequence, product.Reverse.Mismatches)
+	}
+	// Output:
+	// gene 0 616 616
+	// AATAATTACACCCAGATAACACATCATGG [12]
+	// TTAAGAAAGCGCATTTTCCAGC []
+}
+
+func ExampleCheckSpecificity() {
+	puc19, _ := genbank.Read("../../data/puc19.gbk")
+	background := pcr.TemplatesFromGenbank([]genbank.Genbank{puc19})
+
+	// M13 primers bind either side of the multiple cloning site of pUC19
+	m13 := [2]string{"GTAAAACGACGGCCAGT", "CAGGAAACAGCTATGAC"}
+	options := pcr.DefaultSpecificityOptions(45)
+	options.MaxProductSize = 1000
+	reports, _ := pcr.CheckSpecificity([][2]string{m13}, background, options)
+
+	for _, site := range reports[0].BindingSites {
+		fmt.Printf("primer %d binds %s %d..%d, %.1f°C\n", site.Primer, site.Template, site.Start, site.End, site.MeltingTemp)
+	}
+	for _, amplicon := range reports[0].Amplicons {
+		fmt.Printf("%d bp amplicon of %s\n", amplicon.Length, amplicon.Template)
+	}
+	// Output:
+	// primer 1 binds puc19.gbk 602..619, 47.0°C
+	// primer 0 binds puc19.gbk 688..705, 52.6°C
+	// 103 bp amplicon of puc19.gbk
+}
+
+func ExampleDesignMultiplexPanel() {
+	puc19, _ := genbank.Read("../../data/puc19.gbk")
+	targets := []pcr.MultiplexTarget{
+		{Name: "lacZ", Template: puc19.Sequence, Start: 500, End: 550},
+		{Name: "bla", Template: puc19.Sequence, Start: 2100, End: 2150},
+	}
+	constraints := pcr.DefaultMultiplexConstraints()
+	constraints.MaxProductSize = 600
+	panel, _ := pcr.DesignMultiplexPanel(targets, constraints)
+
+	for index, pair := range panel.Pairs {
+		fmt.Printf("%s: %d bp\n", targets[index].Name, pair.ProductSize)
+	}
+	// Output:
+	// lacZ: 454 bp
+	// bla: 583 bp
+}
+
+func ExampleDesignQpcrAssays() {
+	puc19, _ := genbank.Read("../../data/puc19.gbk")
+
+	// quantify the copy number of pUC19 by its ampicillin resistance gene
+	assays, _ := pcr.DesignQpcrAssays(puc19.Sequence, 1283, 2144, pcr.DefaultQpcrConstraints())
+
+	assay := assays[0]
+	fmt.Printf("%s %.1f°C / %s %.1f°C\n", assay.Pair.Forward.Sequence, assay.Pair.Forward.MeltingTemp, assay.Pair.Reverse.Sequence, assay.Pair.Reverse.MeltingTemp)
+	fmt.Printf("probe %s %.1f°C, %d bp amplicon\n", assay.Probe.Sequence, assay.Probe.MeltingTemp, assay.Pair.ProductSize)
+	// Output:
+	// GGCAACAACGTTGCGCAAAC 59.8°C / GCAATAAACCAGCCAGCCGG 59.6°C
+	// probe AAGTTGCAGGACCACTTCTGCGCTCGGC 68.7°C, 138 bp amplicon
+}
@@ -0,0 +1,197 @@
+package pcr
+
+import (
+	"fmt"
+	"math"
+)
+
+/******************************************************************************
+
+Multiplex panel design begins here.
+
+Multiplex PCR amplifies many targets in a single tube, which only works if all
+the primers anneal at the same temperature, don't dimerize with any other
+primer of the pool, and make amplicons that can be told apart. Pairs designed
+one at a time rarely meet all of these, so DesignMultiplexPanel designs
+candidate pairs for every target and searches for the combination that does.
+
+******************************************************************************/
+
+// maxPanelSearchSteps bounds the search for the best panel, which otherwise
+// grows exponentially with the number of targets.
+const maxPanelSearchSteps = 100000
+
+// MultiplexTarget is a target region [Start, End) of a template to amplify
+// in a multiplex panel.
+type MultiplexTarget struct {
+	Name       string
+	Template   string
+	Start, End int
+}
+
+// MultiplexConstraints are the constraints of a multiplex panel.
+type MultiplexConstraints struct {
+	// DesignConstraints constrain each pair, the product sizes being the
+	// range amplicons must fall in, like the read length of a sequencer, and
+	// the dimer free energies applying to every two primers of the pool.
+	DesignConstraints
+	// CandidatesPerTarget is the number of pairs designed for each target to
+	// pick from.
+	CandidatesPerTarget int
+	// MaxTmSpread is the largest difference between the melting temperatures
+	// of any two primers of the pool.
+	MaxTmSpread float64
+	// MinSizeDifference is the smallest difference between the sizes of any
+	// two amplicons, as a fraction of the larger one, for them to be told
+	// apart on a gel. Zero allows amplicons of any size, like for sequencing.
+	MinSizeDifference float64
+}
+
+// DefaultMultiplexConstraints returns constraints for a panel read on a gel,
+// with amplicons at least 10% apart and primers within 3°C of each other.
+func DefaultMultiplexConstraints() MultiplexConstraints {
+	return MultiplexConstraints{
+		DesignConstraints:   DefaultDesignConstraints(),
+		CandidatesPerTarget: 20,
+		MaxTmSpread:         3,
+		MinSizeDifference:   0.1,
+	}
+}
+
+// MultiplexPanel is a primer pair for each target of a multiplex panel.
+type MultiplexPanel struct {
+	// Pairs are the primer pairs of the targets, in order.
+	Pairs []PrimerPair
+	// DimerDeltaG and EndDimerDeltaG are the free energies of the most stable
+	// duplex of any two primers of the pool, and of the most stable one
+	// pairing a 3' end.
+	DimerDeltaG, EndDimerDeltaG float64
+	// Penalty is the sum of the penalties of the pairs.
+	Penalty float64
+}
+
+// DesignMultiplexPanel designs a primer pair for each target such that all
+// primers share a melting temperature window, amplicons have distinguishable
+// sizes, and no two primers of the pool dimerize, picking the candidate pairs
+// of DesignPrimerPairs with the lowest total penalty.
+func DesignMultiplexPanel(targets []MultiplexTarget, constraints MultiplexConstraints) (MultiplexPanel, error) {
+	pairConstraints := constraints.DesignConstraints
+	pairConstraints.MaxPairs = constraints.CandidatesPerTarget
+	candidates := make([][]PrimerPair, len(targets))
+	for index, target := range targets {
+		pairs, err := DesignPrimerPairs(target.Template, target.Start, target.End, pairConstraints)
+		if err != nil {
+			return MultiplexPanel{}, fmt.Errorf("target %s: %w", target.Name, err)
+		}
+		candidates[index] = pairs
+	}
+
+	search := newPanelSearch(candidates, constraints, maxPanelSearchSteps)
+	if err := search.run(); err != nil {
+		return MultiplexPanel{}, err
+	}
+
+	panel := MultiplexPanel{Pairs: search.best, Penalty: search.bestPenalty}
+	var pool []string
+	for _, pair := range panel.Pairs {
+		pool = append(pool, pair.Forward.Sequence, pair.Reverse.Sequence)
+	}
+	for i := range pool {
+		for j := i; j < len(pool); j++ {
+			deltaG, endDeltaG := search.dimer(pool[i], pool[j])
+			panel.DimerDeltaG = min(panel.DimerDeltaG, deltaG)
+			panel.EndDimerDeltaG = min(panel.EndDimerDeltaG, endDeltaG)
+		}
+	}
+	return panel, nil
+}
+
+// panelSearch is a branch and bound search for the panel with the lowest
+// penalty.
+type panelSearch struct {
+	candidates  [][]PrimerPair
+	constraints MultiplexConstraints
+	// steps is the number of pairs tried, stopping the search when it
+	// reaches maxSteps, in which case truncated is true.
+	steps, maxSteps int
+	truncated       bool
+	best            []PrimerPair
+	bestPenalty     float64
+	dimers          map[[2]string][2]float64
+}
+
+// newPanelSearch returns a search for the panel of candidate pairs with the
+// lowest penalty, trying at most maxSteps pairs.
+func newPanelSearch(candidates [][]PrimerPair, constraints MultiplexConstraints, maxSteps int) *panelSearch {
+	return &panelSearch{candidates: candidates, constraints: constraints, maxSteps: maxSteps, bestPenalty: math.Inf(1), dimers: make(map[[2]string][2]float64)}
+}
+
+// run searches for the best panel, returning an error if none was found.
+func (search *panelSearch) run() error {
+	search.extend(nil, 0)
+	switch {
+	case search.best != nil:
+		return nil
+	case search.truncated:
+		return fmt.Errorf("search stopped after %d steps without a combination of the primer pairs of %d targets meeting the constraints", search.maxSteps, len(search.candidates))
+	}
+	return fmt.Errorf("no combination of the primer pairs of %d targets meets the constraints", len(search.candidates))
+}
+
+// extend picks a pair for the next target of a partial panel, trying
+// candidates from the lowest penalty.
+func (search *panelSearch) extend(panel []PrimerPair, penalty float64) {
+	if len(panel) == len(search.candidates) {
+		search.best = append([]PrimerPair{}, panel...)
+		search.bestPenalty = penalty
+		return
+	}
+	for _, pair := range search.candidates[len(panel)] {
+		search.steps++
+		if search.steps > search.maxSteps {
+			search.truncated = true
+			return
+		}
+		// candidates are sorted by penalty, so the rest can't do better
+		if penalty+pair.Penalty >= search.bestPenalty {
+			return
+		}
+		if search.compatible(panel, pair) {
+			search.extend(append(panel, pair), penalty+pair.Penalty)
+		}
+	}
+}
+
+// compatible returns whether a pair can join a partial panel.
+func (search *panelSearch) compatible(panel []PrimerPair, pair PrimerPair) bool {
+	constraints := search.constraints
+	for _, other := range panel {
+		for _, primer := range []Primer{pair.Forward, pair.Reverse} {
+			for _, otherPrimer := range []Primer{other.Forward, other.Reverse} {
+				if math.Abs(primer.MeltingTemp-otherPrimer.MeltingTemp) > constraints.MaxTmSpread {
+					return false
+				}
+				deltaG, endDeltaG := search.dimer(primer.Sequence, otherPrimer.Sequence)
+				if deltaG < constraints.MinDimerDeltaG || endDeltaG < constraints.MinEndDimerDeltaG {
+					return false
+				}
+			}
+		}
+		larger := float64(max(pair.ProductSize, other.ProductSize))
+		if math.Abs(float64(pair.ProductSize-other.ProductSize)) < constraints.MinSizeDifference*larger {
+			return false
+		}
+	}
+	return math.Abs(pair.Forward.MeltingTemp-pair.Reverse.MeltingTemp) <= constraints.MaxTmSpread
+}
+
+// dimer returns the dimer free energies of two primers, caching them.
+func (search *panelSearch) dimer(primer, otherPrimer string) (float64, float64) {
+	key := [2]string{primer, otherPrimer}
+	if energies, ok := search.dimers[key]; ok {
+		return energies[0], energies[1]
+	}
+	deltaG, endDeltaG := dimerDeltaG(primer, otherPrimer)
+	search.dimers[key] = [2]float64{deltaG, endDeltaG}
+	return deltaG, endDeltaG
+}
@@ -31,8 +31,9 @@ import (
 	"github.com/bebop/poly/transform"
 )
 
+// MinimalPrimerLength is the length of the shortest primer designed.
 // https://doi.org/10.1089/dna.1994.13.75
-var minimalPrimerLength int = 15
+const MinimalPrimerLength = 15
 
 // DesignPrimersWithOverhangs designs two primers to amplify a target sequence,
 // adding on an overhang to the forward and reverse strand. This overhang can
@@ -40,13 +41,13 @@ var minimalPrimerLength int = 15
 // or GoldenGate restriction enzyme sites.
 func DesignPrimersWithOverhangs(sequence, forwardOverhang, reverseOverhang string, targetTm float64) (string, string) {
 	sequence = strings.ToUpper(sequence)
-	forwardPrimer := sequence[0:minimalPrimerLength]
+	forwardPrimer := sequence[0:MinimalPrimerLength]
 	for additionalNucleotides := 0; primers.MeltingTemp(forwardPrimer) < targetTm; additionalNucleotides++ {
-		forwardPrimer = sequence[0 : minimalPrimerLength+additionalNucleotides]
+		forwardPrimer = sequence[0 : MinimalPrimerLength+additionalNucleotides]
 	}
-	reversePrimer := transform.ReverseComplement(sequence[len(sequence)-minimalPrimerLength:])
+	reversePrimer := transform.ReverseComplement(sequence[len(sequence)-MinimalPrimerLength:])
 	for additionalNucleotides := 0; primers.MeltingTemp(reversePrimer) < targetTm; additionalNucleotides++ {
-		reversePrimer = transform.ReverseComplement(sequence[len(sequence)-(minimalPrimerLength+additionalNucleotides):])
+		reversePrimer = transform.ReverseComplement(sequence[len(sequence)-(MinimalPrimerLength+additionalNucleotides):])
 	}
 
 	// Add overhangs to primer
@@ -90,7 +91,7 @@ func SimulateSimple(sequences []string, targetTm float64, circular bool, primerL
 		minimalPrimers := make([]string, primerLength)
 		for primerIndex, primer := range primerList {
 			var minimalLength int
-			for index := minimalPrimerLength; primers.MeltingTemp(primer[len(primer)-index:]) < targetTm; index++ {
+			for index := MinimalPrimerLength; primers.MeltingTemp(primer[len(primer)-index:]) < targetTm; index++ {
 				minimalLength = index
 				if primer[len(primer)-index:] == primer {
 					break
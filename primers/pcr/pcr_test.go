@@ -1,8 +1,16 @@
 package pcr
 
 import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/bebop/poly/io/fasta"
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/transform"
+
 	"github.com/google/go-cmp/cmp"
 )
 
@@ -89,3 +97,301 @@ func TestIssue279PCRBug(t *testing.T) {
 		t.Errorf("incorrect PCR output (-want,+got): %s", diff)
 	}
 }
+
+func TestDesignPrimerPairs(t *testing.T) {
+	constraints := DefaultDesignConstraints()
+	template := strings.ToUpper(gene)
+	pairs, err := DesignPrimerPairs(gene, 300, 500, constraints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != constraints.MaxPairs {
+		t.Fatalf("expected %d pairs, got %d", constraints.MaxPairs, len(pairs))
+	}
+	for index, pair := range pairs {
+		if index > 0 && pair.Penalty < pairs[index-1].Penalty {
+			t.Errorf("pairs aren't sorted by penalty")
+		}
+		forward, reverse := pair.Forward, pair.Reverse
+		if forward.Sequence != template[forward.Start:forward.End] || reverse.Sequence != transform.ReverseComplement(template[reverse.Start:reverse.End]) {
+			t.Errorf("primers %s and %s don't match the template", forward.Sequence, reverse.Sequence)
+		}
+		if forward.End > 300 || reverse.Start < 500 || pair.ProductSize != reverse.End-forward.Start {
+			t.Errorf("pair %+v doesn't amplify the target", pair)
+		}
+		for _, primer := range []Primer{forward, reverse} {
+			if primer.MeltingTemp < constraints.MinTm || primer.MeltingTemp > constraints.MaxTm || len(primer.Sequence) < constraints.MinLength || len(primer.Sequence) > constraints.MaxLength {
+				t.Errorf("primer %+v breaks the constraints", primer)
+			}
+			if last := primer.Sequence[len(primer.Sequence)-1]; last != 'G' && last != 'C' {
+				t.Errorf("primer %s has no GC clamp", primer.Sequence)
+			}
+		}
+		if math.Abs(forward.MeltingTemp-reverse.MeltingTemp) > constraints.MaxTmDifference {
+			t.Errorf("pair %+v has too different melting temperatures", pair)
+		}
+	}
+
+	constraints.MinTm, constraints.MaxTm = 90, 95
+	if _, err := DesignPrimerPairs(gene, 300, 500, constraints); err == nil {
+		t.Errorf("expected an error for impossible constraints")
+	}
+	if _, err := DesignPrimerPairs(gene, 500, 300, DefaultDesignConstraints()); err == nil {
+		t.Errorf("expected an error for an invalid target")
+	}
+	constraints = DefaultDesignConstraints()
+	constraints.MaxPairs = 0
+	if _, err := DesignPrimerPairs(gene, 300, 500, constraints); err == nil {
+		t.Errorf("expected an error for no pairs asked for")
+	}
+}
+
+func TestDimerDeltaG(t *testing.T) {
+	// a palindromic 3' end pairs with itself
+	deltaG, endDeltaG := dimerDeltaG("ATTATTATTGGCGCGCC", "ATTATTATTGGCGCGCC")
+	if deltaG > -5 || endDeltaG != deltaG {
+		t.Errorf("expected a stable 3' dimer, got %f and %f", deltaG, endDeltaG)
+	}
+	// a palindrome in the middle doesn't pair the 3' end
+	deltaG, endDeltaG = dimerDeltaG("ATTGGCGCGCCATTATT", "ATTGGCGCGCCATTATT")
+	if deltaG > -5 || endDeltaG < -2 {
+		t.Errorf("expected a stable internal dimer, got %f and %f", deltaG, endDeltaG)
+	}
+	if deltaG, _ := dimerDeltaG("AAAAAAAAAA", "AAAAAAAAAA"); deltaG != 0 {
+		t.Errorf("expected no dimer, got %f", deltaG)
+	}
+}
+
+func TestSimulateWithMismatches(t *testing.T) {
+	forwardPrimer := "TTATAGGTCTCATACTAATAATTACACCGAGATAACACATCATGG"
+	reversePrimer := "TATATGGTCTCTTCATTTAAGAAAGCGCATTTTCCAGC"
+	expected, _ := Simulate([]string{gene}, 55.0, false, []string{forwardPrimer, reversePrimer})
+	products, err := SimulateWithMismatches([]Template{{Name: "gene", Sequence: gene}}, []string{forwardPrimer, reversePrimer}, DefaultBindingOptions(55.0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].Sequence != expected[0] {
+		t.Fatalf("expected the product of Simulate, got %+v", products)
+	}
+	product := products[0]
+	if product.Template != "gene" || product.Forward.Primer != 0 || product.Reverse.Primer != 1 || product.Start != 0 || product.End != len(gene) || product.Length != len(expected[0]) {
+		t.Errorf("unexpected product %+v", product)
+	}
+	// the forward primer anneals up to its tail
+	if product.Forward.Start != 0 || product.Forward.End != len(forwardPrimer)-16 || len(product.Forward.Mismatches) != 0 {
+		t.Errorf("unexpected forward site %+v", product.Forward)
+	}
+
+	// a mismatch 10 bases from the 3' end is tolerated, and ends up in the
+	// product, but not one on the last base
+	mismatched := forwardPrimer[:len(forwardPrimer)-11] + "C" + forwardPrimer[len(forwardPrimer)-10:]
+	products, _ = SimulateWithMismatches([]Template{{Name: "gene", Sequence: gene}}, []string{mismatched, reversePrimer}, DefaultBindingOptions(55.0))
+	if len(products) != 1 || products[0].Forward.Penalty != 1 || !reflect.DeepEqual(products[0].Forward.Mismatches, []int{len(forwardPrimer) - 11}) || !strings.HasPrefix(products[0].Sequence, mismatched) {
+		t.Errorf("expected a product with a mismatch, got %+v", products)
+	}
+	mismatched = forwardPrimer[:len(forwardPrimer)-1] + "C"
+	if products, _ = SimulateWithMismatches([]Template{{Name: "gene", Sequence: gene}}, []string{mismatched, reversePrimer}, DefaultBindingOptions(55.0)); len(products) != 0 {
+		t.Errorf("expected a 3' mismatch to block amplification, got %+v", products)
+	}
+
+	// degenerate primers bind with the variant matching the template
+	degenerate := "AATAATTACACCGAGATAACNCATCATGG"
+	products, err = SimulateWithMismatches([]Template{{Name: "gene", Sequence: gene}}, []string{degenerate, reversePrimer}, DefaultBindingOptions(55.0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 1 || products[0].Forward.Sequence != "AATAATTACACCGAGATAACACATCATGG" || len(products[0].Forward.Mismatches) != 0 {
+		t.Errorf("expected the exact variant to bind, got %+v", products)
+	}
+	if _, err := SimulateWithMismatches([]Template{{Name: "gene", Sequence: gene}}, []string{"AATAATTACACCGAGATAAC!"}, DefaultBindingOptions(55.0)); err == nil {
+		t.Errorf("expected an error for an invalid primer")
+	}
+
+	// products of circular templates may span the origin
+	rotated := strings.ToUpper(gene[400:] + gene[:400])
+	products, _ = SimulateWithMismatches([]Template{{Name: "plasmid", Sequence: rotated, Circular: true}}, []string{forwardPrimer, reversePrimer}, DefaultBindingOptions(55.0))
+	if len(products) != 1 || products[0].Sequence != expected[0] || products[0].Start != len(gene)-400 || products[0].End <= len(gene) {
+		t.Errorf("expected a product spanning the origin, got %+v", products)
+	}
+
+	// circular templates shorter than the primer anneals to aren't bound
+	products, err = SimulateWithMismatches([]Template{{Sequence: "ACGTACGTAGCTAGC", Circular: true}}, []string{"ACGTACGTAGCTAGCATGCAGT"}, DefaultBindingOptions(55.0))
+	if err != nil || len(products) != 0 {
+		t.Errorf("expected no products from a template shorter than the primer, got %+v: %v", products, err)
+	}
+}
+
+func TestCheckSpecificity(t *testing.T) {
+	puc19, err := genbank.Read("../../data/puc19.gbk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	background := append(TemplatesFromGenbank([]genbank.Genbank{puc19}), TemplatesFromFasta([]fasta.Fasta{{Name: "gene", Sequence: gene}})...)
+	if !background[0].Circular || background[1].Circular {
+		t.Fatalf("unexpected templates %+v", background)
+	}
+
+	m13 := [2]string{"GTAAAACGACGGCCAGT", "CAGGAAACAGCTATGAC"}
+	genePair := [2]string{"AATAATTACACCGAGATAACACATCATGG", "TTAAGAAAGCGCATTTTCCAGC"}
+	options := DefaultSpecificityOptions(45)
+	options.MaxProductSize = 1000
+	reports, err := CheckSpecificity([][2]string{m13, genePair}, background, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// M13 primers amplify the multiple cloning site of pUC19
+	m13Report := reports[0]
+	if m13Report.Specific() || len(m13Report.Amplicons) != 1 || m13Report.Amplicons[0].Template != puc19.Meta.Locus.Name {
+		t.Fatalf("expected a single amplicon in pUC19, got %+v", m13Report.Amplicons)
+	}
+	if length := m13Report.Amplicons[0].Length; length < 100 || length > 250 {
+		t.Errorf("unexpected amplicon of %d bases", length)
+	}
+	for _, site := range m13Report.BindingSites {
+		if len(site.Mismatches) == 0 && site.MeltingTemp < 45 {
+			t.Errorf("expected perfect sites to melt above the target, got %+v", site)
+		}
+	}
+
+	// the gene pair only amplifies the gene
+	geneReport := reports[1]
+	if len(geneReport.Amplicons) != 1 || geneReport.Amplicons[0].Template != "gene" {
+		t.Errorf("expected a single amplicon of the gene, got %+v", geneReport.Amplicons)
+	}
+	options.MaxProductSize = 500
+	if reports, _ := CheckSpecificity([][2]string{genePair}, background, options); !reports[0].Specific() {
+		t.Errorf("expected amplicons longer than the maximum product size to be left out")
+	}
+	options.MaxProductSize = 0
+	if _, err := CheckSpecificity([][2]string{genePair}, background, options); err == nil {
+		t.Errorf("expected an error without a maximum product size")
+	}
+}
+
+func TestDesignMultiplexPanel(t *testing.T) {
+	puc19, err := genbank.Read("../../data/puc19.gbk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var targets []MultiplexTarget
+	for index, start := range []int{500, 1400, 2100} {
+		targets = append(targets, MultiplexTarget{Name: fmt.Sprint("target ", index), Template: puc19.Sequence, Start: start, End: start + 50})
+	}
+	constraints := DefaultMultiplexConstraints()
+	constraints.MaxProductSize = 600
+	panel, err := DesignMultiplexPanel(targets, constraints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(panel.Pairs) != len(targets) {
+		t.Fatalf("expected a pair for each target, got %d", len(panel.Pairs))
+	}
+	for index, pair := range panel.Pairs {
+		if pair.Forward.End > targets[index].Start || pair.Reverse.Start < targets[index].End {
+			t.Errorf("pair %d doesn't amplify its target", index)
+		}
+		for _, other := range panel.Pairs[:index] {
+			larger := float64(max(pair.ProductSize, other.ProductSize))
+			if math.Abs(float64(pair.ProductSize-other.ProductSize)) < 0.1*larger {
+				t.Errorf("amplicons of %d and %d bases can't be told apart", pair.ProductSize, other.ProductSize)
+			}
+			if math.Abs(pair.Forward.MeltingTemp-other.Reverse.MeltingTemp) > constraints.MaxTmSpread {
+				t.Errorf("primers don't share a melting temperature window")
+			}
+		}
+	}
+	if panel.DimerDeltaG < constraints.MinDimerDeltaG || panel.EndDimerDeltaG < constraints.MinEndDimerDeltaG {
+		t.Errorf("pool dimerizes: %f, %f", panel.DimerDeltaG, panel.EndDimerDeltaG)
+	}
+
+	// three amplicons between 100 and 600 bases can't each be twice as long
+	// as the others
+	constraints.MinSizeDifference = 0.6
+	if _, err := DesignMultiplexPanel(targets, constraints); err == nil {
+		t.Errorf("expected an error for indistinguishable amplicons")
+	}
+	if _, err := DesignMultiplexPanel([]MultiplexTarget{{Name: "outside", Template: puc19.Sequence, Start: 5000, End: 5100}}, DefaultMultiplexConstraints()); err == nil {
+		t.Errorf("expected an error for a target outside the template")
+	}
+}
+
+func TestPanelSearchSteps(t *testing.T) {
+	// amplicons of the same size can't be told apart, so no panel exists
+	pair := PrimerPair{Forward: Primer{Sequence: "AAAAAAAAAAAAAAAAAAAA", MeltingTemp: 60}, Reverse: Primer{Sequence: "CCCCCCCCCCCCCCCCCCCC", MeltingTemp: 60}, ProductSize: 200}
+	candidates := [][]PrimerPair{{pair, pair, pair}, {pair, pair, pair}, {pair, pair, pair}}
+	constraints := DefaultMultiplexConstraints()
+
+	err := newPanelSearch(candidates, constraints, 100).run()
+	if err == nil || !strings.Contains(err.Error(), "no combination") {
+		t.Errorf("expected no combination to be found, got %v", err)
+	}
+	err = newPanelSearch(candidates, constraints, 5).run()
+	if err == nil || !strings.Contains(err.Error(), "search stopped after 5 steps") {
+		t.Errorf("expected the search to stop after 5 steps, got %v", err)
+	}
+}
+
+func TestDesignQpcrAssays(t *testing.T) {
+	puc19, err := genbank.Read("../../data/puc19.gbk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// bla, the ampicillin resistance gene
+	regionStart, regionEnd := 1283, 2144
+	constraints := DefaultQpcrConstraints()
+	assays, err := DesignQpcrAssays(puc19.Sequence, regionStart, regionEnd, constraints)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assays) != constraints.MaxAssays {
+		t.Errorf("expected %d assays, got %d", constraints.MaxAssays, len(assays))
+	}
+	for index, assay := range assays {
+		pair, probe := assay.Pair, assay.Probe
+		if pair.ProductSize < 70 || pair.ProductSize > 200 || len(assay.Amplicon) != pair.ProductSize {
+			t.Errorf("expected an amplicon of 70 to 200 bases, got %d", pair.ProductSize)
+		}
+		if pair.Forward.Start < regionStart || pair.Reverse.End > regionEnd {
+			t.Errorf("expected the amplicon within the region, got %d..%d", pair.Forward.Start, pair.Reverse.End)
+		}
+		if probe.Start < pair.Forward.End || probe.End > pair.Reverse.Start {
+			t.Errorf("expected the probe between the primers, got %d..%d", probe.Start, probe.End)
+		}
+		primerTm := (pair.Forward.MeltingTemp + pair.Reverse.MeltingTemp) / 2
+		if probe.MeltingTemp < primerTm+8 || probe.MeltingTemp > primerTm+10 {
+			t.Errorf("expected the probe to melt 8 to 10°C above the primers, got %.1f°C and %.1f°C", probe.MeltingTemp, primerTm)
+		}
+		if probe.Sequence[0] == 'G' {
+			t.Errorf("expected no G at the 5' end of probe %s", probe.Sequence)
+		}
+		if assay.AmpliconDeltaG < constraints.MinAmpliconDeltaG {
+			t.Errorf("expected an amplicon folding above %.1f kcal/mol, got %.1f", constraints.MinAmpliconDeltaG, assay.AmpliconDeltaG)
+		}
+		if index > 0 && assay.Penalty < assays[index-1].Penalty {
+			t.Errorf("expected assays sorted by penalty")
+		}
+	}
+
+	// the best assay is the best by total penalty, whatever the number asked for
+	single := DefaultQpcrConstraints()
+	single.MaxAssays = 1
+	best, err := DesignQpcrAssays(puc19.Sequence, regionStart, regionEnd, single)
+	if err != nil || len(best) != 1 || best[0].Penalty != assays[0].Penalty {
+		t.Errorf("expected the best assay to have a penalty of %f, got %+v, %v", assays[0].Penalty, best, err)
+	}
+
+	// probes can't melt that far above primers
+	constraints.MinProbeTmDifference, constraints.MaxProbeTmDifference = 30, 32
+	if _, err := DesignQpcrAssays(puc19.Sequence, regionStart, regionStart+150, constraints); err == nil {
+		t.Errorf("expected an error for probes melting 30°C above primers")
+	}
+	if _, err := DesignQpcrAssays(puc19.Sequence, 2600, 2700, DefaultQpcrConstraints()); err == nil {
+		t.Errorf("expected an error for a region outside the template")
+	}
+	constraints = DefaultQpcrConstraints()
+	constraints.MaxAssays = 0
+	if _, err := DesignQpcrAssays(puc19.Sequence, regionStart, regionEnd, constraints); err == nil {
+		t.Errorf("expected an error for no assays asked for")
+	}
+}
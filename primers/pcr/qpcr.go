@@ -0,0 +1,242 @@
+package pcr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bebop/poly/checks"
+	"github.com/bebop/poly/primers"
+	"github.com/bebop/poly/primers/internal/bases"
+	"github.com/bebop/poly/transform"
+)
+
+/******************************************************************************
+
+qPCR assay design begins here.
+
+qPCR measures how much of a template there is by how many cycles it takes to
+amplify it, so its amplicons are short to amplify at the same efficiency every
+cycle. TaqMan assays add a hydrolysis probe binding between the primers, with a
+fluorophore quenched until the polymerase chews through it. The probe has to
+bind before the primers do, so it melts 8 to 10°C above them, and can't start
+with a G, which quenches the fluorophore. Amplicons folding at the annealing
+temperature hide the sites of the primers and probe, and amplify poorly.
+
+TaqMan assay design: https://doi.org/10.1101/pdb.top6
+
+******************************************************************************/
+
+// QpcrConstraints are the constraints of qPCR assays.
+type QpcrConstraints struct {
+	// DesignConstraints constrain the primer pairs.
+	DesignConstraints
+	// MinProbeLength and MaxProbeLength bound the length of probes.
+	MinProbeLength, MaxProbeLength int
+	// MinProbeTmDifference and MaxProbeTmDifference bound how far above the
+	// mean melting temperature of the primers that of the probe is.
+	MinProbeTmDifference, MaxProbeTmDifference float64
+	// AnnealingTemp is the temperature the secondary structure of amplicons
+	// is folded at, and MinAmpliconDeltaG the most stable it may be.
+	AnnealingTemp     float64
+	MinAmpliconDeltaG float64
+	// MaxAssays is the number of assays returned.
+	MaxAssays int
+}
+
+// DefaultQpcrConstraints returns constraints for TaqMan assays, with
+// amplicons of 70 to 200 bases and probes melting 8 to 10°C above primers
+// melting at 60°C.
+func DefaultQpcrConstraints() QpcrConstraints {
+	constraints := DefaultDesignConstraints()
+	constraints.MinLength, constraints.MaxLength = 18, 25
+	constraints.MinTm, constraints.OptimalTm, constraints.MaxTm = 58, 60, 62
+	constraints.MaxTmDifference = 2
+	constraints.MinProductSize, constraints.MaxProductSize = 70, 200
+	return QpcrConstraints{
+		DesignConstraints:    constraints,
+		MinProbeLength:       18,
+		MaxProbeLength:       30,
+		MinProbeTmDifference: 8,
+		MaxProbeTmDifference: 10,
+		AnnealingTemp:        60,
+		MinAmpliconDeltaG:    -5,
+		MaxAssays:            5,
+	}
+}
+
+// Probe is a hydrolysis probe binding between the primers of an assay.
+type Probe struct {
+	Sequence string
+	// Start and End are the positions of the probe on the top strand of the
+	// template, End being exclusive, whichever strand it binds.
+	Start, End int
+	// Reverse is true for probes binding the top strand.
+	Reverse       bool
+	MeltingTemp   float64
+	GcContent     float64
+	HairpinDeltaG float64
+	// Penalty is the difference of the melting temperature of the probe from
+	// the middle of its range, plus one for probes with more G than C bases,
+	// which make a brighter background.
+	Penalty float64
+}
+
+// QpcrAssay is a pair of primers and a probe quantifying a template.
+type QpcrAssay struct {
+	Pair  PrimerPair
+	Probe Probe
+	// Amplicon is the sequence amplified, and AmpliconDeltaG the free energy
+	// of its most stable structure at the annealing temperature.
+	Amplicon       string
+	AmpliconDeltaG float64
+	// Penalty is the sum of the penalties of the pair and of the probe.
+	Penalty float64
+}
+
+// DesignQpcrAssays designs TaqMan assays with amplicons within the region
+// [regionStart, regionEnd) of a template, like a gene. Primer pairs are
+// designed like DesignPrimerPairs, then the ones without a probe meeting the
+// constraints or with a folding amplicon are rejected. The assays with the
+// lowest penalties, of the pair and probe together, are returned, up to the
+// maximum number asked for, sorted by penalty.
+func DesignQpcrAssays(template string, regionStart, regionEnd int, constraints QpcrConstraints) ([]QpcrAssay, error) {
+	template = strings.ToUpper(template)
+	pairConstraints := constraints.DesignConstraints
+	if err := pairConstraints.validate(template, "region", regionStart, regionEnd); err != nil {
+		return nil, err
+	}
+	if constraints.MaxAssays <= 0 {
+		return nil, fmt.Errorf("maximum number of assays %d isn't positive", constraints.MaxAssays)
+	}
+
+	forwardPrimers := enumeratePrimers(template, regionStart, regionEnd, false, pairConstraints)
+	reversePrimers := enumeratePrimers(template, regionStart, regionEnd, true, pairConstraints)
+
+	designer := probeDesigner{template: template, constraints: constraints, probes: make(map[[3]int]*probeCandidate)}
+	var assays []QpcrAssay
+	var pairsTried, pairsWithProbes int
+	rankPairs(forwardPrimers, reversePrimers, pairConstraints, func(pair PrimerPair) bool {
+		// pairs come from the lowest penalty and probe penalties aren't
+		// negative, so once the pair alone does no better than the worst
+		// assay kept, no later assay can replace it
+		if len(assays) == constraints.MaxAssays && pair.Penalty >= assays[len(assays)-1].Penalty {
+			return false
+		}
+		pairsTried++
+		probe, ok := designer.design(pair)
+		if !ok {
+			return true
+		}
+		pairsWithProbes++
+		amplicon := template[pair.Forward.Start:pair.Reverse.End]
+		ampliconDeltaG := foldDeltaG(amplicon, constraints.AnnealingTemp)
+		if ampliconDeltaG < constraints.MinAmpliconDeltaG {
+			return true
+		}
+		assays = append(assays, QpcrAssay{Pair: pair, Probe: probe, Amplicon: amplicon, AmpliconDeltaG: ampliconDeltaG, Penalty: pair.Penalty + probe.Penalty})
+		sort.SliceStable(assays, func(i, j int) bool {
+			return assays[i].Penalty < assays[j].Penalty
+		})
+		assays = assays[:min(len(assays), constraints.MaxAssays)]
+		return true
+	})
+	if len(assays) == 0 {
+		return nil, fmt.Errorf("no assays meet the constraints, out of %d primer pairs of which %d have a probe", pairsTried, pairsWithProbes)
+	}
+	return assays, nil
+}
+
+// probeDesigner designs probes on a template, caching the probes it checks
+// as pairs share most of them.
+type probeDesigner struct {
+	template    string
+	constraints QpcrConstraints
+	// probes are the probes binding [start, end) of either strand.
+	probes map[[3]int]*probeCandidate
+}
+
+// probeCandidate is a probe, whether it was checked against the constraints
+// not depending on the primers, and whether it meets them.
+type probeCandidate struct {
+	probe       Probe
+	checked, ok bool
+}
+
+// design returns the probe with the lowest penalty binding between the
+// primers of a pair, on either strand, and whether there is one.
+func (designer *probeDesigner) design(pair PrimerPair) (Probe, bool) {
+	constraints := designer.constraints
+	primerTm := (pair.Forward.MeltingTemp + pair.Reverse.MeltingTemp) / 2
+	minTm, maxTm := primerTm+constraints.MinProbeTmDifference, primerTm+constraints.MaxProbeTmDifference
+	targetTm := (minTm + maxTm) / 2
+
+	best, found := Probe{}, false
+	for start := pair.Forward.End; start < pair.Reverse.Start; start++ {
+		for _, reverse := range []bool{false, true} {
+			// probes are the shortest reaching the melting temperature range
+			for end := start + constraints.MinProbeLength; end <= min(start+constraints.MaxProbeLength, pair.Reverse.Start); end++ {
+				candidate := designer.probe(start, end, reverse)
+				probe := candidate.probe
+				if probe.MeltingTemp < minTm {
+					continue
+				}
+				// probes are only checked once they melt in range, as it
+				// takes folding them
+				if !candidate.checked {
+					candidate.ok = probeMeetsConstraints(&candidate.probe, constraints)
+					candidate.checked = true
+					probe = candidate.probe
+				}
+				if candidate.ok && probe.MeltingTemp <= maxTm {
+					probe.Penalty = math.Abs(probe.MeltingTemp - targetTm)
+					if strings.Count(probe.Sequence, "G") > strings.Count(probe.Sequence, "C") {
+						probe.Penalty++
+					}
+					if !found || probe.Penalty < best.Penalty {
+						best, found = probe, true
+					}
+				}
+				break
+			}
+		}
+	}
+	return best, found
+}
+
+// probe returns the probe binding [start, end) of a strand of the template,
+// calculating its melting temperature the first time it is asked for.
+func (designer *probeDesigner) probe(start, end int, reverse bool) *probeCandidate {
+	key := [3]int{start, end, 0}
+	if reverse {
+		key[2] = 1
+	}
+	if candidate, ok := designer.probes[key]; ok {
+		return candidate
+	}
+
+	sequence := designer.template[start:end]
+	if reverse {
+		sequence = transform.ReverseComplement(sequence)
+	}
+	candidate := &probeCandidate{probe: Probe{Sequence: sequence, Start: start, End: end, Reverse: reverse, MeltingTemp: primers.MeltingTemp(sequence)}}
+	designer.probes[key] = candidate
+	return candidate
+}
+
+// probeMeetsConstraints fills in the properties of a probe and returns
+// whether it meets the constraints not depending on the primers. The cheapest
+// checks are done first.
+func probeMeetsConstraints(probe *Probe, constraints QpcrConstraints) bool {
+	sequence := probe.Sequence
+	if sequence[0] == 'G' || strings.Trim(sequence, "ACGT") != "" || bases.LongestRun(sequence) > constraints.MaxPolyX {
+		return false
+	}
+	probe.GcContent = checks.GcContent(sequence)
+	if probe.GcContent < constraints.MinGcContent || probe.GcContent > constraints.MaxGcContent {
+		return false
+	}
+	probe.HairpinDeltaG = hairpinDeltaG(sequence)
+	return probe.HairpinDeltaG >= constraints.MinHairpinDeltaG
+}
@@ -0,0 +1,100 @@
+package pcr
+
+import (
+	"fmt"
+
+	"github.com/bebop/poly/io/fasta"
+	"github.com/bebop/poly/io/genbank"
+)
+
+/******************************************************************************
+
+Primer specificity screening begins here.
+
+Primers amplifying their target fine can still amplify something else in the
+tube, like the genome of the host a plasmid was prepped from, or the other
+plasmids of a reaction. CheckSpecificity screens primer pairs against such a
+background before they are ordered.
+
+******************************************************************************/
+
+// TemplatesFromFasta returns linear templates of fasta records, like the
+// chromosomes of a genome.
+func TemplatesFromFasta(records []fasta.Fasta) []Template {
+	templates := make([]Template, len(records))
+	for index, record := range records {
+		templates[index] = Template{Name: record.Name, Sequence: record.Sequence}
+	}
+	return templates
+}
+
+// TemplatesFromGenbank returns templates of genbank sequences, circular if
+// their locus is.
+func TemplatesFromGenbank(sequences []genbank.Genbank) []Template {
+	templates := make([]Template, len(sequences))
+	for index, sequence := range sequences {
+		templates[index] = Template{Name: sequence.Meta.Locus.Name, Sequence: sequence.Sequence, Circular: sequence.Meta.Locus.Circular}
+	}
+	return templates
+}
+
+// SpecificityOptions are the options of a specificity screen.
+type SpecificityOptions struct {
+	BindingOptions
+	// MaxProductSize is the longest off-target amplicon reported, as longer
+	// ones hardly amplify in the extension times of a PCR.
+	MaxProductSize int
+}
+
+// DefaultSpecificityOptions returns options binding primers like
+// DefaultBindingOptions, and reporting amplicons of up to 3 kb.
+func DefaultSpecificityOptions(targetTm float64) SpecificityOptions {
+	return SpecificityOptions{
+		BindingOptions: DefaultBindingOptions(targetTm),
+		MaxProductSize: 3000,
+	}
+}
+
+// SpecificityReport is the outcome of screening a primer pair against a
+// background.
+type SpecificityReport struct {
+	Forward, Reverse string
+	// BindingSites are the sites either primer binds in the background, their
+	// Primer being 0 for the forward primer and 1 for the reverse primer.
+	BindingSites []BindingSite
+	// Amplicons are the products of the pair in the background up to the
+	// maximum product size, including the ones of a single primer binding
+	// both strands.
+	Amplicons []Product
+}
+
+// Specific returns whether the primer pair doesn't amplify anything in the
+// background.
+func (report SpecificityReport) Specific() bool {
+	return len(report.Amplicons) == 0
+}
+
+// CheckSpecificity screens primer pairs, each a forward and a reverse
+// primer, against background templates, finding the sites the primers bind
+// with mismatches like SimulateWithMismatches and the amplicons they make. It
+// returns an error if the maximum product size isn't positive, which would
+// report every pair as specific.
+func CheckSpecificity(primerPairs [][2]string, background []Template, options SpecificityOptions) ([]SpecificityReport, error) {
+	if options.MaxProductSize <= 0 {
+		return nil, fmt.Errorf("maximum product size %d isn't positive", options.MaxProductSize)
+	}
+	reports := make([]SpecificityReport, len(primerPairs))
+	for index, primerPair := range primerPairs {
+		report := SpecificityReport{Forward: primerPair[0], Reverse: primerPair[1]}
+		for _, template := range background {
+			sites, err := FindBindingSites(template, primerPair[:], options.BindingOptions)
+			if err != nil {
+				return nil, err
+			}
+			report.BindingSites = append(report.BindingSites, sites...)
+			report.Amplicons = append(report.Amplicons, siteProducts(template, sites, options.MaxProductSize)...)
+		}
+		reports[index] = report
+	}
+	return reports, nil
+}
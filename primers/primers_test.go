@@ -133,3 +133,242 @@ func TestCreateBarcode(t *testing.T) {
 		t.Errorf("TestUniqueSequence string should return CTCTCGGTCGCTCCGTCCCG. Got:\n%s", output)
 	}
 }
+
+func TestDuplexThermodynamics(t *testing.T) {
+	primer := "GTAAAACGACGGCCAGT" // M13 fwd
+
+	// at 1 M sodium, salt corrections don't change the melting temperature
+	conditions := primers.Conditions{PrimerConcentration: 500e-9, Monovalent: 1}
+	perfect, err := primers.DuplexThermodynamics(primer, primer, conditions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedTm, _, _ := primers.SantaLucia(primer, 500e-9, 1, 0)
+	if math.Abs(perfect.MeltingTemp-expectedTm) > 0.5 {
+		t.Errorf("expected a melting temperature of %f at 1 M sodium, got %f", expectedTm, perfect.MeltingTemp)
+	}
+
+	// magnesium stabilizes duplexes, dNTPs take magnesium away and DMSO
+	// destabilizes them
+	conditions = primers.DefaultConditions()
+	sodium, _ := primers.MeltingTempWithConditions(primer, conditions)
+	conditions.Magnesium = 2e-3
+	magnesium, _ := primers.MeltingTempWithConditions(primer, conditions)
+	conditions.DNTP = 0.8e-3
+	dntp, _ := primers.MeltingTempWithConditions(primer, conditions)
+	conditions.DMSO = 5
+	dmso, _ := primers.MeltingTempWithConditions(primer, conditions)
+	if !(magnesium > dntp && dntp > sodium) || math.Abs(dntp-dmso-3.75) > 1e-9 {
+		t.Errorf("unexpected melting temperatures %f, %f, %f and %f", sodium, magnesium, dntp, dmso)
+	}
+	if math.Abs(sodium-primers.MeltingTemp(primer)) > 2 {
+		t.Errorf("expected a melting temperature close to MeltingTemp, got %f", sodium)
+	}
+
+	// an internal mismatch destabilizes the duplex, reading it from either
+	// strand
+	target := "GTAAAACGTCGGCCAGT"
+	mismatched, err := primers.DuplexThermodynamics(primer, target, primers.DefaultConditions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	perfect, _ = primers.DuplexThermodynamics(primer, primer, primers.DefaultConditions())
+	if mismatched.MeltingTemp >= perfect.MeltingTemp-5 || mismatched.DeltaG(37) <= perfect.DeltaG(37) {
+		t.Errorf("expected a mismatch to destabilize the duplex, got %+v and %+v", mismatched, perfect)
+	}
+	otherStrand, _ := primers.DuplexThermodynamics(transform.ReverseComplement(target), transform.ReverseComplement(primer), primers.DefaultConditions())
+	if math.Abs(otherStrand.DeltaH-mismatched.DeltaH) > 1e-9 || math.Abs(otherStrand.DeltaS-mismatched.DeltaS) > 1e-9 {
+		t.Errorf("expected the same duplex from the other strand, got %+v and %+v", otherStrand, mismatched)
+	}
+
+	// a template base past the 3' end of the primer dangles, and a
+	// mismatched 3' end of the primer counts as dangling
+	dangling, err := primers.DuplexThermodynamics(primer+".", primer+"G", primers.DefaultConditions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dangling.DeltaH == perfect.DeltaH {
+		t.Errorf("expected a dangling end to change the duplex")
+	}
+	terminal, err := primers.DuplexThermodynamics(primer[:len(primer)-1]+"A", primer, primers.DefaultConditions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if danglingPrimer, _ := primers.DuplexThermodynamics(primer[:len(primer)-1]+"A", primer[:len(primer)-1]+".", primers.DefaultConditions()); terminal != danglingPrimer {
+		t.Errorf("expected a 3' mismatch to dangle, got %+v and %+v", terminal, danglingPrimer)
+	}
+
+	for _, test := range [][2]string{{primer, primer[1:]}, {primer, "GTAAAACGTTGGCCAGT"}, {"ACGN", "ACGT"}, {"AC", "TG"}} {
+		if _, err := primers.DuplexThermodynamics(test[0], test[1], primers.DefaultConditions()); err == nil {
+			t.Errorf("expected an error for %s and %s", test[0], test[1])
+		}
+	}
+	if _, err := primers.MeltingTempWithConditions(primer, primers.Conditions{PrimerConcentration: 500e-9}); err == nil {
+		t.Errorf("expected an error without cations")
+	}
+}
+
+func ExampleDuplexThermodynamics() {
+	// a Taq buffer with 1.5 mM magnesium and 0.2 mM of each dNTP
+	conditions := primers.Conditions{PrimerConcentration: 200e-9, Monovalent: 50e-3, Magnesium: 1.5e-3, DNTP: 0.8e-3}
+
+	primer := "GTAAAACGACGGCCAGT" // M13 fwd
+	perfect, _ := primers.DuplexThermodynamics(primer, primer, conditions)
+	mismatched, _ := primers.DuplexThermodynamics(primer, "GTAAAACGTCGGCCAGT", conditions)
+
+	fmt.Printf("%.1f°C %.1f kcal/mol\n", perfect.MeltingTemp, perfect.DeltaG(60))
+	fmt.Printf("%.1f°C %.1f kcal/mol\n", mismatched.MeltingTemp, mismatched.DeltaG(60))
+	// Output:
+	// 55.5°C -9.7 kcal/mol
+	// 49.5°C -7.2 kcal/mol
+}
+
+func TestCreateBarcodeSet(t *testing.T) {
+	for _, metric := range []primers.DistanceMetric{primers.Hamming, primers.Levenshtein} {
+		constraints := primers.DefaultBarcodeConstraints(10, 24)
+		constraints.Metric = metric
+		constraints.BannedSequences = []string{"GGTCTC"} // BsaI
+		barcodes, err := primers.CreateBarcodeSet(constraints)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(barcodes) != constraints.Count {
+			t.Fatalf("expected %d barcodes, got %d", constraints.Count, len(barcodes))
+		}
+		for i, barcode := range barcodes {
+			if len(barcode) != constraints.Length {
+				t.Errorf("expected barcodes of %d bases, got %s", constraints.Length, barcode)
+			}
+			if strings.Contains(barcode, "GGTCTC") || strings.Contains(barcode, "GAGACC") {
+				t.Errorf("expected %s not to contain BsaI sites", barcode)
+			}
+			if strings.Contains(barcode, "AAA") || strings.Contains(barcode, "CCC") || strings.Contains(barcode, "GGG") || strings.Contains(barcode, "TTT") {
+				t.Errorf("expected %s to have no runs of more than two bases", barcode)
+			}
+			for j, other := range barcodes {
+				if i != j && metric.Distance(barcode, other) < constraints.MinDistance {
+					t.Errorf("expected %s and %s to be at least %d apart", barcode, other, constraints.MinDistance)
+				}
+				if metric.Distance(barcode, transform.ReverseComplement(other)) < constraints.MinDistance {
+					t.Errorf("expected %s and the reverse complement of %s to be at least %d apart", barcode, other, constraints.MinDistance)
+				}
+			}
+		}
+	}
+
+	// there are only 16 barcodes of two bases
+	constraints := primers.BarcodeConstraints{Length: 2, Count: 17, Metric: primers.Hamming, MinDistance: 1, MaxGcContent: 1, MaxHomopolymer: 2, MaxAttempts: 1000}
+	if _, err := primers.CreateBarcodeSet(constraints); err == nil {
+		t.Errorf("expected an error for more barcodes than there are")
+	}
+	if _, err := primers.CreateBarcodeSet(primers.DefaultBarcodeConstraints(0, 10)); err == nil {
+		t.Errorf("expected an error for barcodes without bases")
+	}
+}
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b                 string
+		hamming, levenshtein int
+	}{
+		{"ACGTACGT", "ACGTACGT", 0, 0},
+		{"ACGTACGT", "ACGAACGT", 1, 1},
+		{"ACGTACGT", "CGTACGTA", 8, 2},
+		{"ACGTACGT", "ACGACGT", 5, 1},
+		{"", "ACG", 3, 3},
+	}
+	for _, test := range tests {
+		if distance := primers.Hamming.Distance(test.a, test.b); distance != test.hamming {
+			t.Errorf("expected a Hamming distance of %d between %s and %s, got %d", test.hamming, test.a, test.b, distance)
+		}
+		if distance := primers.Levenshtein.Distance(test.a, test.b); distance != test.levenshtein {
+			t.Errorf("expected a Levenshtein distance of %d between %s and %s, got %d", test.levenshtein, test.a, test.b, distance)
+		}
+	}
+}
+
+func TestBarcodeDecoder(t *testing.T) {
+	barcodes, err := primers.CreateBarcodeSet(primers.DefaultBarcodeConstraints(10, 12))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder, err := primers.NewBarcodeDecoder(barcodes, primers.Levenshtein, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	barcode := barcodes[5]
+	substitute := "A"
+	if barcode[2] == 'A' {
+		substitute = "C"
+	}
+	observed := map[string]int{
+		barcode:                                  0,
+		barcode[:4] + "N" + barcode[5:]:          -1, // N isn't a base
+		strings.ToLower(barcode):                 0,
+		barcode[:3] + barcode[4:]:                1,
+		barcode[:6] + "A" + barcode[6:]:          1,
+		barcode[:2] + substitute + barcode[3:]:   1,
+		barcode[:2] + barcode[3:7] + barcode[8:]: -1,
+	}
+	for sequence, distance := range observed {
+		match, ok := decoder.Decode(sequence)
+		if distance < 0 {
+			if ok && match.Barcode == 5 {
+				t.Errorf("expected %s not to decode to barcode 5, got %+v", sequence, match)
+			}
+			continue
+		}
+		if !ok || match.Barcode != 5 || match.Distance != distance {
+			t.Errorf("expected %s to decode to barcode 5 with %d errors, got %+v", sequence, distance, match)
+		}
+	}
+
+	// sequences one error from two barcodes aren't decoded
+	ambiguousDecoder, err := primers.NewBarcodeDecoder([]string{"AAAAAA", "AAAATT"}, primers.Hamming, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if match, ok := ambiguousDecoder.Decode("AAAAAT"); ok {
+		t.Errorf("expected AAAAAT to be ambiguous, got %+v", match)
+	}
+	if match, ok := ambiguousDecoder.Decode("CAAAAA"); !ok || match.Barcode != 0 {
+		t.Errorf("expected CAAAAA to decode to AAAAAA, got %+v", match)
+	}
+
+	// barcodes with a deletion span one base less of the read
+	read := barcode[:3] + barcode[4:] + "GATTACA"
+	match, length, ok := decoder.DecodePrefix(read)
+	if !ok || match.Barcode != 5 || length != len(barcode)-1 {
+		t.Errorf("expected the start of %s to decode to barcode 5 over %d bases, got %+v over %d", read, len(barcode)-1, match, length)
+	}
+	match, length, ok = decoder.DecodePrefix(barcode + "GATTACA")
+	if !ok || match.Barcode != 5 || match.Distance != 0 || length != len(barcode) {
+		t.Errorf("expected an exact barcode to decode over %d bases, got %+v over %d", len(barcode), match, length)
+	}
+
+	if _, err := primers.NewBarcodeDecoder([]string{"ACGT", "ACG"}, primers.Hamming, 1); err == nil {
+		t.Errorf("expected an error for barcodes of different lengths")
+	}
+	if _, err := primers.NewBarcodeDecoder([]string{"ACGT", "TTGA", "acgt"}, primers.Hamming, 1); err == nil {
+		t.Errorf("expected an error for duplicate barcodes")
+	}
+	if _, err := primers.NewBarcodeDecoder(nil, primers.Hamming, 1); err == nil {
+		t.Errorf("expected an error without barcodes")
+	}
+}
+
+func ExampleCreateBarcodeSet() {
+	// 96 barcodes of 12 bases, any single sequencing error of which is
+	// corrected on either strand
+	barcodes, err := primers.CreateBarcodeSet(primers.DefaultBarcodeConstraints(12, 96))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	decoder, _ := primers.NewBarcodeDecoder(barcodes, primers.Levenshtein, 1)
+
+	// a read of the first barcode with its fourth base deleted
+	match, length, _ := decoder.DecodePrefix(barcodes[0][:3] + barcodes[0][4:] + "AGATCGGAAGAGC")
+	fmt.Println(len(barcodes), match.Barcode, match.Distance, length)
+	// Output: 96 0 1 11
+}
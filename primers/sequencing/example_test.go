@@ -0,0 +1,34 @@
+package sequencing_test
+
+import (
+	"fmt"
+
+	"github.com/bebop/poly/io/fasta"
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/primers/sequencing"
+)
+
+func ExampleDesignPrimers() {
+	puc19, _ := genbank.Read("../../data/puc19.gbk")
+	library := []fasta.Fasta{{Name: "M13F", Sequence: "GTAAAACGACGGCCAGT"}, {Name: "M13R", Sequence: "CAGGAAACAGCTATGAC"}}
+	tiling, _ := sequencing.DesignPrimers(sequencing.PartFromGenbank(puc19), library, sequencing.DefaultOptions())
+
+	for _, primer := range tiling.Primers {
+		name := primer.Name
+		if name == "" {
+			name = "new"
+		}
+		fmt.Printf("%s %s reads %d..%d\n", name, primer.Sequence, primer.ReadStart, primer.ReadEnd)
+	}
+	// Output:
+	// new ATAAGGCGCAGCGGTCGG reads 2678..3378
+	// new GTGTGGAATTGTGAGCGG reads 638..1338
+	// new TCAAATATGTATCCGCTCATGAG reads 1287..1987
+	// new GAACTACTTACTCTAGCTTCCC reads 1937..2637
+	// new CATACCTCGCTCTGCTAATC reads 2587..3287
+	// M13F GTAAAACGACGGCCAGT reads 2624..3324
+	// new CGCCTTTCTCCCTTCGGG reads 1976..2676
+	// new TCGTTCATCCATAGTTGCC reads 1327..2027
+	// new GGATCTTACCGCTGTTGAG reads 677..1377
+	// new CCTCTTCGCTATTACGCC reads 27..727
+}
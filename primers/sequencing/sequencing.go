@@ -0,0 +1,377 @@
+/*
+Package sequencing designs primers for verifying constructs by Sanger sequencing.
+
+A Sanger read starts some bases after the primer it is primed from, as the
+shortest fragments don't resolve, and its quality drops after 700 to 900
+bases. Verifying a whole construct therefore takes primers every 600 to 800
+bases along both of its strands, each read overlapping the next one so they
+can be assembled.
+
+DesignPrimers tiles a construct with the fewest primers covering both strands,
+reusing the primers of a lab library where they already bind so fewer have to
+be ordered.
+*/
+package sequencing
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bebop/poly/checks"
+	"github.com/bebop/poly/clone"
+	"github.com/bebop/poly/io/fasta"
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/primers"
+	"github.com/bebop/poly/primers/internal/bases"
+	"github.com/bebop/poly/primers/pcr"
+	"github.com/bebop/poly/transform"
+)
+
+// Options are the read and primer parameters of a sequencing design.
+type Options struct {
+	// DeadZone is the number of bases after the 3' end of a primer before its
+	// read starts, and ReadLength the number of bases read after it.
+	DeadZone, ReadLength int
+	// Overlap is the number of bases consecutive reads overlap by.
+	Overlap int
+	// MinLength and MaxLength bound the length of designed primers, which are
+	// the shortest reaching MinTm, calculated by primers.MeltingTemp.
+	MinLength, MaxLength int
+	MinTm, MaxTm         float64
+	// MinGcContent and MaxGcContent are fractions of G and C bases.
+	MinGcContent, MaxGcContent float64
+	// MaxPolyX is the longest run of a single base primers may have.
+	MaxPolyX int
+	// UniqueLength is the number of bases at the 3' end of designed primers
+	// which must only occur once in the template, on either strand, so they
+	// don't prime anywhere else. 0 turns the check off.
+	UniqueLength int
+}
+
+// DefaultOptions returns options for reads of 700 good bases starting 50
+// bases after the primer, with primers of 18 to 25 bases between 52°C and
+// 62°C.
+func DefaultOptions() Options {
+	return Options{
+		DeadZone:     50,
+		ReadLength:   700,
+		Overlap:      50,
+		MinLength:    18,
+		MaxLength:    25,
+		MinTm:        52,
+		MaxTm:        62,
+		MinGcContent: 0.3,
+		MaxGcContent: 0.7,
+		MaxPolyX:     4,
+		UniqueLength: 12,
+	}
+}
+
+// Primer is a sequencing primer and the read it primes.
+type Primer struct {
+	// Name is the name of library primers, and empty for designed primers.
+	Name     string
+	Sequence string
+	// Reverse is true for primers binding the top strand, reading towards
+	// the start of the template.
+	Reverse bool
+	// Start and End are the positions of the primer on the top strand of the
+	// template, End being exclusive, and ReadStart and ReadEnd those of the
+	// bases it reads. Positions of circular templates are past their length
+	// when spanning the origin.
+	Start, End         int
+	ReadStart, ReadEnd int
+	MeltingTemp        float64
+}
+
+// Gap is a region [Start, End) of a strand of the template no read covers.
+type Gap struct {
+	Reverse    bool
+	Start, End int
+}
+
+// Tiling is a set of sequencing primers covering a template.
+type Tiling struct {
+	// Primers are the forward primers from the start of the template,
+	// followed by the reverse primers from its end.
+	Primers []Primer
+	// Gaps are the regions no primer could be found for.
+	Gaps []Gap
+}
+
+// PartFromGenbank returns the part of a genbank sequence, circular if its
+// locus is.
+func PartFromGenbank(sequence genbank.Genbank) clone.Part {
+	return clone.Part{Sequence: sequence.Sequence, Circular: sequence.Meta.Locus.Circular}
+}
+
+// DesignPrimers tiles both strands of a part with sequencing primers. Each
+// read overlaps the previous one, and primers are picked to read as far as
+// possible, so the tiling has as few primers as it can. Library primers
+// binding the part once, with the mismatches of pcr.DefaultBindingOptions,
+// are picked over designed ones whenever that doesn't take more primers.
+func DesignPrimers(part clone.Part, library []fasta.Fasta, options Options) (Tiling, error) {
+	sequence := strings.ToUpper(part.Sequence)
+	if len(sequence) == 0 {
+		return Tiling{}, fmt.Errorf("can't tile an empty part")
+	}
+	if options.ReadLength <= options.Overlap {
+		return Tiling{}, fmt.Errorf("reads of %d bases can't overlap by %d", options.ReadLength, options.Overlap)
+	}
+	if options.MinLength <= 0 || options.MinLength > options.MaxLength || options.UniqueLength < 0 {
+		return Tiling{}, fmt.Errorf("primer lengths %d to %d and unique length %d aren't valid", options.MinLength, options.MaxLength, options.UniqueLength)
+	}
+
+	// library primers binding the part once
+	libraryPrimers := [2][]Primer{}
+	template := pcr.Template{Sequence: sequence, Circular: part.Circular}
+	for _, record := range library {
+		sites, err := pcr.FindBindingSites(template, []string{record.Sequence}, pcr.DefaultBindingOptions(options.MinTm))
+		if err != nil {
+			return Tiling{}, fmt.Errorf("library primer %s: %w", record.Name, err)
+		}
+		if len(sites) != 1 {
+			continue
+		}
+		primer := Primer{Name: record.Name, Sequence: strings.ToUpper(record.Sequence), Reverse: sites[0].Reverse, MeltingTemp: sites[0].MeltingTemp}
+		strand := 0
+		if primer.Reverse {
+			strand = 1
+		}
+		libraryPrimers[strand] = append(libraryPrimers[strand], strandPrimer(primer, sites[0].Start, sites[0].End, len(sequence)))
+	}
+
+	// the reverse strand is tiled like the forward strand of the reverse
+	// complement
+	var tiling Tiling
+	var kmers map[string]int
+	if options.UniqueLength > 0 {
+		kmers = countKmers(sequence, part.Circular, options.UniqueLength)
+	}
+	for strand, strandSequence := range []string{sequence, transform.ReverseComplement(sequence)} {
+		candidates := append(libraryPrimers[strand], designPrimers(strandSequence, part.Circular, kmers, options)...)
+		primerList, gaps := tileStrand(candidates, len(sequence), part.Circular, options)
+		for _, primer := range primerList {
+			if strand == 1 {
+				primer = topStrandPrimer(primer, len(sequence))
+			}
+			tiling.Primers = append(tiling.Primers, primer)
+		}
+		for _, gap := range gaps {
+			if strand == 1 {
+				gap = Gap{Reverse: true, Start: len(sequence) - gap.End, End: len(sequence) - gap.Start}
+				for gap.Start < 0 {
+					gap.Start, gap.End = gap.Start+len(sequence), gap.End+len(sequence)
+				}
+			}
+			tiling.Gaps = append(tiling.Gaps, gap)
+		}
+	}
+	return tiling, nil
+}
+
+// strandPrimer returns a primer binding [start, end) of the top strand in the
+// coordinates of the strand it reads, with its read.
+func strandPrimer(primer Primer, start, end, length int) Primer {
+	if primer.Reverse {
+		start, end = length-end, length-start
+	}
+	primer.Start, primer.End = start, end
+	return primer
+}
+
+// topStrandPrimer returns a primer of the reverse complement in the
+// coordinates of the top strand.
+func topStrandPrimer(primer Primer, length int) Primer {
+	primer.Reverse = true
+	primer.Start, primer.End = length-primer.End, length-primer.Start
+	primer.ReadStart, primer.ReadEnd = length-primer.ReadEnd, length-primer.ReadStart
+	for primer.Start < 0 {
+		primer.Start, primer.End = primer.Start+length, primer.End+length
+	}
+	for primer.ReadStart < 0 {
+		primer.ReadStart, primer.ReadEnd = primer.ReadStart+length, primer.ReadEnd+length
+	}
+	return primer
+}
+
+// designPrimers returns the shortest primer meeting the options ending at
+// each position of a strand.
+func designPrimers(sequence string, circular bool, kmers map[string]int, options Options) []Primer {
+	var designed []Primer
+	for end := 1; end <= len(sequence); end++ {
+		if !circular && end < options.MinLength {
+			continue
+		}
+		if !strings.ContainsAny(bases.Window(sequence, circular, end-1, end), "GC") {
+			continue
+		}
+		if options.UniqueLength > 0 && kmers[bases.Window(sequence, circular, end-options.UniqueLength, end)] != 1 {
+			continue
+		}
+		for length := options.MinLength; length <= options.MaxLength && (circular || length <= end) && length <= len(sequence); length++ {
+			primer := bases.Window(sequence, circular, end-length, end)
+			meltingTemp := primers.MeltingTemp(primer)
+			if meltingTemp < options.MinTm {
+				continue
+			}
+			gcContent := checks.GcContent(primer)
+			if meltingTemp <= options.MaxTm && gcContent >= options.MinGcContent && gcContent <= options.MaxGcContent && bases.LongestRun(primer) <= options.MaxPolyX {
+				designed = append(designed, Primer{Sequence: primer, Start: end - length, End: end, MeltingTemp: meltingTemp})
+			}
+			break
+		}
+	}
+	return designed
+}
+
+// tileStrand picks primers reading a strand from its start to its end, each
+// read overlapping the previous one, and returns them with the gaps left. At
+// each step the primer reading furthest is picked, which takes the fewest
+// primers, unless a library primer can be picked instead without taking more.
+func tileStrand(candidates []Primer, length int, circular bool, options Options) ([]Primer, []Gap) {
+	// primers of circular strands read around the origin, so each is tried
+	// one turn before and after its position too
+	var reads []Primer
+	for _, candidate := range candidates {
+		for _, turn := range []int{-1, 0, 1} {
+			if turn != 0 && !circular {
+				continue
+			}
+			read := candidate
+			read.Start, read.End = read.Start+turn*length, read.End+turn*length
+			read.ReadStart = read.End + options.DeadZone
+			read.ReadEnd = read.ReadStart + options.ReadLength
+			if !circular {
+				read.ReadEnd = min(read.ReadEnd, length)
+			}
+			if read.ReadStart < read.ReadEnd {
+				reads = append(reads, read)
+			}
+		}
+	}
+
+	var tiled []Primer
+	var gaps []Gap
+	covered, end := 0, length
+	// circular strands are covered once reads come back around to the first
+	// one
+	endAfter := func(read Primer) int {
+		if circular && len(tiled) == 0 {
+			return read.ReadStart + length + options.Overlap
+		}
+		return end
+	}
+	for covered < end {
+		// reads must overlap the previous one, and the first one must start
+		// at the start of the strand
+		limit := covered - options.Overlap
+		if len(tiled) == 0 {
+			limit = covered
+		}
+		valid, gapEnd := nextReads(reads, limit, covered)
+		if len(valid) == 0 || gapEnd >= end {
+			gaps = append(gaps, Gap{Start: covered, End: end})
+			break
+		}
+		if gapEnd > covered {
+			gaps = append(gaps, Gap{Start: covered, End: gapEnd})
+		}
+
+		next := furthestRead(valid)
+		fewest := readsTo(reads, next.ReadEnd, endAfter(next), options.Overlap)
+		for _, read := range valid {
+			if read.Name == "" {
+				continue
+			}
+			steps := readsTo(reads, read.ReadEnd, endAfter(read), options.Overlap)
+			if steps < fewest || (steps == fewest && (next.Name == "" || read.ReadEnd > next.ReadEnd)) {
+				next, fewest = read, steps
+			}
+		}
+		end = endAfter(next)
+		tiled = append(tiled, next)
+		covered = next.ReadEnd
+	}
+
+	for index := range tiled {
+		for tiled[index].Start < 0 {
+			tiled[index].Start, tiled[index].End = tiled[index].Start+length, tiled[index].End+length
+			tiled[index].ReadStart, tiled[index].ReadEnd = tiled[index].ReadStart+length, tiled[index].ReadEnd+length
+		}
+	}
+	return tiled, gaps
+}
+
+// nextReads returns the reads starting at most at a limit and reading past
+// the bases covered. If there are none, it returns the reads starting at most
+// where the closest read past the limit starts, along with that position.
+func nextReads(reads []Primer, limit, covered int) ([]Primer, int) {
+	gapEnd := covered
+	var valid []Primer
+	for _, read := range reads {
+		if read.ReadStart <= limit && read.ReadEnd > covered {
+			valid = append(valid, read)
+		}
+	}
+	if len(valid) > 0 {
+		return valid, gapEnd
+	}
+
+	gapEnd = math.MaxInt
+	for _, read := range reads {
+		if read.ReadEnd > covered && read.ReadStart < gapEnd {
+			gapEnd = read.ReadStart
+		}
+	}
+	for _, read := range reads {
+		if read.ReadStart <= gapEnd && read.ReadEnd > covered {
+			valid = append(valid, read)
+		}
+	}
+	// reads starting before the end of the previous one only miss the
+	// overlap
+	return valid, max(gapEnd, covered)
+}
+
+// furthestRead returns the read reading furthest.
+func furthestRead(reads []Primer) Primer {
+	furthest := reads[0]
+	for _, read := range reads[1:] {
+		if read.ReadEnd > furthest.ReadEnd {
+			furthest = read
+		}
+	}
+	return furthest
+}
+
+// readsTo returns the number of reads picked, reading furthest at each step,
+// to cover a strand from the bases covered to an end.
+func readsTo(reads []Primer, covered, end, overlap int) int {
+	count := 0
+	for covered < end {
+		valid, gapEnd := nextReads(reads, covered-overlap, covered)
+		if len(valid) == 0 || gapEnd >= end {
+			break
+		}
+		covered = furthestRead(valid).ReadEnd
+		count++
+	}
+	return count
+}
+
+// countKmers counts the k-mers of both strands of a sequence.
+func countKmers(sequence string, circular bool, k int) map[string]int {
+	kmers := make(map[string]int)
+	for _, strand := range []string{sequence, transform.ReverseComplement(sequence)} {
+		positions := len(strand) - k + 1
+		if circular {
+			positions = len(strand)
+		}
+		for position := 0; position < positions; position++ {
+			kmers[bases.Window(strand, circular, position, position+k)]++
+		}
+	}
+	return kmers
+}
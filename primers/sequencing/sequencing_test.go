@@ -0,0 +1,147 @@
+package sequencing
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/clone"
+	"github.com/bebop/poly/io/fasta"
+	"github.com/bebop/poly/io/genbank"
+	"github.com/bebop/poly/primers/internal/bases"
+	"github.com/bebop/poly/transform"
+)
+
+// coverage returns the number of reads covering each base of each strand.
+func coverage(tiling Tiling, length int) [2][]int {
+	covered := [2][]int{make([]int, length), make([]int, length)}
+	for _, primer := range tiling.Primers {
+		strand := 0
+		if primer.Reverse {
+			strand = 1
+		}
+		for position := primer.ReadStart; position < primer.ReadEnd; position++ {
+			covered[strand][position%length]++
+		}
+	}
+	return covered
+}
+
+func TestDesignPrimers(t *testing.T) {
+	puc19, err := genbank.Read("../../data/puc19.gbk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := PartFromGenbank(puc19)
+	options := DefaultOptions()
+	sequence := strings.ToUpper(part.Sequence)
+
+	tiling, err := DesignPrimers(part, nil, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tiling.Gaps) != 0 {
+		t.Errorf("expected no gaps, got %v", tiling.Gaps)
+	}
+	for strand, covered := range coverage(tiling, len(sequence)) {
+		for position, reads := range covered {
+			if reads == 0 {
+				t.Fatalf("expected position %d of strand %d to be read", position, strand)
+			}
+		}
+	}
+	// 2686 bases take at least five reads of 700 bases overlapping by 50
+	if len(tiling.Primers) != 10 {
+		t.Errorf("expected 10 primers, got %d", len(tiling.Primers))
+	}
+	for _, primer := range tiling.Primers {
+		bound := strings.ToUpper(bases.Window(sequence, true, primer.Start, primer.End))
+		if primer.Reverse {
+			bound = transform.ReverseComplement(bound)
+		}
+		if bound != primer.Sequence {
+			t.Errorf("expected %s to bind %d..%d, which is %s", primer.Sequence, primer.Start, primer.End, bound)
+		}
+		if primer.MeltingTemp < options.MinTm || primer.MeltingTemp > options.MaxTm {
+			t.Errorf("expected %s to melt between %.0f°C and %.0f°C, got %.1f°C", primer.Sequence, options.MinTm, options.MaxTm, primer.MeltingTemp)
+		}
+		threePrimeEnd := primer.Sequence[len(primer.Sequence)-options.UniqueLength:]
+		doubled := sequence + sequence[:options.UniqueLength]
+		if strings.Count(doubled, threePrimeEnd)+strings.Count(transform.ReverseComplement(doubled), threePrimeEnd) != 1 {
+			t.Errorf("expected the 3' end of %s to be unique", primer.Sequence)
+		}
+	}
+
+	// library primers are reused without taking more primers
+	library := []fasta.Fasta{
+		{Name: "M13F", Sequence: "GTAAAACGACGGCCAGT"},
+		{Name: "AmpR", Sequence: "ATAATACCGCGCCACATAGC"},
+	}
+	libraryTiling, err := DesignPrimers(part, library, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(libraryTiling.Primers) != len(tiling.Primers) {
+		t.Errorf("expected library primers not to take more primers, got %d", len(libraryTiling.Primers))
+	}
+	reused := map[string]bool{}
+	for _, primer := range libraryTiling.Primers {
+		reused[primer.Name] = true
+	}
+	if !reused["M13F"] || !reused["AmpR"] {
+		t.Errorf("expected M13F and AmpR to be reused, got %v", reused)
+	}
+}
+
+func TestDesignPrimersLinear(t *testing.T) {
+	puc19, err := genbank.Read("../../data/puc19.gbk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part := clone.Part{Sequence: puc19.Sequence}
+	tiling, err := DesignPrimers(part, nil, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reads can't start before their primer, so the start of each strand is
+	// left to the other one
+	if len(tiling.Gaps) != 2 || tiling.Gaps[0].Start != 0 || tiling.Gaps[1].End != len(part.Sequence) {
+		t.Errorf("expected gaps at the start of each strand only, got %v", tiling.Gaps)
+	}
+	for _, primer := range tiling.Primers {
+		if primer.ReadEnd > len(part.Sequence) || primer.Start < 0 {
+			t.Errorf("expected reads within the linear part, got %d..%d", primer.ReadStart, primer.ReadEnd)
+		}
+	}
+
+	// a part with no primer meeting the options is a single gap on each
+	// strand
+	tiling, err = DesignPrimers(clone.Part{Sequence: strings.Repeat("AT", 500)}, nil, DefaultOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tiling.Primers) != 0 || len(tiling.Gaps) != 2 {
+		t.Errorf("expected two gaps and no primers, got %v and %v", tiling.Gaps, tiling.Primers)
+	}
+
+	if _, err := DesignPrimers(clone.Part{}, nil, DefaultOptions()); err == nil {
+		t.Errorf("expected an error for an empty part")
+	}
+	options := DefaultOptions()
+	options.Overlap = options.ReadLength
+	if _, err := DesignPrimers(part, nil, options); err == nil {
+		t.Errorf("expected an error for reads overlapping by their length")
+	}
+	options = DefaultOptions()
+	options.MinLength = 0
+	if _, err := DesignPrimers(part, nil, options); err == nil {
+		t.Errorf("expected an error for primers without bases")
+	}
+
+	// a unique length of 0 doesn't check the 3' ends of primers
+	options = DefaultOptions()
+	options.UniqueLength = 0
+	tiling, err = DesignPrimers(part, nil, options)
+	if err != nil || len(tiling.Primers) == 0 {
+		t.Errorf("expected primers without checking their 3' ends, got %v: %v", tiling.Primers, err)
+	}
+}
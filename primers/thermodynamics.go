@@ -0,0 +1,267 @@
+package primers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bebop/poly/transform"
+)
+
+/******************************************************************************
+This section contains the thermodynamics of primers binding templates with
+mismatches, in the buffers they are actually used in.
+
+SantaLucia only handles perfectly matched duplexes in sodium, while primers
+are used in buffers with magnesium, which dNTPs bind, and additives like DMSO,
+and bind templates they don't match perfectly. DuplexThermodynamics handles
+all of these.
+******************************************************************************/
+
+// internalMismatchThermodynamics are the nearest neighbor parameters of
+// single internal mismatches, as top strand 5'-3' / bottom strand 3'-5'.
+// Stacks missing from the table are found reading the duplex from the other
+// strand, which reverses the key.
+// [Allawi & SantaLucia (1997) Biochemistry 36:10581; (1998) Biochemistry
+// 37:2170, 37:9435 and NAR 26:2694; Peyret et al. (1999) Biochemistry
+// 38:3468]
+var internalMismatchThermodynamics = map[string]thermodynamics{
+	"AG/TT": {1.0, 0.9}, "AT/TG": {-2.5, -8.3}, "CG/GT": {-4.1, -11.7},
+	"CT/GG": {-2.8, -8.0}, "GG/CT": {3.3, 10.4}, "GG/TT": {5.8, 16.3},
+	"GT/CG": {-4.4, -12.3}, "GT/TG": {4.1, 9.5}, "TG/AT": {-0.1, -1.7},
+	"TG/GT": {-1.4, -6.2}, "TT/AG": {-1.3, -5.3}, "AA/TG": {-0.6, -2.3},
+	"AG/TA": {-0.7, -2.3}, "CA/GG": {-0.7, -2.3}, "CG/GA": {-4.0, -13.2},
+	"GA/CG": {-0.6, -1.0}, "GG/CA": {0.5, 3.2}, "TA/AG": {0.7, 0.7},
+	"TG/AA": {3.0, 7.4},
+	"AC/TT": {0.7, 0.2}, "AT/TC": {-1.2, -6.2}, "CC/GT": {-0.8, -4.5},
+	"CT/GC": {-1.5, -6.1}, "GC/CT": {2.3, 5.4}, "GT/CC": {5.2, 13.5},
+	"TC/AT": {1.2, 0.7}, "TT/AC": {1.0, 0.7},
+	"AA/TC": {2.3, 4.6}, "AC/TA": {5.3, 14.6}, "CA/GC": {1.9, 3.7},
+	"CC/GA": {0.6, -0.6}, "GA/CC": {5.2, 14.2}, "GC/CA": {-0.7, -3.8},
+	"TA/AC": {3.4, 8.0}, "TC/AA": {7.6, 20.2},
+	"AA/TA": {1.2, 1.7}, "CA/GA": {-0.9, -4.2}, "GA/CA": {-2.9, -9.8},
+	"TA/AA": {4.7, 12.9}, "AC/TC": {0.0, -4.4}, "CC/GC": {-1.5, -7.2},
+	"GC/CC": {3.6, 8.9}, "TC/AC": {6.1, 16.4}, "AG/TG": {-3.1, -9.5},
+	"CG/GG": {-4.9, -15.3}, "GG/CG": {-6.0, -15.8}, "TG/AG": {1.6, 3.6},
+	"AT/TT": {-2.7, -10.8}, "CT/GT": {-5.0, -15.8}, "GT/CT": {-2.2, -8.4},
+	"TT/AT": {0.2, -1.5},
+}
+
+// danglingEndThermodynamics are the parameters of single unpaired bases next
+// to the end of a duplex, written like internalMismatchThermodynamics with
+// a dot for the missing base.
+// [Bommarito et al. (2000) NAR 28:1929]
+var danglingEndThermodynamics = map[string]thermodynamics{
+	"AA/.T": {0.2, 2.3}, "AC/.G": {-6.3, -17.1}, "AG/.C": {-3.7, -10.0},
+	"AT/.A": {-2.9, -7.6}, "CA/.T": {0.6, 3.3}, "CC/.G": {-4.4, -12.6},
+	"CG/.C": {-4.0, -11.9}, "CT/.A": {-4.1, -13.0}, "GA/.T": {-1.1, -1.6},
+	"GC/.G": {-5.1, -14.0}, "GG/.C": {-3.9, -10.9}, "GT/.A": {-4.2, -15.0},
+	"TA/.T": {-6.9, -20.0}, "TC/.G": {-4.0, -10.9}, "TG/.C": {-4.9, -13.8},
+	"TT/.A": {-0.2, -0.5},
+	".A/AT": {-0.7, -0.8}, ".C/AG": {-2.1, -3.9}, ".G/AC": {-5.9, -16.5},
+	".T/AA": {-0.5, -1.1}, ".A/CT": {4.4, 14.9}, ".C/CG": {-0.2, -0.1},
+	".G/CC": {-2.6, -7.4}, ".T/CA": {4.7, 14.2}, ".A/GT": {-1.6, -3.6},
+	".C/GG": {-3.9, -11.2}, ".G/GC": {-3.2, -10.4}, ".T/GA": {-4.1, -13.1},
+	".A/TT": {2.9, 10.4}, ".C/TG": {-4.4, -13.1}, ".G/TC": {-5.2, -15.0},
+	".T/TA": {-3.8, -12.6},
+}
+
+// Conditions are the concentrations of a reaction primers anneal in.
+type Conditions struct {
+	// PrimerConcentration, Monovalent, Magnesium and DNTP are molar
+	// concentrations, Monovalent being the total of Na+, K+ and Tris+.
+	PrimerConcentration float64
+	Monovalent          float64
+	Magnesium           float64
+	DNTP                float64
+	// DMSO and Formamide are percentages by volume.
+	DMSO      float64
+	Formamide float64
+}
+
+// DefaultConditions returns the conditions of MeltingTemp: 500 nM primer in
+// 50 mM sodium.
+func DefaultConditions() Conditions {
+	return Conditions{PrimerConcentration: 500e-9, Monovalent: 50e-3}
+}
+
+// Duplex are the thermodynamics of a primer bound to a template.
+type Duplex struct {
+	// MeltingTemp is in Celsius, corrected for salts according to Owczarzy
+	// et al. (2008) and for DMSO and formamide.
+	MeltingTemp float64
+	// DeltaH (kcal/mol) and DeltaS (cal/mol-K) are corrected for salts
+	// according to SantaLucia (1998), with the sodium equivalent of von Ahsen
+	// et al. (2001).
+	DeltaH, DeltaS float64
+}
+
+// DeltaG returns the free energy of a duplex, in kcal/mol, at a temperature
+// in Celsius.
+func (duplex Duplex) DeltaG(temperature float64) float64 {
+	return duplex.DeltaH - (temperature+273.15)*duplex.DeltaS/1000
+}
+
+// DuplexThermodynamics returns the thermodynamics of a primer bound to a
+// template, both written 5'-3' as the primer would read: the target is the
+// reverse complement of the template strand the primer binds, base for base,
+// so they are equal where the primer matches.
+//
+// Single internal mismatches are supported. A dot for a missing base in
+// either sequence next to the end of the duplex makes a dangling end, and
+// mismatches at the ends of the duplex are counted as dangling ends of the
+// primer.
+func DuplexThermodynamics(primer, target string, conditions Conditions) (Duplex, error) {
+	primer, target = strings.ToUpper(primer), strings.ToUpper(target)
+	if len(primer) != len(target) {
+		return Duplex{}, fmt.Errorf("primer and target must be aligned base for base, got %d and %d bases", len(primer), len(target))
+	}
+	if strings.Trim(primer, "ACGT.") != "" || strings.Trim(target, "ACGT.") != "" {
+		return Duplex{}, errors.New("primer and target may only contain A, C, G, T and dots")
+	}
+
+	// the bottom strand, 3'-5', pairs with the primer where the target
+	// matches it
+	top := []byte(primer)
+	bottom := []byte(transform.Complement(target))
+	for index := range bottom {
+		if target[index] == '.' {
+			bottom[index] = '.'
+		}
+	}
+	paired := func(index int) bool {
+		return top[index] != '.' && top[index] == target[index]
+	}
+
+	// the duplex spans the first to the last paired bases, and mismatched
+	// bases past them dangle
+	start, end := 0, len(top)
+	for start < end && !paired(start) {
+		start++
+	}
+	for end > start && !paired(end-1) {
+		end--
+	}
+	if end-start < 2 {
+		return Duplex{}, errors.New("primer and target share fewer than 2 paired bases")
+	}
+	if start > 0 && top[start-1] != '.' {
+		bottom[start-1] = '.'
+	}
+	if end < len(top) && top[end] != '.' {
+		bottom[end] = '.'
+	}
+
+	var dH, dS float64
+	add := func(parameters thermodynamics) {
+		dH += parameters.H
+		dS += parameters.S
+	}
+	add(initialThermodynamicPenalty)
+	for _, index := range []int{start, end - 1} {
+		if top[index] == 'A' || top[index] == 'T' {
+			add(terminalATThermodynamicPenalty)
+		}
+	}
+	core := primer[start:end]
+	symmetryFactor := 4.0
+	if core == transform.ReverseComplement(core) && core == target[start:end] {
+		add(symmetryThermodynamicPenalty)
+		symmetryFactor = 1
+	}
+	if start > 0 {
+		if parameters, ok := danglingEndThermodynamics[string(top[start-1:start+1])+"/"+string(bottom[start-1:start+1])]; ok {
+			add(parameters)
+		}
+	}
+	if end < len(top) {
+		// read from the other strand, the right end is a left end
+		if parameters, ok := danglingEndThermodynamics[transform.Reverse(string(top[end-1:end+1])+"/"+string(bottom[end-1:end+1]))]; ok {
+			add(parameters)
+		}
+	}
+	for index := start; index+1 < end; index++ {
+		if paired(index) && paired(index+1) {
+			add(nearestNeighborsThermodynamics[primer[index:index+2]])
+			continue
+		}
+		if !paired(index) && !paired(index+1) {
+			return Duplex{}, fmt.Errorf("adjacent mismatches at %d and %d aren't supported", index, index+1)
+		}
+		key := string(top[index:index+2]) + "/" + string(bottom[index:index+2])
+		parameters, ok := internalMismatchThermodynamics[key]
+		if !ok {
+			parameters, ok = internalMismatchThermodynamics[transform.Reverse(key)]
+		}
+		if !ok {
+			return Duplex{}, fmt.Errorf("no parameters for the stack %s", key)
+		}
+		add(parameters)
+	}
+
+	// melting temperature at 1 M Na+, corrected for salts
+	const gasConstant = 1.9872 // gas constant (cal / mol - K)
+	meltingTemp := dH * 1000 / (dS + gasConstant*math.Log(conditions.PrimerConcentration/symmetryFactor))
+	gcFraction := float64(strings.Count(core, "G")+strings.Count(core, "C")) / float64(len(core))
+	inverseMeltingTemp, err := owczarzySaltCorrection(1/meltingTemp, gcFraction, len(core), conditions)
+	if err != nil {
+		return Duplex{}, err
+	}
+	meltingTemp = 1/inverseMeltingTemp - 273.15
+	meltingTemp -= 0.75*conditions.DMSO + 0.65*conditions.Formamide
+
+	// entropy corrected for salts, for free energies away from the melting
+	// temperature, the sodium equivalent being 120 √[Mg2+] in mM
+	sodiumEquivalent := conditions.Monovalent + 0.120*math.Sqrt(freeMagnesium(conditions)*1000)
+	dS += 0.368 * float64(len(core)-1) * math.Log(sodiumEquivalent)
+
+	return Duplex{MeltingTemp: meltingTemp, DeltaH: dH, DeltaS: dS}, nil
+}
+
+// MeltingTempWithConditions returns the melting temperature of a perfectly
+// matched primer in the given conditions.
+func MeltingTempWithConditions(sequence string, conditions Conditions) (float64, error) {
+	duplex, err := DuplexThermodynamics(sequence, sequence, conditions)
+	return duplex.MeltingTemp, err
+}
+
+// freeMagnesium returns the concentration of magnesium not bound to dNTPs,
+// with an association constant of 3×10^4 /M.
+// [Owczarzy et al. (2008) Biochemistry 47:5336]
+func freeMagnesium(conditions Conditions) float64 {
+	if conditions.DNTP == 0 {
+		return conditions.Magnesium
+	}
+	const association = 3e4
+	b := association*(conditions.DNTP-conditions.Magnesium) + 1
+	return (-b + math.Sqrt(b*b+4*association*conditions.Magnesium)) / (2 * association)
+}
+
+// owczarzySaltCorrection corrects the inverse of a melting temperature at
+// 1 M Na+, in Kelvin, for the monovalent cations and free magnesium of the
+// conditions.
+// [Owczarzy et al. (2004) Biochemistry 43:3537; (2008) Biochemistry 47:5336]
+func owczarzySaltCorrection(inverseMeltingTemp, gcFraction float64, length int, conditions Conditions) (float64, error) {
+	monovalent, magnesium := conditions.Monovalent, freeMagnesium(conditions)
+	if monovalent <= 0 && magnesium <= 0 {
+		return 0, errors.New("conditions must have monovalent cations or free magnesium")
+	}
+
+	// monovalent cations dominate at low magnesium ratios
+	if magnesium <= 0 || (monovalent > 0 && math.Sqrt(magnesium)/monovalent < 0.22) {
+		logMonovalent := math.Log(monovalent)
+		return inverseMeltingTemp + (4.29*gcFraction-3.95)*1e-5*logMonovalent + 9.40e-6*logMonovalent*logMonovalent, nil
+	}
+
+	a, b, c, d, e, f, g := 3.92e-5, -9.11e-6, 6.26e-5, 1.42e-5, -4.82e-4, 5.25e-4, 8.31e-5
+	// monovalent cations compete with magnesium at intermediate ratios
+	if monovalent > 0 && math.Sqrt(magnesium)/monovalent < 6 {
+		logMonovalent := math.Log(monovalent)
+		a *= 0.843 - 0.352*math.Sqrt(monovalent)*logMonovalent
+		d *= 1.279 - 4.03e-3*logMonovalent - 8.03e-3*logMonovalent*logMonovalent
+		g *= 0.486 - 0.258*logMonovalent + 5.25e-3*logMonovalent*logMonovalent*logMonovalent
+	}
+	logMagnesium := math.Log(magnesium)
+	return inverseMeltingTemp + a + b*logMagnesium + gcFraction*(c+d*logMagnesium) + (e+f*logMagnesium+g*logMagnesium*logMagnesium)/(2*float64(length-1)), nil
+}
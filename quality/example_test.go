@@ -0,0 +1,37 @@
+package quality_test
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bebop/poly/io/fastq"
+	"github.com/bebop/poly/quality"
+)
+
+func ExampleSummarize() {
+	file, _ := os.Open("../io/fastq/data/nanosavseq.fastq")
+	defer file.Close()
+
+	report, _ := quality.Summarize(fastq.NewParser(file, 2*32*1024), 0)
+	fmt.Printf("Phred%d: %d reads of %.0f bases on average, %.1f errors expected\n", report.Offset, report.Reads, report.MeanLength(), report.ExpectedErrors)
+	// Output: Phred33: 4 reads of 440 bases on average, 188.9 errors expected
+}
+
+func Example_basic() {
+	reads, _ := fastq.Read("../io/fastq/data/nanosavseq.fastq")
+
+	// trim the ends below Q7, then the rest of the read once 20 bases drop
+	// below Q7, and keep reads of at least 100 bases and Q7
+	filter := quality.DefaultFilter()
+	filter.MinLength, filter.MinMeanQuality = 100, 7
+	for _, read := range reads {
+		read = quality.TrimEnds(read, quality.Phred33, 7)
+		read = quality.TrimSlidingWindow(read, quality.Phred33, 20, 7)
+		fmt.Printf("%s %d bases Q%.1f %t\n", read.Identifier[:8], len(read.Sequence), quality.MeanQuality(read, quality.Phred33), filter.Keep(read, quality.Phred33))
+	}
+	// Output:
+	// e3cc70d5 82 bases Q10.6 false
+	// 92728f25 134 bases Q12.1 true
+	// 60907b6b 440 bases Q10.2 true
+	// 990e110e 95 bases Q10.9 false
+}
@@ -0,0 +1,339 @@
+/*
+Package quality checks, trims and filters sequencing reads by their quality
+scores.
+
+Each base of a fastq read has a PHRED quality score, the error probability of
+the base on a log scale: a base of quality Q is wrong with probability
+10^(-Q/10), so Q20 is one error in a hundred and Q30 one in a thousand. Scores
+are written as characters, offset by 33 in Sanger and Illumina 1.8+ reads and
+by 64 in older Illumina reads.
+
+Reads get worse towards their ends, and short inserts are read through into
+the adapter, so reads are usually trimmed before they are filtered by length
+and quality. Summarize reports the length, quality and GC content of all the
+reads of a run, reading them one at a time.
+*/
+package quality
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/bebop/poly/checks"
+	"github.com/bebop/poly/io/fastq"
+)
+
+// Offsets of PHRED quality scores.
+const (
+	// Phred33 is the offset of Sanger, Illumina 1.8+, nanopore and PacBio
+	// reads.
+	Phred33 = 33
+	// Phred64 is the offset of Illumina 1.3 to 1.7 reads.
+	Phred64 = 64
+)
+
+// Scores returns the quality scores of a read.
+func Scores(read fastq.Fastq, offset int) []int {
+	scores := make([]int, len(read.Quality))
+	for index := range read.Quality {
+		scores[index] = int(read.Quality[index]) - offset
+	}
+	return scores
+}
+
+// errorProbability returns the error probability of a quality score.
+func errorProbability(score int) float64 {
+	return math.Pow(10, -float64(score)/10)
+}
+
+// ExpectedErrors returns the number of errors expected in a read, the sum of
+// the error probabilities of its bases.
+func ExpectedErrors(read fastq.Fastq, offset int) float64 {
+	var expectedErrors float64
+	for index := range read.Quality {
+		expectedErrors += errorProbability(int(read.Quality[index]) - offset)
+	}
+	return expectedErrors
+}
+
+// MeanQuality returns the mean quality of a read, the quality score of its
+// mean error probability. Averaging scores instead would make a few bad bases
+// of a good read count for little.
+func MeanQuality(read fastq.Fastq, offset int) float64 {
+	if len(read.Quality) == 0 {
+		return 0
+	}
+	return -10 * math.Log10(ExpectedErrors(read, offset)/float64(len(read.Quality)))
+}
+
+// DetectOffset returns the offset of the quality scores of reads, like
+// FastQC: reads with any score below '@' are Phred33, and Phred64 otherwise.
+// It returns an error if there are no scores or any is out of range.
+func DetectOffset(reads []fastq.Fastq) (int, error) {
+	lowest, highest := byte(math.MaxUint8), byte(0)
+	for _, read := range reads {
+		for index := range read.Quality {
+			lowest, highest = min(lowest, read.Quality[index]), max(highest, read.Quality[index])
+		}
+	}
+	switch {
+	case highest == 0:
+		return 0, errors.New("no quality scores to detect the offset of")
+	case lowest < '!' || highest > '~':
+		return 0, fmt.Errorf("quality characters %q to %q are out of range", lowest, highest)
+	case lowest < '@':
+		return Phred33, nil
+	}
+	return Phred64, nil
+}
+
+/******************************************************************************
+
+Trimming begins here.
+
+Trimming cuts the bases off reads that are more likely to be errors or adapter
+than insert. TrimEnds and TrimSlidingWindow work like the LEADING, TRAILING
+and SLIDINGWINDOW steps of Trimmomatic, and TrimAdapter like cutadapt without
+insertions and deletions.
+
+Trimmomatic: https://doi.org/10.1093/bioinformatics/btu170
+Cutadapt: https://doi.org/10.14806/ej.17.1.200
+
+******************************************************************************/
+
+// trim returns the bases [start, end) of a read.
+func trim(read fastq.Fastq, start, end int) fastq.Fastq {
+	read.Sequence = read.Sequence[start:end]
+	if len(read.Quality) >= end {
+		read.Quality = read.Quality[start:end]
+	}
+	return read
+}
+
+// TrimEnds trims bases with a quality below the minimum off both ends of a
+// read.
+func TrimEnds(read fastq.Fastq, offset, minQuality int) fastq.Fastq {
+	if len(read.Quality) != len(read.Sequence) {
+		return read
+	}
+	start, end := 0, len(read.Quality)
+	for start < end && int(read.Quality[start])-offset < minQuality {
+		start++
+	}
+	for end > start && int(read.Quality[end-1])-offset < minQuality {
+		end--
+	}
+	return trim(read, start, end)
+}
+
+// TrimSlidingWindow cuts a read at the first window of bases with a mean
+// quality score below the minimum, keeping the bases of the window up to the
+// first one below it. Reads shorter than the window are trimmed if their mean
+// score is below the minimum.
+func TrimSlidingWindow(read fastq.Fastq, offset, windowSize int, minQuality float64) fastq.Fastq {
+	if len(read.Quality) != len(read.Sequence) || windowSize <= 0 {
+		return read
+	}
+	windowSize = min(windowSize, len(read.Quality))
+	scores := Scores(read, offset)
+	sum := 0
+	for index := 0; index < windowSize; index++ {
+		sum += scores[index]
+	}
+	for start := 0; start+windowSize <= len(scores); start++ {
+		if start > 0 {
+			sum += scores[start+windowSize-1] - scores[start-1]
+		}
+		if float64(sum)/float64(windowSize) < minQuality {
+			end := start
+			for end < start+windowSize && float64(scores[end]) >= minQuality {
+				end++
+			}
+			return trim(read, 0, end)
+		}
+	}
+	return read
+}
+
+// TrimAdapter cuts a read at the first position an adapter starts, or the
+// adapter sequence read into at the 3' end, with at most maxErrorRate
+// mismatches per base of the adapter aligned. Adapters are only looked for
+// where at least minOverlap of their bases are read, so a few bases at the end
+// of reads matching the adapter by chance aren't trimmed.
+func TrimAdapter(read fastq.Fastq, adapter string, minOverlap int, maxErrorRate float64) fastq.Fastq {
+	if adapter == "" {
+		return read
+	}
+	minOverlap = max(1, min(minOverlap, len(adapter)))
+	for start := 0; start+minOverlap <= len(read.Sequence); start++ {
+		overlap := min(len(adapter), len(read.Sequence)-start)
+		maxMismatches := int(maxErrorRate * float64(overlap))
+		mismatches := 0
+		for index := 0; index < overlap && mismatches <= maxMismatches; index++ {
+			if upper(read.Sequence[start+index]) != upper(adapter[index]) {
+				mismatches++
+			}
+		}
+		if mismatches <= maxMismatches {
+			return trim(read, 0, start)
+		}
+	}
+	return read
+}
+
+// upper returns the upper case of an ASCII letter.
+func upper(base byte) byte {
+	if base >= 'a' && base <= 'z' {
+		return base - 'a' + 'A'
+	}
+	return base
+}
+
+/******************************************************************************
+
+Filtering begins here.
+
+******************************************************************************/
+
+// Filter is the length and quality reads need to be kept.
+type Filter struct {
+	MinLength, MaxLength int
+	// MinMeanQuality is the lowest mean quality of reads, as returned by
+	// MeanQuality.
+	MinMeanQuality float64
+	// MaxExpectedErrors is the most errors expected in reads, as returned by
+	// ExpectedErrors, which unlike the mean quality gets stricter with the
+	// length of reads.
+	MaxExpectedErrors float64
+}
+
+// DefaultFilter returns a filter keeping all reads.
+func DefaultFilter() Filter {
+	return Filter{
+		MaxLength:         math.MaxInt,
+		MaxExpectedErrors: math.Inf(1),
+	}
+}
+
+// Keep returns whether a read passes a filter.
+func (filter Filter) Keep(read fastq.Fastq, offset int) bool {
+	if len(read.Sequence) < filter.MinLength || len(read.Sequence) > filter.MaxLength {
+		return false
+	}
+	if filter.MinMeanQuality > 0 && MeanQuality(read, offset) < filter.MinMeanQuality {
+		return false
+	}
+	return ExpectedErrors(read, offset) <= filter.MaxExpectedErrors
+}
+
+/******************************************************************************
+
+Summary reports begin here.
+
+******************************************************************************/
+
+// detectionReads is the number of reads the offset of a run is detected from.
+const detectionReads = 1000
+
+// Report is a summary of the reads of a run.
+type Report struct {
+	// Offset is the offset of the quality scores of the reads.
+	Offset int
+	// Reads and Bases are the number of reads and bases, and
+	// ExpectedErrors the number of errors expected in all of them.
+	Reads, Bases   int
+	ExpectedErrors float64
+	// Lengths is the number of reads of each length.
+	Lengths map[int]int
+	// MeanQualities is the number of reads of each mean quality, rounded
+	// down.
+	MeanQualities map[int]int
+	// GcContents is the number of reads of each GC content, in percents
+	// rounded to the nearest one.
+	GcContents [101]int
+	// qualitySums and positionReads are the sums of the quality scores of
+	// the bases at each position of reads, and the number of reads reaching
+	// it.
+	qualitySums   []int
+	positionReads []int
+}
+
+// NewReport returns an empty report of reads with quality scores of an
+// offset.
+func NewReport(offset int) *Report {
+	return &Report{Offset: offset, Lengths: make(map[int]int), MeanQualities: make(map[int]int)}
+}
+
+// Add adds a read to a report.
+func (report *Report) Add(read fastq.Fastq) {
+	report.Reads++
+	report.Bases += len(read.Sequence)
+	report.Lengths[len(read.Sequence)]++
+	if len(read.Sequence) > 0 {
+		report.GcContents[int(math.Round(100*checks.GcContent(read.Sequence)))]++
+	}
+	if len(read.Quality) == 0 {
+		return
+	}
+	report.ExpectedErrors += ExpectedErrors(read, report.Offset)
+	report.MeanQualities[int(MeanQuality(read, report.Offset))]++
+	for len(report.qualitySums) < len(read.Quality) {
+		report.qualitySums = append(report.qualitySums, 0)
+		report.positionReads = append(report.positionReads, 0)
+	}
+	for index := range read.Quality {
+		report.qualitySums[index] += int(read.Quality[index]) - report.Offset
+		report.positionReads[index]++
+	}
+}
+
+// PositionQualities returns the mean quality score of the bases at each
+// position of reads.
+func (report *Report) PositionQualities() []float64 {
+	qualities := make([]float64, len(report.qualitySums))
+	for index := range qualities {
+		qualities[index] = float64(report.qualitySums[index]) / float64(report.positionReads[index])
+	}
+	return qualities
+}
+
+// MeanLength returns the mean length of reads.
+func (report *Report) MeanLength() float64 {
+	if report.Reads == 0 {
+		return 0
+	}
+	return float64(report.Bases) / float64(report.Reads)
+}
+
+// Summarize reports the reads of a parser, reading them one at a time. If
+// offset is 0, the offset is detected from the first thousand reads.
+func Summarize(parser *fastq.Parser, offset int) (*Report, error) {
+	var reads []fastq.Fastq
+	if offset == 0 {
+		var err error
+		reads, err = parser.ParseN(detectionReads)
+		if err != nil {
+			return nil, err
+		}
+		if offset, err = DetectOffset(reads); err != nil {
+			return nil, err
+		}
+	}
+
+	report := NewReport(offset)
+	for _, read := range reads {
+		report.Add(read)
+	}
+	for {
+		read, _, err := parser.ParseNext()
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		report.Add(read)
+	}
+}
@@ -0,0 +1,183 @@
+package quality
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/bebop/poly/io/fastq"
+)
+
+// newRead returns a read of a sequence with Phred33 quality scores.
+func newRead(sequence string, scores ...int) fastq.Fastq {
+	quality := make([]byte, len(scores))
+	for index, score := range scores {
+		quality[index] = byte(score + Phred33)
+	}
+	return fastq.Fastq{Identifier: "read", Sequence: sequence, Quality: string(quality)}
+}
+
+func TestExpectedErrors(t *testing.T) {
+	read := newRead("ACGT", 10, 20, 30, 40)
+	if expectedErrors := ExpectedErrors(read, Phred33); math.Abs(expectedErrors-0.1111) > 1e-9 {
+		t.Errorf("expected 0.1111 errors, got %f", expectedErrors)
+	}
+	// the mean error probability is 0.1111/4
+	if meanQuality := MeanQuality(read, Phred33); math.Abs(meanQuality-15.5635) > 1e-4 {
+		t.Errorf("expected a mean quality of 15.56, got %f", meanQuality)
+	}
+	if meanQuality := MeanQuality(newRead("AAA", 20, 20, 20), Phred33); math.Abs(meanQuality-20) > 1e-9 {
+		t.Errorf("expected a mean quality of 20, got %f", meanQuality)
+	}
+	if meanQuality := MeanQuality(fastq.Fastq{}, Phred33); meanQuality != 0 {
+		t.Errorf("expected a mean quality of 0 without scores, got %f", meanQuality)
+	}
+	if scores := Scores(fastq.Fastq{Quality: "@Jh"}, Phred64); scores[0] != 0 || scores[1] != 10 || scores[2] != 40 {
+		t.Errorf("expected Phred64 scores 0, 10 and 40, got %v", scores)
+	}
+}
+
+func TestDetectOffset(t *testing.T) {
+	reads, err := fastq.Read("../io/fastq/data/nanosavseq.fastq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if offset, err := DetectOffset(reads); err != nil || offset != Phred33 {
+		t.Errorf("expected nanopore reads to be Phred33, got %d: %v", offset, err)
+	}
+	if offset, err := DetectOffset([]fastq.Fastq{{Quality: "@ABhgfJ"}}); err != nil || offset != Phred64 {
+		t.Errorf("expected Phred64, got %d: %v", offset, err)
+	}
+	for _, quality := range []string{"", "AB\x7f", "AB "} {
+		if _, err := DetectOffset([]fastq.Fastq{{Quality: quality}}); err == nil {
+			t.Errorf("expected an error for quality %q", quality)
+		}
+	}
+}
+
+func TestTrimEnds(t *testing.T) {
+	read := TrimEnds(newRead("ACGTACGT", 2, 30, 30, 2, 30, 30, 10, 2), Phred33, 15)
+	if read.Sequence != "CGTAC" || read.Quality != newRead("", 30, 30, 2, 30, 30).Quality {
+		t.Errorf("expected CGTAC, got %s", read.Sequence)
+	}
+	if read := TrimEnds(newRead("ACG", 2, 2, 2), Phred33, 15); read.Sequence != "" || read.Quality != "" {
+		t.Errorf("expected a read of low quality bases to be trimmed off, got %s", read.Sequence)
+	}
+}
+
+func TestTrimSlidingWindow(t *testing.T) {
+	read := newRead("ACGTACGTAC", 30, 30, 30, 30, 30, 25, 10, 10, 30, 30)
+	trimmed := TrimSlidingWindow(read, Phred33, 4, 20)
+	// the window at 4 has a mean of 18.75, and is cut at its first base below 20
+	if trimmed.Sequence != "ACGTAC" || len(trimmed.Quality) != 6 {
+		t.Errorf("expected ACGTAC, got %s", trimmed.Sequence)
+	}
+	if trimmed := TrimSlidingWindow(read, Phred33, 4, 5); trimmed.Sequence != read.Sequence {
+		t.Errorf("expected a read above the minimum not to be trimmed, got %s", trimmed.Sequence)
+	}
+	if trimmed := TrimSlidingWindow(newRead("AC", 5, 5), Phred33, 4, 20); trimmed.Sequence != "" {
+		t.Errorf("expected a short read of low quality to be trimmed off, got %s", trimmed.Sequence)
+	}
+}
+
+func TestTrimAdapter(t *testing.T) {
+	adapter := "AGATCGGAAGAGC" // Illumina TruSeq
+	insert := "GATTACAGATTACACCCTTT"
+	scores := make([]int, 60)
+	tests := map[string]struct {
+		sequence, trimmed string
+	}{
+		"full adapter":    {insert + adapter + "ACACGTCT", insert},
+		"partial adapter": {insert + adapter[:6], insert},
+		"mismatch":        {insert + "AGATCGGTAGAGC", insert},
+		"too short":       {insert + adapter[:2], insert + adapter[:2]},
+		"no adapter":      {insert, insert},
+		"lowercase":       {insert + strings.ToLower(adapter), insert},
+		"adapter dimer":   {adapter + adapter, ""},
+	}
+	for name, test := range tests {
+		read := TrimAdapter(newRead(test.sequence, scores[:len(test.sequence)]...), adapter, 3, 0.1)
+		if read.Sequence != test.trimmed || len(read.Quality) != len(test.trimmed) {
+			t.Errorf("%s: expected %s, got %s", name, test.trimmed, read.Sequence)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	filter := DefaultFilter()
+	good, bad := newRead("ACGTACGTAC", 30, 30, 30, 30, 30, 30, 30, 30, 30, 30), newRead("ACGTACGTAC", 10, 10, 10, 10, 10, 10, 10, 10, 10, 10)
+	if !filter.Keep(good, Phred33) || !filter.Keep(bad, Phred33) {
+		t.Errorf("expected the default filter to keep all reads")
+	}
+	filter.MinMeanQuality = 20
+	if !filter.Keep(good, Phred33) || filter.Keep(bad, Phred33) {
+		t.Errorf("expected reads below Q20 to be filtered out")
+	}
+	filter = DefaultFilter()
+	filter.MaxExpectedErrors = 0.5
+	if !filter.Keep(good, Phred33) || filter.Keep(bad, Phred33) {
+		t.Errorf("expected reads with more than 0.5 expected errors to be filtered out")
+	}
+	filter = DefaultFilter()
+	filter.MinLength, filter.MaxLength = 11, 20
+	if filter.Keep(good, Phred33) {
+		t.Errorf("expected reads shorter than 11 bases to be filtered out")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	reads, err := fastq.Read("../io/fastq/data/nanosavseq.fastq")
+	if err != nil {
+		t.Fatal(err)
+	}
+	runBytes, _ := fastq.Build(reads)
+	report, err := Summarize(fastq.NewParser(strings.NewReader(string(runBytes)), 2*32*1024), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Offset != Phred33 || report.Reads != len(reads) {
+		t.Fatalf("expected %d Phred33 reads, got %d with offset %d", len(reads), report.Reads, report.Offset)
+	}
+
+	// the report adds up to the reads it was made of
+	bases, expectedErrors, gcReads, qualityReads, lengthReads := 0, 0.0, 0, 0, 0
+	for _, read := range reads {
+		bases += len(read.Sequence)
+		expectedErrors += ExpectedErrors(read, Phred33)
+	}
+	for _, count := range report.GcContents {
+		gcReads += count
+	}
+	for _, count := range report.MeanQualities {
+		qualityReads += count
+	}
+	for length, count := range report.Lengths {
+		lengthReads += count
+		if length <= 0 {
+			t.Errorf("unexpected length %d", length)
+		}
+	}
+	if report.Bases != bases || math.Abs(report.ExpectedErrors-expectedErrors) > 1e-9 || gcReads != len(reads) || qualityReads != len(reads) || lengthReads != len(reads) {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if report.MeanLength() != float64(bases)/float64(len(reads)) {
+		t.Errorf("expected a mean length of %f, got %f", float64(bases)/float64(len(reads)), report.MeanLength())
+	}
+	positionQualities := report.PositionQualities()
+	longest := 0
+	for _, read := range reads {
+		longest = max(longest, len(read.Quality))
+	}
+	if len(positionQualities) != longest || positionQualities[0] != float64(int(reads[0].Quality[0])+int(reads[1].Quality[0])+int(reads[2].Quality[0])+int(reads[3].Quality[0])-4*Phred33)/4 {
+		t.Errorf("unexpected position qualities %v", positionQualities[:2])
+	}
+
+	// an offset given isn't detected
+	report, _ = Summarize(fastq.NewParser(strings.NewReader(string(runBytes)), 2*32*1024), Phred64)
+	if report.Offset != Phred64 || report.Reads != len(reads) {
+		t.Errorf("expected the offset given to be used, got %d", report.Offset)
+	}
+	if _, err := Summarize(fastq.NewParser(strings.NewReader(""), 2*32*1024), 0); err == nil {
+		t.Errorf("expected an error detecting the offset of no reads")
+	}
+}
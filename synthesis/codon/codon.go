@@ -119,6 +119,9 @@ type TranslationTable struct {
 // Copy returns a deep copy of the translation table. This is to prevent an unintended update of data used in another
 // process, since the tables are generated at build time.
 func (table *TranslationTable) Copy() *TranslationTable {
+	if table == nil {
+		return nil
+	}
 	return &TranslationTable{
 		StartCodons: table.StartCodons,
 		StopCodons:  table.StopCodons,
@@ -446,7 +449,8 @@ func generateCodonTable(aminoAcids, starts string) *TranslationTable {
 	}
 }
 
-// NewTranslationTable takes the index of desired NCBI codon table and returns it.
+// NewTranslationTable takes the index of desired NCBI codon table and returns it, or nil if NCBI has no table of that
+// index.
 func NewTranslationTable(index int) *TranslationTable {
 	return translationTablesByNumber[index].Copy()
 }
@@ -21,6 +21,19 @@ func TestTranslation(t *testing.T) {
 	}
 }
 
+func TestNewTranslationTableUnknownIndex(t *testing.T) {
+	// NCBI has no tables 7, 8 or 99
+	for _, index := range []int{0, 7, 8, 99} {
+		if table := NewTranslationTable(index); table != nil {
+			t.Errorf("expected no translation table %d, got %+v", index, table)
+		}
+	}
+	var table *TranslationTable
+	if table.Copy() != nil {
+		t.Error("Copy of a nil translation table should be nil")
+	}
+}
+
 func TestTranslationErrorsOnEmptyAminoAcidString(t *testing.T) {
 	nonEmptyCodonTable := NewTranslationTable(1)
 	_, err := nonEmptyCodonTable.Translate("")
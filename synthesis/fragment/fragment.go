@@ -37,6 +37,19 @@ func SetEfficiency(overhangs []string) float64 {
 	return efficiency
 }
 
+// LigationCount gets how many ligations of an overhang to another overhang
+// were observed in the fidelity data. Overhangs are written as the top strand
+// of the ends they are on once joined, so an overhang ligating to itself is a
+// correct ligation. Ligations missing from the data count as 0.
+func LigationCount(overhang string, otherOverhang string) int {
+	overhang, otherOverhang = strings.ToUpper(overhang), strings.ToUpper(otherOverhang)
+	if count, ok := mismatches[key{overhang, otherOverhang}]; ok {
+		return count
+	}
+	// the data only lists one strand of each ligation
+	return mismatches[key{transform.ReverseComplement(otherOverhang), transform.ReverseComplement(overhang)}]
+}
+
 // NextOverhangs gets a list of possible next overhangs to use for an overhang
 // list, along with their efficiencies. This can be used for more optimal
 // fragmentation of sequences with potential degeneracy.
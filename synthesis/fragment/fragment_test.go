@@ -95,3 +95,19 @@ func TestFragmentWithOverhangs(t *testing.T) {
 		t.Errorf(err.Error())
 	}
 }
+
+func TestLigationCount(t *testing.T) {
+	if count := LigationCount("AATG", "AATG"); count != 625 {
+		t.Errorf("Expected 625 correct ligations of AATG. Got: %d", count)
+	}
+	// CATT is only in the data as its reverse complement, AATG
+	if count := LigationCount("CATT", "catt"); count != 625 {
+		t.Errorf("Expected 625 correct ligations of CATT. Got: %d", count)
+	}
+	if count := LigationCount("AATG", "AATC"); count != 3 {
+		t.Errorf("Expected 3 ligations of AATG to AATC. Got: %d", count)
+	}
+	if count := LigationCount("AAT", "AAT"); count != 0 {
+		t.Errorf("Expected no ligations of a 3 base overhang. Got: %d", count)
+	}
+}